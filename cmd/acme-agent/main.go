@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/imkerbos/ACME-Console/internal/agent"
+)
+
+func main() {
+	configPath := flag.String("config", "/etc/acme-agent/config.yaml", "path to agent config file")
+	once := flag.Bool("once", false, "poll once and exit")
+	flag.Parse()
+
+	cfg, err := agent.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	a, err := agent.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize agent: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if *once {
+		result, err := a.Sync(ctx)
+		report(result, err)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
+	log.Printf("Polling %s every %s", cfg.Server, cfg.Interval)
+	a.Run(ctx, report)
+	log.Printf("Agent stopped")
+}
+
+func report(result *agent.SyncResult, err error) {
+	if result != nil && result.ReloadOutput != "" {
+		log.Printf("Reload output: %s", result.ReloadOutput)
+	}
+	switch {
+	case err != nil:
+		log.Printf("Sync failed: %v", err)
+	case result.Changed && result.Reloaded:
+		log.Printf("Certificate updated (fingerprint %s), reload command succeeded", result.Fingerprint)
+	case result.Changed:
+		log.Printf("Certificate updated (fingerprint %s)", result.Fingerprint)
+	case result.Reloaded:
+		log.Printf("Certificate unchanged (fingerprint %s), pending reload succeeded", result.Fingerprint)
+	default:
+		log.Printf("Certificate unchanged (fingerprint %s)", result.Fingerprint)
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/auditsink"
 	"github.com/imkerbos/ACME-Console/internal/auth"
 	"github.com/imkerbos/ACME-Console/internal/config"
 	"github.com/imkerbos/ACME-Console/internal/crypto"
 	"github.com/imkerbos/ACME-Console/internal/handler"
+	"github.com/imkerbos/ACME-Console/internal/keystorage"
 	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/mailer"
 	"github.com/imkerbos/ACME-Console/internal/model"
 	"github.com/imkerbos/ACME-Console/internal/router"
 	"github.com/imkerbos/ACME-Console/internal/scheduler"
@@ -63,8 +66,9 @@ func main() {
 	// Initialize JWT manager
 	jwtManager := auth.NewJWTManager(
 		cfg.JWT.Secret,
-		time.Duration(cfg.JWT.ExpireHours)*time.Hour,
+		cfg.JWT.AccessTokenTTL(),
 	)
+	sessionSvc := service.NewSessionService(db, cfg.JWT.SessionTTL())
 
 	// Initialize setting service (reads from database)
 	settingSvc := service.NewSettingService(db)
@@ -77,16 +81,20 @@ func main() {
 
 	// Initialize certificate service
 	var certSvc *service.CertificateService
+	var encryptor *crypto.Encryptor
 
 	// Check if encryption key is configured (required for real ACME)
-	if cfg.Encryption.MasterKey != "" {
-		encryptor, err := crypto.NewEncryptor(cfg.Encryption.MasterKey)
+	if cfg.Encryption.Enabled() {
+		encryptor, err = crypto.NewKeyring(cfg.Encryption.Keyring())
 		if err != nil {
 			logger.Fatal("Failed to initialize encryptor", logger.Err(err))
 		}
+		logger.Info("Encryption keyring loaded", logger.String("active_key", encryptor.ActiveKeyID()))
 
 		// Use database settings for ACME config
-		legoSvc := service.NewLegoServiceWithSettings(db, settingSvc, encryptor)
+		keys := newKeyStorage(&cfg.KeyStorage, encryptor)
+		logger.Info("Key storage initialized", logger.String("backend", keys.Active().Name()))
+		legoSvc := service.NewLegoServiceWithSettings(db, settingSvc, encryptor, keys)
 		certSvc = service.NewCertificateServiceWithLego(db, legoSvc)
 		logger.Info("ACME service initialized (production environment)")
 	} else {
@@ -96,18 +104,115 @@ func main() {
 		logger.Info("Using mock ACME service (no encryption key configured)")
 	}
 
+	// Initialize deployment service (SSH, Kubernetes and cloud push targets)
+	deploymentSvc := service.NewDeploymentService(db, certSvc, workspaceSvc, notificationSvc, encryptor)
+	cloudCredentialSvc := service.NewCloudCredentialService(db, workspaceSvc, encryptor)
+
+	// Initialize the audit trail and stream it to the configured sinks
+	auditSvc := service.NewAuditService(db, settingSvc)
+	if sinks := newAuditSinks(&cfg.Audit); len(sinks) > 0 {
+		dispatcher := auditsink.NewDispatcher(sinks, cfg.Audit.BufferSize)
+		defer dispatcher.Close(10 * time.Second)
+		auditSvc.SetForwarder(dispatcher)
+		logger.Info("Audit log streaming enabled", logger.Int("sinks", len(sinks)))
+	}
+
 	// Initialize renewal service
-	renewalSvc := service.NewRenewalService(db, certSvc, notificationSvc, settingSvc)
+	renewalSvc := service.NewRenewalService(db, certSvc, notificationSvc, settingSvc, deploymentSvc, auditSvc)
+	certSvc.SetIssuedHook(renewalSvc.OnCertificateIssued)
+	certSvc.SetWorkspaceService(workspaceSvc)
+
+	// Initialize discovery service
+	discoverySvc := service.NewDiscoveryService(db, workspaceSvc, certSvc)
+
+	// Initialize deploy service
+	deploySvc := service.NewDeployService(db, certSvc, workspaceSvc)
+
+	// Initialize two-factor authentication
+	mfaSvc, err := service.NewMFAService(db, &cfg.MFA, settingSvc, encryptor)
+	if err != nil {
+		logger.Fatal("Failed to initialize two-factor authentication", logger.Err(err))
+	}
+	if len(mfaSvc.Methods()) == 0 {
+		logger.Warn("Two-factor authentication is unavailable: configure encryption for TOTP or mfa.webauthn for passkeys")
+	}
+
+	// Initialize login protection
+	loginGuard := service.NewLoginGuardService(db, settingSvc, auditSvc)
+	passwordPolicy, err := service.NewPasswordPolicyService(settingSvc, cfg.Password.BreachedList)
+	if err != nil {
+		logger.Fatal("Failed to load password policy", logger.Err(err))
+	}
+
+	// Initialize private key export policy enforcement
+	keyExportSvc := service.NewKeyExportService(db, workspaceSvc, mfaSvc, loginGuard)
+	deploySvc.SetKeyExportService(keyExportSvc)
+	deploymentSvc.SetKeyExportService(keyExportSvc)
+	deploymentSvc.SetInClusterNamespaces(cfg.Deployment.InClusterNamespaces)
+
+	// Initialize one-time download link service
+	downloadLinkSvc := service.NewDownloadLinkService(db, certSvc, workspaceSvc, keyExportSvc, encryptor)
+
+	// Initialize encryption key rotation service
+	keyRotationSvc := service.NewKeyRotationService(db, encryptor)
+	apiTokenSvc := service.NewAPITokenService(db, workspaceSvc)
+
+	// Initialize single sign-on
+	oidcSvc := service.NewOIDCService(db, &cfg.OIDC)
+	if len(cfg.OIDC.Providers) > 0 {
+		logger.Info("OIDC single sign-on enabled", logger.Int("providers", len(cfg.OIDC.Providers)))
+	}
+	ldapSvc, err := service.NewLDAPService(db, &cfg.LDAP)
+	if err != nil {
+		logger.Fatal("Failed to initialize LDAP authentication", logger.Err(err))
+	}
+	if ldapSvc.Enabled() {
+		logger.Info("LDAP authentication enabled", logger.String("url", cfg.LDAP.URL))
+	}
+
+	// Initialize workspace invitations, emailed when a mail server is configured
+	invitationSvc := service.NewInvitationService(db, workspaceSvc, settingSvc, auditSvc)
+	if cfg.Mail.Host != "" {
+		timeout, _ := time.ParseDuration(cfg.Mail.Timeout)
+		m, err := mailer.New(mailer.Config{
+			Host:               cfg.Mail.Host,
+			Port:               cfg.Mail.Port,
+			TLS:                cfg.Mail.TLS,
+			Username:           cfg.Mail.Username,
+			Password:           cfg.Mail.Password,
+			From:               cfg.Mail.From,
+			InsecureSkipVerify: cfg.Mail.InsecureSkipVerify,
+			Timeout:            timeout,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize mail", logger.Err(err))
+		}
+		if cfg.Mail.ConsoleURL == "" {
+			logger.Fatal("mail.console_url is required to email invitation links")
+		}
+		invitationSvc.SetMailer(m, cfg.Mail.ConsoleURL)
+		logger.Info("Invitation email enabled", logger.String("host", cfg.Mail.Host))
+	}
+	oidcSvc.SetInvitations(invitationSvc)
 
 	// Initialize handlers
 	handlers := &router.Handlers{
-		Auth:         handler.NewAuthHandler(db, jwtManager),
-		Certificate:  handler.NewCertificateHandler(certSvc, renewalSvc),
-		Challenge:    handler.NewChallengeHandler(certSvc),
-		User:         handler.NewUserHandler(db),
-		Setting:      handler.NewSettingHandler(settingSvc),
-		Workspace:    handler.NewWorkspaceHandler(workspaceSvc),
-		Notification: handler.NewNotificationHandler(notificationSvc),
+		Auth:            handler.NewAuthHandler(db, jwtManager, oidcSvc, ldapSvc, mfaSvc, sessionSvc, loginGuard, passwordPolicy, invitationSvc),
+		Certificate:     handler.NewCertificateHandler(certSvc, renewalSvc, keyExportSvc),
+		Challenge:       handler.NewChallengeHandler(certSvc),
+		User:            handler.NewUserHandler(db, mfaSvc, sessionSvc, loginGuard, passwordPolicy, workspaceSvc),
+		Setting:         handler.NewSettingHandler(settingSvc),
+		Workspace:       handler.NewWorkspaceHandler(workspaceSvc),
+		Notification:    handler.NewNotificationHandler(notificationSvc, workspaceSvc),
+		Discovery:       handler.NewDiscoveryHandler(discoverySvc),
+		Deploy:          handler.NewDeployHandler(deploySvc),
+		Deployment:      handler.NewDeploymentHandler(deploymentSvc),
+		CloudCredential: handler.NewCloudCredentialHandler(cloudCredentialSvc),
+		DownloadLink:    handler.NewDownloadLinkHandler(downloadLinkSvc),
+		Encryption:      handler.NewEncryptionHandler(keyRotationSvc),
+		APIToken:        handler.NewAPITokenHandler(apiTokenSvc),
+		Audit:           handler.NewAuditHandler(auditSvc, workspaceSvc),
+		Invitation:      handler.NewInvitationHandler(invitationSvc),
 	}
 
 	// Setup static file serving
@@ -117,14 +222,41 @@ func main() {
 		logger.Info("Serving static files", logger.String("path", *staticDir))
 	}
 
-	// Setup router
-	r := router.Setup(handlers, jwtManager, staticFS)
+	// Setup router; per-certificate routes are authorized by the authz service
+	authzSvc := service.NewAuthzService(db, workspaceSvc)
+	r, err := router.Setup(handlers, jwtManager, apiTokenSvc, sessionSvc, authzSvc, auditSvc, cfg.Server.TrustedProxies, staticFS)
+	if err != nil {
+		logger.Fatal("Failed to set up router", logger.Err(err))
+	}
 
 	// Start notification and renewal scheduler
-	notifScheduler := scheduler.NewScheduler(notificationSvc, renewalSvc)
+	notifScheduler := scheduler.NewScheduler(notificationSvc, renewalSvc, discoverySvc, auditSvc)
 	notifScheduler.Start()
 	defer notifScheduler.Stop()
 
+	// Start Kubernetes Ingress/Gateway controller
+	if cfg.Ingress.Enabled {
+		if encryptor == nil {
+			logger.Fatal("Ingress controller requires encryption.master_key")
+		}
+		var kubeconfig []byte
+		if cfg.Ingress.Kubeconfig != "" {
+			if kubeconfig, err = os.ReadFile(cfg.Ingress.Kubeconfig); err != nil {
+				logger.Fatal("Failed to read ingress kubeconfig", logger.Err(err))
+			}
+		}
+		interval, _ := time.ParseDuration(cfg.Ingress.Interval)
+		ingressSvc := service.NewIngressService(db, certSvc, encryptor, service.IngressOptions{
+			Kubeconfig: kubeconfig,
+			Namespace:  cfg.Ingress.Namespace,
+			Email:      cfg.Ingress.Email,
+			Workspaces: cfg.Ingress.Workspaces,
+		})
+		ingressWatcher := scheduler.NewIngressWatcher(ingressSvc, interval)
+		ingressWatcher.Start()
+		defer ingressWatcher.Stop()
+	}
+
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	logger.Info("Starting server", logger.String("address", addr))
@@ -133,3 +265,95 @@ func main() {
 		logger.Fatal("Failed to start server", logger.Err(err))
 	}
 }
+
+// newAuditSinks opens the configured audit sinks
+func newAuditSinks(cfg *config.AuditConfig) []auditsink.Sink {
+	var sinks []auditsink.Sink
+	for i, sc := range cfg.Sinks {
+		var (
+			sink auditsink.Sink
+			err  error
+		)
+		switch sc.Type {
+		case "syslog":
+			timeout, _ := time.ParseDuration(sc.Timeout)
+			sink, err = auditsink.NewSyslogSink(auditsink.SyslogConfig{
+				Network:            sc.Network,
+				Address:            sc.Address,
+				Format:             sc.Format,
+				Facility:           sc.Facility,
+				AppName:            sc.AppName,
+				CAFile:             sc.CAFile,
+				InsecureSkipVerify: sc.InsecureSkipVerify,
+				Timeout:            timeout,
+			})
+		case "file":
+			sink, err = auditsink.NewFileSink(auditsink.FileConfig{
+				Path:       sc.Path,
+				MaxSizeMB:  sc.MaxSizeMB,
+				MaxBackups: sc.MaxBackups,
+			})
+		default:
+			err = fmt.Errorf("unknown type %q", sc.Type)
+		}
+		if err != nil {
+			logger.Fatal("Failed to initialize audit sink", logger.Int("index", i), logger.Err(err))
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// newKeyStorage builds the certificate key storage backends. The database
+// backend is always available so keys generated before a switch stay readable.
+func newKeyStorage(cfg *config.KeyStorageConfig, encryptor *crypto.Encryptor) *keystorage.Set {
+	database, err := keystorage.NewDatabaseBackend(encryptor)
+	if err != nil {
+		logger.Fatal("Failed to initialize database key storage", logger.Err(err))
+	}
+
+	var others []keystorage.Backend
+	if cfg.Vault.Address != "" {
+		timeout, _ := time.ParseDuration(cfg.Vault.Timeout)
+		vault, err := keystorage.NewVaultBackend(keystorage.VaultConfig{
+			Address:    cfg.Vault.Address,
+			Token:      cfg.Vault.Token,
+			Namespace:  cfg.Vault.Namespace,
+			Mode:       cfg.Vault.Mode,
+			Mount:      cfg.Vault.Mount,
+			KeyName:    cfg.Vault.KeyName,
+			PathPrefix: cfg.Vault.PathPrefix,
+			Timeout:    timeout,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize Vault key storage", logger.Err(err))
+		}
+		others = append(others, vault)
+	}
+	if cfg.PKCS11.Module != "" {
+		hsm, err := keystorage.NewPKCS11Backend(keystorage.PKCS11Config{
+			Module:     cfg.PKCS11.Module,
+			TokenLabel: cfg.PKCS11.TokenLabel,
+			PIN:        cfg.PKCS11.PIN,
+			KeyLabel:   cfg.PKCS11.KeyLabel,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize PKCS#11 key storage", logger.Err(err))
+		}
+		others = append(others, hsm)
+	}
+
+	active := keystorage.Backend(database)
+	if cfg.Backend != "" && cfg.Backend != keystorage.BackendDatabase {
+		active = nil
+		for _, b := range others {
+			if b.Name() == cfg.Backend {
+				active = b
+			}
+		}
+		if active == nil {
+			logger.Fatal("Key storage backend is not configured", logger.String("backend", cfg.Backend))
+		}
+	}
+	return keystorage.NewSet(active, append(others, database)...)
+}
@@ -0,0 +1,305 @@
+// Package agent implements the pull-based deployment agent. It polls the
+// console's deploy bundle endpoint with a deploy token, writes the certificate
+// files atomically and runs a reload command when the certificate changed.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized is returned when the console rejects the deploy token
+var ErrUnauthorized = errors.New("deploy token rejected by server")
+
+// Bundle mirrors the console's deploy bundle payload
+type Bundle struct {
+	CertificateID uint       `json:"certificate_id"`
+	Domains       []string   `json:"domains"`
+	Fingerprint   string     `json:"fingerprint"`
+	SerialNumber  string     `json:"serial_number"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	CertPEM       string     `json:"cert_pem"`
+	ChainPEM      string     `json:"chain_pem"`
+	FullChainPEM  string     `json:"fullchain_pem"`
+	KeyPEM        string     `json:"key_pem"`
+}
+
+// SyncResult describes the outcome of one poll
+type SyncResult struct {
+	Changed      bool   // Files were rewritten because the fingerprint changed
+	Reloaded     bool   // The reload command ran successfully
+	Fingerprint  string // Fingerprint of the deployed certificate
+	ReloadOutput string
+}
+
+// Agent polls a single deploy token
+type Agent struct {
+	cfg      *Config
+	client   *http.Client
+	certMode os.FileMode
+	keyMode  os.FileMode
+	uid      int
+	gid      int
+}
+
+// New creates an agent from a validated configuration
+func New(cfg *Config) (*Agent, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	certMode, _ := parseMode(cfg.CertMode, 0o644)
+	keyMode, _ := parseMode(cfg.KeyMode, 0o600)
+
+	uid, gid, err := resolveOwner(cfg.Owner, cfg.Group)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &Agent{
+		cfg:      cfg,
+		client:   &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		certMode: certMode,
+		keyMode:  keyMode,
+		uid:      uid,
+		gid:      gid,
+	}, nil
+}
+
+// Run polls until the context is cancelled. Each result or error is passed to report.
+func (a *Agent) Run(ctx context.Context, report func(*SyncResult, error)) {
+	interval := a.cfg.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	report(a.Sync(ctx))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report(a.Sync(ctx))
+		}
+	}
+}
+
+// Sync performs one poll. The fingerprint of the certificate currently on disk
+// is sent as If-None-Match, so an unchanged certificate costs a single 304 and
+// survives agent restarts without rewriting files or reloading. A reload that
+// failed is recorded on disk and retried on every poll until it succeeds.
+func (a *Agent) Sync(ctx context.Context) (*SyncResult, error) {
+	current := a.currentFingerprint()
+
+	bundle, err := a.fetch(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil || (bundle.Fingerprint != "" && bundle.Fingerprint == current) {
+		result := &SyncResult{Fingerprint: current}
+		if a.reloadPending() {
+			return a.runReload(ctx, result)
+		}
+		return result, nil
+	}
+
+	// Marked before the files change, so a crash in between still reloads later
+	if a.cfg.ReloadCommand != "" {
+		if err := a.markReloadPending(bundle.Fingerprint); err != nil {
+			return nil, fmt.Errorf("failed to record pending reload: %w", err)
+		}
+	}
+	if err := a.writeFiles(bundle); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Changed: true, Fingerprint: bundle.Fingerprint}
+	if a.cfg.ReloadCommand == "" {
+		return result, nil
+	}
+	return a.runReload(ctx, result)
+}
+
+func (a *Agent) runReload(ctx context.Context, result *SyncResult) (*SyncResult, error) {
+	out, err := a.reload(ctx)
+	result.ReloadOutput = out
+	if err != nil {
+		return result, fmt.Errorf("reload command failed: %w", err)
+	}
+	result.Reloaded = true
+	if err := os.Remove(a.pendingPath()); err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to clear pending reload: %w", err)
+	}
+	return result, nil
+}
+
+// pendingPath is the marker that exists while written files still await a reload
+func (a *Agent) pendingPath() string {
+	if a.cfg.Files.Cert != "" {
+		return a.cfg.Files.Cert + ".reload-pending"
+	}
+	return a.cfg.Files.FullChain + ".reload-pending"
+}
+
+func (a *Agent) markReloadPending(fingerprint string) error {
+	path := a.pendingPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(fingerprint), 0o600)
+}
+
+func (a *Agent) reloadPending() bool {
+	if a.cfg.ReloadCommand == "" {
+		return false
+	}
+	_, err := os.Stat(a.pendingPath())
+	return err == nil
+}
+
+func (a *Agent) currentFingerprint() string {
+	if fp := fingerprintFile(a.cfg.Files.Cert); fp != "" {
+		return fp
+	}
+	return fingerprintFile(a.cfg.Files.FullChain)
+}
+
+func (a *Agent) bundleURL() string {
+	return strings.TrimRight(a.cfg.Server, "/") + "/api/v1/deploy/" + url.PathEscape(a.cfg.Token) + "/bundle"
+}
+
+// fetch downloads the bundle; a nil bundle means the server answered 304
+func (a *Agent) fetch(ctx context.Context, fingerprint string) (*Bundle, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.bundleURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if fingerprint != "" {
+		req.Header.Set("If-None-Match", `"`+fingerprint+`"`)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch bundle: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	var envelope struct {
+		Code    int     `json:"code"`
+		Message string  `json:"message"`
+		Data    *Bundle `json:"data"`
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		if json.Unmarshal(body, &envelope) == nil && envelope.Message != "" {
+			return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, envelope.Message)
+		}
+		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if envelope.Data == nil || envelope.Data.CertPEM == "" {
+		return nil, fmt.Errorf("server returned an empty bundle")
+	}
+	return envelope.Data, nil
+}
+
+// writeFiles stages every configured file before renaming any of them, so a
+// failure never leaves a certificate next to a key it does not belong to.
+func (a *Agent) writeFiles(b *Bundle) error {
+	type target struct {
+		path string
+		data string
+		mode os.FileMode
+	}
+	targets := []target{
+		{a.cfg.Files.Key, b.KeyPEM, a.keyMode},
+		{a.cfg.Files.Cert, b.CertPEM, a.certMode},
+		{a.cfg.Files.Chain, b.ChainPEM, a.certMode},
+		{a.cfg.Files.FullChain, b.FullChainPEM, a.certMode},
+	}
+
+	var staged []*stagedFile
+	discardAll := func() {
+		for _, s := range staged {
+			s.discard()
+		}
+	}
+
+	for _, t := range targets {
+		if t.path == "" {
+			continue
+		}
+		if t.data == "" {
+			discardAll()
+			return fmt.Errorf("bundle has no content for %s", t.path)
+		}
+		s, err := stageFile(t.path, []byte(t.data), t.mode, a.uid, a.gid)
+		if err != nil {
+			discardAll()
+			return err
+		}
+		staged = append(staged, s)
+	}
+
+	for i, s := range staged {
+		if err := s.commit(); err != nil {
+			for _, rest := range staged[i+1:] {
+				rest.discard()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Agent) reload(ctx context.Context) (string, error) {
+	timeout := a.cfg.ReloadTimeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", a.cfg.ReloadCommand)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return strings.TrimSpace(out.String()), err
+}
@@ -0,0 +1,281 @@
+package agent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testBundle(t *testing.T, cn string) *Bundle {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	sum := sha256.Sum256(der)
+	return &Bundle{
+		Domains:      []string{cn},
+		Fingerprint:  hex.EncodeToString(sum[:]),
+		CertPEM:      certPEM,
+		ChainPEM:     certPEM,
+		FullChainPEM: certPEM + certPEM,
+		KeyPEM:       string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+// fakeConsole serves a bundle the way the deploy endpoint does
+type fakeConsole struct {
+	bundle  atomic.Pointer[Bundle]
+	fetches atomic.Int32
+}
+
+func (f *fakeConsole) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api/v1/deploy/acd_test/bundle" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	b := f.bundle.Load()
+	etag := `"` + b.Fingerprint + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	f.fetches.Add(1)
+	json.NewEncoder(w).Encode(map[string]any{"code": 0, "message": "success", "data": b})
+}
+
+func newTestAgent(t *testing.T, server string) (*Agent, string) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &Config{
+		Server: server,
+		Token:  "acd_test",
+		Files: FilesConfig{
+			Cert:      filepath.Join(dir, "ssl", "cert.pem"),
+			Key:       filepath.Join(dir, "ssl", "key.pem"),
+			FullChain: filepath.Join(dir, "ssl", "fullchain.pem"),
+		},
+		CertMode:      "0644",
+		KeyMode:       "0600",
+		ReloadCommand: "echo reloaded >> " + filepath.Join(dir, "reload.log"),
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, dir
+}
+
+func reloadCount(t *testing.T, dir string) int {
+	data, err := os.ReadFile(filepath.Join(dir, "reload.log"))
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "reloaded")
+}
+
+func TestSyncWritesFilesAndReloadsOnlyOnChange(t *testing.T) {
+	console := &fakeConsole{}
+	first := testBundle(t, "a.example.com")
+	console.bundle.Store(first)
+	srv := httptest.NewServer(console)
+	defer srv.Close()
+
+	a, dir := newTestAgent(t, srv.URL)
+	ctx := context.Background()
+
+	res, err := a.Sync(ctx)
+	if err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if !res.Changed || !res.Reloaded || res.Fingerprint != first.Fingerprint {
+		t.Fatalf("unexpected first result: %+v", res)
+	}
+	if got := reloadCount(t, dir); got != 1 {
+		t.Fatalf("reload count = %d, want 1", got)
+	}
+
+	keyInfo, err := os.Stat(a.cfg.Files.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyInfo.Mode().Perm() != 0o600 {
+		t.Errorf("key mode = %o, want 600", keyInfo.Mode().Perm())
+	}
+	certInfo, err := os.Stat(a.cfg.Files.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certInfo.Mode().Perm() != 0o644 {
+		t.Errorf("cert mode = %o, want 644", certInfo.Mode().Perm())
+	}
+	fullchain, _ := os.ReadFile(a.cfg.Files.FullChain)
+	if string(fullchain) != first.FullChainPEM {
+		t.Error("fullchain content mismatch")
+	}
+
+	// Unchanged certificate: 304, no rewrite, no reload
+	res, err = a.Sync(ctx)
+	if err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if res.Changed || res.Reloaded {
+		t.Fatalf("expected no change, got %+v", res)
+	}
+	if console.fetches.Load() != 1 {
+		t.Errorf("bundle fetched %d times, want 1", console.fetches.Load())
+	}
+
+	// A fresh agent on the same files derives the fingerprint from disk
+	b, _ := New(a.cfg)
+	if res, err := b.Sync(ctx); err != nil || res.Changed {
+		t.Fatalf("restarted agent: res=%+v err=%v", res, err)
+	}
+
+	// Renewed certificate: rewrite and reload
+	second := testBundle(t, "a.example.com")
+	console.bundle.Store(second)
+	res, err = a.Sync(ctx)
+	if err != nil {
+		t.Fatalf("third sync: %v", err)
+	}
+	if !res.Changed || res.Fingerprint != second.Fingerprint {
+		t.Fatalf("unexpected third result: %+v", res)
+	}
+	if got := reloadCount(t, dir); got != 2 {
+		t.Fatalf("reload count = %d, want 2", got)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "ssl", ".*.tmp-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestSyncRejectedToken(t *testing.T) {
+	console := &fakeConsole{}
+	console.bundle.Store(testBundle(t, "a.example.com"))
+	srv := httptest.NewServer(console)
+	defer srv.Close()
+
+	a, dir := newTestAgent(t, srv.URL)
+	a.cfg.Token = "acd_revoked"
+
+	if _, err := a.Sync(context.Background()); err != ErrUnauthorized {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if _, err := os.Stat(a.cfg.Files.Cert); !os.IsNotExist(err) {
+		t.Error("cert file should not be written")
+	}
+	if reloadCount(t, dir) != 0 {
+		t.Error("reload should not run")
+	}
+}
+
+func TestSyncReloadFailure(t *testing.T) {
+	console := &fakeConsole{}
+	console.bundle.Store(testBundle(t, "a.example.com"))
+	srv := httptest.NewServer(console)
+	defer srv.Close()
+
+	a, _ := newTestAgent(t, srv.URL)
+	a.cfg.ReloadCommand = "echo boom; exit 3"
+
+	res, err := a.Sync(context.Background())
+	if err == nil {
+		t.Fatal("expected reload error")
+	}
+	if res == nil || !res.Changed || res.Reloaded || res.ReloadOutput != "boom" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestSyncRetriesFailedReload(t *testing.T) {
+	console := &fakeConsole{}
+	console.bundle.Store(testBundle(t, "a.example.com"))
+	srv := httptest.NewServer(console)
+	defer srv.Close()
+
+	a, dir := newTestAgent(t, srv.URL)
+	reloadCommand := a.cfg.ReloadCommand
+	a.cfg.ReloadCommand = "exit 1"
+
+	if _, err := a.Sync(context.Background()); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if _, err := a.Sync(context.Background()); err == nil {
+		t.Fatal("expected the pending reload to be retried and fail again")
+	}
+
+	a.cfg.ReloadCommand = reloadCommand
+	res, err := a.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed || !res.Reloaded || reloadCount(t, dir) != 1 {
+		t.Fatalf("unexpected result: %+v, reloads = %d", res, reloadCount(t, dir))
+	}
+
+	res, err = a.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Reloaded || reloadCount(t, dir) != 1 {
+		t.Fatal("reload should not repeat once it succeeded")
+	}
+	if console.fetches.Load() != 1 {
+		t.Errorf("fetches = %d, want 1", console.fetches.Load())
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := &Config{Server: "https://x", Token: "acd_x", Files: FilesConfig{Cert: "/tmp/c"}, KeyMode: "0999"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected invalid key_mode error")
+	}
+	cfg.KeyMode = "0640"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cfg.Files = FilesConfig{Key: "/tmp/k"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected missing cert path error")
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Config is the deploy agent configuration
+type Config struct {
+	Server             string        `mapstructure:"server"` // Console base URL, e.g. https://acme.example.com
+	Token              string        `mapstructure:"token"`  // Deploy token (acd_...)
+	Interval           time.Duration `mapstructure:"interval"`
+	InsecureSkipVerify bool          `mapstructure:"insecure_skip_verify"`
+	Files              FilesConfig   `mapstructure:"files"`
+	Owner              string        `mapstructure:"owner"`     // User name or uid for written files
+	Group              string        `mapstructure:"group"`     // Group name or gid for written files
+	CertMode           string        `mapstructure:"cert_mode"` // Octal, e.g. "0644"
+	KeyMode            string        `mapstructure:"key_mode"`  // Octal, e.g. "0600"
+	ReloadCommand      string        `mapstructure:"reload_command"`
+	ReloadTimeout      time.Duration `mapstructure:"reload_timeout"`
+}
+
+// FilesConfig lists the destination paths; empty paths are not written
+type FilesConfig struct {
+	Cert      string `mapstructure:"cert"`
+	Key       string `mapstructure:"key"`
+	Chain     string `mapstructure:"chain"`
+	FullChain string `mapstructure:"fullchain"`
+}
+
+// LoadConfig reads the agent configuration from a YAML file.
+// Every key can be overridden with an ACME_AGENT_ prefixed environment variable.
+func LoadConfig(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+
+	v.SetEnvPrefix("ACME_AGENT")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	v.SetDefault("interval", "1h")
+	v.SetDefault("cert_mode", "0644")
+	v.SetDefault("key_mode", "0600")
+	v.SetDefault("reload_timeout", "60s")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks that the configuration is usable
+func (c *Config) Validate() error {
+	if c.Server == "" {
+		return fmt.Errorf("server is required")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	if c.Files.Cert == "" && c.Files.FullChain == "" {
+		return fmt.Errorf("at least one of files.cert or files.fullchain is required")
+	}
+	if _, err := parseMode(c.CertMode, 0o644); err != nil {
+		return fmt.Errorf("invalid cert_mode: %w", err)
+	}
+	if _, err := parseMode(c.KeyMode, 0o600); err != nil {
+		return fmt.Errorf("invalid key_mode: %w", err)
+	}
+	return nil
+}
+
+func parseMode(s string, def os.FileMode) (os.FileMode, error) {
+	if s == "" {
+		return def, nil
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, err
+	}
+	return os.FileMode(m).Perm(), nil
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// stagedFile is a fully written temp file waiting to be renamed into place
+type stagedFile struct {
+	tmp  string
+	dest string
+}
+
+// stageFile writes data to a temp file next to dest with the final mode and
+// owner already applied, so the rename that publishes it is atomic.
+func stageFile(dest string, data []byte, mode os.FileMode, uid, gid int) (*stagedFile, error) {
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	// CreateTemp opens with 0600, so key material is never world-readable
+	f, err := os.CreateTemp(dir, "."+filepath.Base(dest)+".tmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file for %s: %w", dest, err)
+	}
+	tmp := f.Name()
+
+	fail := func(err error) (*stagedFile, error) {
+		f.Close()
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return fail(err)
+	}
+	if err := f.Chmod(mode); err != nil {
+		return fail(err)
+	}
+	if uid >= 0 || gid >= 0 {
+		if err := f.Chown(uid, gid); err != nil {
+			return fail(err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+
+	return &stagedFile{tmp: tmp, dest: dest}, nil
+}
+
+func (s *stagedFile) commit() error {
+	if err := os.Rename(s.tmp, s.dest); err != nil {
+		os.Remove(s.tmp)
+		return fmt.Errorf("failed to replace %s: %w", s.dest, err)
+	}
+	return nil
+}
+
+func (s *stagedFile) discard() {
+	os.Remove(s.tmp)
+}
+
+// resolveOwner maps configured owner/group names or ids to numeric ids; -1 leaves them unchanged
+func resolveOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if owner != "" {
+		if id, err := strconv.Atoi(owner); err == nil {
+			uid = id
+		} else {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unknown owner %q: %w", owner, err)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+
+	if group != "" {
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		} else {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, fmt.Errorf("unknown group %q: %w", group, err)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+
+	return uid, gid, nil
+}
+
+// fingerprintFile returns the SHA-256 fingerprint of the first certificate in a
+// PEM file, matching what the console reports. Files that do not parse are
+// hashed as-is, which mirrors the console's fallback for certificates without
+// a stored fingerprint. Missing files yield an empty fingerprint.
+func fingerprintFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+
+	if block, _ := pem.Decode(data); block != nil && block.Type == "CERTIFICATE" {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			sum := sha256.Sum256(cert.Raw)
+			return hex.EncodeToString(sum[:])
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,226 @@
+package auditsink
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+	logger.S = logger.Log.Sugar()
+}
+
+func testEntry() *model.AuditLog {
+	workspaceID := uint(4)
+	return &model.AuditLog{
+		ID:           12,
+		ActorType:    model.AuditActorUser,
+		ActorID:      3,
+		ActorName:    "alice",
+		Action:       "certificate.delete",
+		ResourceType: "certificate",
+		ResourceID:   "9",
+		WorkspaceID:  &workspaceID,
+		RequestID:    "req-1",
+		IP:           "10.0.0.5",
+		Outcome:      model.AuditOutcomeFailure,
+		Detail:       "a=b|c\nd",
+		CreatedAt:    time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestFormatCEFLine(t *testing.T) {
+	got := FormatCEFLine(testEntry())
+	want := "CEF:0|ACME Console|ACME Console|0.1.0|certificate.delete|certificate.delete failed|6|" +
+		"rt=1772368200000 act=certificate.delete outcome=failure suser=alice suid=3 src=10.0.0.5 " +
+		`msg=a\=b|c\nd cs1Label=requestId cs1=req-1 cs2Label=resourceType cs2=certificate ` +
+		"cs3Label=resourceId cs3=9 cs5Label=actorType cs5=user cn1Label=workspaceId cn1=4"
+	if got != want {
+		t.Errorf("FormatCEFLine() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatLEEFLine(t *testing.T) {
+	got := FormatLEEFLine(testEntry())
+	if !strings.HasPrefix(got, "LEEF:1.0|ACME Console|ACME Console|0.1.0|certificate.delete|devTime=Mar 01 2026 12:30:00.000 UTC\t") {
+		t.Errorf("unexpected header: %s", got)
+	}
+	for _, attr := range []string{"usrName=alice", "src=10.0.0.5", "sev=6", "workspaceId=4", "reason=a=b|c d"} {
+		if !strings.Contains(got, "\t"+attr) {
+			t.Errorf("missing %q in %s", attr, got)
+		}
+	}
+	if strings.Contains(got, "\n") {
+		t.Error("LEEF line contains a newline")
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			received <- string(buf)
+		}
+	}()
+
+	sink, err := NewSyslogSink(SyslogConfig{Address: ln.Addr().String(), Format: FormatCEF, Facility: "auth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.hostname = "console"
+
+	entry := testEntry()
+	for i := 0; i < 2; i++ {
+		if err := sink.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-received:
+			// auth (4) * 8 + warning (4) for a failure
+			prefix := "<36>1 2026-03-01T12:30:00.000000Z console acme-console "
+			if !strings.HasPrefix(msg, prefix) || !strings.Contains(msg, " certificate.delete - CEF:0|") {
+				t.Errorf("unexpected message: %s", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for syslog message")
+		}
+	}
+}
+
+func TestSyslogConfigErrors(t *testing.T) {
+	for _, cfg := range []SyslogConfig{
+		{},
+		{Address: "localhost:514", Network: "http"},
+		{Address: "localhost:514", Facility: "nope"},
+		{Address: "localhost:514", Format: "xml"},
+	} {
+		if _, err := NewSyslogSink(cfg); err == nil {
+			t.Errorf("NewSyslogSink(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileSink(FileConfig{Path: path, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	entry := testEntry()
+	entry.Changes = strings.Repeat("x", 300<<10)
+	for i := 0; i < 10; i++ {
+		if err := sink.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 1<<20 {
+			t.Errorf("%s is %d bytes, over the limit", name, len(data))
+		}
+		var decoded model.AuditLog
+		if err := json.Unmarshal([]byte(strings.SplitN(string(data), "\n", 2)[0]), &decoded); err != nil || decoded.Action != entry.Action {
+			t.Errorf("%s: first line is not an event: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("more backups kept than configured")
+	}
+}
+
+// blockingSink fails until released, then records what it receives
+type blockingSink struct {
+	mu       sync.Mutex
+	release  chan struct{}
+	failed   chan struct{}
+	once     sync.Once
+	received []string
+}
+
+func (s *blockingSink) Name() string { return "blocking" }
+func (s *blockingSink) Close() error { return nil }
+
+func (s *blockingSink) Write(entry *model.AuditLog) error {
+	select {
+	case <-s.release:
+	default:
+		s.once.Do(func() { close(s.failed) })
+		return errors.New("collector down")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, entry.Action)
+	return nil
+}
+
+func TestDispatcherNeverBlocks(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{}), failed: make(chan struct{})}
+	d := NewDispatcher([]Sink{sink}, 2)
+
+	d.Forward(model.AuditLog{Action: "event.0"})
+	<-sink.failed
+
+	start := time.Now()
+	for i := 1; i < 100; i++ {
+		d.Forward(model.AuditLog{Action: "event." + strconv.Itoa(i)})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Forward blocked for %v", elapsed)
+	}
+
+	// The collector recovers: the event being retried and the two queued
+	// ones arrive, the rest were dropped
+	close(sink.release)
+	d.Close(5 * time.Second)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	want := []string{"event.0", "event.1", "event.2"}
+	if strings.Join(sink.received, ",") != strings.Join(want, ",") {
+		t.Errorf("received %v", sink.received)
+	}
+}
@@ -0,0 +1,159 @@
+package auditsink
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+// Sink delivers events to one destination. Each sink is used by a single
+// goroutine, so implementations need no locking.
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Write delivers one event; an error means it should be retried
+	Write(entry *model.AuditLog) error
+	Close() error
+}
+
+// Retry delays while a sink is failing
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+// DefaultBufferSize is the number of events queued per sink
+const DefaultBufferSize = 10000
+
+// Dispatcher fans events out to sinks. Every sink has its own queue and
+// goroutine: a failing or slow collector holds up only its own deliveries and,
+// once its queue is full, loses new events instead of blocking the caller.
+type Dispatcher struct {
+	queues []*queue
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+type queue struct {
+	sink    Sink
+	events  chan model.AuditLog
+	dropped atomic.Int64
+}
+
+// NewDispatcher starts delivering to sinks; bufferSize <= 0 uses DefaultBufferSize
+func NewDispatcher(sinks []Sink, bufferSize int) *Dispatcher {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	d := &Dispatcher{done: make(chan struct{})}
+	for _, sink := range sinks {
+		q := &queue{sink: sink, events: make(chan model.AuditLog, bufferSize)}
+		d.queues = append(d.queues, q)
+		d.wg.Add(1)
+		go d.run(q)
+	}
+	return d
+}
+
+// Forward queues an event for every sink without waiting
+func (d *Dispatcher) Forward(entry model.AuditLog) {
+	for _, q := range d.queues {
+		select {
+		case q.events <- entry:
+		default:
+			if q.dropped.Add(1) == 1 {
+				logger.Warn("Audit sink queue is full, dropping events",
+					logger.String("sink", q.sink.Name()),
+				)
+			}
+		}
+	}
+}
+
+// Close stops accepting retries, flushes what is queued with one attempt per
+// event and closes the sinks, giving up after timeout
+func (d *Dispatcher) Close(timeout time.Duration) {
+	close(d.done)
+
+	finished := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		logger.Warn("Timed out flushing audit sinks")
+	}
+}
+
+func (d *Dispatcher) run(q *queue) {
+	defer d.wg.Done()
+	for {
+		select {
+		case entry := <-q.events:
+			d.deliver(q, &entry)
+		case <-d.done:
+			d.flush(q)
+			return
+		}
+	}
+}
+
+// deliver retries with backoff until the event is written or the dispatcher closes
+func (d *Dispatcher) deliver(q *queue, entry *model.AuditLog) {
+	delay := minRetryDelay
+	failing := false
+	for {
+		err := q.sink.Write(entry)
+		if err == nil {
+			if failing {
+				logger.Info("Audit sink delivery resumed", logger.String("sink", q.sink.Name()))
+			}
+			if dropped := q.dropped.Swap(0); dropped > 0 {
+				logger.Warn("Audit sink dropped events while its queue was full",
+					logger.String("sink", q.sink.Name()),
+					logger.Int64("dropped", dropped),
+				)
+			}
+			return
+		}
+		if !failing {
+			logger.Warn("Audit sink delivery failed, retrying",
+				logger.String("sink", q.sink.Name()),
+				logger.Err(err),
+			)
+			failing = true
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-d.done:
+			// One last attempt; flush reports the sink if it is still down
+			q.sink.Write(entry)
+			return
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+func (d *Dispatcher) flush(q *queue) {
+	defer q.sink.Close()
+	for {
+		select {
+		case entry := <-q.events:
+			if err := q.sink.Write(&entry); err != nil {
+				logger.Warn("Audit sink unavailable at shutdown, events not delivered",
+					logger.String("sink", q.sink.Name()),
+					logger.Int("pending", len(q.events)+1),
+				)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package auditsink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+// FileConfig configures a JSON-lines file sink
+type FileConfig struct {
+	Path       string
+	MaxSizeMB  int // Rotate when the file would grow past this size, default 100
+	MaxBackups int // Rotated files kept as path.1 ... path.N, default 5
+}
+
+// FileSink appends one JSON object per line and rotates by size
+type FileSink struct {
+	cfg  FileConfig
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens (or creates) the file
+func NewFileSink(cfg FileConfig) (*FileSink, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("file audit sink requires a path")
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = 100
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = 5
+	}
+	s := &FileSink{cfg: cfg}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name identifies the sink in logs
+func (s *FileSink) Name() string {
+	return "file " + s.cfg.Path
+}
+
+// Write appends one event
+func (s *FileSink) Write(entry *model.AuditLog) error {
+	line := []byte(FormatJSONLine(entry) + "\n")
+	if s.size > 0 && s.size+int64(len(line)) > int64(s.cfg.MaxSizeMB)<<20 {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	// Audit trails are not for every local user to read
+	file, err := os.OpenFile(s.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and starts a new file
+func (s *FileSink) rotate() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	os.Remove(s.backup(s.cfg.MaxBackups))
+	for i := s.cfg.MaxBackups - 1; i >= 1; i-- {
+		os.Rename(s.backup(i), s.backup(i+1))
+	}
+	if err := os.Rename(s.cfg.Path, s.backup(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate audit log file: %w", err)
+	}
+	return s.open()
+}
+
+func (s *FileSink) backup(n int) string {
+	return fmt.Sprintf("%s.%d", s.cfg.Path, n)
+}
@@ -0,0 +1,178 @@
+// Package auditsink streams audit events to external collectors: syslog
+// servers and SIEMs (RFC 5424 syslog carrying JSON, CEF or LEEF) and JSON-lines
+// files. Delivery is asynchronous, so a slow collector never delays requests.
+package auditsink
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+// Message formats
+const (
+	FormatJSON = "json"
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+)
+
+// Identify the console in CEF and LEEF headers
+const (
+	vendor         = "ACME Console"
+	product        = "ACME Console"
+	productVersion = "0.1.0"
+)
+
+// Formatter turns an event into a single-line message
+type Formatter func(entry *model.AuditLog) string
+
+// NewFormatter returns the formatter for a format name; empty means JSON
+func NewFormatter(format string) (Formatter, error) {
+	switch format {
+	case "", FormatJSON:
+		return FormatJSONLine, nil
+	case FormatCEF:
+		return FormatCEFLine, nil
+	case FormatLEEF:
+		return FormatLEEFLine, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink format %q", format)
+	}
+}
+
+// FormatJSONLine encodes the event as a JSON object with the audit log API's
+// field names
+func FormatJSONLine(entry *model.AuditLog) string {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		// AuditLog holds only strings, numbers and a time
+		return `{"action":` + strconv.Quote(entry.Action) + `}`
+	}
+	return string(data)
+}
+
+// FormatCEFLine encodes the event in ArcSight Common Event Format
+func FormatCEFLine(entry *model.AuditLog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeader(vendor), cefHeader(product), productVersion,
+		cefHeader(entry.Action), cefHeader(eventName(entry)), cefSeverity(entry))
+
+	ext := []string{
+		"rt=" + strconv.FormatInt(entry.CreatedAt.UnixMilli(), 10),
+		"act=" + cefValue(entry.Action),
+		"outcome=" + cefValue(entry.Outcome),
+	}
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefValue(value))
+		}
+	}
+	add("suser", entry.ActorName)
+	if entry.ActorID != 0 {
+		add("suid", strconv.FormatUint(uint64(entry.ActorID), 10))
+	}
+	add("src", entry.IP)
+	add("msg", entry.Detail)
+	add("cs1Label", labelIf(entry.RequestID, "requestId"))
+	add("cs1", entry.RequestID)
+	add("cs2Label", labelIf(entry.ResourceType, "resourceType"))
+	add("cs2", entry.ResourceType)
+	add("cs3Label", labelIf(entry.ResourceID, "resourceId"))
+	add("cs3", entry.ResourceID)
+	add("cs4Label", labelIf(entry.Changes, "changes"))
+	add("cs4", entry.Changes)
+	add("cs5Label", labelIf(entry.ActorType, "actorType"))
+	add("cs5", entry.ActorType)
+	if entry.WorkspaceID != nil {
+		add("cn1Label", "workspaceId")
+		add("cn1", strconv.FormatUint(uint64(*entry.WorkspaceID), 10))
+	}
+	if entry.TokenID != 0 {
+		add("cn2Label", "apiTokenId")
+		add("cn2", strconv.FormatUint(uint64(entry.TokenID), 10))
+	}
+	b.WriteString(strings.Join(ext, " "))
+	return b.String()
+}
+
+// FormatLEEFLine encodes the event in IBM QRadar Log Event Extended Format 1.0
+func FormatLEEFLine(entry *model.AuditLog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|%s|",
+		leefHeader(vendor), leefHeader(product), productVersion, leefHeader(entry.Action))
+
+	attrs := []string{
+		"devTime=" + entry.CreatedAt.Format("Jan 02 2006 15:04:05.000 MST"),
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+		"cat=" + leefValue(entry.ResourceType),
+		"sev=" + strconv.Itoa(cefSeverity(entry)),
+		"outcome=" + leefValue(entry.Outcome),
+	}
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, key+"="+leefValue(value))
+		}
+	}
+	add("usrName", entry.ActorName)
+	if entry.ActorID != 0 {
+		add("accountId", strconv.FormatUint(uint64(entry.ActorID), 10))
+	}
+	add("actorType", entry.ActorType)
+	add("src", entry.IP)
+	add("resource", entry.ResourceID)
+	if entry.WorkspaceID != nil {
+		add("workspaceId", strconv.FormatUint(uint64(*entry.WorkspaceID), 10))
+	}
+	if entry.TokenID != 0 {
+		add("apiTokenId", strconv.FormatUint(uint64(entry.TokenID), 10))
+	}
+	add("requestId", entry.RequestID)
+	add("reason", entry.Detail)
+	add("changes", entry.Changes)
+	b.WriteString(strings.Join(attrs, "\t"))
+	return b.String()
+}
+
+// eventName is a human-readable summary for the CEF name field
+func eventName(entry *model.AuditLog) string {
+	name := strings.ReplaceAll(entry.Action, "_", " ")
+	if entry.Outcome == model.AuditOutcomeFailure {
+		name += " failed"
+	}
+	return name
+}
+
+// cefSeverity rates an event from 0 to 10; failures and lockouts stand out
+func cefSeverity(entry *model.AuditLog) int {
+	switch {
+	case entry.Action == model.AuditActionAccountLocked:
+		return 8
+	case entry.Outcome == model.AuditOutcomeFailure:
+		return 6
+	default:
+		return 3
+	}
+}
+
+func labelIf(value, label string) string {
+	if value == "" {
+		return ""
+	}
+	return label
+}
+
+var (
+	cefHeaderEscaper  = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper   = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\r", " ", "\n", " ", "\t", " ")
+	leefValueEscaper  = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+func cefHeader(s string) string  { return cefHeaderEscaper.Replace(s) }
+func cefValue(s string) string   { return cefValueEscaper.Replace(s) }
+func leefHeader(s string) string { return leefHeaderEscaper.Replace(s) }
+func leefValue(s string) string  { return leefValueEscaper.Replace(s) }
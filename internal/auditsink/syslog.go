@@ -0,0 +1,211 @@
+package auditsink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+// Syslog transports
+const (
+	NetworkUDP = "udp"
+	NetworkTCP = "tcp"
+	NetworkTLS = "tls"
+)
+
+// Syslog facilities by name (RFC 5424 section 6.2.1)
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog severities used for audit events
+const (
+	severityWarning = 4
+	severityNotice  = 5
+)
+
+// SyslogConfig configures a syslog sink
+type SyslogConfig struct {
+	Network            string // udp, tcp (default) or tls
+	Address            string // host:port
+	Format             string // Message body: json (default), cef or leef
+	Facility           string // Default local0
+	AppName            string // Default acme-console
+	CAFile             string // PEM CA bundle for tls; empty = system roots
+	InsecureSkipVerify bool
+	Timeout            time.Duration // Dial and write timeout, default 10s
+}
+
+// SyslogSink sends RFC 5424 messages. TCP and TLS use octet-counting framing
+// (RFC 6587, RFC 5425); a broken connection is redialed on the next event.
+type SyslogSink struct {
+	cfg      SyslogConfig
+	format   Formatter
+	facility int
+	hostname string
+	tls      *tls.Config
+	conn     net.Conn
+}
+
+// NewSyslogSink creates a SyslogSink. It connects on the first event, so a
+// collector that is down at startup does not stop the console.
+func NewSyslogSink(cfg SyslogConfig) (*SyslogSink, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("syslog audit sink requires an address")
+	}
+	if cfg.Network == "" {
+		cfg.Network = NetworkTCP
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "acme-console"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Facility == "" {
+		cfg.Facility = "local0"
+	}
+	facility, ok := facilities[cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", cfg.Facility)
+	}
+	format, err := NewFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SyslogSink{cfg: cfg, format: format, facility: facility, hostname: "-"}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		s.hostname = host
+	}
+
+	switch cfg.Network {
+	case NetworkUDP, NetworkTCP:
+	case NetworkTLS:
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog address: %w", err)
+		}
+		s.tls = &tls.Config{
+			ServerName:         host,
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read syslog CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New("syslog CA file contains no certificates")
+			}
+			s.tls.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", cfg.Network)
+	}
+	return s, nil
+}
+
+// Name identifies the sink in logs
+func (s *SyslogSink) Name() string {
+	return "syslog " + s.cfg.Network + "://" + s.cfg.Address
+}
+
+// Write sends one event
+func (s *SyslogSink) Write(entry *model.AuditLog) error {
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return err
+		}
+	}
+
+	msg := s.message(entry)
+	if s.cfg.Network != NetworkUDP {
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+	if _, err := s.conn.Write([]byte(msg)); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SyslogSink) dial() error {
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.cfg.Address, s.tls)
+	} else {
+		conn, err = dialer.Dial(s.cfg.Network, s.cfg.Address)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// message builds "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG"
+func (s *SyslogSink) message(entry *model.AuditLog) string {
+	severity := severityNotice
+	if entry.Outcome == model.AuditOutcomeFailure {
+		severity = severityWarning
+	}
+	ts := entry.CreatedAt
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.facility*8+severity,
+		ts.UTC().Format("2006-01-02T15:04:05.000000Z"),
+		headerField(s.hostname, 255),
+		headerField(s.cfg.AppName, 48),
+		os.Getpid(),
+		headerField(entry.Action, 32),
+		s.format(entry))
+}
+
+// headerField keeps a header field to printable US-ASCII without spaces
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
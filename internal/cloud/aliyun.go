@@ -0,0 +1,218 @@
+package cloud
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aliyunClient calls Alibaba Cloud RPC-style APIs (signature version 1.0)
+type aliyunClient struct {
+	creds    Credentials
+	endpoint string // Overrides every product endpoint when set
+	http     *http.Client
+	now      func() time.Time
+}
+
+func newAliyunClient(creds Credentials, opts Options) *aliyunClient {
+	if creds.Region == "" {
+		creds.Region = "cn-hangzhou"
+	}
+	return &aliyunClient{creds: creds, endpoint: strings.TrimRight(opts.Endpoint, "/"), http: opts.HTTPClient, now: time.Now}
+}
+
+func (c *aliyunClient) call(ctx context.Context, host, version, action string, params map[string]string, out any) error {
+	values := map[string]string{
+		"Format":           "JSON",
+		"Version":          version,
+		"AccessKeyId":      c.creds.AccessKeyID,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   nonce(),
+		"Timestamp":        c.now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Action":           action,
+	}
+	for k, v := range params {
+		values[k] = v
+	}
+	values["Signature"] = signAliyunRPC(http.MethodPost, values, c.creds.SecretAccessKey)
+
+	form := url.Values{}
+	for k, v := range values {
+		form.Set(k, v)
+	}
+
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = "https://" + host
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Code != "" {
+			return &APIError{Code: e.Code, Message: e.Message}
+		}
+		return fmt.Errorf("%s returned %d", action, resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// signAliyunRPC computes the signature over the sorted, percent-encoded parameters
+func signAliyunRPC(method string, params map[string]string, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, aliyunEscape(k)+"="+aliyunEscape(params[k]))
+	}
+	stringToSign := method + "&" + aliyunEscape("/") + "&" + aliyunEscape(strings.Join(pairs, "&"))
+
+	h := hmac.New(sha1.New, []byte(secret+"&"))
+	h.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// aliyunEscape is RFC 3986 percent-encoding as required by the signature
+func aliyunEscape(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
+
+func nonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// aliyunCDN uploads to Certificate Management Service (CAS) and sets the
+// certificate on each CDN domain by its CAS ID.
+type aliyunCDN struct {
+	client *aliyunClient
+}
+
+func (p *aliyunCDN) Upload(ctx context.Context, cert *Certificate, _ string) (string, error) {
+	var out struct {
+		CertID int64 `json:"CertId"`
+	}
+	err := p.client.call(ctx, "cas.aliyuncs.com", "2020-04-07", "UploadUserCertificate", map[string]string{
+		"Name": cert.Name,
+		"Cert": string(cert.FullChain),
+		"Key":  string(cert.Key),
+	}, &out)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(out.CertID, 10), nil
+}
+
+func (p *aliyunCDN) Bind(ctx context.Context, certID string, domains []string) error {
+	for _, domain := range domains {
+		err := p.client.call(ctx, "cdn.aliyuncs.com", "2018-05-10", "SetCdnDomainSSLCertificate", map[string]string{
+			"DomainName":  domain,
+			"SSLProtocol": "on",
+			"CertType":    "cas",
+			"CertId":      certID,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("domain %s: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+func (p *aliyunCDN) Delete(ctx context.Context, certID string) error {
+	return p.client.call(ctx, "cas.aliyuncs.com", "2020-04-07", "DeleteUserCertificate", map[string]string{
+		"CertId": certID,
+	}, nil)
+}
+
+// aliyunSLB uploads SLB server certificates and sets them on HTTPS listeners.
+// Resources are "<load balancer id>:<listener port>".
+type aliyunSLB struct {
+	client *aliyunClient
+}
+
+func (p *aliyunSLB) host() string {
+	return "slb." + p.client.creds.Region + ".aliyuncs.com"
+}
+
+func (p *aliyunSLB) Upload(ctx context.Context, cert *Certificate, _ string) (string, error) {
+	var out struct {
+		ServerCertificateID string `json:"ServerCertificateId"`
+	}
+	err := p.client.call(ctx, p.host(), "2014-05-15", "UploadServerCertificate", map[string]string{
+		"RegionId":              p.client.creds.Region,
+		"ServerCertificateName": cert.Name,
+		"ServerCertificate":     string(cert.FullChain),
+		"PrivateKey":            string(cert.Key),
+	}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.ServerCertificateID, nil
+}
+
+func (p *aliyunSLB) Bind(ctx context.Context, certID string, listeners []string) error {
+	for _, listener := range listeners {
+		lbID, port, ok := strings.Cut(listener, ":")
+		if !ok || lbID == "" || port == "" {
+			return fmt.Errorf("invalid SLB listener %q, expected <load balancer id>:<port>", listener)
+		}
+		err := p.client.call(ctx, p.host(), "2014-05-15", "SetLoadBalancerHTTPSListenerAttribute", map[string]string{
+			"RegionId":            p.client.creds.Region,
+			"LoadBalancerId":      lbID,
+			"ListenerPort":        port,
+			"ServerCertificateId": certID,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", listener, err)
+		}
+	}
+	return nil
+}
+
+func (p *aliyunSLB) Delete(ctx context.Context, certID string) error {
+	return p.client.call(ctx, p.host(), "2014-05-15", "DeleteServerCertificate", map[string]string{
+		"RegionId":            p.client.creds.Region,
+		"ServerCertificateId": certID,
+	}, nil)
+}
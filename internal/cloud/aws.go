@@ -0,0 +1,320 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsClient sends Signature Version 4 signed requests
+type awsClient struct {
+	creds    Credentials
+	endpoint string // Overrides every service endpoint when set
+	http     *http.Client
+	now      func() time.Time
+}
+
+func newAWSClient(creds Credentials, opts Options) *awsClient {
+	if creds.Region == "" {
+		creds.Region = "us-east-1"
+	}
+	return &awsClient{creds: creds, endpoint: strings.TrimRight(opts.Endpoint, "/"), http: opts.HTTPClient, now: time.Now}
+}
+
+func (c *awsClient) serviceURL(service, region string) string {
+	if c.endpoint != "" {
+		return c.endpoint + "/"
+	}
+	if service == "iam" {
+		return "https://iam.amazonaws.com/"
+	}
+	return fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region)
+}
+
+// query calls a Query-protocol API (IAM, ELBv2) and decodes the XML result into out
+func (c *awsClient) query(ctx context.Context, service, region string, params url.Values, out any) error {
+	body := []byte(params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serviceURL(service, region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	data, status, err := c.send(req, body, service, region)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		var e struct {
+			Error struct {
+				Code    string `xml:"Code"`
+				Message string `xml:"Message"`
+			} `xml:"Error"`
+		}
+		if xml.Unmarshal(data, &e) == nil && e.Error.Code != "" {
+			return &APIError{Code: e.Error.Code, Message: e.Error.Message}
+		}
+		return fmt.Errorf("%s returned %d", service, status)
+	}
+	if out != nil {
+		return xml.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// json11 calls a JSON 1.1 protocol API (ACM)
+func (c *awsClient) json11(ctx context.Context, service, target string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serviceURL(service, c.creds.Region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	data, status, err := c.send(req, body, service, c.creds.Region)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Type != "" {
+			return &APIError{Code: e.Type[strings.LastIndex(e.Type, "#")+1:], Message: e.Message}
+		}
+		return fmt.Errorf("%s returned %d", service, status)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+func (c *awsClient) send(req *http.Request, body []byte, service, region string) ([]byte, int, error) {
+	signV4(req, body, c.creds, service, region, c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return data, resp.StatusCode, err
+}
+
+// signV4 adds the Authorization and X-Amz-Date headers to req
+func signV4(req *http.Request, body []byte, creds Credentials, service, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	// Canonical headers: host plus every header already set, lower-cased and sorted
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalQuery := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// bindELBv2Listeners sets the default certificate of each HTTPS listener
+func (c *awsClient) bindELBv2Listeners(ctx context.Context, certARN string, listeners []string) error {
+	for _, listener := range listeners {
+		region := c.creds.Region
+		// arn:aws:elasticloadbalancing:<region>:<account>:listener/...
+		if parts := strings.Split(listener, ":"); len(parts) > 3 && parts[3] != "" {
+			region = parts[3]
+		}
+		params := url.Values{
+			"Action":                               {"ModifyListener"},
+			"Version":                              {"2015-12-01"},
+			"ListenerArn":                          {listener},
+			"Certificates.member.1.CertificateArn": {certARN},
+		}
+		if err := c.query(ctx, "elasticloadbalancing", region, params, nil); err != nil {
+			return fmt.Errorf("listener %s: %w", listener, err)
+		}
+	}
+	return nil
+}
+
+// awsACM imports into AWS Certificate Manager. Re-imports reuse the previous
+// ARN, so resources already using it pick up the renewed certificate and
+// there is nothing to clean up.
+type awsACM struct {
+	aws *awsClient
+}
+
+func (p *awsACM) Upload(ctx context.Context, cert *Certificate, previousID string) (string, error) {
+	in := map[string]any{
+		"Certificate": base64.StdEncoding.EncodeToString(cert.Leaf),
+		"PrivateKey":  base64.StdEncoding.EncodeToString(cert.Key),
+	}
+	if len(cert.Chain) > 0 {
+		in["CertificateChain"] = base64.StdEncoding.EncodeToString(cert.Chain)
+	}
+	if previousID != "" {
+		in["CertificateArn"] = previousID
+	} else {
+		in["Tags"] = []map[string]string{{"Key": "managed-by", "Value": "acme-console"}, {"Key": "Name", "Value": cert.Name}}
+	}
+
+	var out struct {
+		CertificateArn string `json:"CertificateArn"`
+	}
+	if err := p.aws.json11(ctx, "acm", "CertificateManager.ImportCertificate", in, &out); err != nil {
+		return "", err
+	}
+	return out.CertificateArn, nil
+}
+
+func (p *awsACM) Bind(ctx context.Context, certID string, listeners []string) error {
+	return p.aws.bindELBv2Listeners(ctx, certID, listeners)
+}
+
+func (p *awsACM) Delete(ctx context.Context, certID string) error {
+	return p.aws.json11(ctx, "acm", "CertificateManager.DeleteCertificate", map[string]string{"CertificateArn": certID}, nil)
+}
+
+// awsIAM uploads IAM server certificates, which are immutable: every renewal
+// is a new upload and the old one is deleted once listeners moved over.
+type awsIAM struct {
+	aws *awsClient
+}
+
+type iamMetadata struct {
+	Arn string `xml:"Arn"`
+}
+
+func (p *awsIAM) Upload(ctx context.Context, cert *Certificate, _ string) (string, error) {
+	params := url.Values{
+		"Action":                {"UploadServerCertificate"},
+		"Version":               {"2010-05-08"},
+		"ServerCertificateName": {cert.Name},
+		"CertificateBody":       {string(cert.Leaf)},
+		"PrivateKey":            {string(cert.Key)},
+	}
+	if len(cert.Chain) > 0 {
+		params.Set("CertificateChain", string(cert.Chain))
+	}
+
+	var out struct {
+		Metadata iamMetadata `xml:"UploadServerCertificateResult>ServerCertificateMetadata"`
+	}
+	err := p.aws.query(ctx, "iam", "us-east-1", params, &out)
+	if apiErr, ok := err.(*APIError); ok && apiErr.Code == "EntityAlreadyExists" {
+		// Same name means same certificate version: an earlier run uploaded it
+		return p.lookup(ctx, cert.Name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return out.Metadata.Arn, nil
+}
+
+func (p *awsIAM) lookup(ctx context.Context, name string) (string, error) {
+	var out struct {
+		Metadata iamMetadata `xml:"GetServerCertificateResult>ServerCertificate>ServerCertificateMetadata"`
+	}
+	params := url.Values{
+		"Action":                {"GetServerCertificate"},
+		"Version":               {"2010-05-08"},
+		"ServerCertificateName": {name},
+	}
+	if err := p.aws.query(ctx, "iam", "us-east-1", params, &out); err != nil {
+		return "", err
+	}
+	return out.Metadata.Arn, nil
+}
+
+func (p *awsIAM) Bind(ctx context.Context, certID string, listeners []string) error {
+	return p.aws.bindELBv2Listeners(ctx, certID, listeners)
+}
+
+func (p *awsIAM) Delete(ctx context.Context, certID string) error {
+	// arn:aws:iam::<account>:server-certificate/<path/>name
+	name := certID[strings.LastIndex(certID, "/")+1:]
+	params := url.Values{
+		"Action":                {"DeleteServerCertificate"},
+		"Version":               {"2010-05-08"},
+		"ServerCertificateName": {name},
+	}
+	return p.aws.query(ctx, "iam", "us-east-1", params, nil)
+}
+
+// APIError is an error reported by a cloud API
+type APIError struct {
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
@@ -0,0 +1,165 @@
+// Package cloud uploads certificates to cloud load balancers and CDNs and
+// points the configured resources at them. Requests are signed by hand
+// (SigV4, Alibaba Cloud RPC, TC3) so no vendor SDKs are pulled in.
+package cloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown cloud provider")
+	ErrNoResources     = errors.New("at least one resource is required")
+)
+
+// Providers, each one a deployment flavour of a cloud vendor
+const (
+	ProviderAWSACM     = "aws_acm"     // Import into ACM, attach to ELBv2 listeners
+	ProviderAWSIAM     = "aws_iam"     // Upload as IAM server certificate, attach to ELBv2 listeners
+	ProviderAliyunCDN  = "aliyun_cdn"  // Upload to Certificate Management Service, set on CDN domains
+	ProviderAliyunSLB  = "aliyun_slb"  // Upload to SLB, set on HTTPS listeners
+	ProviderTencentSSL = "tencent_ssl" // Upload to SSL Certificates, deploy to CLB/CDN/... instances
+)
+
+// Vendors group providers that share credentials
+const (
+	VendorAWS     = "aws"
+	VendorAliyun  = "aliyun"
+	VendorTencent = "tencent"
+)
+
+// VendorOf returns the credential vendor a provider needs
+func VendorOf(provider string) string {
+	switch provider {
+	case ProviderAWSACM, ProviderAWSIAM:
+		return VendorAWS
+	case ProviderAliyunCDN, ProviderAliyunSLB:
+		return VendorAliyun
+	case ProviderTencentSSL:
+		return VendorTencent
+	}
+	return ""
+}
+
+// Credentials is an access key pair
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Region          string
+}
+
+// Certificate is the material to upload
+type Certificate struct {
+	Name        string // Stable, provider-safe name derived from certificate ID and fingerprint
+	Fingerprint string
+	Leaf        []byte
+	Chain       []byte // Intermediates only
+	FullChain   []byte // Leaf + intermediates
+	Key         []byte
+}
+
+// Options tunes a provider client
+type Options struct {
+	Endpoint     string // Overrides the API endpoint, e.g. for tests or private regions
+	ResourceType string // Tencent Cloud: clb, cdn, waf, ... (default clb)
+	HTTPClient   *http.Client
+}
+
+// Provider uploads certificates and binds them to resources
+type Provider interface {
+	// Upload imports the certificate and returns its provider ID. previousID is
+	// the ID from the last deployment and may be reused for in-place re-imports.
+	Upload(ctx context.Context, cert *Certificate, previousID string) (string, error)
+	// Bind points every resource at the certificate
+	Bind(ctx context.Context, certID string, resources []string) error
+	// Delete removes a superseded certificate
+	Delete(ctx context.Context, certID string) error
+}
+
+// New creates a provider client
+func New(provider string, creds Credentials, opts Options) (Provider, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	switch provider {
+	case ProviderAWSACM:
+		return &awsACM{aws: newAWSClient(creds, opts)}, nil
+	case ProviderAWSIAM:
+		return &awsIAM{aws: newAWSClient(creds, opts)}, nil
+	case ProviderAliyunCDN:
+		return &aliyunCDN{client: newAliyunClient(creds, opts)}, nil
+	case ProviderAliyunSLB:
+		return &aliyunSLB{client: newAliyunClient(creds, opts)}, nil
+	case ProviderTencentSSL:
+		return newTencentSSL(creds, opts), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+}
+
+// State is what a target remembers between deployments
+type State struct {
+	CertID      string
+	Fingerprint string
+	Superseded  string // Previous upload still awaiting deletion after a failed bind
+}
+
+// Deploy uploads the certificate unless the previous deployment already holds
+// the same fingerprint, binds it to every resource, then deletes the
+// superseded upload. Deleting is best effort: a failure there is reported in
+// the returned notes but does not fail the deployment, because the resources
+// already serve the new certificate.
+func Deploy(ctx context.Context, p Provider, cert *Certificate, resources []string, prev State) (State, []string, error) {
+	if len(resources) == 0 {
+		return prev, nil, ErrNoResources
+	}
+
+	var notes []string
+	certID := prev.CertID
+	if certID == "" || prev.Fingerprint != cert.Fingerprint {
+		id, err := p.Upload(ctx, cert, prev.CertID)
+		if err != nil {
+			return prev, nil, fmt.Errorf("upload failed: %w", err)
+		}
+		certID = id
+		notes = append(notes, "uploaded "+certID)
+	} else {
+		notes = append(notes, "certificate already uploaded as "+certID)
+	}
+
+	superseded := prev.Superseded
+	if prev.CertID != "" && prev.CertID != certID {
+		superseded = prev.CertID
+	}
+
+	if err := p.Bind(ctx, certID, resources); err != nil {
+		// Keep the new upload recorded so a retry does not upload it again,
+		// and remember the old one so it is still cleaned up afterwards.
+		return State{CertID: certID, Fingerprint: cert.Fingerprint, Superseded: superseded}, notes, fmt.Errorf("bind failed: %w", err)
+	}
+	notes = append(notes, "bound to "+strings.Join(resources, ", "))
+
+	if superseded != "" && superseded != certID {
+		if err := p.Delete(ctx, superseded); err != nil {
+			notes = append(notes, fmt.Sprintf("warning: failed to delete superseded certificate %s: %v", superseded, err))
+		} else {
+			notes = append(notes, "deleted superseded "+superseded)
+		}
+	}
+
+	return State{CertID: certID, Fingerprint: cert.Fingerprint}, notes, nil
+}
+
+// CertificateName builds a name that is unique per certificate version and
+// valid for every provider (letters, digits and dashes, at most 64 chars).
+func CertificateName(certID uint, fingerprint string) string {
+	fp := fingerprint
+	if len(fp) > 16 {
+		fp = fp[:16]
+	}
+	return fmt.Sprintf("acme-console-%d-%s", certID, fp)
+}
@@ -0,0 +1,340 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignV4KnownVector(t *testing.T) {
+	// Example request from the AWS Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	signV4(req, nil, creds, "iam", "us-east-1", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSignAliyunRPCKnownVector(t *testing.T) {
+	// Example from the Alibaba Cloud RPC signature documentation
+	params := map[string]string{
+		"AccessKeyId":      "testid",
+		"Action":           "DescribeRegions",
+		"Format":           "XML",
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf",
+		"SignatureVersion": "1.0",
+		"Timestamp":        "2016-02-23T12:46:24Z",
+		"Version":          "2014-05-26",
+	}
+	if got := signAliyunRPC(http.MethodGet, params, "testsecret"); got != "OLeaidS1JvxuMvnyHOwuJ+uX5qY=" {
+		t.Errorf("signature = %s", got)
+	}
+}
+
+// standIn records the calls a fake cloud API received
+type standIn struct {
+	mu     sync.Mutex
+	calls  []string
+	failOn string // Action that returns an error
+	nextID int
+}
+
+func (s *standIn) record(action string) (fail bool, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, action)
+	s.nextID++
+	return action == s.failOn, fmt.Sprint(s.nextID)
+}
+
+func (s *standIn) actions() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := strings.Join(s.calls, ",")
+	s.calls = nil
+	return out
+}
+
+func testCert(fp string) *Certificate {
+	return &Certificate{
+		Name:        CertificateName(7, fp),
+		Fingerprint: fp,
+		Leaf:        []byte("LEAF"),
+		Chain:       []byte("CHAIN"),
+		FullChain:   []byte("LEAFCHAIN"),
+		Key:         []byte("KEY"),
+	}
+}
+
+// awsStandIn serves ACM (JSON 1.1), IAM and ELBv2 (Query) from one endpoint
+func awsStandIn(t *testing.T, s *standIn) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("missing SigV4 authorization: %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		if target := r.Header.Get("X-Amz-Target"); target != "" {
+			action := strings.TrimPrefix(target, "CertificateManager.")
+			fail, id := s.record(action)
+			if fail {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"__type":"com.amazonaws#ValidationException","message":"boom"}`)
+				return
+			}
+			var in map[string]any
+			json.Unmarshal(body, &in)
+			arn, ok := in["CertificateArn"].(string)
+			if !ok {
+				arn = "arn:aws:acm:us-east-1:1:certificate/" + id
+			}
+			json.NewEncoder(w).Encode(map[string]string{"CertificateArn": arn})
+			return
+		}
+
+		form, _ := url.ParseQuery(string(body))
+		action := form.Get("Action")
+		fail, _ := s.record(action)
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<ErrorResponse><Error><Code>Throttling</Code><Message>slow down</Message></Error></ErrorResponse>`)
+			return
+		}
+		switch action {
+		case "UploadServerCertificate":
+			fmt.Fprintf(w, `<UploadServerCertificateResponse><UploadServerCertificateResult><ServerCertificateMetadata><Arn>arn:aws:iam::1:server-certificate/%s</Arn></ServerCertificateMetadata></UploadServerCertificateResult></UploadServerCertificateResponse>`, form.Get("ServerCertificateName"))
+		default:
+			fmt.Fprintf(w, `<%sResponse/>`, action)
+		}
+	}))
+}
+
+func TestDeployAWSACMReimportsInPlace(t *testing.T) {
+	s := &standIn{}
+	srv := awsStandIn(t, s)
+	defer srv.Close()
+
+	p, _ := New(ProviderAWSACM, Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, Options{Endpoint: srv.URL})
+	listener := []string{"arn:aws:elasticloadbalancing:eu-west-1:1:listener/app/web/1/2"}
+	ctx := context.Background()
+
+	state, _, err := Deploy(ctx, p, testCert("fp1"), listener, State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.actions(); got != "ImportCertificate,ModifyListener" {
+		t.Errorf("first deploy calls = %s", got)
+	}
+
+	// Same certificate again: no upload, binding re-asserted
+	state, _, err = Deploy(ctx, p, testCert("fp1"), listener, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.actions(); got != "ModifyListener" {
+		t.Errorf("idempotent deploy calls = %s", got)
+	}
+
+	// Renewal re-imports into the same ARN, so nothing is deleted
+	arn := state.CertID
+	state, _, err = Deploy(ctx, p, testCert("fp2"), listener, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.actions(); got != "ImportCertificate,ModifyListener" {
+		t.Errorf("renewal calls = %s", got)
+	}
+	if state.CertID != arn || state.Fingerprint != "fp2" {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestDeployAWSIAMCleansUpSuperseded(t *testing.T) {
+	s := &standIn{}
+	srv := awsStandIn(t, s)
+	defer srv.Close()
+
+	p, _ := New(ProviderAWSIAM, Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, Options{Endpoint: srv.URL})
+	listener := []string{"arn:aws:elasticloadbalancing:us-east-1:1:listener/app/web/1/2"}
+	ctx := context.Background()
+
+	state, _, err := Deploy(ctx, p, testCert("fp1"), listener, State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.actions()
+
+	// Bind fails on renewal: the new upload is kept, the old one awaits deletion
+	s.failOn = "ModifyListener"
+	failed, _, err := Deploy(ctx, p, testCert("fp2"), listener, state)
+	if err == nil {
+		t.Fatal("expected bind error")
+	}
+	if failed.Superseded != state.CertID || failed.Fingerprint != "fp2" {
+		t.Errorf("failed state = %+v", failed)
+	}
+	if got := s.actions(); got != "UploadServerCertificate,ModifyListener" {
+		t.Errorf("failed renewal calls = %s", got)
+	}
+
+	// Retry: no second upload, and the superseded certificate is removed
+	s.failOn = ""
+	final, notes, err := Deploy(ctx, p, testCert("fp2"), listener, failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.actions(); got != "ModifyListener,DeleteServerCertificate" {
+		t.Errorf("retry calls = %s", got)
+	}
+	if final.Superseded != "" || final.CertID != failed.CertID {
+		t.Errorf("final state = %+v", final)
+	}
+	if !strings.Contains(strings.Join(notes, ";"), "deleted superseded") {
+		t.Errorf("notes = %v", notes)
+	}
+}
+
+func TestDeployAliyun(t *testing.T) {
+	s := &standIn{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		params := map[string]string{}
+		for k := range r.PostForm {
+			if k != "Signature" {
+				params[k] = r.PostForm.Get(k)
+			}
+		}
+		if signAliyunRPC(http.MethodPost, params, "secret") != r.PostForm.Get("Signature") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Code":"SignatureDoesNotMatch"}`)
+			return
+		}
+
+		action := r.PostForm.Get("Action")
+		fail, id := s.record(action)
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"Code":"Forbidden","Message":"denied"}`)
+			return
+		}
+		switch action {
+		case "UploadUserCertificate":
+			fmt.Fprintf(w, `{"CertId":%s}`, id)
+		case "UploadServerCertificate":
+			fmt.Fprintf(w, `{"ServerCertificateId":"sc-%s"}`, id)
+		default:
+			fmt.Fprint(w, `{"RequestId":"r"}`)
+		}
+	}))
+	defer srv.Close()
+
+	creds := Credentials{AccessKeyID: "id", SecretAccessKey: "secret", Region: "cn-shanghai"}
+	ctx := context.Background()
+
+	cdn, _ := New(ProviderAliyunCDN, creds, Options{Endpoint: srv.URL})
+	state, _, err := Deploy(ctx, cdn, testCert("fp1"), []string{"a.example.com", "b.example.com"}, State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.actions(); got != "UploadUserCertificate,SetCdnDomainSSLCertificate,SetCdnDomainSSLCertificate" {
+		t.Errorf("cdn calls = %s", got)
+	}
+
+	// Delete failure is reported but does not fail the deployment
+	s.failOn = "DeleteUserCertificate"
+	_, notes, err := Deploy(ctx, cdn, testCert("fp2"), []string{"a.example.com"}, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(notes, ";"), "warning: failed to delete") {
+		t.Errorf("notes = %v", notes)
+	}
+	s.actions()
+	s.failOn = ""
+
+	slb, _ := New(ProviderAliyunSLB, creds, Options{Endpoint: srv.URL})
+	state, _, err = Deploy(ctx, slb, testCert("fp1"), []string{"lb-1:443"}, State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Deploy(ctx, slb, testCert("fp2"), []string{"lb-1:443"}, state); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.actions(); got != "UploadServerCertificate,SetLoadBalancerHTTPSListenerAttribute,UploadServerCertificate,SetLoadBalancerHTTPSListenerAttribute,DeleteServerCertificate" {
+		t.Errorf("slb calls = %s", got)
+	}
+
+	if _, _, err := Deploy(ctx, slb, testCert("fp3"), []string{"lb-1"}, State{}); err == nil {
+		t.Error("expected invalid listener error")
+	}
+}
+
+func TestDeployTencent(t *testing.T) {
+	s := &standIn{}
+	var lastBind map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "TC3-HMAC-SHA256 Credential=AKID/") {
+			t.Errorf("missing TC3 authorization: %q", r.Header.Get("Authorization"))
+		}
+		action := r.Header.Get("X-TC-Action")
+		fail, id := s.record(action)
+		if fail {
+			fmt.Fprint(w, `{"Response":{"Error":{"Code":"FailedOperation","Message":"nope"},"RequestId":"r"}}`)
+			return
+		}
+		switch action {
+		case "UploadCertificate":
+			fmt.Fprintf(w, `{"Response":{"CertificateId":"cert-%s","RequestId":"r"}}`, id)
+		case "DeployCertificateInstance":
+			json.NewDecoder(r.Body).Decode(&lastBind)
+			fmt.Fprint(w, `{"Response":{"DeployRecordId":1,"RequestId":"r"}}`)
+		default:
+			fmt.Fprint(w, `{"Response":{"RequestId":"r"}}`)
+		}
+	}))
+	defer srv.Close()
+
+	p, _ := New(ProviderTencentSSL, Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, Options{Endpoint: srv.URL, ResourceType: "cdn"})
+	ctx := context.Background()
+
+	state, _, err := Deploy(ctx, p, testCert("fp1"), []string{"www.example.com"}, State{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastBind["ResourceType"] != "cdn" || lastBind["CertificateId"] != state.CertID {
+		t.Errorf("bind request = %v", lastBind)
+	}
+
+	s.failOn = "UploadCertificate"
+	_, _, err = Deploy(ctx, p, testCert("fp2"), []string{"www.example.com"}, state)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "FailedOperation" {
+		t.Fatalf("err = %v, want FailedOperation", err)
+	}
+}
+
+func TestNewUnknownProvider(t *testing.T) {
+	if _, err := New("gcp", Credentials{}, Options{}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("err = %v", err)
+	}
+	if VendorOf(ProviderAliyunSLB) != VendorAliyun || VendorOf("x") != "" {
+		t.Error("VendorOf mismatch")
+	}
+}
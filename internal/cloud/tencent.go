@@ -0,0 +1,152 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tencentSSLHost    = "ssl.tencentcloudapi.com"
+	tencentSSLVersion = "2019-12-05"
+)
+
+// tencentSSL uploads to Tencent Cloud SSL Certificates and deploys the
+// certificate to CLB, CDN and other cloud resources of one type.
+type tencentSSL struct {
+	creds        Credentials
+	endpoint     string
+	resourceType string
+	http         *http.Client
+	now          func() time.Time
+}
+
+func newTencentSSL(creds Credentials, opts Options) *tencentSSL {
+	endpoint := strings.TrimRight(opts.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://" + tencentSSLHost
+	}
+	resourceType := opts.ResourceType
+	if resourceType == "" {
+		resourceType = "clb"
+	}
+	return &tencentSSL{creds: creds, endpoint: endpoint, resourceType: resourceType, http: opts.HTTPClient, now: time.Now}
+}
+
+func (p *tencentSSL) call(ctx context.Context, action string, in map[string]any, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", tencentSSLVersion)
+	if p.creds.Region != "" {
+		req.Header.Set("X-TC-Region", p.creds.Region)
+	}
+	signTC3(req, body, p.creds, "ssl", p.now())
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	// Errors come back with HTTP 200 and Response.Error set
+	var envelope struct {
+		Response json.RawMessage `json:"Response"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("%s returned %d", action, resp.StatusCode)
+	}
+	var e struct {
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if json.Unmarshal(envelope.Response, &e) == nil && e.Error != nil {
+		return &APIError{Code: e.Error.Code, Message: e.Error.Message}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %d", action, resp.StatusCode)
+	}
+	if out != nil {
+		return json.Unmarshal(envelope.Response, out)
+	}
+	return nil
+}
+
+// signTC3 adds the TC3-HMAC-SHA256 Authorization and X-TC-Timestamp headers
+func signTC3(req *http.Request, body []byte, creds Credentials, service string, now time.Time) {
+	ts := now.UTC().Unix()
+	date := now.UTC().Format("2006-01-02")
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(ts, 10))
+
+	contentType := req.Header.Get("Content-Type")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		req.URL.RawQuery,
+		"content-type:" + contentType + "\nhost:" + req.URL.Host + "\n",
+		"content-type;host",
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + service + "/tc3_request"
+	stringToSign := "TC3-HMAC-SHA256\n" + strconv.FormatInt(ts, 10) + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("TC3"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=content-type;host, Signature=%s",
+		creds.AccessKeyID, scope, signature))
+}
+
+func (p *tencentSSL) Upload(ctx context.Context, cert *Certificate, _ string) (string, error) {
+	var out struct {
+		CertificateID string `json:"CertificateId"`
+	}
+	err := p.call(ctx, "UploadCertificate", map[string]any{
+		"CertificatePublicKey":  string(cert.FullChain),
+		"CertificatePrivateKey": string(cert.Key),
+		"CertificateType":       "SVR",
+		"Alias":                 cert.Name,
+		"Repeatable":            false, // Identical uploads return the existing ID
+	}, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.CertificateID, nil
+}
+
+// Bind deploys to instances such as "lb-xxx|lbl-xxx" for CLB or a domain for CDN
+func (p *tencentSSL) Bind(ctx context.Context, certID string, instances []string) error {
+	return p.call(ctx, "DeployCertificateInstance", map[string]any{
+		"CertificateId":  certID,
+		"InstanceIdList": instances,
+		"ResourceType":   p.resourceType,
+	}, nil)
+}
+
+func (p *tencentSSL) Delete(ctx context.Context, certID string) error {
+	return p.call(ctx, "DeleteCertificate", map[string]any{"CertificateId": certID}, nil)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,6 +14,15 @@ type Config struct {
 	JWT        JWTConfig        `mapstructure:"jwt"`
 	ACME       ACMEConfig       `mapstructure:"acme"`
 	Encryption EncryptionConfig `mapstructure:"encryption"`
+	Ingress    IngressConfig    `mapstructure:"ingress"`
+	Deployment DeploymentConfig `mapstructure:"deployment"`
+	KeyStorage KeyStorageConfig `mapstructure:"key_storage"`
+	OIDC       OIDCConfig       `mapstructure:"oidc"`
+	LDAP       LDAPConfig       `mapstructure:"ldap"`
+	MFA        MFAConfig        `mapstructure:"mfa"`
+	Password   PasswordConfig   `mapstructure:"password"`
+	Audit      AuditConfig      `mapstructure:"audit"`
+	Mail       MailConfig       `mapstructure:"mail"`
 }
 
 type ACMEConfig struct {
@@ -25,17 +35,238 @@ type DNSConfig struct {
 }
 
 type EncryptionConfig struct {
-	MasterKey string `mapstructure:"master_key"` // 32-byte hex-encoded key for AES-256-GCM
+	MasterKey string            `mapstructure:"master_key"` // 32-byte hex-encoded key for AES-256-GCM, key ID "master"
+	Keys      map[string]string `mapstructure:"keys"`       // Additional keys by ID, for rotation
+	ActiveKey string            `mapstructure:"active_key"` // Key ID for new ciphertexts; default "master"
+}
+
+// Enabled reports whether any encryption key is configured
+func (e *EncryptionConfig) Enabled() bool {
+	return e.MasterKey != "" || len(e.Keys) > 0
+}
+
+// Keyring returns every configured key by ID, master_key included, and the active key ID
+func (e *EncryptionConfig) Keyring() (map[string]string, string) {
+	keys := make(map[string]string, len(e.Keys)+1)
+	for id, key := range e.Keys {
+		keys[id] = key
+	}
+	if e.MasterKey != "" {
+		keys["master"] = e.MasterKey
+	}
+
+	active := e.ActiveKey
+	if active == "" {
+		active = "master"
+	}
+	return keys, active
+}
+
+// IngressConfig enables the controller that issues certificates for annotated
+// Kubernetes Ingresses and Gateways
+type IngressConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Kubeconfig string `mapstructure:"kubeconfig"` // Path to a kubeconfig file; empty = in-cluster service account
+	Namespace  string `mapstructure:"namespace"`  // Watch one namespace only; empty = all namespaces
+	Interval   string `mapstructure:"interval"`   // Resync interval, e.g. "1m"
+	Email      string `mapstructure:"email"`      // ACME account email unless set per resource
+
+	// Workspaces each namespace may issue into; annotations naming any
+	// other workspace are ignored. Empty = nothing is issued.
+	Workspaces []IngressWorkspaceBinding `mapstructure:"workspaces"`
+}
+
+// IngressWorkspaceBinding lets resources in a namespace issue certificates in a workspace
+type IngressWorkspaceBinding struct {
+	Namespace   string `mapstructure:"namespace"`
+	WorkspaceID uint   `mapstructure:"workspace_id"`
+}
+
+// DeploymentConfig limits what push deployment targets may reach
+type DeploymentConfig struct {
+	// Namespaces workspace users may write Secrets to with the console's own
+	// service account (in_cluster targets). System admins may use any
+	// namespace; empty = admins only.
+	InClusterNamespaces []string `mapstructure:"in_cluster_namespaces"`
+}
+
+// KeyStorageConfig selects where new certificate private keys are kept.
+// Keys already issued stay in the backend that generated them.
+type KeyStorageConfig struct {
+	Backend string             `mapstructure:"backend"` // database (default), vault or pkcs11
+	Vault   VaultStorageConfig `mapstructure:"vault"`
+	PKCS11  PKCS11Config       `mapstructure:"pkcs11"`
+}
+
+type VaultStorageConfig struct {
+	Address    string `mapstructure:"address"`
+	Token      string `mapstructure:"token"`
+	Namespace  string `mapstructure:"namespace"`
+	Mode       string `mapstructure:"mode"`        // transit (default) or kv
+	Mount      string `mapstructure:"mount"`       // Default "transit" or "secret"
+	KeyName    string `mapstructure:"key_name"`    // Transit key name
+	PathPrefix string `mapstructure:"path_prefix"` // KV path prefix
+	Timeout    string `mapstructure:"timeout"`     // Request timeout, e.g. "10s"
+}
+
+// PKCS11Config needs a binary built with -tags pkcs11
+type PKCS11Config struct {
+	Module     string `mapstructure:"module"` // Path to the PKCS#11 module (.so)
+	TokenLabel string `mapstructure:"token_label"`
+	PIN        string `mapstructure:"pin"`
+	KeyLabel   string `mapstructure:"key_label"`
+}
+
+// OIDCConfig enables single sign-on with OpenID Connect providers
+type OIDCConfig struct {
+	Providers     []OIDCProviderConfig `mapstructure:"providers"`
+	PasswordLogin string               `mapstructure:"password_login"` // all (default) or admins: who may still log in with a local password
+}
+
+// OIDCProviderConfig is one identity provider. Endpoints are discovered from
+// the issuer unless set explicitly.
+type OIDCProviderConfig struct {
+	Name          string   `mapstructure:"name"` // Used in URLs, e.g. "keycloak"
+	DisplayName   string   `mapstructure:"display_name"`
+	Issuer        string   `mapstructure:"issuer"`
+	ClientID      string   `mapstructure:"client_id"`
+	ClientSecret  string   `mapstructure:"client_secret"`
+	RedirectURL   string   `mapstructure:"redirect_url"` // https://<console>/api/v1/auth/oidc/<name>/callback
+	Scopes        []string `mapstructure:"scopes"`       // Default openid, profile, email
+	AuthURL       string   `mapstructure:"auth_url"`
+	TokenURL      string   `mapstructure:"token_url"`
+	UserInfoURL   string   `mapstructure:"userinfo_url"`
+	JWKSURL       string   `mapstructure:"jwks_url"`
+	UsernameClaim string   `mapstructure:"username_claim"` // Default preferred_username, then email
+	GroupsClaim   string   `mapstructure:"groups_claim"`   // Default groups
+
+	AutoProvision bool                   `mapstructure:"auto_provision"` // Create users on first login
+	LinkByEmail   bool                   `mapstructure:"link_by_email"`  // Link first login to a user with the same email, verified by the provider and the console
+	AdminGroups   []string               `mapstructure:"admin_groups"`   // Members become system admins, everyone else a user
+	Workspaces    []WorkspaceMapping `mapstructure:"workspaces"`
+}
+
+// WorkspaceMapping grants a workspace role to members of a directory or
+// identity provider group
+type WorkspaceMapping struct {
+	Group       string `mapstructure:"group"`
+	WorkspaceID uint   `mapstructure:"workspace_id"`
+	Role        string `mapstructure:"role"` // admin, operator, member or viewer
+}
+
+// LDAPConfig enables password login against LDAP or Active Directory.
+// Local accounts keep logging in with their own passwords.
+type LDAPConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	URL                string `mapstructure:"url"`       // ldap://host:389 or ldaps://host:636
+	StartTLS           bool   `mapstructure:"start_tls"` // Upgrade ldap:// connections before binding
+	CAFile             string `mapstructure:"ca_file"`   // PEM CA bundle for the server certificate; empty = system roots
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	Timeout            string `mapstructure:"timeout"` // e.g. "10s"
+
+	BindDN       string `mapstructure:"bind_dn"` // Service account used to search; empty = anonymous search
+	BindPassword string `mapstructure:"bind_password"`
+	BaseDN       string `mapstructure:"base_dn"`
+	UserFilter   string `mapstructure:"user_filter"` // %s is the escaped username, default (sAMAccountName=%s)
+
+	NicknameAttribute string `mapstructure:"nickname_attribute"` // Default displayName
+	EmailAttribute    string `mapstructure:"email_attribute"`    // Default mail
+	GroupAttribute    string `mapstructure:"group_attribute"`    // Default memberOf
+
+	AdminGroups []string           `mapstructure:"admin_groups"` // Group DNs or CNs; sets admin/user on every login
+	Workspaces  []WorkspaceMapping `mapstructure:"workspaces"`
+}
+
+// MFAConfig configures second factors. TOTP needs the encryption key, which
+// protects the shared secrets; passkeys need the relying party settings.
+type MFAConfig struct {
+	Issuer   string         `mapstructure:"issuer"` // Account label in authenticator apps, default "ACME Console"
+	WebAuthn WebAuthnConfig `mapstructure:"webauthn"`
+}
+
+// WebAuthnConfig identifies the console to passkeys and security keys
+type WebAuthnConfig struct {
+	RPID          string   `mapstructure:"rp_id"` // The console's domain, e.g. "console.example.com"
+	RPDisplayName string   `mapstructure:"rp_display_name"`
+	RPOrigins     []string `mapstructure:"rp_origins"` // e.g. ["https://console.example.com"]
+}
+
+// PasswordConfig extends the built-in password checks
+type PasswordConfig struct {
+	// Optional file of breached passwords, one per line: plain text or
+	// uppercase SHA-1 hashes (the "HASH:count" format of Pwned Passwords
+	// downloads is accepted). Checked in addition to a built-in list.
+	BreachedList string `mapstructure:"breached_list"`
+}
+
+// AuditConfig streams audit and security events to syslog servers, SIEMs or
+// files in addition to the audit log table
+type AuditConfig struct {
+	BufferSize int               `mapstructure:"buffer_size"` // Events queued per sink while its collector is slow or down, default 10000
+	Sinks      []AuditSinkConfig `mapstructure:"sinks"`
+}
+
+// AuditSinkConfig is one destination
+type AuditSinkConfig struct {
+	Type string `mapstructure:"type"` // syslog or file
+
+	// syslog: RFC 5424 messages whose body is json (default), cef or leef
+	Network            string `mapstructure:"network"` // udp, tcp (default) or tls
+	Address            string `mapstructure:"address"` // host:port
+	Format             string `mapstructure:"format"`
+	Facility           string `mapstructure:"facility"` // Default local0
+	AppName            string `mapstructure:"app_name"` // Default acme-console
+	CAFile             string `mapstructure:"ca_file"`  // PEM CA bundle for tls; empty = system roots
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	Timeout            string `mapstructure:"timeout"` // e.g. "10s"
+
+	// file: one JSON object per line
+	Path       string `mapstructure:"path"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"` // Default 100
+	MaxBackups int    `mapstructure:"max_backups"` // Default 5
+}
+
+// MailConfig is the SMTP server used for workspace invitations
+type MailConfig struct {
+	Host               string `mapstructure:"host"` // Empty disables email
+	Port               int    `mapstructure:"port"` // Default 587, or 465 for tls
+	TLS                string `mapstructure:"tls"`  // starttls (default), tls or none
+	Username           string `mapstructure:"username"`
+	Password           string `mapstructure:"password"`
+	From               string `mapstructure:"from"`        // e.g. "ACME Console <acme@example.com>"
+	ConsoleURL         string `mapstructure:"console_url"` // Links in emails point here, e.g. "https://console.example.com"
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	Timeout            string `mapstructure:"timeout"` // e.g. "10s"
 }
 
 type JWTConfig struct {
 	Secret   string `mapstructure:"secret"`
-	ExpireHours int `mapstructure:"expire_hours"`
+	ExpireHours int `mapstructure:"expire_hours"` // Idle session lifetime: refresh tokens expire after this long unused
+	AccessTokenMinutes int `mapstructure:"access_token_minutes"` // Default 15
+}
+
+// AccessTokenTTL is the lifetime of a console JWT
+func (j *JWTConfig) AccessTokenTTL() time.Duration {
+	if j.AccessTokenMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(j.AccessTokenMinutes) * time.Minute
+}
+
+// SessionTTL is how long a session survives without a refresh
+func (j *JWTConfig) SessionTTL() time.Duration {
+	if j.ExpireHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(j.ExpireHours) * time.Hour
 }
 
 type ServerConfig struct {
 	Host string `mapstructure:"host"`
 	Port int    `mapstructure:"port"`
+	// Reverse proxies (IPs or CIDRs) whose X-Forwarded-For is believed; empty
+	// = none, client IPs are the connecting address
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type DatabaseConfig struct {
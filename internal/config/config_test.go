@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDatabaseConfig_DSN(t *testing.T) {
@@ -21,6 +22,24 @@ func TestDatabaseConfig_DSN(t *testing.T) {
 	}
 }
 
+func TestJWTConfig_TTLs(t *testing.T) {
+	var cfg JWTConfig
+	if got := cfg.AccessTokenTTL(); got != 15*time.Minute {
+		t.Errorf("default AccessTokenTTL() = %v, want 15m", got)
+	}
+	if got := cfg.SessionTTL(); got != 24*time.Hour {
+		t.Errorf("default SessionTTL() = %v, want 24h", got)
+	}
+
+	cfg = JWTConfig{ExpireHours: 8, AccessTokenMinutes: 5}
+	if got := cfg.AccessTokenTTL(); got != 5*time.Minute {
+		t.Errorf("AccessTokenTTL() = %v, want 5m", got)
+	}
+	if got := cfg.SessionTTL(); got != 8*time.Hour {
+		t.Errorf("SessionTTL() = %v, want 8h", got)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	// Create a temporary config file
 	tmpDir := t.TempDir()
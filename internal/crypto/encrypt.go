@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 var (
@@ -16,37 +17,87 @@ var (
 	ErrDecryptionFailed   = errors.New("decryption failed")
 )
 
-// Encryptor provides AES-256-GCM encryption and decryption for sensitive data
+var (
+	ErrInvalidKeyID = errors.New("key ID must be 1-32 characters of a-z, 0-9, '-' or '_'")
+	ErrNoActiveKey  = errors.New("active key is not in the keyring")
+	ErrUnknownKey   = errors.New("ciphertext was encrypted with a key that is not in the keyring")
+)
+
+// LegacyKeyID names the key configured as encryption.master_key. Ciphertexts
+// written before key IDs existed carry no prefix and are decrypted with it.
+const LegacyKeyID = "master"
+
+// Encryptor provides AES-256-GCM encryption and decryption for sensitive data.
+// It holds a keyring: new ciphertexts use the active key and are framed as
+// "<key id>:<hex>", with the key ID bound as additional authenticated data,
+// while older ciphertexts keep decrypting with the key they name.
 type Encryptor struct {
-	key []byte
+	keys     map[string][]byte
+	activeID string
 }
 
 // NewEncryptor creates a new Encryptor with the provided master key.
 // The key must be a 32-byte hex-encoded string (64 characters).
 func NewEncryptor(masterKeyHex string) (*Encryptor, error) {
-	key, err := hex.DecodeString(masterKeyHex)
-	if err != nil {
-		return nil, fmt.Errorf("invalid hex key: %w", err)
+	return NewKeyring(map[string]string{LegacyKeyID: masterKeyHex}, LegacyKeyID)
+}
+
+// NewKeyring creates an Encryptor from hex-encoded keys by ID. activeID selects
+// the key used for new ciphertexts.
+func NewKeyring(keysHex map[string]string, activeID string) (*Encryptor, error) {
+	keys := make(map[string][]byte, len(keysHex))
+	for id, keyHex := range keysHex {
+		if !validKeyID(id) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidKeyID, id)
+		}
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex key %q: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q: %w", id, ErrInvalidKeyLength)
+		}
+		keys[id] = key
 	}
 
-	if len(key) != 32 {
-		return nil, ErrInvalidKeyLength
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoActiveKey, activeID)
 	}
 
-	return &Encryptor{key: key}, nil
+	return &Encryptor{keys: keys, activeID: activeID}, nil
 }
 
-// Encrypt encrypts plaintext using AES-256-GCM.
-// Returns a hex-encoded string containing nonce + ciphertext.
-func (e *Encryptor) Encrypt(plaintext []byte) (string, error) {
-	block, err := aes.NewCipher(e.key)
+// ActiveKeyID returns the ID of the key used for new ciphertexts
+func (e *Encryptor) ActiveKeyID() string {
+	return e.activeID
+}
+
+// KeyID returns the ID of the key a ciphertext was encrypted with
+func (e *Encryptor) KeyID(ciphertext string) string {
+	id, _ := splitCiphertext(ciphertext)
+	return id
+}
+
+// IsCurrent reports whether a ciphertext is framed with the active key
+func (e *Encryptor) IsCurrent(ciphertext string) bool {
+	return strings.HasPrefix(ciphertext, e.activeID+":")
+}
+
+// Rewrap decrypts a ciphertext and encrypts it again with the active key
+func (e *Encryptor) Rewrap(ciphertext string) (string, error) {
+	plaintext, err := e.Decrypt(ciphertext)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return "", err
 	}
+	return e.Encrypt(plaintext)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// Encrypt encrypts plaintext using AES-256-GCM with the active key.
+// Returns the key ID and the hex-encoded nonce + ciphertext.
+func (e *Encryptor) Encrypt(plaintext []byte) (string, error) {
+	gcm, err := newGCM(e.keys[e.activeID])
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return "", err
 	}
 
 	// Generate random nonce
@@ -56,25 +107,27 @@ func (e *Encryptor) Encrypt(plaintext []byte) (string, error) {
 	}
 
 	// Encrypt and prepend nonce
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return hex.EncodeToString(ciphertext), nil
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, []byte(e.activeID))
+	return e.activeID + ":" + hex.EncodeToString(ciphertext), nil
 }
 
-// Decrypt decrypts a hex-encoded ciphertext that was encrypted with Encrypt.
+// Decrypt decrypts a ciphertext that was encrypted with Encrypt, by any key in
+// the keyring, or an un-prefixed one written before key IDs existed.
 func (e *Encryptor) Decrypt(ciphertextHex string) ([]byte, error) {
-	ciphertext, err := hex.DecodeString(ciphertextHex)
-	if err != nil {
-		return nil, fmt.Errorf("invalid hex ciphertext: %w", err)
+	id, body := splitCiphertext(ciphertextHex)
+	key, ok := e.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
 	}
 
-	block, err := aes.NewCipher(e.key)
+	ciphertext, err := hex.DecodeString(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("invalid hex ciphertext: %w", err)
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	if len(ciphertext) < gcm.NonceSize() {
@@ -85,7 +138,13 @@ func (e *Encryptor) Decrypt(ciphertextHex string) ([]byte, error) {
 	nonce := ciphertext[:gcm.NonceSize()]
 	ciphertext = ciphertext[gcm.NonceSize():]
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	// Legacy ciphertexts were sealed without additional data
+	var additionalData []byte
+	if body != ciphertextHex {
+		additionalData = []byte(id)
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, ErrDecryptionFailed
 	}
@@ -93,12 +152,47 @@ func (e *Encryptor) Decrypt(ciphertextHex string) ([]byte, error) {
 	return plaintext, nil
 }
 
-// EncryptString encrypts a string and returns hex-encoded ciphertext.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// splitCiphertext separates the key ID from the hex body. Hex never contains
+// ':', so a value without one is a legacy ciphertext of the master key.
+func splitCiphertext(ciphertext string) (string, string) {
+	id, body, ok := strings.Cut(ciphertext, ":")
+	if !ok {
+		return LegacyKeyID, ciphertext
+	}
+	return id, body
+}
+
+func validKeyID(id string) bool {
+	if len(id) == 0 || len(id) > 32 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// EncryptString encrypts a string and returns the framed ciphertext.
 func (e *Encryptor) EncryptString(plaintext string) (string, error) {
 	return e.Encrypt([]byte(plaintext))
 }
 
-// DecryptString decrypts hex-encoded ciphertext and returns the original string.
+// DecryptString decrypts a ciphertext and returns the original string.
 func (e *Encryptor) DecryptString(ciphertextHex string) (string, error) {
 	plaintext, err := e.Decrypt(ciphertextHex)
 	if err != nil {
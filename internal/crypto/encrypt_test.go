@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	testKeyA = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testKeyB = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+)
+
+// legacyEncrypt produces the un-prefixed format written before key IDs existed
+func legacyEncrypt(t *testing.T, keyHex string, plaintext []byte) string {
+	t.Helper()
+	key, _ := hex.DecodeString(keyHex)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return hex.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil))
+}
+
+func TestEncryptorFraming(t *testing.T) {
+	enc, err := NewEncryptor(testKeyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := enc.EncryptString("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ct, LegacyKeyID+":") || !enc.IsCurrent(ct) {
+		t.Errorf("ciphertext %q is not framed with the master key", ct)
+	}
+	if pt, err := enc.DecryptString(ct); err != nil || pt != "secret" {
+		t.Errorf("round trip = %q, %v", pt, err)
+	}
+
+	legacy := legacyEncrypt(t, testKeyA, []byte("old secret"))
+	if pt, err := enc.DecryptString(legacy); err != nil || pt != "old secret" {
+		t.Errorf("legacy decrypt = %q, %v", pt, err)
+	}
+	if enc.IsCurrent(legacy) || enc.KeyID(legacy) != LegacyKeyID {
+		t.Error("legacy ciphertext should need rotation")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, _ := NewEncryptor(testKeyA)
+	oldCT, _ := old.EncryptString("secret")
+	legacy := legacyEncrypt(t, testKeyA, []byte("secret"))
+
+	ring, err := NewKeyring(map[string]string{LegacyKeyID: testKeyA, "2026-10": testKeyB}, "2026-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ct := range []string{oldCT, legacy} {
+		rewrapped, err := ring.Rewrap(ct)
+		if err != nil {
+			t.Fatalf("Rewrap: %v", err)
+		}
+		if ring.KeyID(rewrapped) != "2026-10" || !ring.IsCurrent(rewrapped) {
+			t.Errorf("rewrapped with %q", ring.KeyID(rewrapped))
+		}
+		if pt, _ := ring.DecryptString(rewrapped); pt != "secret" {
+			t.Errorf("rewrapped plaintext = %q", pt)
+		}
+	}
+
+	// Dropping the old key leaves its ciphertexts unreadable
+	newOnly, _ := NewKeyring(map[string]string{"2026-10": testKeyB}, "2026-10")
+	if _, err := newOnly.Decrypt(oldCT); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestKeyIDIsAuthenticated(t *testing.T) {
+	// Two IDs for the same key: relabeling a ciphertext must not decrypt
+	ring, _ := NewKeyring(map[string]string{"a": testKeyA, "b": testKeyA}, "a")
+	ct, _ := ring.EncryptString("secret")
+	if _, err := ring.Decrypt("b" + strings.TrimPrefix(ct, "a")); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("err = %v, want ErrDecryptionFailed", err)
+	}
+}
+
+func TestNewKeyringValidation(t *testing.T) {
+	if _, err := NewKeyring(map[string]string{"a": testKeyA}, "b"); !errors.Is(err, ErrNoActiveKey) {
+		t.Errorf("missing active key: err = %v", err)
+	}
+	if _, err := NewKeyring(map[string]string{"Bad:ID": testKeyA}, "Bad:ID"); !errors.Is(err, ErrInvalidKeyID) {
+		t.Errorf("invalid ID: err = %v", err)
+	}
+	if _, err := NewKeyring(map[string]string{"a": "abcd"}, "a"); !errors.Is(err, ErrInvalidKeyLength) {
+		t.Errorf("short key: err = %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// TLSRequest is one TLS Secret an Ingress or Gateway expects, with the hosts it must cover
+type TLSRequest struct {
+	Kind        string // Ingress or Gateway
+	Namespace   string // Namespace of the resource
+	Name        string // Name of the resource
+	SecretName  string
+	Hosts       []string
+	Annotations map[string]string // Annotations of the resource
+}
+
+// Source identifies the resource and Secret, e.g. "Ingress default/web:web-tls"
+func (r *TLSRequest) Source() string {
+	return fmt.Sprintf("%s %s/%s:%s", r.Kind, r.Namespace, r.Name, r.SecretName)
+}
+
+type ingressList struct {
+	Items []struct {
+		Metadata kubeMeta `json:"metadata"`
+		Spec     struct {
+			TLS []struct {
+				Hosts      []string `json:"hosts"`
+				SecretName string   `json:"secretName"`
+			} `json:"tls"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+type gatewayList struct {
+	Items []struct {
+		Metadata kubeMeta `json:"metadata"`
+		Spec     struct {
+			Listeners []struct {
+				Hostname string `json:"hostname"`
+				TLS      *struct {
+					Mode            string `json:"mode"`
+					CertificateRefs []struct {
+						Kind      string `json:"kind"`
+						Name      string `json:"name"`
+						Namespace string `json:"namespace"`
+					} `json:"certificateRefs"`
+				} `json:"tls"`
+			} `json:"listeners"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// ListTLSRequests lists the TLS Secrets referenced by Ingresses and Gateways
+// that carry the given annotation. target.Namespace limits the scan to one
+// namespace; empty means all namespaces. Clusters without the Gateway API
+// CRDs are handled by skipping Gateways.
+func (d *KubernetesDeployer) ListTLSRequests(ctx context.Context, target *KubernetesTarget, annotation string) ([]TLSRequest, error) {
+	client, err := newKubeClient(target)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := ""
+	if target.Namespace != "" {
+		scope = "/namespaces/" + url.PathEscape(target.Namespace)
+	}
+
+	var ingresses ingressList
+	if _, err := client.do(ctx, http.MethodGet, "/apis/networking.k8s.io/v1"+scope+"/ingresses", nil, &ingresses); err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+
+	var requests []TLSRequest
+	for _, ing := range ingresses.Items {
+		if _, ok := ing.Metadata.Annotations[annotation]; !ok {
+			continue
+		}
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == "" || len(tls.Hosts) == 0 {
+				continue
+			}
+			requests = append(requests, TLSRequest{
+				Kind:        "Ingress",
+				Namespace:   ing.Metadata.Namespace,
+				Name:        ing.Metadata.Name,
+				SecretName:  tls.SecretName,
+				Hosts:       normalizeHosts(tls.Hosts),
+				Annotations: ing.Metadata.Annotations,
+			})
+		}
+	}
+
+	var gateways gatewayList
+	status, err := client.do(ctx, http.MethodGet, "/apis/gateway.networking.k8s.io/v1"+scope+"/gateways", nil, &gateways)
+	if status == http.StatusNotFound {
+		return requests, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gateways: %w", err)
+	}
+
+	for _, gw := range gateways.Items {
+		if _, ok := gw.Metadata.Annotations[annotation]; !ok {
+			continue
+		}
+		// Listeners sharing a Secret become one certificate covering all their hostnames
+		hostsBySecret := map[string][]string{}
+		var secrets []string
+		for _, l := range gw.Spec.Listeners {
+			if l.TLS == nil || l.Hostname == "" || len(l.TLS.CertificateRefs) == 0 {
+				continue
+			}
+			if l.TLS.Mode != "" && l.TLS.Mode != "Terminate" {
+				continue
+			}
+			ref := l.TLS.CertificateRefs[0]
+			if (ref.Kind != "" && ref.Kind != "Secret") || (ref.Namespace != "" && ref.Namespace != gw.Metadata.Namespace) {
+				continue // Cross-namespace refs need a ReferenceGrant we do not manage
+			}
+			if _, seen := hostsBySecret[ref.Name]; !seen {
+				secrets = append(secrets, ref.Name)
+			}
+			hostsBySecret[ref.Name] = append(hostsBySecret[ref.Name], l.Hostname)
+		}
+		for _, secret := range secrets {
+			requests = append(requests, TLSRequest{
+				Kind:        "Gateway",
+				Namespace:   gw.Metadata.Namespace,
+				Name:        gw.Metadata.Name,
+				SecretName:  secret,
+				Hosts:       normalizeHosts(hostsBySecret[secret]),
+				Annotations: gw.Metadata.Annotations,
+			})
+		}
+	}
+	return requests, nil
+}
+
+// normalizeHosts lower-cases, de-duplicates and sorts hosts so changes can be detected by comparison
+func normalizeHosts(hosts []string) []string {
+	seen := make(map[string]bool, len(hosts))
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		out = append(out, h)
+	}
+	sort.Strings(out)
+	return out
+}
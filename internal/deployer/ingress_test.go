@@ -0,0 +1,100 @@
+package deployer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testIngresses = `{"items": [
+  {"metadata": {"name": "web", "namespace": "shop", "annotations": {"acme-console/workspace": "3"}},
+   "spec": {"tls": [
+     {"hosts": ["WWW.example.com", "example.com", "www.example.com"], "secretName": "web-tls"},
+     {"hosts": ["api.example.com"]}
+   ]}},
+  {"metadata": {"name": "other", "namespace": "shop"},
+   "spec": {"tls": [{"hosts": ["other.example.com"], "secretName": "other-tls"}]}}
+]}`
+
+const testGateways = `{"items": [
+  {"metadata": {"name": "edge", "namespace": "infra", "annotations": {"acme-console/workspace": "3"}},
+   "spec": {"listeners": [
+     {"hostname": "a.example.com", "tls": {"certificateRefs": [{"name": "edge-tls"}]}},
+     {"hostname": "b.example.com", "tls": {"mode": "Terminate", "certificateRefs": [{"kind": "Secret", "name": "edge-tls"}]}},
+     {"hostname": "c.example.com", "tls": {"mode": "Passthrough"}},
+     {"hostname": "d.example.com", "tls": {"certificateRefs": [{"name": "shared", "namespace": "certs"}]}},
+     {"hostname": "plain.example.com"}
+   ]}}
+]}`
+
+func TestListTLSRequests(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apis/networking.k8s.io/v1/ingresses":
+			w.Write([]byte(testIngresses))
+		case "/apis/gateway.networking.k8s.io/v1/gateways":
+			w.Write([]byte(testGateways))
+		default:
+			writeStatus(w, http.StatusNotFound, "not found")
+		}
+	}))
+	defer srv.Close()
+
+	requests, err := NewKubernetesDeployer().ListTLSRequests(context.Background(), &KubernetesTarget{
+		Kubeconfig: testKubeconfig(srv, "sa-token"),
+	}, "acme-console/workspace")
+	if err != nil {
+		t.Fatalf("ListTLSRequests: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2: %+v", len(requests), requests)
+	}
+
+	ing := requests[0]
+	if ing.Source() != "Ingress shop/web:web-tls" {
+		t.Errorf("source = %q", ing.Source())
+	}
+	if want := []string{"example.com", "www.example.com"}; !reflect.DeepEqual(ing.Hosts, want) {
+		t.Errorf("ingress hosts = %v, want %v", ing.Hosts, want)
+	}
+	if ing.Annotations["acme-console/workspace"] != "3" {
+		t.Errorf("annotations = %v", ing.Annotations)
+	}
+
+	gw := requests[1]
+	if gw.Source() != "Gateway infra/edge:edge-tls" {
+		t.Errorf("source = %q", gw.Source())
+	}
+	if want := []string{"a.example.com", "b.example.com"}; !reflect.DeepEqual(gw.Hosts, want) {
+		t.Errorf("gateway hosts = %v, want %v", gw.Hosts, want)
+	}
+}
+
+func TestListTLSRequestsWithoutGatewayAPI(t *testing.T) {
+	var paths []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/apis/networking.k8s.io/v1/namespaces/shop/ingresses" {
+			w.Write([]byte(testIngresses))
+			return
+		}
+		writeStatus(w, http.StatusNotFound, "the server could not find the requested resource")
+	}))
+	defer srv.Close()
+
+	requests, err := NewKubernetesDeployer().ListTLSRequests(context.Background(), &KubernetesTarget{
+		Kubeconfig: testKubeconfig(srv, "sa-token"),
+		Namespace:  "shop",
+	}, "acme-console/workspace")
+	if err != nil {
+		t.Fatalf("ListTLSRequests: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(requests))
+	}
+	if paths[1] != "/apis/gateway.networking.k8s.io/v1/namespaces/shop/gateways" {
+		t.Errorf("gateway path = %q", paths[1])
+	}
+}
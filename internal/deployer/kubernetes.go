@@ -0,0 +1,396 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	ErrInvalidKubeconfig = errors.New("invalid kubeconfig")
+	ErrNotInCluster      = errors.New("not running inside a Kubernetes cluster")
+	ErrSecretTypeInUse   = errors.New("secret exists with a different type")
+	ErrSecretNotManaged  = errors.New("secret exists and is not managed by acme-console")
+)
+
+const (
+	SecretTypeTLS = "kubernetes.io/tls"
+
+	LabelManagedBy    = "app.kubernetes.io/managed-by"
+	ManagedByValue    = "acme-console"
+	LabelCertificate  = "acme-console/certificate-id"
+	AnnotFingerprint  = "acme-console/fingerprint"
+	AnnotExpiresAt    = "acme-console/expires-at"
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// KubernetesTarget describes where to write the Secret
+type KubernetesTarget struct {
+	Kubeconfig    []byte // Used when set; otherwise InCluster must be true
+	InCluster     bool
+	Namespace     string
+	SecretName    string
+	AdoptExisting bool // Update a Secret that lacks the managed-by label
+	Timeout       time.Duration
+}
+
+// TLSSecret is the content to store; CA is optional
+type TLSSecret struct {
+	Cert        []byte // tls.crt (full chain)
+	Key         []byte // tls.key
+	CA          []byte // ca.crt
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// KubernetesDeployer creates or updates kubernetes.io/tls Secrets through the
+// core/v1 REST API, without depending on client-go.
+type KubernetesDeployer struct{}
+
+func NewKubernetesDeployer() *KubernetesDeployer {
+	return &KubernetesDeployer{}
+}
+
+// SyncSecret creates the Secret, or replaces its TLS data, labels and
+// annotations while keeping unrelated keys. Existing Secrets without the
+// managed-by label are left alone unless the target adopts them. Updates carry the resourceVersion
+// that was read, so concurrent edits fail with a conflict instead of being
+// silently overwritten.
+func (d *KubernetesDeployer) SyncSecret(ctx context.Context, target *KubernetesTarget, secret *TLSSecret) (created bool, err error) {
+	client, err := newKubeClient(target)
+	if err != nil {
+		return false, err
+	}
+
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets", url.PathEscape(target.Namespace))
+
+	// The existing object is kept as a generic map so fields this client does
+	// not model (ownerReferences, finalizers, ...) survive the update.
+	var existing map[string]any
+	status, err := client.do(ctx, http.MethodGet, path+"/"+url.PathEscape(target.SecretName), nil, &existing)
+	switch {
+	case status == http.StatusNotFound:
+		obj := kubeSecret{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Type:       SecretTypeTLS,
+			Metadata: kubeMeta{
+				Name:        target.SecretName,
+				Namespace:   target.Namespace,
+				Labels:      secret.Labels,
+				Annotations: secret.Annotations,
+			},
+			Data: secret.data(),
+		}
+		if _, err := client.do(ctx, http.MethodPost, path, obj, nil); err != nil {
+			return false, fmt.Errorf("failed to create secret: %w", err)
+		}
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to read secret: %w", err)
+	}
+
+	if t, _ := existing["type"].(string); t != SecretTypeTLS {
+		return false, fmt.Errorf("%w: %s", ErrSecretTypeInUse, t)
+	}
+
+	metadata := childMap(existing, "metadata")
+	labels := childMap(metadata, "labels")
+	if managedBy, _ := labels[LabelManagedBy].(string); managedBy != ManagedByValue && !target.AdoptExisting {
+		return false, ErrSecretNotManaged
+	}
+	for k, v := range secret.Labels {
+		labels[k] = v
+	}
+	annotations := childMap(metadata, "annotations")
+	for k, v := range secret.Annotations {
+		annotations[k] = v
+	}
+	data := childMap(existing, "data")
+	if len(secret.CA) == 0 {
+		delete(data, "ca.crt")
+	}
+	for k, v := range secret.data() {
+		data[k] = v
+	}
+
+	if _, err := client.do(ctx, http.MethodPut, path+"/"+url.PathEscape(target.SecretName), existing, nil); err != nil {
+		return false, fmt.Errorf("failed to update secret: %w", err)
+	}
+	return false, nil
+}
+
+// childMap returns m[key] as a map, creating it when absent
+func childMap(m map[string]any, key string) map[string]any {
+	child, ok := m[key].(map[string]any)
+	if !ok {
+		child = map[string]any{}
+		m[key] = child
+	}
+	return child
+}
+
+func (s *TLSSecret) data() map[string]string {
+	data := map[string]string{
+		"tls.crt": base64.StdEncoding.EncodeToString(s.Cert),
+		"tls.key": base64.StdEncoding.EncodeToString(s.Key),
+	}
+	if len(s.CA) > 0 {
+		data["ca.crt"] = base64.StdEncoding.EncodeToString(s.CA)
+	}
+	return data
+}
+
+type kubeMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type kubeSecret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   kubeMeta          `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string]string `json:"data,omitempty"`
+}
+
+type kubeStatus struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+// kubeClient is a bearer-token or client-certificate authenticated API client
+type kubeClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func (c *kubeClient) do(ctx context.Context, method, path string, body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var st kubeStatus
+		if json.Unmarshal(data, &st) == nil && st.Message != "" {
+			return resp.StatusCode, fmt.Errorf("API server returned %d: %s", resp.StatusCode, st.Message)
+		}
+		return resp.StatusCode, fmt.Errorf("API server returned %d", resp.StatusCode)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func newKubeClient(target *KubernetesTarget) (*kubeClient, error) {
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	var (
+		client *kubeClient
+		tlsCfg *tls.Config
+		err    error
+	)
+	if len(target.Kubeconfig) > 0 {
+		client, tlsCfg, err = clientFromKubeconfig(target.Kubeconfig)
+	} else if target.InCluster {
+		client, tlsCfg, err = clientInCluster()
+	} else {
+		return nil, fmt.Errorf("%w: kubeconfig is required unless in_cluster is set", ErrInvalidKubeconfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+	client.http = &http.Client{Transport: transport, Timeout: timeout}
+	return client, nil
+}
+
+func clientInCluster() (*kubeClient, *tls.Config, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, nil, ErrNotInCluster
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotInCluster, err)
+	}
+	caPEM, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotInCluster, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, nil, fmt.Errorf("%w: invalid service account CA", ErrNotInCluster)
+	}
+
+	return &kubeClient{
+		server: "https://" + net.JoinHostPort(host, port),
+		token:  strings.TrimSpace(string(token)),
+	}, &tls.Config{RootCAs: pool}, nil
+}
+
+// kubeconfig holds the subset of the kubeconfig format needed to reach one cluster
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// ParseKubeconfig checks that a kubeconfig is self-contained and usable
+func ParseKubeconfig(data []byte) error {
+	_, _, err := clientFromKubeconfig(data)
+	return err
+}
+
+// clientFromKubeconfig resolves the current context. Only inline credentials
+// (*-data fields and tokens) are supported, since file paths would refer to
+// the machine the kubeconfig was exported from.
+func clientFromKubeconfig(data []byte) (*kubeClient, *tls.Config, error) {
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidKubeconfig, err)
+	}
+
+	ctxName := kc.CurrentContext
+	if ctxName == "" && len(kc.Contexts) == 1 {
+		ctxName = kc.Contexts[0].Name
+	}
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == ctxName {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("%w: context %q not found", ErrInvalidKubeconfig, ctxName)
+	}
+
+	client := &kubeClient{}
+	tlsCfg := &tls.Config{}
+
+	found = false
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		client.server = strings.TrimRight(c.Cluster.Server, "/")
+		tlsCfg.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		if c.Cluster.CertificateAuthorityData != "" {
+			caPEM, err := base64.StdEncoding.DecodeString(c.Cluster.CertificateAuthorityData)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: certificate-authority-data: %v", ErrInvalidKubeconfig, err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return nil, nil, fmt.Errorf("%w: certificate-authority-data has no certificates", ErrInvalidKubeconfig)
+			}
+			tlsCfg.RootCAs = pool
+		}
+	}
+	if !found || client.server == "" {
+		return nil, nil, fmt.Errorf("%w: cluster %q not found", ErrInvalidKubeconfig, clusterName)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		client.token = u.User.Token
+		if u.User.ClientCertificateData != "" {
+			certPEM, err := base64.StdEncoding.DecodeString(u.User.ClientCertificateData)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: client-certificate-data: %v", ErrInvalidKubeconfig, err)
+			}
+			keyPEM, err := base64.StdEncoding.DecodeString(u.User.ClientKeyData)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: client-key-data: %v", ErrInvalidKubeconfig, err)
+			}
+			pair, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: client certificate: %v", ErrInvalidKubeconfig, err)
+			}
+			tlsCfg.Certificates = []tls.Certificate{pair}
+		}
+	}
+
+	return client, tlsCfg, nil
+}
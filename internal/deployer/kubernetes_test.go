@@ -0,0 +1,263 @@
+package deployer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAPIServer implements the core/v1 Secret endpoints used by SyncSecret
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	token   string
+	secrets map[string]map[string]any // namespace/name -> object
+	version int
+}
+
+func newFakeAPIServer(token string) *fakeAPIServer {
+	return &fakeAPIServer{token: token, secrets: map[string]map[string]any{}}
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+f.token {
+		writeStatus(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// /api/v1/namespaces/{ns}/secrets[/{name}]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 5 || parts[0] != "api" || parts[1] != "v1" || parts[2] != "namespaces" || parts[4] != "secrets" {
+		writeStatus(w, http.StatusNotFound, "not found")
+		return
+	}
+	ns := parts[3]
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 6:
+		obj, ok := f.secrets[ns+"/"+parts[5]]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, fmt.Sprintf("secrets %q not found", parts[5]))
+			return
+		}
+		json.NewEncoder(w).Encode(obj)
+
+	case r.Method == http.MethodPost && len(parts) == 5:
+		var obj map[string]any
+		json.NewDecoder(r.Body).Decode(&obj)
+		name := obj["metadata"].(map[string]any)["name"].(string)
+		if _, ok := f.secrets[ns+"/"+name]; ok {
+			writeStatus(w, http.StatusConflict, "already exists")
+			return
+		}
+		f.store(ns, name, obj)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(obj)
+
+	case r.Method == http.MethodPut && len(parts) == 6:
+		var obj map[string]any
+		json.NewDecoder(r.Body).Decode(&obj)
+		current, ok := f.secrets[ns+"/"+parts[5]]
+		if !ok {
+			writeStatus(w, http.StatusNotFound, "not found")
+			return
+		}
+		if rv(obj) != rv(current) {
+			writeStatus(w, http.StatusConflict, "the object has been modified")
+			return
+		}
+		f.store(ns, parts[5], obj)
+		json.NewEncoder(w).Encode(obj)
+
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (f *fakeAPIServer) store(ns, name string, obj map[string]any) {
+	f.version++
+	obj["metadata"].(map[string]any)["resourceVersion"] = strconv.Itoa(f.version)
+	f.secrets[ns+"/"+name] = obj
+}
+
+func (f *fakeAPIServer) get(ns, name string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.secrets[ns+"/"+name]
+}
+
+func rv(obj map[string]any) string {
+	v, _ := obj["metadata"].(map[string]any)["resourceVersion"].(string)
+	return v
+}
+
+func writeStatus(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{"kind": "Status", "message": msg, "code": code})
+}
+
+func testKubeconfig(srv *httptest.Server, token string) []byte {
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test-cluster
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+users:
+- name: test-user
+  user:
+    token: %s
+contexts:
+- name: test
+  context:
+    cluster: test-cluster
+    user: test-user
+`, srv.URL, base64.StdEncoding.EncodeToString(caPEM), token))
+}
+
+func decodeData(t *testing.T, obj map[string]any, key string) string {
+	t.Helper()
+	v, _ := obj["data"].(map[string]any)[key].(string)
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		t.Fatalf("decode %s: %v", key, err)
+	}
+	return string(b)
+}
+
+func TestSyncSecretCreatesAndUpdates(t *testing.T) {
+	api := newFakeAPIServer("sa-token")
+	srv := httptest.NewTLSServer(api)
+	defer srv.Close()
+
+	target := &KubernetesTarget{
+		Kubeconfig: testKubeconfig(srv, "sa-token"),
+		Namespace:  "web",
+		SecretName: "example-tls",
+	}
+	d := NewKubernetesDeployer()
+
+	created, err := d.SyncSecret(context.Background(), target, &TLSSecret{
+		Cert:        []byte("CHAIN-1"),
+		Key:         []byte("KEY-1"),
+		CA:          []byte("CA-1"),
+		Labels:      map[string]string{LabelManagedBy: ManagedByValue},
+		Annotations: map[string]string{AnnotFingerprint: "fp1"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !created {
+		t.Error("expected secret to be created")
+	}
+
+	obj := api.get("web", "example-tls")
+	if obj["type"] != SecretTypeTLS {
+		t.Errorf("type = %v", obj["type"])
+	}
+	if got := decodeData(t, obj, "tls.crt"); got != "CHAIN-1" {
+		t.Errorf("tls.crt = %q", got)
+	}
+	if got := decodeData(t, obj, "ca.crt"); got != "CA-1" {
+		t.Errorf("ca.crt = %q", got)
+	}
+
+	// Simulate fields added by other controllers, which must survive the update
+	api.mu.Lock()
+	meta := obj["metadata"].(map[string]any)
+	meta["labels"].(map[string]any)["team"] = "web"
+	meta["finalizers"] = []any{"example.com/keep"}
+	obj["data"].(map[string]any)["extra"] = base64.StdEncoding.EncodeToString([]byte("x"))
+	api.mu.Unlock()
+
+	created, err = d.SyncSecret(context.Background(), target, &TLSSecret{
+		Cert:        []byte("CHAIN-2"),
+		Key:         []byte("KEY-2"),
+		Labels:      map[string]string{LabelManagedBy: ManagedByValue},
+		Annotations: map[string]string{AnnotFingerprint: "fp2"},
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if created {
+		t.Error("expected secret to be updated, not created")
+	}
+
+	obj = api.get("web", "example-tls")
+	meta = obj["metadata"].(map[string]any)
+	if got := decodeData(t, obj, "tls.key"); got != "KEY-2" {
+		t.Errorf("tls.key = %q", got)
+	}
+	if _, ok := obj["data"].(map[string]any)["ca.crt"]; ok {
+		t.Error("stale ca.crt should be removed")
+	}
+	if got := decodeData(t, obj, "extra"); got != "x" {
+		t.Error("unrelated data key was dropped")
+	}
+	if meta["labels"].(map[string]any)["team"] != "web" {
+		t.Error("existing label was dropped")
+	}
+	if meta["finalizers"] == nil {
+		t.Error("finalizers were dropped")
+	}
+	if meta["annotations"].(map[string]any)[AnnotFingerprint] != "fp2" {
+		t.Error("fingerprint annotation not updated")
+	}
+}
+
+func TestSyncSecretRejectsOtherTypes(t *testing.T) {
+	api := newFakeAPIServer("sa-token")
+	api.secrets["web/opaque"] = map[string]any{
+		"metadata": map[string]any{"name": "opaque", "resourceVersion": "1"},
+		"type":     "Opaque",
+	}
+	srv := httptest.NewTLSServer(api)
+	defer srv.Close()
+
+	_, err := NewKubernetesDeployer().SyncSecret(context.Background(), &KubernetesTarget{
+		Kubeconfig: testKubeconfig(srv, "sa-token"),
+		Namespace:  "web",
+		SecretName: "opaque",
+	}, &TLSSecret{Cert: []byte("c"), Key: []byte("k")})
+	if !errors.Is(err, ErrSecretTypeInUse) {
+		t.Fatalf("err = %v, want ErrSecretTypeInUse", err)
+	}
+}
+
+func TestSyncSecretUnauthorized(t *testing.T) {
+	api := newFakeAPIServer("sa-token")
+	srv := httptest.NewTLSServer(api)
+	defer srv.Close()
+
+	_, err := NewKubernetesDeployer().SyncSecret(context.Background(), &KubernetesTarget{
+		Kubeconfig: testKubeconfig(srv, "wrong"),
+		Namespace:  "web",
+		SecretName: "example-tls",
+	}, &TLSSecret{Cert: []byte("c"), Key: []byte("k")})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want 401", err)
+	}
+}
+
+func TestParseKubeconfig(t *testing.T) {
+	if err := ParseKubeconfig([]byte("current-context: missing\n")); !errors.Is(err, ErrInvalidKubeconfig) {
+		t.Errorf("err = %v, want ErrInvalidKubeconfig", err)
+	}
+	if err := ParseKubeconfig([]byte(":::")); !errors.Is(err, ErrInvalidKubeconfig) {
+		t.Errorf("err = %v, want ErrInvalidKubeconfig", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package deployer pushes certificate files to remote hosts.
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrHostKeyMismatch = errors.New("remote host key does not match the pinned fingerprint")
+	ErrInvalidPath     = errors.New("remote path must be absolute")
+)
+
+// maxOutput caps how much post-deploy command output is kept
+const maxOutput = 8 << 10
+
+// SSHTarget describes how to reach a host
+type SSHTarget struct {
+	Host               string
+	Port               int
+	User               string
+	PrivateKey         []byte // PEM/OpenSSH private key
+	HostKeyFingerprint string // SHA256:... as printed by ssh-keygen -l; empty trusts on first use
+	Timeout            time.Duration
+}
+
+// File is written to Path atomically with the given permission bits
+type File struct {
+	Path    string
+	Content []byte
+	Mode    uint32
+}
+
+// Result describes a finished push
+type Result struct {
+	HostKeyFingerprint string // Fingerprint presented by the host, to pin on first use
+	Output             string // Combined output of the post-deploy command
+}
+
+// SSHDeployer uploads files over SSH exec sessions, so the remote side only
+// needs a POSIX shell: no SFTP subsystem or extra tooling is required.
+type SSHDeployer struct{}
+
+func NewSSHDeployer() *SSHDeployer {
+	return &SSHDeployer{}
+}
+
+// Push uploads the files and then runs command (if any) on the target.
+// Each file goes to a temp file next to its destination and is renamed into
+// place, so services never read a half-written certificate or key.
+func (d *SSHDeployer) Push(ctx context.Context, target *SSHTarget, files []File, command string) (*Result, error) {
+	for _, f := range files {
+		if !path.IsAbs(f.Path) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, f.Path)
+		}
+	}
+
+	signer, err := ssh.ParsePrivateKey(target.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH private key: %w", err)
+	}
+
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	port := target.Port
+	if port == 0 {
+		port = 22
+	}
+
+	result := &Result{}
+	config := &ssh.ClientConfig{
+		User:    target.User,
+		Auth:    []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		Timeout: timeout,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			fp := ssh.FingerprintSHA256(key)
+			if target.HostKeyFingerprint != "" && fp != target.HostKeyFingerprint {
+				return fmt.Errorf("%w: got %s", ErrHostKeyMismatch, fp)
+			}
+			result.HostKeyFingerprint = fp
+			return nil
+		},
+	}
+
+	addr := net.JoinHostPort(target.Host, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH handshake with %s failed: %w", addr, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	// Abort in-flight sessions when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	for _, f := range files {
+		if _, err := run(client, writeFileCommand(f), f.Content); err != nil {
+			return result, fmt.Errorf("failed to upload %s: %w", f.Path, err)
+		}
+	}
+
+	if command != "" {
+		out, err := run(client, command, nil)
+		result.Output = out
+		if err != nil {
+			return result, fmt.Errorf("post-deploy command failed: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// writeFileCommand streams stdin into a private temp file, fixes its mode and
+// renames it over the destination.
+func writeFileCommand(f File) string {
+	tmp := f.Path + ".acme-tmp"
+	return fmt.Sprintf("umask 077 && cat > %s && chmod %o %s && mv -f %s %s",
+		shellQuote(tmp), f.Mode, shellQuote(tmp), shellQuote(tmp), shellQuote(f.Path))
+}
+
+func run(client *ssh.Client, cmd string, stdin []byte) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	var out limitedBuffer
+	session.Stdout = &out
+	session.Stderr = &out
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+
+	err = session.Run(cmd)
+	output := strings.TrimSpace(out.String())
+	if err != nil && output != "" {
+		return output, fmt.Errorf("%w: %s", err, output)
+	}
+	return output, err
+}
+
+// shellQuote wraps s in single quotes for POSIX sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,217 @@
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process SSH server that executes exec requests with the local shell
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func newTestServer(t *testing.T, authorized ssh.PublicKey) *testServer {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unauthorized")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+
+	return &testServer{addr: ln.Addr().String(), hostKey: hostKey}
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					req.Reply(false, nil)
+					continue
+				}
+				n := binary.BigEndian.Uint32(req.Payload)
+				cmdline := string(req.Payload[4 : 4+n])
+				req.Reply(true, nil)
+
+				cmd := exec.Command("/bin/sh", "-c", cmdline)
+				cmd.Stdin = ch
+				cmd.Stdout = ch
+				cmd.Stderr = ch.Stderr()
+				status := 0
+				if err := cmd.Run(); err != nil {
+					status = 1
+					var exitErr *exec.ExitError
+					if errors.As(err, &exitErr) {
+						status = exitErr.ExitCode()
+					}
+				}
+				ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, uint32(status)))
+				return
+			}
+		}()
+	}
+}
+
+func clientKey(t *testing.T) (ssh.PublicKey, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sshPub, pem.EncodeToMemory(block)
+}
+
+func targetFor(t *testing.T, srv *testServer, key []byte) *SSHTarget {
+	host, portStr, _ := net.SplitHostPort(srv.addr)
+	port, _ := strconv.Atoi(portStr)
+	return &SSHTarget{Host: host, Port: port, User: "deploy", PrivateKey: key}
+}
+
+func TestPushWritesFilesAndRunsCommand(t *testing.T) {
+	pub, key := clientKey(t)
+	srv := newTestServer(t, pub)
+	dir := t.TempDir()
+
+	files := []File{
+		{Path: filepath.Join(dir, "fullchain.pem"), Content: []byte("CHAIN\n"), Mode: 0o644},
+		{Path: filepath.Join(dir, "it's.key"), Content: []byte("KEY\n"), Mode: 0o600},
+	}
+	marker := filepath.Join(dir, "reloaded")
+
+	res, err := NewSSHDeployer().Push(context.Background(), targetFor(t, srv, key), files, "touch "+marker+" && echo reloaded")
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if res.Output != "reloaded" {
+		t.Errorf("output = %q", res.Output)
+	}
+	if res.HostKeyFingerprint != ssh.FingerprintSHA256(srv.hostKey.PublicKey()) {
+		t.Errorf("host key fingerprint = %q", res.HostKeyFingerprint)
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, f.Content) {
+			t.Errorf("%s content = %q", f.Path, data)
+		}
+		info, _ := os.Stat(f.Path)
+		if uint32(info.Mode().Perm()) != f.Mode {
+			t.Errorf("%s mode = %o, want %o", f.Path, info.Mode().Perm(), f.Mode)
+		}
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("post-deploy command did not run")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.acme-tmp")); len(leftovers) != 0 {
+		t.Errorf("temp files left behind: %v", leftovers)
+	}
+}
+
+func TestPushHostKeyMismatch(t *testing.T) {
+	pub, key := clientKey(t)
+	srv := newTestServer(t, pub)
+	dir := t.TempDir()
+
+	target := targetFor(t, srv, key)
+	target.HostKeyFingerprint = "SHA256:not-the-right-key"
+
+	dest := filepath.Join(dir, "fullchain.pem")
+	_, err := NewSSHDeployer().Push(context.Background(), target, []File{{Path: dest, Content: []byte("x"), Mode: 0o644}}, "")
+	if !errors.Is(err, ErrHostKeyMismatch) {
+		t.Fatalf("err = %v, want ErrHostKeyMismatch", err)
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("file should not be written")
+	}
+}
+
+func TestPushCommandFailure(t *testing.T) {
+	pub, key := clientKey(t)
+	srv := newTestServer(t, pub)
+
+	res, err := NewSSHDeployer().Push(context.Background(), targetFor(t, srv, key), nil, "echo bad config >&2; exit 2")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if res == nil || res.Output != "bad config" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestPushRejectsRelativePath(t *testing.T) {
+	_, err := NewSSHDeployer().Push(context.Background(), &SSHTarget{}, []File{{Path: "etc/cert.pem"}}, "")
+	if !errors.Is(err, ErrInvalidPath) {
+		t.Fatalf("err = %v, want ErrInvalidPath", err)
+	}
+}
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidCIDR    = errors.New("invalid CIDR")
+	ErrTooManyTargets = errors.New("scan range exceeds the address limit")
+)
+
+const (
+	DefaultConcurrency = 32
+	DefaultTimeout     = 5 * time.Second
+	DefaultMaxAddrs    = 65536
+	DefaultMaxSNIHints = 32
+)
+
+// Options controls how a scan is performed
+type Options struct {
+	Concurrency int           // Maximum number of handshakes in flight
+	Timeout     time.Duration // Per-handshake dial + TLS timeout
+	SNIHints    []string      // Server names tried on every endpoint in addition to the bare handshake
+}
+
+// Result is a certificate observed on one endpoint with one server name
+type Result struct {
+	IP          string
+	Port        int
+	ServerName  string // Empty when the handshake was made without SNI
+	Fingerprint string // SHA-256 of the leaf certificate
+	Leaf        *x509.Certificate
+	Chain       []*x509.Certificate // Intermediates presented by the server, leaf excluded
+}
+
+// Scanner performs bounded-concurrency TLS handshakes against address ranges
+type Scanner struct {
+	opts Options
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// NewScanner creates a Scanner, filling in defaults for unset options
+func NewScanner(opts Options) *Scanner {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if len(opts.SNIHints) > DefaultMaxSNIHints {
+		opts.SNIHints = opts.SNIHints[:DefaultMaxSNIHints]
+	}
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+	return &Scanner{opts: opts, dial: dialer.DialContext}
+}
+
+// Scan handshakes every host:port pair, once without SNI and once per SNI hint.
+// Endpoints that refuse the connection or fail the handshake are skipped.
+// Duplicate certificates on the same endpoint are reported only once.
+func (s *Scanner) Scan(ctx context.Context, hosts []string, ports []int) []Result {
+	type probe struct {
+		host       string
+		port       int
+		serverName string
+	}
+
+	probes := make(chan probe)
+	var (
+		mu      sync.Mutex
+		results []Result
+		seen    = make(map[string]bool)
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < s.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range probes {
+				res, err := s.handshake(ctx, p.host, p.port, p.serverName)
+				if err != nil {
+					continue
+				}
+				key := fmt.Sprintf("%s|%d|%s", res.IP, res.Port, res.Fingerprint)
+				mu.Lock()
+				if !seen[key] {
+					seen[key] = true
+					results = append(results, *res)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	serverNames := append([]string{""}, s.opts.SNIHints...)
+
+feed:
+	for _, host := range hosts {
+		for _, port := range ports {
+			for _, name := range serverNames {
+				select {
+				case probes <- probe{host: host, port: port, serverName: name}:
+				case <-ctx.Done():
+					break feed
+				}
+			}
+		}
+	}
+	close(probes)
+	wg.Wait()
+
+	return results
+}
+
+func (s *Scanner) handshake(ctx context.Context, host string, port int, serverName string) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	rawConn, err := s.dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer rawConn.Close()
+
+	// Verification is intentionally skipped: the point is to inventory
+	// whatever the endpoint presents, including expired and self-signed certs.
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	peers := conn.ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no certificate presented by %s", addr)
+	}
+
+	return &Result{
+		IP:          host,
+		Port:        port,
+		ServerName:  serverName,
+		Fingerprint: Fingerprint(peers[0]),
+		Leaf:        peers[0],
+		Chain:       peers[1:],
+	}, nil
+}
+
+// Fingerprint returns the hex-encoded SHA-256 of a certificate's DER bytes
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ExpandCIDRs turns CIDR ranges (or bare IPs) into a list of host addresses.
+// For IPv4 prefixes shorter than /31 the network and broadcast addresses are skipped.
+// It fails if the total exceeds maxAddrs (DefaultMaxAddrs when <= 0).
+func ExpandCIDRs(cidrs []string, maxAddrs int) ([]string, error) {
+	if maxAddrs <= 0 {
+		maxAddrs = DefaultMaxAddrs
+	}
+
+	seen := make(map[netip.Addr]bool)
+	var hosts []string
+
+	for _, raw := range cidrs {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		var prefix netip.Prefix
+		if strings.Contains(raw, "/") {
+			p, err := netip.ParsePrefix(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidCIDR, raw)
+			}
+			prefix = p.Masked()
+		} else {
+			addr, err := netip.ParseAddr(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidCIDR, raw)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+
+		hostBits := prefix.Addr().BitLen() - prefix.Bits()
+		if hostBits > 30 || len(hosts)+(1<<hostBits) > maxAddrs+2 {
+			return nil, fmt.Errorf("%w: %s", ErrTooManyTargets, raw)
+		}
+
+		var addrs []netip.Addr
+		for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+			addrs = append(addrs, addr)
+		}
+		if prefix.Addr().Is4() && prefix.Bits() < 31 && len(addrs) > 2 {
+			addrs = addrs[1 : len(addrs)-1]
+		}
+
+		for _, addr := range addrs {
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			hosts = append(hosts, addr.String())
+		}
+		if len(hosts) > maxAddrs {
+			return nil, fmt.Errorf("%w: %d", ErrTooManyTargets, maxAddrs)
+		}
+	}
+
+	return hosts, nil
+}
+
+// SNIHintsFromDomains derives server names to try from certificate domains.
+// Wildcards are replaced by their base domain, and duplicates are removed.
+func SNIHintsFromDomains(domains []string) []string {
+	seen := make(map[string]bool)
+	var hints []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(d, "*.")
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		hints = append(hints, d)
+	}
+	return hints
+}
@@ -0,0 +1,175 @@
+package discovery
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, names ...string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTLSListener serves the default cert, or the one matching the SNI name.
+func startTLSListener(t *testing.T, def tls.Certificate, bySNI map[string]tls.Certificate) int {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if c, ok := bySNI[hello.ServerName]; ok {
+				return &c, nil
+			}
+			return &def, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestScanner_Scan(t *testing.T) {
+	def := selfSigned(t, "default.local")
+	api := selfSigned(t, "api.example.com", "www.example.com")
+	port := startTLSListener(t, def, map[string]tls.Certificate{"api.example.com": api})
+
+	scanner := NewScanner(Options{
+		Concurrency: 4,
+		Timeout:     2 * time.Second,
+		SNIHints:    []string{"api.example.com", "unknown.example.com"},
+	})
+	results := scanner.Scan(context.Background(), []string{"127.0.0.1"}, []int{port})
+
+	if len(results) != 2 {
+		t.Fatalf("Scan() got %d results, want 2 (default + SNI match)", len(results))
+	}
+
+	byCN := make(map[string]Result)
+	for _, r := range results {
+		byCN[r.Leaf.Subject.CommonName] = r
+	}
+
+	if r, ok := byCN["default.local"]; !ok {
+		t.Error("default certificate not discovered")
+	} else if r.Port != port || r.IP != "127.0.0.1" {
+		t.Errorf("default result endpoint = %s:%d", r.IP, r.Port)
+	}
+
+	r, ok := byCN["api.example.com"]
+	if !ok {
+		t.Fatal("SNI certificate not discovered")
+	}
+	if r.ServerName != "api.example.com" {
+		t.Errorf("ServerName = %q, want api.example.com", r.ServerName)
+	}
+	if len(r.Leaf.DNSNames) != 2 {
+		t.Errorf("DNSNames = %v, want 2 entries", r.Leaf.DNSNames)
+	}
+	if r.Fingerprint == "" || r.Fingerprint == byCN["default.local"].Fingerprint {
+		t.Error("fingerprints should be set and distinct")
+	}
+}
+
+func TestScanner_Scan_ClosedPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	scanner := NewScanner(Options{Timeout: time.Second})
+	if results := scanner.Scan(context.Background(), []string{"127.0.0.1"}, []int{port}); len(results) != 0 {
+		t.Errorf("Scan() got %d results on a closed port, want 0", len(results))
+	}
+}
+
+func TestExpandCIDRs(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidrs   []string
+		max     int
+		want    int
+		wantErr bool
+	}{
+		{name: "single ip", cidrs: []string{"10.0.0.1"}, want: 1},
+		{name: "slash 30 skips edges", cidrs: []string{"10.0.0.0/30"}, want: 2},
+		{name: "slash 31 keeps both", cidrs: []string{"10.0.0.0/31"}, want: 2},
+		{name: "overlap deduplicated", cidrs: []string{"10.0.0.0/29", "10.0.0.1"}, want: 6},
+		{name: "ipv6", cidrs: []string{"fd00::/126"}, want: 4},
+		{name: "invalid", cidrs: []string{"not-a-cidr"}, wantErr: true},
+		{name: "too large", cidrs: []string{"10.0.0.0/8"}, wantErr: true},
+		{name: "over limit", cidrs: []string{"10.0.0.0/24"}, max: 16, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := ExpandCIDRs(tt.cidrs, tt.max)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ExpandCIDRs() expected error, got %d hosts", len(hosts))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandCIDRs() error = %v", err)
+			}
+			if len(hosts) != tt.want {
+				t.Errorf("ExpandCIDRs() got %d hosts, want %d", len(hosts), tt.want)
+			}
+		})
+	}
+}
+
+func TestSNIHintsFromDomains(t *testing.T) {
+	got := SNIHintsFromDomains([]string{"example.com", "*.example.com", "API.example.com", ""})
+	want := []string{"example.com", "api.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("SNIHintsFromDomains() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SNIHintsFromDomains()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+type APITokenHandler struct {
+	svc *service.APITokenService
+}
+
+func NewAPITokenHandler(svc *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{svc: svc}
+}
+
+// ListTokens handles GET /api/v1/tokens
+func (h *APITokenHandler) ListTokens(c *gin.Context) {
+	tokens, err := h.svc.ListPersonalTokens(utils.GetUserID(c))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// CreateToken handles POST /api/v1/tokens
+func (h *APITokenHandler) CreateToken(c *gin.Context) {
+	var req service.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.svc.CreatePersonalToken(utils.GetUserID(c), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, resp.APIToken.ID)
+	response.Created(c, resp)
+}
+
+// RevokeToken handles DELETE /api/v1/tokens/:id
+func (h *APITokenHandler) RevokeToken(c *gin.Context) {
+	tokenID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid token id")
+		return
+	}
+
+	if err := h.svc.RevokePersonalToken(utils.GetUserID(c), tokenID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "token revoked successfully")
+}
+
+// ListServiceAccounts handles GET /api/v1/workspaces/:id/service-accounts
+func (h *APITokenHandler) ListServiceAccounts(c *gin.Context) {
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	accounts, err := h.svc.ListServiceAccounts(workspaceID, utils.GetUserID(c))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, accounts)
+}
+
+// CreateServiceAccount handles POST /api/v1/workspaces/:id/service-accounts
+func (h *APITokenHandler) CreateServiceAccount(c *gin.Context) {
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	var req service.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	account, err := h.svc.CreateServiceAccount(workspaceID, utils.GetUserID(c), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, account.ID)
+	response.Created(c, account)
+}
+
+// DeleteServiceAccount handles DELETE /api/v1/workspaces/:id/service-accounts/:accountId
+func (h *APITokenHandler) DeleteServiceAccount(c *gin.Context) {
+	workspaceID, accountID, ok := parseServiceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteServiceAccount(workspaceID, accountID, utils.GetUserID(c)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "service account deleted successfully")
+}
+
+// ListServiceAccountTokens handles GET /api/v1/workspaces/:id/service-accounts/:accountId/tokens
+func (h *APITokenHandler) ListServiceAccountTokens(c *gin.Context) {
+	workspaceID, accountID, ok := parseServiceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.svc.ListServiceAccountTokens(workspaceID, accountID, utils.GetUserID(c))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// CreateServiceAccountToken handles POST /api/v1/workspaces/:id/service-accounts/:accountId/tokens
+func (h *APITokenHandler) CreateServiceAccountToken(c *gin.Context) {
+	workspaceID, accountID, ok := parseServiceAccountParams(c)
+	if !ok {
+		return
+	}
+
+	var req service.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.svc.CreateServiceAccountToken(workspaceID, accountID, utils.GetUserID(c), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, resp.APIToken.ID)
+	response.Created(c, resp)
+}
+
+// RevokeServiceAccountToken handles DELETE /api/v1/workspaces/:id/service-accounts/:accountId/tokens/:tokenId
+func (h *APITokenHandler) RevokeServiceAccountToken(c *gin.Context) {
+	workspaceID, accountID, ok := parseServiceAccountParams(c)
+	if !ok {
+		return
+	}
+	tokenID, err := utils.ParseIDParam(c, "tokenId")
+	if err != nil {
+		response.BadRequest(c, "invalid token id")
+		return
+	}
+
+	if err := h.svc.RevokeServiceAccountToken(workspaceID, accountID, tokenID, utils.GetUserID(c)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "token revoked successfully")
+}
+
+func parseServiceAccountParams(c *gin.Context) (uint, uint, bool) {
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return 0, 0, false
+	}
+	accountID, err := utils.ParseIDParam(c, "accountId")
+	if err != nil {
+		response.BadRequest(c, "invalid service account id")
+		return 0, 0, false
+	}
+	return workspaceID, accountID, true
+}
+
+func (h *APITokenHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err == service.ErrAPITokenNotFound, err == service.ErrServiceAccountNotFound:
+		response.NotFound(c, err.Error())
+	case err == service.ErrServiceAccountExists, err == service.ErrInvalidServiceAccountName,
+		errors.Is(err, service.ErrInvalidScope):
+		response.BadRequest(c, err.Error())
+	default:
+		handleRoleError(c, err)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+type AuditHandler struct {
+	svc          *service.AuditService
+	workspaceSvc *service.WorkspaceService
+}
+
+func NewAuditHandler(svc *service.AuditService, workspaceSvc *service.WorkspaceService) *AuditHandler {
+	return &AuditHandler{
+		svc:          svc,
+		workspaceSvc: workspaceSvc,
+	}
+}
+
+// List handles GET /api/v1/admin/audit-logs
+func (h *AuditHandler) List(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	filter.WorkspaceID = utils.ParseQueryUint(c, "workspace_id")
+	h.list(c, filter)
+}
+
+// Export handles GET /api/v1/admin/audit-logs/export
+func (h *AuditHandler) Export(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	filter.WorkspaceID = utils.ParseQueryUint(c, "workspace_id")
+	h.export(c, filter, "audit-log.csv")
+}
+
+// ListWorkspace handles GET /api/v1/workspaces/:id/audit-logs
+// Workspace owners and admins see the events of their workspace.
+func (h *AuditHandler) ListWorkspace(c *gin.Context) {
+	filter, ok := h.workspaceFilter(c)
+	if !ok {
+		return
+	}
+	h.list(c, filter)
+}
+
+// ExportWorkspace handles GET /api/v1/workspaces/:id/audit-logs/export
+func (h *AuditHandler) ExportWorkspace(c *gin.Context) {
+	filter, ok := h.workspaceFilter(c)
+	if !ok {
+		return
+	}
+	h.export(c, filter, fmt.Sprintf("workspace-%d-audit-log.csv", filter.WorkspaceID))
+}
+
+func (h *AuditHandler) workspaceFilter(c *gin.Context) (service.AuditFilter, bool) {
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return service.AuditFilter{}, false
+	}
+	if !h.workspaceSvc.CanManageWorkspace(workspaceID, utils.GetUserID(c)) {
+		response.Forbidden(c, "access denied")
+		return service.AuditFilter{}, false
+	}
+
+	filter, ok := parseAuditFilter(c)
+	filter.WorkspaceID = workspaceID
+	return filter, ok
+}
+
+func (h *AuditHandler) list(c *gin.Context, filter service.AuditFilter) {
+	page := utils.ParseQueryInt(c, "page", 1)
+	pageSize := utils.ParseQueryInt(c, "page_size", 50)
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 50
+	}
+
+	logs, total, err := h.svc.List(filter, page, pageSize)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, utils.NewPagination(logs, int(total), page, pageSize))
+}
+
+func (h *AuditHandler) export(c *gin.Context, filter service.AuditFilter, filename string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	// Headers are sent by now; a failure can only cut the file short
+	if err := h.svc.ExportCSV(c.Writer, filter); err != nil {
+		logger.Error("Failed to export audit log", logger.Err(err))
+	}
+}
+
+// parseAuditFilter reads the query filters shared by every audit endpoint.
+// from and to are RFC 3339 times or dates; a date in to includes that day.
+func parseAuditFilter(c *gin.Context) (service.AuditFilter, bool) {
+	filter := service.AuditFilter{
+		Action:       c.Query("action"),
+		ActorName:    c.Query("actor"),
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		Outcome:      c.Query("outcome"),
+		RequestID:    c.Query("request_id"),
+	}
+
+	for _, bound := range []struct {
+		param string
+		dest  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			day, dayErr := time.ParseInLocation("2006-01-02", value, time.Local)
+			if dayErr != nil {
+				response.BadRequest(c, "invalid "+bound.param+" time, use RFC 3339 or YYYY-MM-DD")
+				return filter, false
+			}
+			t = day
+			if bound.param == "to" {
+				t = day.AddDate(0, 0, 1)
+			}
+		}
+		*bound.dest = &t
+	}
+	return filter, true
+}
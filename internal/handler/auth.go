@@ -1,47 +1,94 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/imkerbos/ACME-Console/internal/auth"
 	"github.com/imkerbos/ACME-Console/internal/model"
 	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	db         *gorm.DB
-	jwtManager *auth.JWTManager
+	db          *gorm.DB
+	jwtManager  *auth.JWTManager
+	oidcSvc     *service.OIDCService
+	ldapSvc     *service.LDAPService
+	mfaSvc      *service.MFAService
+	sessionSvc  *service.SessionService
+	loginGuard  *service.LoginGuardService
+	policy      *service.PasswordPolicyService
+	invitations *service.InvitationService
 }
 
-func NewAuthHandler(db *gorm.DB, jwtManager *auth.JWTManager) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, jwtManager *auth.JWTManager, oidcSvc *service.OIDCService, ldapSvc *service.LDAPService, mfaSvc *service.MFAService, sessionSvc *service.SessionService, loginGuard *service.LoginGuardService, policy *service.PasswordPolicyService, invitations *service.InvitationService) *AuthHandler {
 	return &AuthHandler{
-		db:         db,
-		jwtManager: jwtManager,
+		db:          db,
+		jwtManager:  jwtManager,
+		oidcSvc:     oidcSvc,
+		ldapSvc:     ldapSvc,
+		mfaSvc:      mfaSvc,
+		sessionSvc:  sessionSvc,
+		loginGuard:  loginGuard,
+		policy:      policy,
+		invitations: invitations,
 	}
 }
 
+// Compared against when the username is unknown, so the response time does
+// not reveal which usernames exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
 type LoginResponse struct {
-	Token     string   `json:"token"`
-	ExpiresIn int64    `json:"expires_in"`
-	User      UserInfo `json:"user"`
+	Token        string   `json:"token"`
+	RefreshToken string   `json:"refresh_token"`
+	ExpiresIn    int64    `json:"expires_in"` // Seconds until the access token must be refreshed
+	User         UserInfo `json:"user"`
+
+	// Shown once, when a login set up the user's first second factor
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 type UserInfo struct {
-	ID       uint   `json:"id"`
-	Username string `json:"username"`
-	Nickname string `json:"nickname"`
-	Email    string `json:"email"`
-	Role     string `json:"role"`
+	ID         uint   `json:"id"`
+	Username   string `json:"username"`
+	Nickname   string `json:"nickname"`
+	Email      string `json:"email"`
+	Role       string `json:"role"`
+	AuthSource string `json:"auth_source,omitempty"`
+
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+}
+
+func newUserInfo(user *model.User) UserInfo {
+	return UserInfo{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Nickname:           user.Nickname,
+		Email:              user.Email,
+		Role:               user.Role,
+		AuthSource:         user.AuthSource,
+		MustChangePassword: user.MustChangePassword,
+	}
 }
 
 // Login handles POST /api/v1/auth/login
+// Users with a second factor, or who must set one up, get an MFA challenge
+// instead of a session. Failed attempts slow down and then lock out the
+// username and the client IP; every failure gets the same message.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -49,44 +96,144 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+	if wait := h.loginGuard.Wait(req.Username, ip); wait > 0 {
+		tooManyAttempts(c, wait)
+		return
+	}
+
+	// Service accounts only authenticate with API tokens
 	var user model.User
-	if err := h.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+	err := h.db.Where("username = ? AND service_account = ?", req.Username, false).First(&user).Error
+
+	// Directory users, and usernames without a local account, go to LDAP;
+	// local accounts keep their own passwords
+	if h.ldapSvc.Enabled() && (err != nil || user.AuthSource == model.AuthSourceLDAP) {
+		h.ldapLogin(c, &req)
+		return
+	}
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		h.loginGuard.Fail(req.Username, ip, nil, "unknown user")
 		response.BadRequest(c, "invalid username or password")
 		return
 	}
 
+	if !user.CheckPassword(req.Password) {
+		h.loginGuard.Fail(req.Username, ip, &user, "wrong password")
+		response.BadRequest(c, "invalid username or password")
+		return
+	}
 	if user.Status != 1 {
-		response.BadRequest(c, "user is disabled")
+		h.loginGuard.Fail(req.Username, ip, &user, "account disabled")
+		response.BadRequest(c, "invalid username or password")
 		return
 	}
 
-	if !user.CheckPassword(req.Password) {
+	if !h.oidcSvc.PasswordLoginAllowed(&user) {
+		response.Forbidden(c, "password login is disabled, please use single sign-on")
+		return
+	}
+
+	// Update last login time
+	now := time.Now()
+	h.db.Model(&user).Update("last_login", now)
+
+	h.completePasswordLogin(c, &user, ip)
+}
+
+// ldapLogin authenticates against the directory, which also syncs the user
+func (h *AuthHandler) ldapLogin(c *gin.Context, req *LoginRequest) {
+	ip := c.ClientIP()
+	user, err := h.ldapSvc.Authenticate(req.Username, req.Password)
+	switch {
+	case err == nil:
+		h.completePasswordLogin(c, user, ip)
+	case errors.Is(err, service.ErrLDAPUnavailable):
+		response.Error(c, http.StatusServiceUnavailable, response.CodeInternalError, err.Error())
+	case errors.Is(err, service.ErrLDAPAccountDisabled):
+		h.loginGuard.Fail(req.Username, ip, nil, "directory account disabled")
+		response.BadRequest(c, "invalid username or password")
+	default:
+		h.loginGuard.Fail(req.Username, ip, nil, "directory rejected credentials")
 		response.BadRequest(c, "invalid username or password")
+	}
+}
+
+// completeLogin starts a session for a user who passed the first factor,
+// unless a second factor is still needed
+func (h *AuthHandler) completeLogin(c *gin.Context, user *model.User) {
+	challenge, err := h.mfaSvc.BeginLogin(user)
+	if err != nil {
+		response.InternalError(c, err)
 		return
 	}
+	if challenge != nil {
+		response.Success(c, challenge)
+		return
+	}
+	h.startSession(c, user)
+}
 
-	// Generate token with role
-	token, err := h.jwtManager.Generate(user.ID, user.Username, user.Role)
+// completePasswordLogin continues a login whose password was right. Failed
+// attempts are only cleared once no second factor is pending, so guessed
+// codes keep counting toward the same lockout as guessed passwords.
+func (h *AuthHandler) completePasswordLogin(c *gin.Context, user *model.User, ip string) {
+	challenge, err := h.mfaSvc.BeginLogin(user)
 	if err != nil {
 		response.InternalError(c, err)
 		return
 	}
+	// Users still enrolling have no second factor to guess
+	if challenge == nil || challenge.EnrollmentRequired {
+		h.loginGuard.Succeed(user, ip)
+	}
+	if challenge != nil {
+		response.Success(c, challenge)
+		return
+	}
+	h.startSession(c, user)
+}
 
-	// Update last login time
-	now := time.Now()
-	h.db.Model(&user).Update("last_login", now)
+// tooManyAttempts rejects a login step while the username or IP is throttled
+func tooManyAttempts(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	response.Error(c, http.StatusTooManyRequests, response.CodeTooManyRequests,
+		fmt.Sprintf("too many failed attempts, try again in %d seconds", seconds))
+}
+
+// startSession opens a session for a logged-in user
+func (h *AuthHandler) startSession(c *gin.Context, user *model.User) {
+	session, err := h.newSession(c, user)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, session)
+}
+
+func (h *AuthHandler) newSession(c *gin.Context, user *model.User) (*LoginResponse, error) {
+	session, refreshToken, err := h.sessionSvc.Create(user.ID, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return nil, err
+	}
+	return h.sessionResponse(user, session.ID, refreshToken)
+}
 
-	response.Success(c, LoginResponse{
-		Token:     token,
-		ExpiresIn: 86400, // 24 hours
-		User: UserInfo{
-			ID:       user.ID,
-			Username: user.Username,
-			Nickname: user.Nickname,
-			Email:    user.Email,
-			Role:     user.Role,
-		},
-	})
+// sessionResponse issues an access token for a session
+func (h *AuthHandler) sessionResponse(user *model.User, sessionID uint, refreshToken string) (*LoginResponse, error) {
+	token, err := h.jwtManager.Generate(user.ID, user.Username, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(h.jwtManager.TokenDuration().Seconds()),
+		User:         newUserInfo(user),
+	}, nil
 }
 
 // GetCurrentUser handles GET /api/v1/auth/me
@@ -103,18 +250,12 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, UserInfo{
-		ID:       user.ID,
-		Username: user.Username,
-		Nickname: user.Nickname,
-		Email:    user.Email,
-		Role:     user.Role,
-	})
+	response.Success(c, newUserInfo(&user))
 }
 
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 type UpdateProfileRequest struct {
@@ -142,21 +283,43 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	if user.AuthSource == model.AuthSourceLDAP {
+		response.BadRequest(c, "password is managed by the directory")
+		return
+	}
+
 	if !user.CheckPassword(req.OldPassword) {
 		response.BadRequest(c, "old password is incorrect")
 		return
 	}
+	if req.NewPassword == req.OldPassword {
+		response.BadRequest(c, service.ErrPasswordUnchanged.Error())
+		return
+	}
+	if err := h.policy.Validate(req.NewPassword, user.Username); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
 
 	if err := user.SetPassword(req.NewPassword); err != nil {
 		response.InternalError(c, err)
 		return
 	}
+	user.MustChangePassword = false
 
 	if err := h.db.Save(&user).Error; err != nil {
 		response.InternalError(c, err)
 		return
 	}
 
+	// Other devices have to log in with the new password
+	sessionID, _ := c.Get("sessionID")
+	currentID, _ := sessionID.(uint)
+	if err := h.sessionSvc.RevokeAll(user.ID, currentID); err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
 	response.OK(c, "password changed successfully")
 }
 
@@ -185,8 +348,9 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 	if req.Nickname != "" {
 		updates["nickname"] = req.Nickname
 	}
-	if req.Email != "" {
+	if req.Email != "" && req.Email != user.Email {
 		updates["email"] = req.Email
+		updates["email_verified"] = false
 	}
 
 	if len(updates) > 0 {
@@ -202,11 +366,5 @@ func (h *AuthHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, UserInfo{
-		ID:       user.ID,
-		Username: user.Username,
-		Nickname: user.Nickname,
-		Email:    user.Email,
-		Role:     user.Role,
-	})
+	response.Success(c, newUserInfo(&user))
 }
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -9,15 +11,17 @@ import (
 	"github.com/imkerbos/ACME-Console/internal/response"
 	"github.com/imkerbos/ACME-Console/internal/service"
 	"github.com/imkerbos/ACME-Console/internal/utils"
+	"gorm.io/gorm"
 )
 
 type CertificateHandler struct {
-	svc        *service.CertificateService
-	renewalSvc *service.RenewalService
+	svc          *service.CertificateService
+	renewalSvc   *service.RenewalService
+	keyExportSvc *service.KeyExportService
 }
 
-func NewCertificateHandler(svc *service.CertificateService, renewalSvc *service.RenewalService) *CertificateHandler {
-	return &CertificateHandler{svc: svc, renewalSvc: renewalSvc}
+func NewCertificateHandler(svc *service.CertificateService, renewalSvc *service.RenewalService, keyExportSvc *service.KeyExportService) *CertificateHandler {
+	return &CertificateHandler{svc: svc, renewalSvc: renewalSvc, keyExportSvc: keyExportSvc}
 }
 
 // Create handles POST /api/v1/certificates
@@ -32,10 +36,23 @@ func (h *CertificateHandler) Create(c *gin.Context) {
 
 	resp, err := h.svc.Create(&req, userID)
 	if err != nil {
-		response.InternalError(c, err)
+		switch err {
+		case service.ErrWorkspaceAccessDenied:
+			response.Forbidden(c, "access denied")
+		case service.ErrWorkspaceArchived:
+			response.BadRequest(c, err.Error())
+		case service.ErrWorkspaceNotFound:
+			response.NotFound(c, err.Error())
+		default:
+			response.InternalError(c, err)
+		}
 		return
 	}
 
+	utils.SetAuditWorkspace(c, req.WorkspaceID)
+	if resp.Certificate != nil {
+		utils.SetAuditResource(c, resp.Certificate.ID)
+	}
 	response.Created(c, resp)
 }
 
@@ -134,8 +151,20 @@ func getContentType(format string) string {
 		return "application/x-pem-file"
 	case "pfx":
 		return "application/x-pkcs12"
-	case "zip":
+	case "zip", "profile":
 		return "application/zip"
+	case "jks":
+		return "application/x-java-keystore"
+	case "jceks":
+		return "application/x-java-jce-keystore"
+	case "p7b":
+		return "application/x-pkcs7-certificates"
+	case "der":
+		return "application/pkix-cert"
+	case "pkcs8", "haproxy":
+		return "application/x-pem-file"
+	case "k8s":
+		return "application/yaml"
 	default:
 		return "application/octet-stream"
 	}
@@ -143,9 +172,20 @@ func getContentType(format string) string {
 
 // Download handles GET /api/v1/certificates/:id/download
 // Query params:
-//   - format: pem, fullchain, pfx, zip (default: pem)
-//   - password: password for PFX format (default: changeit)
+//   - format: pem, fullchain, pfx, zip, jks, jceks, p7b, der, pkcs8, haproxy, k8s, profile (default: pem)
+//   - alias: JKS/JCEKS entry alias (default: certificate)
+//   - namespace: namespace of the Kubernetes Secret manifest (optional)
+//   - reason: why the private key is exported, if the workspace policy asks for one
+//
+// Formats containing the private key follow the workspace key export policy; for
+// step-up policies a grant from POST /auth/step-up goes in the X-Step-Up-Token
+// header; users without a second factor may send their login password in
+// X-Reauth-Password instead.
+// Passwords are never taken from the URL, where they end up in access logs;
+// PFX, JKS, JCEKS and profile downloads and passphrase-protected PKCS#8 go
+// through POST /certificates/:id/download-links instead.
 func (h *CertificateHandler) Download(c *gin.Context) {
+	userID := utils.GetUserID(c)
 	id, err := utils.ParseID(c)
 	if err != nil {
 		response.BadRequest(c, "invalid certificate id")
@@ -164,7 +204,18 @@ func (h *CertificateHandler) Download(c *gin.Context) {
 	}
 
 	format := c.DefaultQuery("format", "pem")
-	password := c.Query("password")
+	if c.Query("password") != "" {
+		response.BadRequest(c, "passwords are not accepted in the URL; create a download link instead")
+		return
+	}
+	if service.RequiresPassword(format) {
+		response.BadRequest(c, "format "+format+" needs a password; create a download link instead")
+		return
+	}
+	opts := service.BundleOptions{
+		Alias:     c.Query("alias"),
+		Namespace: c.Query("namespace"),
+	}
 
 	// For legacy API compatibility, return JSON if no format specified
 	if format == "pem" && c.Query("format") == "" {
@@ -175,12 +226,33 @@ func (h *CertificateHandler) Download(c *gin.Context) {
 		return
 	}
 
+	keyExport := service.IsPrivateKeyFormat(format)
+	if keyExport {
+		err := h.keyExportSvc.Authorize(id, userID, &service.KeyExportRequest{
+			Format:         format,
+			Reason:         c.Query("reason"),
+			ReauthPassword: c.GetHeader("X-Reauth-Password"),
+			StepUpToken:    c.GetHeader("X-Step-Up-Token"),
+		})
+		if err != nil {
+			handleKeyExportError(c, err)
+			return
+		}
+	}
+
 	// Get certificate bundle in requested format
-	data, filename, err := h.svc.GetCertificateBundle(id, format, password)
+	data, filename, err := h.svc.GetCertificateBundle(id, format, opts)
+	if errors.Is(err, service.ErrInvalidBundleOptions) || errors.Is(err, service.ErrKeyNotExportable) {
+		response.BadRequest(c, err.Error())
+		return
+	}
 	if err != nil {
 		response.InternalError(c, err)
 		return
 	}
+	if keyExport {
+		h.keyExportSvc.Record(id, userID, format, c.Query("reason"), c.ClientIP(), service.KeyExportViaDownload)
+	}
 
 	// Set appropriate content type
 	contentType := getContentType(format)
@@ -264,3 +336,164 @@ func (h *CertificateHandler) RenewalLogs(c *gin.Context) {
 
 	response.Success(c, logs)
 }
+
+// KeyExports handles GET /api/v1/certificates/:id/key-exports
+func (h *CertificateHandler) KeyExports(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	exports, err := h.keyExportSvc.ListExports(id, userID)
+	if err != nil {
+		handleKeyExportError(c, err)
+		return
+	}
+
+	response.Success(c, exports)
+}
+
+// handleKeyExportError maps key export policy errors, shared with download links
+func handleKeyExportError(c *gin.Context, err error) {
+	switch {
+	case err == service.ErrWorkspaceAccessDenied:
+		response.Forbidden(c, "access denied")
+	case err == service.ErrKeyExportDisabled,
+		err == service.ErrKeyExportReauthRequired,
+		err == service.ErrKeyExportInvalidPassword:
+		response.Forbidden(c, err.Error())
+	case err == service.ErrKeyExportReasonRequired,
+		err == service.ErrKeyExportEncryptedOnly:
+		response.BadRequest(c, err.Error())
+	case err == service.ErrKeyExportReauthLocked:
+		response.Error(c, http.StatusTooManyRequests, response.CodeTooManyRequests, err.Error())
+	case err == service.ErrWorkspaceNotFound, errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, "certificate not found")
+	default:
+		response.InternalError(c, err)
+	}
+}
+
+// Move handles POST /api/v1/certificates/:id/move
+func (h *CertificateHandler) Move(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	var req service.MoveCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	cert, err := h.svc.GetByID(id)
+	if err != nil {
+		response.NotFound(c, "certificate not found")
+		return
+	}
+
+	changes, err := h.svc.Move(cert, userID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWorkspaceAccessDenied):
+			response.Forbidden(c, "access denied")
+		case errors.Is(err, service.ErrWorkspaceNotFound):
+			response.NotFound(c, err.Error())
+		case errors.Is(err, service.ErrKeyExportDisabled):
+			response.Forbidden(c, err.Error())
+		case errors.Is(err, service.ErrCertificateMoveInvalid),
+			errors.Is(err, service.ErrCertificateMoveBlocked),
+			errors.Is(err, service.ErrWorkspaceArchived):
+			response.BadRequest(c, err.Error())
+		default:
+			response.InternalError(c, err)
+		}
+		return
+	}
+
+	utils.SetAuditChanges(c, changes)
+	response.OK(c, "certificate moved")
+}
+
+// ListShares handles GET /api/v1/certificates/:id/shares
+func (h *CertificateHandler) ListShares(c *gin.Context) {
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	shares, err := h.svc.ListShares(id)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, shares)
+}
+
+// Share handles POST /api/v1/certificates/:id/shares
+func (h *CertificateHandler) Share(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	var req service.ShareCertificateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	cert, err := h.svc.GetByID(id)
+	if err != nil {
+		response.NotFound(c, "certificate not found")
+		return
+	}
+
+	share, err := h.svc.Share(cert, userID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrShareTargetInvalid) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	utils.SetAuditChanges(c, service.DiffFields(nil, map[string]interface{}{"shared_with": req.WorkspaceID}))
+	response.Created(c, share)
+}
+
+// Unshare handles DELETE /api/v1/certificates/:id/shares/:workspaceId
+func (h *CertificateHandler) Unshare(c *gin.Context) {
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+	workspaceID, err := strconv.ParseUint(c.Param("workspaceId"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	if err := h.svc.Unshare(id, uint(workspaceID)); err != nil {
+		if errors.Is(err, service.ErrShareNotFound) {
+			response.NotFound(c, err.Error())
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	utils.SetAuditChanges(c, service.DiffFields(map[string]interface{}{"shared_with": uint(workspaceID)}, nil))
+	response.OK(c, "certificate share removed")
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+type CloudCredentialHandler struct {
+	svc *service.CloudCredentialService
+}
+
+func NewCloudCredentialHandler(svc *service.CloudCredentialService) *CloudCredentialHandler {
+	return &CloudCredentialHandler{svc: svc}
+}
+
+// List handles GET /api/v1/cloud-credentials?workspace_id=
+// Without workspace_id the user's personal credentials are returned.
+func (h *CloudCredentialHandler) List(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID := utils.ParseQueryUint(c, "workspace_id")
+
+	creds, err := h.svc.List(workspaceID, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, creds)
+}
+
+// Create handles POST /api/v1/cloud-credentials
+func (h *CloudCredentialHandler) Create(c *gin.Context) {
+	userID := utils.GetUserID(c)
+
+	var req service.CreateCloudCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	cred, err := h.svc.Create(userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, cred.ID)
+	utils.SetAuditWorkspace(c, cred.WorkspaceID)
+	response.Created(c, cred)
+}
+
+// Delete handles DELETE /api/v1/cloud-credentials/:id
+func (h *CloudCredentialHandler) Delete(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid credential id")
+		return
+	}
+
+	if err := h.svc.Delete(id, userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "cloud credential deleted successfully")
+}
+
+func (h *CloudCredentialHandler) handleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrWorkspaceAccessDenied:
+		response.Forbidden(c, "access denied")
+	case service.ErrCloudCredentialNotFound:
+		response.NotFound(c, "cloud credential not found")
+	case service.ErrCloudCredentialInUse, service.ErrDeploymentNotAvailable:
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
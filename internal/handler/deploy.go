@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+type DeployHandler struct {
+	svc *service.DeployService
+}
+
+func NewDeployHandler(svc *service.DeployService) *DeployHandler {
+	return &DeployHandler{svc: svc}
+}
+
+// ListTokens handles GET /api/v1/certificates/:id/deploy-tokens
+func (h *DeployHandler) ListTokens(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	tokens, err := h.svc.ListTokens(certID, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+// CreateToken handles POST /api/v1/certificates/:id/deploy-tokens
+func (h *DeployHandler) CreateToken(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	var req service.CreateDeployTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.svc.CreateToken(certID, userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, resp.DeployToken.ID)
+	response.Created(c, resp)
+}
+
+// RevokeToken handles DELETE /api/v1/certificates/:id/deploy-tokens/:tokenId
+func (h *DeployHandler) RevokeToken(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+	tokenID, err := utils.ParseIDParam(c, "tokenId")
+	if err != nil {
+		response.BadRequest(c, "invalid token id")
+		return
+	}
+
+	if err := h.svc.RevokeToken(certID, tokenID, userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "deploy token revoked successfully")
+}
+
+// Bundle handles GET /api/v1/deploy/:token/bundle
+// Authenticated by the deploy token itself. Supports If-None-Match so agents
+// can poll cheaply and only rewrite files when the certificate changed.
+func (h *DeployHandler) Bundle(c *gin.Context) {
+	bundle, etag, err := h.svc.GetBundle(c.Param("token"), c.ClientIP(), c.GetHeader("If-None-Match"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-store")
+	if bundle == nil {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	utils.SetAuditResource(c, bundle.CertificateID)
+	response.Success(c, bundle)
+}
+
+func (h *DeployHandler) handleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrWorkspaceAccessDenied:
+		response.Forbidden(c, "access denied")
+	case service.ErrDeployTokenNotFound:
+		response.NotFound(c, "deploy token not found")
+	case service.ErrDeployTokenInvalid:
+		response.Unauthorized(c, err.Error())
+	case service.ErrCertificateNotReady, service.ErrCertificateNoPrivateKey:
+		response.Error(c, http.StatusConflict, response.CodeBadRequest, err.Error())
+	default:
+		// Key export policy errors, shared with downloads
+		handleKeyExportError(c, err)
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+	"gorm.io/gorm"
+)
+
+type DeploymentHandler struct {
+	svc *service.DeploymentService
+}
+
+func NewDeploymentHandler(svc *service.DeploymentService) *DeploymentHandler {
+	return &DeploymentHandler{svc: svc}
+}
+
+// ListTargets handles GET /api/v1/certificates/:id/deployment-targets
+func (h *DeploymentHandler) ListTargets(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	targets, err := h.svc.ListTargets(certID, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, targets)
+}
+
+// ListStatus handles GET /api/v1/certificates/:id/deployment-status
+func (h *DeploymentHandler) ListStatus(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	statuses, err := h.svc.ListStatus(certID, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, statuses)
+}
+
+// CreateTarget handles POST /api/v1/certificates/:id/deployment-targets
+func (h *DeploymentHandler) CreateTarget(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	var req service.DeploymentTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	target, err := h.svc.CreateTarget(certID, userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, target.ID)
+	response.Created(c, target)
+}
+
+// UpdateTarget handles PUT /api/v1/certificates/:id/deployment-targets/:targetId
+func (h *DeploymentHandler) UpdateTarget(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+	targetID, err := utils.ParseIDParam(c, "targetId")
+	if err != nil {
+		response.BadRequest(c, "invalid target id")
+		return
+	}
+
+	var req service.DeploymentTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	target, err := h.svc.UpdateTarget(certID, targetID, userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, target)
+}
+
+// DeleteTarget handles DELETE /api/v1/certificates/:id/deployment-targets/:targetId
+func (h *DeploymentHandler) DeleteTarget(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+	targetID, err := utils.ParseIDParam(c, "targetId")
+	if err != nil {
+		response.BadRequest(c, "invalid target id")
+		return
+	}
+
+	if err := h.svc.DeleteTarget(certID, targetID, userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "deployment target deleted successfully")
+}
+
+// Redeploy handles POST /api/v1/certificates/:id/redeploy
+// Pushes the current certificate synchronously and returns the per-target results.
+func (h *DeploymentHandler) Redeploy(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	var req service.RedeployRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ValidationError(c, err)
+			return
+		}
+	}
+
+	logs, err := h.svc.Redeploy(certID, userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, logs)
+}
+
+// ListLogs handles GET /api/v1/certificates/:id/deployment-logs
+func (h *DeploymentHandler) ListLogs(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	logs, err := h.svc.ListLogs(certID, userID, limit)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, logs)
+}
+
+func (h *DeploymentHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err == service.ErrWorkspaceAccessDenied:
+		response.Forbidden(c, "access denied")
+	case err == service.ErrDeploymentTargetNotFound:
+		response.NotFound(c, "deployment target not found")
+	case err == service.ErrCloudCredentialNotFound:
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, "certificate not found")
+	case err == service.ErrKeyExportDisabled,
+		err == service.ErrKeyExportReauthRequired,
+		err == service.ErrKeyExportInvalidPassword,
+		err == service.ErrKeyExportReauthLocked,
+		err == service.ErrKeyExportReasonRequired,
+		err == service.ErrKeyExportEncryptedOnly:
+		handleKeyExportError(c, err)
+	case err == service.ErrDeploymentNotAvailable,
+		err == service.ErrCertificateNotReady,
+		err == service.ErrInvalidRemotePath,
+		errors.Is(err, service.ErrInvalidSSHKey),
+		errors.Is(err, service.ErrInvalidDeploymentTarget),
+		errors.Is(err, service.ErrCertificateNoPrivateKey):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
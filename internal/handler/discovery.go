@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/discovery"
+	"github.com/imkerbos/ACME-Console/internal/pagination"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+type DiscoveryHandler struct {
+	svc *service.DiscoveryService
+}
+
+func NewDiscoveryHandler(svc *service.DiscoveryService) *DiscoveryHandler {
+	return &DiscoveryHandler{svc: svc}
+}
+
+// ListJobs handles GET /api/v1/discovery/jobs?workspace_id=
+func (h *DiscoveryHandler) ListJobs(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID := utils.ParseQueryUint(c, "workspace_id")
+	if workspaceID == 0 {
+		response.BadRequest(c, "workspace_id is required")
+		return
+	}
+
+	jobs, err := h.svc.ListJobs(workspaceID, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, jobs)
+}
+
+// CreateJob handles POST /api/v1/discovery/jobs
+func (h *DiscoveryHandler) CreateJob(c *gin.Context) {
+	userID := utils.GetUserID(c)
+
+	var req service.CreateDiscoveryJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	job, err := h.svc.CreateJob(userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, job.ID)
+	utils.SetAuditWorkspace(c, &job.WorkspaceID)
+	response.Created(c, job)
+}
+
+// DeleteJob handles DELETE /api/v1/discovery/jobs/:id
+func (h *DiscoveryHandler) DeleteJob(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid job id")
+		return
+	}
+
+	if err := h.svc.DeleteJob(id, userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "discovery job deleted successfully")
+}
+
+// RunJob handles POST /api/v1/discovery/jobs/:id/run
+func (h *DiscoveryHandler) RunJob(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid job id")
+		return
+	}
+
+	if err := h.svc.RunJob(id, userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "discovery scan started")
+}
+
+// ListCertificates handles GET /api/v1/discovery/certificates?workspace_id=&status=
+func (h *DiscoveryHandler) ListCertificates(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID := utils.ParseQueryUint(c, "workspace_id")
+	if workspaceID == 0 {
+		response.BadRequest(c, "workspace_id is required")
+		return
+	}
+	params := pagination.ParseFromContext(c)
+
+	result, err := h.svc.ListDiscovered(params, workspaceID, userID, c.Query("status"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// Manage handles POST /api/v1/discovery/certificates/:id/manage
+func (h *DiscoveryHandler) Manage(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid discovered certificate id")
+		return
+	}
+
+	var req service.ManageDiscoveredRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.svc.Manage(id, userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Created(c, resp)
+}
+
+// Track handles POST /api/v1/discovery/certificates/:id/track
+func (h *DiscoveryHandler) Track(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid discovered certificate id")
+		return
+	}
+
+	cert, err := h.svc.Track(id, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Created(c, cert)
+}
+
+// Ignore handles POST /api/v1/discovery/certificates/:id/ignore
+func (h *DiscoveryHandler) Ignore(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid discovered certificate id")
+		return
+	}
+
+	if err := h.svc.Ignore(id, userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "discovered certificate ignored")
+}
+
+func (h *DiscoveryHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err == service.ErrWorkspaceAccessDenied:
+		response.Forbidden(c, "access denied")
+	case err == service.ErrDiscoveryJobNotFound:
+		response.NotFound(c, "discovery job not found")
+	case err == service.ErrDiscoveredCertNotFound:
+		response.NotFound(c, "discovered certificate not found")
+	case err == service.ErrDiscoveryJobRunning,
+		err == service.ErrDiscoveredCertLinked,
+		err == service.ErrDiscoveredCertNoSANs,
+		errors.Is(err, discovery.ErrInvalidCIDR),
+		errors.Is(err, discovery.ErrTooManyTargets):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+	"gorm.io/gorm"
+)
+
+type DownloadLinkHandler struct {
+	svc *service.DownloadLinkService
+}
+
+func NewDownloadLinkHandler(svc *service.DownloadLinkService) *DownloadLinkHandler {
+	return &DownloadLinkHandler{svc: svc}
+}
+
+// ListLinks handles GET /api/v1/certificates/:id/download-links
+func (h *DownloadLinkHandler) ListLinks(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	links, err := h.svc.ListLinks(certID, userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, links)
+}
+
+// CreateLink handles POST /api/v1/certificates/:id/download-links
+func (h *DownloadLinkHandler) CreateLink(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	certID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid certificate id")
+		return
+	}
+
+	var req service.CreateDownloadLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, err := h.svc.CreateLink(certID, userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, resp.Link.ID)
+	response.Created(c, resp)
+}
+
+// Redeem handles GET /api/v1/download/:token
+// Authenticated by the link token itself; the link stops working after the first attempt.
+func (h *DownloadLinkHandler) Redeem(c *gin.Context) {
+	data, filename, format, err := h.svc.Redeem(c.Param("token"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, getContentType(format), data)
+}
+
+func (h *DownloadLinkHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case err == service.ErrWorkspaceAccessDenied,
+		err == service.ErrWorkspaceNotFound,
+		err == service.ErrKeyExportDisabled,
+		err == service.ErrKeyExportReauthRequired,
+		err == service.ErrKeyExportInvalidPassword,
+		err == service.ErrKeyExportReauthLocked,
+		err == service.ErrKeyExportReasonRequired,
+		err == service.ErrKeyExportEncryptedOnly:
+		handleKeyExportError(c, err)
+	case err == service.ErrDownloadLinkInvalid:
+		response.NotFound(c, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, "certificate not found")
+	case err == service.ErrCertificateNotReady,
+		err == service.ErrDownloadLinkNoPassword,
+		errors.Is(err, service.ErrDownloadLinkUnsupported),
+		errors.Is(err, service.ErrInvalidBundleOptions):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+)
+
+type EncryptionHandler struct {
+	svc *service.KeyRotationService
+}
+
+func NewEncryptionHandler(svc *service.KeyRotationService) *EncryptionHandler {
+	return &EncryptionHandler{svc: svc}
+}
+
+// Status handles GET /api/v1/admin/encryption
+// Reports the active key and how many secrets are still on older keys.
+func (h *EncryptionHandler) Status(c *gin.Context) {
+	status, err := h.svc.Status()
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, status)
+}
+
+// Rotate handles POST /api/v1/admin/encryption/rotate
+// Starts re-encrypting stored secrets with the active key; poll Status for progress.
+func (h *EncryptionHandler) Rotate(c *gin.Context) {
+	if err := h.svc.Start(); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.OK(c, "key rotation started")
+}
+
+func (h *EncryptionHandler) handleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrEncryptionNotConfigured:
+		response.BadRequest(c, err.Error())
+	case service.ErrKeyRotationRunning:
+		response.Error(c, http.StatusConflict, response.CodeBadRequest, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
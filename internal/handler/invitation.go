@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+type InvitationHandler struct {
+	svc *service.InvitationService
+}
+
+func NewInvitationHandler(svc *service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{svc: svc}
+}
+
+// List handles GET /api/v1/workspaces/:id/invitations
+func (h *InvitationHandler) List(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	invitations, err := h.svc.List(workspaceID, userID)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"invitations":  invitations,
+		"mail_enabled": h.svc.MailEnabled(),
+	})
+}
+
+// Create handles POST /api/v1/workspaces/:id/invitations
+func (h *InvitationHandler) Create(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	var req service.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	resp, changes, err := h.svc.Create(workspaceID, userID, &req)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, resp.Invitation.ID)
+	utils.SetAuditChanges(c, changes)
+	response.Created(c, resp)
+}
+
+// Revoke handles DELETE /api/v1/workspaces/:id/invitations/:invitationId
+func (h *InvitationHandler) Revoke(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+	invitationID, err := utils.ParseIDParam(c, "invitationId")
+	if err != nil {
+		response.BadRequest(c, "invalid invitation id")
+		return
+	}
+
+	changes, err := h.svc.Revoke(workspaceID, userID, invitationID)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	utils.SetAuditChanges(c, changes)
+	response.OK(c, "invitation revoked")
+}
+
+// Preview handles GET /api/v1/invitations/:token
+// Authenticated by the invitation token; shows what accepting it grants.
+func (h *InvitationHandler) Preview(c *gin.Context) {
+	preview, err := h.svc.Preview(c.Param("token"))
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	response.Success(c, preview)
+}
+
+// Accept handles POST /api/v1/invitations/:token/accept
+// The logged-in user joins the workspace.
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	userID := utils.GetUserID(c)
+
+	invitation, changes, err := h.svc.Accept(c.Param("token"), userID)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, invitation.ID)
+	utils.SetAuditWorkspace(c, &invitation.WorkspaceID)
+	utils.SetAuditChanges(c, changes)
+	response.Success(c, gin.H{"workspace_id": invitation.WorkspaceID})
+}
+
+// handleInvitationError maps invitation errors, and the role errors shared
+// with member endpoints
+func handleInvitationError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrInvitationInvalid, service.ErrInvitationNotFound:
+		response.NotFound(c, err.Error())
+	case service.ErrUserAlreadyMember, service.ErrInvitationEmailRequired, service.ErrMailNotConfigured,
+		service.ErrUsernameTaken:
+		response.BadRequest(c, err.Error())
+	default:
+		handleRoleError(c, err)
+	}
+}
+
+// InvitationSignup handles POST /api/v1/invitations/:token/signup
+// Creates a local account for the invitee, adds it to the workspace and logs
+// it in. Not offered where only administrators may use local passwords.
+func (h *AuthHandler) InvitationSignup(c *gin.Context) {
+	if h.oidcSvc.AdminsOnlyPasswordLogin() {
+		response.Forbidden(c, "password login is disabled, please use single sign-on")
+		return
+	}
+
+	var req service.InvitationSignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+	if err := h.policy.Validate(req.Password, req.Username); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	user, invitation, err := h.invitations.Signup(c.Param("token"), &req)
+	if err != nil {
+		handleInvitationError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, invitation.ID)
+	utils.SetAuditWorkspace(c, &invitation.WorkspaceID)
+	utils.SetAuditChanges(c, service.DiffFields(nil, map[string]interface{}{
+		"username": user.Username,
+		"role":     invitation.Role,
+	}))
+	h.completeLogin(c, user)
+}
@@ -0,0 +1,461 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+// StepUpHeader carries a step-up grant to sensitive endpoints
+const StepUpHeader = "X-Step-Up-Token"
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Method   string `json:"method" binding:"required,oneof=totp recovery"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFAPasskeyRequest struct {
+	MFAToken   string          `json:"mfa_token" binding:"required"`
+	Name       string          `json:"name" binding:"max=100"` // Registration only
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type PasskeyRequest struct {
+	Name       string          `json:"name" binding:"max=100"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// StepUpRequest re-authenticates with one of: the login password (users
+// without a second factor), a TOTP or recovery code, or a passkey assertion
+// for the mfa_token from POST /auth/step-up/options
+type StepUpRequest struct {
+	Method     string          `json:"method" binding:"required,oneof=password totp recovery webauthn"`
+	Password   string          `json:"password"`
+	Code       string          `json:"code"`
+	MFAToken   string          `json:"mfa_token"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// MFAVerify handles POST /api/v1/auth/mfa/login/verify
+func (h *AuthHandler) MFAVerify(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, ip, ok := h.guardMFALogin(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	verified, err := h.mfaSvc.VerifyLogin(req.MFAToken, req.Method, req.Code)
+	if err != nil {
+		h.failMFALogin(c, user, ip, err)
+		return
+	}
+	h.loginGuard.Succeed(verified, ip)
+	h.startSession(c, verified)
+}
+
+// guardMFALogin loads the user of a login challenge and enforces the login
+// throttle on second factor attempts. Each challenge allows a few attempts,
+// but new challenges are one password away, so the lockout is what bounds
+// guessing.
+func (h *AuthHandler) guardMFALogin(c *gin.Context, token string) (*model.User, string, bool) {
+	user, err := h.mfaSvc.LoginChallengeUser(token)
+	if err != nil {
+		handleMFAError(c, err)
+		return nil, "", false
+	}
+	ip := c.ClientIP()
+	if wait := h.loginGuard.Wait(user.Username, ip); wait > 0 {
+		tooManyAttempts(c, wait)
+		return nil, "", false
+	}
+	return user, ip, true
+}
+
+// failMFALogin counts a wrong second factor like a wrong password
+func (h *AuthHandler) failMFALogin(c *gin.Context, user *model.User, ip string, err error) {
+	if errors.Is(err, service.ErrMFAInvalidCode) || errors.Is(err, service.ErrWebAuthnFailed) {
+		h.loginGuard.Fail(user.Username, ip, user, "wrong second factor")
+	}
+	handleMFAError(c, err)
+}
+
+// MFAPasskeyOptions handles POST /api/v1/auth/mfa/login/webauthn/options
+func (h *AuthHandler) MFAPasskeyOptions(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	options, err := h.mfaSvc.BeginPasskeyLogin(req.MFAToken)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, options)
+}
+
+// MFAPasskeyVerify handles POST /api/v1/auth/mfa/login/webauthn
+func (h *AuthHandler) MFAPasskeyVerify(c *gin.Context) {
+	var req MFAPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, ip, ok := h.guardMFALogin(c, req.MFAToken)
+	if !ok {
+		return
+	}
+	verified, err := h.mfaSvc.VerifyPasskeyLogin(req.MFAToken, req.Credential)
+	if err != nil {
+		h.failMFALogin(c, user, ip, err)
+		return
+	}
+	h.loginGuard.Succeed(verified, ip)
+	h.startSession(c, verified)
+}
+
+// MFAEnrollTOTP handles POST /api/v1/auth/mfa/enroll/totp
+// For users the policy requires to set up a second factor before logging in.
+func (h *AuthHandler) MFAEnrollTOTP(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, err := h.mfaSvc.EnrollmentUser(req.MFAToken)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	setup, err := h.mfaSvc.SetupTOTP(user)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, setup)
+}
+
+// MFAEnrollTOTPEnable handles POST /api/v1/auth/mfa/enroll/totp/enable
+func (h *AuthHandler) MFAEnrollTOTPEnable(c *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, err := h.mfaSvc.EnrollmentUser(req.MFAToken)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	codes, err := h.mfaSvc.EnableTOTP(user.ID, req.Code)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	h.completeEnrollment(c, req.MFAToken, codes)
+}
+
+// MFAEnrollPasskeyOptions handles POST /api/v1/auth/mfa/enroll/webauthn/options
+func (h *AuthHandler) MFAEnrollPasskeyOptions(c *gin.Context) {
+	var req MFATokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, err := h.mfaSvc.EnrollmentUser(req.MFAToken)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	options, err := h.mfaSvc.BeginPasskeyRegistration(user)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, options)
+}
+
+// MFAEnrollPasskey handles POST /api/v1/auth/mfa/enroll/webauthn
+func (h *AuthHandler) MFAEnrollPasskey(c *gin.Context) {
+	var req MFAPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, err := h.mfaSvc.EnrollmentUser(req.MFAToken)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	_, codes, err := h.mfaSvc.FinishPasskeyRegistration(user, req.Name, req.Credential)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	h.completeEnrollment(c, req.MFAToken, codes)
+}
+
+// completeEnrollment logs in a user who just set up their first second factor
+func (h *AuthHandler) completeEnrollment(c *gin.Context, token string, recoveryCodes []string) {
+	user, err := h.mfaSvc.CompleteEnrollment(token)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	session, err := h.newSession(c, user)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	session.RecoveryCodes = recoveryCodes
+	response.Success(c, session)
+}
+
+// MFAStatus handles GET /api/v1/auth/mfa
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.mfaSvc.Status(user)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, status)
+}
+
+// SetupTOTP handles POST /api/v1/auth/mfa/totp
+func (h *AuthHandler) SetupTOTP(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	setup, err := h.mfaSvc.SetupTOTP(user)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, setup)
+}
+
+// EnableTOTP handles POST /api/v1/auth/mfa/totp/enable
+func (h *AuthHandler) EnableTOTP(c *gin.Context) {
+	var req TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	codes, err := h.mfaSvc.EnableTOTP(utils.GetUserID(c), req.Code)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTP handles DELETE /api/v1/auth/mfa/totp (step-up required)
+func (h *AuthHandler) DisableTOTP(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mfaSvc.DisableTOTP(user, c.GetHeader(StepUpHeader)); err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.OK(c, "authenticator app removed")
+}
+
+// PasskeyOptions handles POST /api/v1/auth/mfa/webauthn/options
+func (h *AuthHandler) PasskeyOptions(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	options, err := h.mfaSvc.BeginPasskeyRegistration(user)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, options)
+}
+
+// RegisterPasskey handles POST /api/v1/auth/mfa/webauthn
+func (h *AuthHandler) RegisterPasskey(c *gin.Context) {
+	var req PasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	passkey, codes, err := h.mfaSvc.FinishPasskeyRegistration(user, req.Name, req.Credential)
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Created(c, gin.H{"passkey": passkey, "recovery_codes": codes})
+}
+
+// DeletePasskey handles DELETE /api/v1/auth/mfa/webauthn/:id (step-up required)
+func (h *AuthHandler) DeletePasskey(c *gin.Context) {
+	id, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid passkey id")
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mfaSvc.DeletePasskey(user, id, c.GetHeader(StepUpHeader)); err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.OK(c, "passkey removed")
+}
+
+// RegenerateRecoveryCodes handles POST /api/v1/auth/mfa/recovery-codes (step-up required)
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	codes, err := h.mfaSvc.RegenerateRecoveryCodesWithStepUp(utils.GetUserID(c), c.GetHeader(StepUpHeader))
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"recovery_codes": codes})
+}
+
+// StepUpOptions handles POST /api/v1/auth/step-up/options
+// Starts a passkey re-authentication.
+func (h *AuthHandler) StepUpOptions(c *gin.Context) {
+	token, options, err := h.mfaSvc.BeginStepUpPasskey(utils.GetUserID(c))
+	if err != nil {
+		handleMFAError(c, err)
+		return
+	}
+	response.Success(c, gin.H{"mfa_token": token, "options": options})
+}
+
+// StepUp handles POST /api/v1/auth/step-up
+// Returns a grant for the X-Step-Up-Token header of sensitive actions such as
+// private key export.
+func (h *AuthHandler) StepUp(c *gin.Context) {
+	var req StepUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if wait := h.loginGuard.StepUpWait(user.ID); wait > 0 {
+		tooManyAttempts(c, wait)
+		return
+	}
+
+	var grant *service.StepUpGrant
+	var err error
+	switch req.Method {
+	case service.StepUpPassword:
+		if !h.checkPassword(user, req.Password) {
+			h.loginGuard.StepUpFail(user, c.ClientIP(), "wrong password")
+			response.BadRequest(c, "invalid password")
+			return
+		}
+		grant, err = h.mfaSvc.StepUpWithPassword(user.ID)
+	case model.MFAMethodWebAuthn:
+		grant, err = h.mfaSvc.StepUpWithPasskey(user.ID, req.MFAToken, req.Credential)
+	default:
+		grant, err = h.mfaSvc.StepUpWithCode(user.ID, req.Method, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrMFAInvalidCode) || errors.Is(err, service.ErrWebAuthnFailed) {
+			h.loginGuard.StepUpFail(user, c.ClientIP(), "wrong second factor")
+		}
+		handleMFAError(c, err)
+		return
+	}
+	h.loginGuard.StepUpSucceed(user.ID)
+	response.Success(c, grant)
+}
+
+// checkPassword verifies a password against the user's own source
+func (h *AuthHandler) checkPassword(user *model.User, password string) bool {
+	if password == "" {
+		return false
+	}
+	if user.AuthSource == model.AuthSourceLDAP {
+		_, err := h.ldapSvc.Authenticate(user.Username, password)
+		return err == nil
+	}
+	return user.CheckPassword(password)
+}
+
+// currentUser loads the logged-in user, or responds with an error
+func (h *AuthHandler) currentUser(c *gin.Context) (*model.User, bool) {
+	var user model.User
+	if err := h.db.First(&user, utils.GetUserID(c)).Error; err != nil {
+		response.NotFound(c, "user not found")
+		return nil, false
+	}
+	return &user, true
+}
+
+func handleMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrMFAStepUpRequired),
+		errors.Is(err, service.ErrMFASecondFactor),
+		errors.Is(err, service.ErrMFARequired),
+		errors.Is(err, service.ErrMFAUserDisabled):
+		response.Forbidden(c, err.Error())
+	case errors.Is(err, service.ErrPasskeyNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, service.ErrMFAChallengeInvalid),
+		errors.Is(err, service.ErrMFAInvalidCode),
+		errors.Is(err, service.ErrMFAMethodUnavailable),
+		errors.Is(err, service.ErrMFANotEnrolled),
+		errors.Is(err, service.ErrMFAAlreadyEnabled),
+		errors.Is(err, service.ErrWebAuthnFailed):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
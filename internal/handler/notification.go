@@ -8,11 +8,31 @@ import (
 )
 
 type NotificationHandler struct {
-	svc *service.NotificationService
+	svc          *service.NotificationService
+	workspaceSvc *service.WorkspaceService
 }
 
-func NewNotificationHandler(svc *service.NotificationService) *NotificationHandler {
-	return &NotificationHandler{svc: svc}
+func NewNotificationHandler(svc *service.NotificationService, workspaceSvc *service.WorkspaceService) *NotificationHandler {
+	return &NotificationHandler{svc: svc, workspaceSvc: workspaceSvc}
+}
+
+// canManage checks notification.manage for workspace-level channels
+func (h *NotificationHandler) canManage(c *gin.Context, workspaceID *uint) bool {
+	if workspaceID == nil || h.workspaceSvc.CanManageNotifications(*workspaceID, utils.GetUserID(c)) {
+		return true
+	}
+	response.Forbidden(c, "access denied")
+	return false
+}
+
+// canManageConfig loads a channel and checks notification.manage on its workspace
+func (h *NotificationHandler) canManageConfig(c *gin.Context, id uint) bool {
+	config, err := h.svc.GetConfig(id)
+	if err != nil {
+		response.NotFound(c, "notification config not found")
+		return false
+	}
+	return h.canManage(c, config.WorkspaceID)
 }
 
 // List handles GET /api/v1/notifications
@@ -50,12 +70,18 @@ func (h *NotificationHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if !h.canManage(c, req.WorkspaceID) {
+		return
+	}
+
 	config, err := h.svc.CreateConfig(&req)
 	if err != nil {
 		response.InternalError(c, err)
 		return
 	}
 
+	utils.SetAuditResource(c, config.ID)
+	utils.SetAuditWorkspace(c, config.WorkspaceID)
 	response.Created(c, config)
 }
 
@@ -90,6 +116,10 @@ func (h *NotificationHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if !h.canManageConfig(c, id) || !h.canManage(c, req.WorkspaceID) {
+		return
+	}
+
 	if err := h.svc.UpdateConfig(id, &req); err != nil {
 		response.InternalError(c, err)
 		return
@@ -106,6 +136,10 @@ func (h *NotificationHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	if !h.canManageConfig(c, id) {
+		return
+	}
+
 	if err := h.svc.DeleteConfig(id); err != nil {
 		response.InternalError(c, err)
 		return
@@ -141,6 +175,10 @@ func (h *NotificationHandler) Test(c *gin.Context) {
 		return
 	}
 
+	if !h.canManageConfig(c, id) {
+		return
+	}
+
 	if err := h.svc.TestWebhook(id); err != nil {
 		response.InternalError(c, err)
 		return
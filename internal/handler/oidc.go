@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+)
+
+// oidcLoginPage is where the browser lands after the provider callback
+const oidcLoginPage = "/login"
+
+// oidcStateCookie binds a login to the browser that started it
+const oidcStateCookie = "acme_oidc_state"
+
+// OIDCProviders handles GET /api/v1/auth/oidc/providers
+func (h *AuthHandler) OIDCProviders(c *gin.Context) {
+	passwordLogin := "all"
+	if h.oidcSvc.AdminsOnlyPasswordLogin() {
+		passwordLogin = service.PasswordLoginAdmins
+	}
+	response.Success(c, gin.H{
+		"providers":      h.oidcSvc.Providers(),
+		"password_login": passwordLogin,
+	})
+}
+
+// OIDCLogin handles GET /api/v1/auth/oidc/:provider/login
+// Redirects the browser to the provider's authorization endpoint. The
+// invitation page passes ?invite=<token> to join a workspace on the way.
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	target, state, err := h.oidcSvc.LoginURL(c.Request.Context(), c.Param("provider"), c.Query("invite"))
+	if err != nil {
+		redirectOIDCError(c, err)
+		return
+	}
+	setOIDCStateCookie(c, state, int(service.OIDCLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, target)
+}
+
+// OIDCCallback handles GET /api/v1/auth/oidc/:provider/callback
+// The login page receives a one-time code to exchange for a session.
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		redirectOIDCError(c, errors.New(errCode+": "+c.Query("error_description")))
+		return
+	}
+
+	browserState, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+
+	code, err := h.oidcSvc.Callback(c.Request.Context(), c.Param("provider"), c.Query("state"), browserState, c.Query("code"), c.ClientIP())
+	if err != nil {
+		redirectOIDCError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, oidcLoginPage+"?sso_code="+url.QueryEscape(code))
+}
+
+// OIDCExchange handles POST /api/v1/auth/oidc/exchange
+func (h *AuthHandler) OIDCExchange(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	user, err := h.oidcSvc.Exchange(req.Code)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	h.completeLogin(c, user)
+}
+
+// setOIDCStateCookie sets or, with a negative maxAge, clears the state cookie.
+// Lax still sends it on the provider's top-level redirect back.
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, "/api/v1/auth/oidc", "", secure, true)
+}
+
+func redirectOIDCError(c *gin.Context, err error) {
+	c.Redirect(http.StatusFound, oidcLoginPage+"?sso_error="+url.QueryEscape(err.Error()))
+}
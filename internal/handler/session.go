@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// SessionInfo is a session as listed to its user
+type SessionInfo struct {
+	model.Session
+	Current bool `json:"current"`
+}
+
+// Refresh handles POST /api/v1/auth/refresh
+// The refresh token is single-use: the response carries its replacement.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	session, user, refreshToken, err := h.sessionSvc.Refresh(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, service.ErrSessionInvalid) {
+			response.Unauthorized(c, err.Error())
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	resp, err := h.sessionResponse(user, session.ID, refreshToken)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, resp)
+}
+
+// Logout handles POST /api/v1/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if err := h.sessionSvc.Revoke(userID, c.GetUint("sessionID")); err != nil && !errors.Is(err, service.ErrSessionNotFound) {
+		response.InternalError(c, err)
+		return
+	}
+	response.OK(c, "logged out")
+}
+
+// ListSessions handles GET /api/v1/auth/sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.sessionSvc.List(c.GetUint("userID"))
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	current := c.GetUint("sessionID")
+	items := make([]SessionInfo, len(sessions))
+	for i, s := range sessions {
+		items[i] = SessionInfo{Session: s, Current: s.ID == current}
+	}
+	response.Success(c, items)
+}
+
+// RevokeSession handles DELETE /api/v1/auth/sessions/:id
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid session id")
+		return
+	}
+
+	if err := h.sessionSvc.Revoke(c.GetUint("userID"), uint(id)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			response.NotFound(c, err.Error())
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+	response.OK(c, "session revoked")
+}
+
+// RevokeAllSessions handles DELETE /api/v1/auth/sessions
+// Logs out every device, the current one included.
+func (h *AuthHandler) RevokeAllSessions(c *gin.Context) {
+	if err := h.sessionSvc.RevokeAll(c.GetUint("userID"), 0); err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.OK(c, "all sessions revoked")
+}
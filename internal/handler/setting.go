@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/imkerbos/ACME-Console/internal/model"
 	"github.com/imkerbos/ACME-Console/internal/response"
 	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
 )
 
 type SettingHandler struct {
@@ -48,18 +51,15 @@ func (h *SettingHandler) UpdateACME(c *gin.Context) {
 		return
 	}
 
+	updates := make(map[string]string)
 	if req.DNSResolvers != nil {
-		if err := h.svc.Set(model.SettingDNSResolvers, *req.DNSResolvers); err != nil {
-			response.InternalError(c, err)
-			return
-		}
+		updates[model.SettingDNSResolvers] = *req.DNSResolvers
 	}
-
 	if req.DNSTimeout != nil {
-		if err := h.svc.Set(model.SettingDNSTimeout, *req.DNSTimeout); err != nil {
-			response.InternalError(c, err)
-			return
-		}
+		updates[model.SettingDNSTimeout] = *req.DNSTimeout
+	}
+	if !h.apply(c, updates) {
+		return
 	}
 
 	// 返回更新后的配置
@@ -89,24 +89,72 @@ func (h *SettingHandler) UpdateSite(c *gin.Context) {
 		return
 	}
 
+	updates := make(map[string]string)
 	if req.Title != nil {
-		if err := h.svc.Set(model.SettingSiteTitle, *req.Title); err != nil {
-			response.InternalError(c, err)
-			return
-		}
+		updates[model.SettingSiteTitle] = *req.Title
 	}
-
 	if req.Subtitle != nil {
-		if err := h.svc.Set(model.SettingSiteSubtitle, *req.Subtitle); err != nil {
-			response.InternalError(c, err)
-			return
-		}
+		updates[model.SettingSiteSubtitle] = *req.Subtitle
+	}
+	if !h.apply(c, updates) {
+		return
 	}
 
 	config := h.svc.GetSiteConfig()
 	response.Success(c, config)
 }
 
+// GetSecurity 获取安全策略配置
+// GET /api/v1/admin/settings/security
+func (h *SettingHandler) GetSecurity(c *gin.Context) {
+	response.Success(c, h.svc.GetSecurityConfig())
+}
+
+// UpdateSecurityRequest 安全策略更新请求
+type UpdateSecurityRequest struct {
+	MFARequireAdmins          *bool `json:"mfa_require_admins"`
+	MFARequireWorkspaceOwners *bool `json:"mfa_require_workspace_owners"`
+	PasswordMinLength         *int  `json:"password_min_length" binding:"omitempty,min=8,max=72"`
+	LockoutThreshold          *int  `json:"lockout_threshold" binding:"omitempty,min=3,max=100"`
+	LockoutMinutes            *int  `json:"lockout_minutes" binding:"omitempty,min=1,max=1440"`
+	AuditRetentionDays        *int  `json:"audit_retention_days" binding:"omitempty,min=0,max=3650"`
+}
+
+// UpdateSecurity 更新安全策略配置
+// PUT /api/v1/admin/settings/security
+func (h *SettingHandler) UpdateSecurity(c *gin.Context) {
+	var req UpdateSecurityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	updates := make(map[string]string)
+	if req.MFARequireAdmins != nil {
+		updates[model.SettingMFARequireAdmins] = strconv.FormatBool(*req.MFARequireAdmins)
+	}
+	if req.MFARequireWorkspaceOwners != nil {
+		updates[model.SettingMFARequireWorkspaceOwners] = strconv.FormatBool(*req.MFARequireWorkspaceOwners)
+	}
+	if req.PasswordMinLength != nil {
+		updates[model.SettingPasswordMinLength] = strconv.Itoa(*req.PasswordMinLength)
+	}
+	if req.LockoutThreshold != nil {
+		updates[model.SettingLockoutThreshold] = strconv.Itoa(*req.LockoutThreshold)
+	}
+	if req.LockoutMinutes != nil {
+		updates[model.SettingLockoutMinutes] = strconv.Itoa(*req.LockoutMinutes)
+	}
+	if req.AuditRetentionDays != nil {
+		updates[model.SettingAuditRetentionDays] = strconv.Itoa(*req.AuditRetentionDays)
+	}
+	if !h.apply(c, updates) {
+		return
+	}
+
+	response.Success(c, h.svc.GetSecurityConfig())
+}
+
 // UpdateRequest 通用配置更新请求
 type UpdateRequest struct {
 	Settings map[string]string `json:"settings" binding:"required"`
@@ -121,8 +169,7 @@ func (h *SettingHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.SetMultiple(req.Settings); err != nil {
-		response.InternalError(c, err)
+	if !h.apply(c, req.Settings) {
 		return
 	}
 
@@ -133,3 +180,14 @@ func (h *SettingHandler) Update(c *gin.Context) {
 	}
 	response.Success(c, settings)
 }
+
+// apply 保存配置，并将新旧值记录到审计日志
+func (h *SettingHandler) apply(c *gin.Context, updates map[string]string) bool {
+	changes := h.svc.Changes(updates)
+	if err := h.svc.SetMultiple(updates); err != nil {
+		response.InternalError(c, err)
+		return false
+	}
+	utils.SetAuditChanges(c, changes)
+	return true
+}
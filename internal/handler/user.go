@@ -1,21 +1,35 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/imkerbos/ACME-Console/internal/model"
 	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
 	"github.com/imkerbos/ACME-Console/internal/utils"
 	"gorm.io/gorm"
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db           *gorm.DB
+	mfaSvc       *service.MFAService
+	sessionSvc   *service.SessionService
+	loginGuard   *service.LoginGuardService
+	policy       *service.PasswordPolicyService
+	workspaceSvc *service.WorkspaceService
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *gorm.DB, mfaSvc *service.MFAService, sessionSvc *service.SessionService, loginGuard *service.LoginGuardService, policy *service.PasswordPolicyService, workspaceSvc *service.WorkspaceService) *UserHandler {
+	return &UserHandler{
+		db:           db,
+		mfaSvc:       mfaSvc,
+		sessionSvc:   sessionSvc,
+		loginGuard:   loginGuard,
+		policy:       policy,
+		workspaceSvc: workspaceSvc,
+	}
 }
 
 type UserListItem struct {
@@ -27,6 +41,8 @@ type UserListItem struct {
 	Status    int    `json:"status"`
 	LastLogin string `json:"last_login,omitempty"`
 	CreatedAt string `json:"created_at"`
+
+	LockedUntil string `json:"locked_until,omitempty"` // Set while failed logins lock the account
 }
 
 // List handles GET /api/v1/admin/users
@@ -35,7 +51,8 @@ func (h *UserHandler) List(c *gin.Context) {
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 	keyword := c.Query("keyword")
 
-	query := h.db.Model(&model.User{})
+	// Service accounts are managed from their workspace
+	query := h.db.Model(&model.User{}).Where("service_account = ?", false)
 
 	if keyword != "" {
 		query = query.Where("username LIKE ? OR nickname LIKE ? OR email LIKE ?",
@@ -52,6 +69,12 @@ func (h *UserHandler) List(c *gin.Context) {
 		return
 	}
 
+	usernames := make([]string, len(users))
+	for i, u := range users {
+		usernames[i] = u.Username
+	}
+	locked := h.loginGuard.LockedUntil(usernames)
+
 	items := make([]UserListItem, len(users))
 	for i, u := range users {
 		items[i] = UserListItem{
@@ -66,6 +89,9 @@ func (h *UserHandler) List(c *gin.Context) {
 		if u.LastLogin != nil {
 			items[i].LastLogin = u.LastLogin.Format("2006-01-02 15:04:05")
 		}
+		if until, ok := locked[u.Username]; ok {
+			items[i].LockedUntil = until.Format("2006-01-02 15:04:05")
+		}
 	}
 
 	response.Success(c, utils.NewPagination(items, int(total), page, pageSize))
@@ -103,7 +129,7 @@ func (h *UserHandler) Get(c *gin.Context) {
 
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Nickname string `json:"nickname"`
 	Email    string `json:"email"`
 	Role     string `json:"role" binding:"required,oneof=admin user"`
@@ -125,12 +151,21 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if err := h.policy.Validate(req.Password, req.Username); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	// The admin knows the initial password, so the user replaces it first
 	user := &model.User{
 		Username: req.Username,
 		Nickname: req.Nickname,
 		Email:    req.Email,
 		Role:     req.Role,
 		Status:   1,
+
+		EmailVerified:      req.Email != "",
+		MustChangePassword: true,
 	}
 
 	if err := user.SetPassword(req.Password); err != nil {
@@ -143,6 +178,11 @@ func (h *UserHandler) Create(c *gin.Context) {
 		return
 	}
 
+	utils.SetAuditResource(c, user.ID)
+	utils.SetAuditChanges(c, service.DiffFields(nil, map[string]interface{}{
+		"username": user.Username,
+		"role":     user.Role,
+	}))
 	response.Success(c, gin.H{"id": user.ID})
 }
 
@@ -199,6 +239,7 @@ func (h *UserHandler) Update(c *gin.Context) {
 	}
 	if req.Email != "" {
 		updates["email"] = req.Email
+		updates["email_verified"] = true
 	}
 	if req.Role != "" {
 		updates["role"] = req.Role
@@ -207,6 +248,21 @@ func (h *UserHandler) Update(c *gin.Context) {
 		updates["status"] = *req.Status
 	}
 
+	// Access tokens carry the role, so role and status changes end the
+	// user's sessions
+	revokeSessions := (req.Role != "" && req.Role != user.Role) || (req.Status != nil && *req.Status != user.Status)
+	before := map[string]interface{}{
+		"nickname": user.Nickname,
+		"email":    user.Email,
+		"role":     user.Role,
+		"status":   user.Status,
+	}
+	for key := range before {
+		if _, ok := updates[key]; !ok {
+			delete(before, key)
+		}
+	}
+
 	if len(updates) > 0 {
 		if err := h.db.Model(&user).Updates(updates).Error; err != nil {
 			response.InternalError(c, err)
@@ -214,14 +270,23 @@ func (h *UserHandler) Update(c *gin.Context) {
 		}
 	}
 
+	if revokeSessions {
+		if err := h.sessionSvc.RevokeAll(user.ID, 0); err != nil {
+			response.InternalError(c, err)
+			return
+		}
+	}
+
+	utils.SetAuditChanges(c, service.DiffFields(before, updates))
 	response.OK(c, "user updated successfully")
 }
 
 type ResetPasswordRequest struct {
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 }
 
 // ResetPassword handles POST /api/v1/admin/users/:id/reset-password
+// The user has to choose a new password at their next login.
 func (h *UserHandler) ResetPassword(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -241,26 +306,98 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	if err := h.policy.Validate(req.Password, user.Username); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	if err := user.SetPassword(req.Password); err != nil {
 		response.InternalError(c, err)
 		return
 	}
 
-	if err := h.db.Model(&user).Update("password", user.Password).Error; err != nil {
+	if err := h.db.Model(&user).Updates(map[string]interface{}{
+		"password":             user.Password,
+		"must_change_password": true,
+	}).Error; err != nil {
 		response.InternalError(c, err)
 		return
 	}
 
+	if err := h.sessionSvc.RevokeAll(user.ID, 0); err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	h.loginGuard.Unlock(user.Username)
+
 	response.OK(c, "password reset successfully")
 }
 
+// Unlock handles POST /api/v1/admin/users/:id/unlock
+// Clears the lockout after repeated failed logins.
+func (h *UserHandler) Unlock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid user id")
+		return
+	}
+
+	var user model.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		response.NotFound(c, "user not found")
+		return
+	}
+
+	if err := h.loginGuard.Unlock(user.Username); err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.OK(c, "user unlocked successfully")
+}
+
+// ResetMFA handles DELETE /api/v1/admin/users/:id/mfa
+// Removes every second factor of a user who lost their devices.
+func (h *UserHandler) ResetMFA(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid user id")
+		return
+	}
+
+	var user model.User
+	if err := h.db.First(&user, id).Error; err != nil {
+		response.NotFound(c, "user not found")
+		return
+	}
+
+	if err := h.mfaSvc.ResetUser(user.ID); err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.OK(c, "two-factor authentication reset successfully")
+}
+
 // Delete handles DELETE /api/v1/admin/users/:id
+// Query parameters:
+//   - reassign_to: user who takes over the workspaces and private certificates
+//     the deleted user owns; required when they own any
 func (h *UserHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		response.BadRequest(c, "invalid user id")
 		return
 	}
+	var reassignTo uint
+	if v := c.Query("reassign_to"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			response.BadRequest(c, "invalid reassign_to user id")
+			return
+		}
+		reassignTo = uint(n)
+	}
 
 	// Get current user from context
 	currentUserID, _ := c.Get("userID")
@@ -285,10 +422,28 @@ func (h *UserHandler) Delete(c *gin.Context) {
 		}
 	}
 
-	if err := h.db.Delete(&user).Error; err != nil {
-		response.InternalError(c, err)
+	// Nothing is changed until whatever the user owns has somewhere to go;
+	// the account and its sign-in state are removed together
+	plan, err := h.workspaceSvc.RemoveUser(&user, reassignTo)
+	if err != nil {
+		handleRemoveUserError(c, err)
 		return
 	}
 
+	if plan.ToUserID != 0 {
+		utils.SetAuditChanges(c, service.DiffFields(nil, map[string]interface{}{
+			"reassigned_to":        plan.ToUserID,
+			"workspaces":           plan.Workspaces,
+			"private_certificates": plan.PrivateCertificates,
+		}))
+	}
 	response.OK(c, "user deleted successfully")
 }
+
+func handleRemoveUserError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrReassignTargetInvalid) {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.InternalError(c, err)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -46,6 +47,8 @@ func (h *WorkspaceHandler) Create(c *gin.Context) {
 		return
 	}
 
+	utils.SetAuditResource(c, workspace.ID)
+	utils.SetAuditWorkspace(c, &workspace.ID)
 	response.Created(c, workspace)
 }
 
@@ -90,15 +93,21 @@ func (h *WorkspaceHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.Update(workspaceID, userID, &req); err != nil {
+	changes, err := h.svc.Update(workspaceID, userID, &req)
+	if err != nil {
 		if err == service.ErrWorkspaceAccessDenied {
 			response.Forbidden(c, "access denied")
 			return
 		}
+		if err == service.ErrWorkspaceArchived {
+			response.BadRequest(c, "workspace is archived, restore it first")
+			return
+		}
 		response.InternalError(c, err)
 		return
 	}
 
+	utils.SetAuditChanges(c, changes)
 	response.OK(c, "workspace updated successfully")
 }
 
@@ -127,6 +136,85 @@ func (h *WorkspaceHandler) Delete(c *gin.Context) {
 	response.OK(c, "workspace deleted successfully")
 }
 
+// RequestTransfer handles POST /api/v1/workspaces/:id/transfer
+// The owner offers the workspace to an admin member.
+func (h *WorkspaceHandler) RequestTransfer(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	var req service.TransferOwnershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	changes, err := h.svc.RequestTransfer(workspaceID, userID, &req)
+	if err != nil {
+		handleTransferError(c, err)
+		return
+	}
+
+	utils.SetAuditChanges(c, changes)
+	response.OK(c, "ownership transfer requested")
+}
+
+// CancelTransfer handles DELETE /api/v1/workspaces/:id/transfer
+// The owner withdraws the offer, or the nominee declines it.
+func (h *WorkspaceHandler) CancelTransfer(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	changes, err := h.svc.CancelTransfer(workspaceID, userID)
+	if err != nil {
+		handleTransferError(c, err)
+		return
+	}
+
+	utils.SetAuditChanges(c, changes)
+	response.OK(c, "ownership transfer cancelled")
+}
+
+// AcceptTransfer handles POST /api/v1/workspaces/:id/transfer/accept
+func (h *WorkspaceHandler) AcceptTransfer(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	changes, err := h.svc.AcceptTransfer(workspaceID, userID)
+	if err != nil {
+		handleTransferError(c, err)
+		return
+	}
+
+	utils.SetAuditChanges(c, changes)
+	response.OK(c, "ownership transferred")
+}
+
+// handleTransferError maps ownership transfer errors
+func handleTransferError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrWorkspaceAccessDenied:
+		response.Forbidden(c, "access denied")
+	case service.ErrWorkspaceNotFound:
+		response.NotFound(c, "workspace not found")
+	case service.ErrTransferTargetInvalid, service.ErrNoPendingTransfer:
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
+
 // ListMembers handles GET /api/v1/workspaces/:id/members
 func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
 	userID := utils.GetUserID(c)
@@ -164,7 +252,8 @@ func (h *WorkspaceHandler) AddMember(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.AddMember(workspaceID, userID, &req); err != nil {
+	changes, err := h.svc.AddMember(workspaceID, userID, &req)
+	if err != nil {
 		if err == service.ErrWorkspaceAccessDenied {
 			response.Forbidden(c, "access denied")
 			return
@@ -173,10 +262,12 @@ func (h *WorkspaceHandler) AddMember(c *gin.Context) {
 			response.BadRequest(c, "user is already a member")
 			return
 		}
-		response.InternalError(c, err)
+		handleRoleError(c, err)
 		return
 	}
 
+	utils.SetAuditResource(c, req.UserID)
+	utils.SetAuditChanges(c, changes)
 	response.Created(c, gin.H{"message": "member added successfully"})
 }
 
@@ -203,7 +294,8 @@ func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.UpdateMember(workspaceID, userID, memberUserID, &req); err != nil {
+	changes, err := h.svc.UpdateMember(workspaceID, userID, memberUserID, &req)
+	if err != nil {
 		if err == service.ErrWorkspaceAccessDenied {
 			response.Forbidden(c, "access denied")
 			return
@@ -216,10 +308,11 @@ func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
 			response.BadRequest(c, "cannot change owner role")
 			return
 		}
-		response.InternalError(c, err)
+		handleRoleError(c, err)
 		return
 	}
 
+	utils.SetAuditChanges(c, changes)
 	response.OK(c, "member updated successfully")
 }
 
@@ -240,7 +333,8 @@ func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
 	}
 	memberUserID := uint(memberUserIDInt)
 
-	if err := h.svc.RemoveMember(workspaceID, userID, memberUserID); err != nil {
+	changes, err := h.svc.RemoveMember(workspaceID, userID, memberUserID)
+	if err != nil {
 		if err == service.ErrWorkspaceAccessDenied {
 			response.Forbidden(c, "access denied")
 			return
@@ -253,9 +347,118 @@ func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
 			response.BadRequest(c, "cannot remove workspace owner")
 			return
 		}
-		response.InternalError(c, err)
+		handleRoleError(c, err)
 		return
 	}
 
+	utils.SetAuditChanges(c, changes)
 	response.OK(c, "member removed successfully")
 }
+
+// ListRoles handles GET /api/v1/workspaces/:id/roles
+func (h *WorkspaceHandler) ListRoles(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	roles, err := h.svc.ListRoles(workspaceID, userID)
+	if err != nil {
+		handleRoleError(c, err)
+		return
+	}
+
+	response.Success(c, roles)
+}
+
+// CreateRole handles POST /api/v1/workspaces/:id/roles
+func (h *WorkspaceHandler) CreateRole(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+
+	var req service.WorkspaceRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	role, err := h.svc.CreateRole(workspaceID, userID, &req)
+	if err != nil {
+		handleRoleError(c, err)
+		return
+	}
+
+	utils.SetAuditResource(c, role.ID)
+	response.Created(c, role)
+}
+
+// UpdateRole handles PUT /api/v1/workspaces/:id/roles/:roleId
+func (h *WorkspaceHandler) UpdateRole(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+	roleID, err := utils.ParseIDParam(c, "roleId")
+	if err != nil {
+		response.BadRequest(c, "invalid role id")
+		return
+	}
+
+	var req service.WorkspaceRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	if err := h.svc.UpdateRole(workspaceID, roleID, userID, &req); err != nil {
+		handleRoleError(c, err)
+		return
+	}
+
+	response.OK(c, "role updated successfully")
+}
+
+// DeleteRole handles DELETE /api/v1/workspaces/:id/roles/:roleId
+func (h *WorkspaceHandler) DeleteRole(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	workspaceID, err := utils.ParseID(c)
+	if err != nil {
+		response.BadRequest(c, "invalid workspace id")
+		return
+	}
+	roleID, err := utils.ParseIDParam(c, "roleId")
+	if err != nil {
+		response.BadRequest(c, "invalid role id")
+		return
+	}
+
+	if err := h.svc.DeleteRole(workspaceID, roleID, userID); err != nil {
+		handleRoleError(c, err)
+		return
+	}
+
+	response.OK(c, "role deleted successfully")
+}
+
+// handleRoleError maps role and permission errors shared by member and role endpoints
+func handleRoleError(c *gin.Context, err error) {
+	switch {
+	case err == service.ErrWorkspaceAccessDenied, err == service.ErrPermissionEscalation:
+		response.Forbidden(c, err.Error())
+	case err == service.ErrRoleNotFound:
+		response.NotFound(c, err.Error())
+	case err == service.ErrInvalidRole, err == service.ErrRoleInUse, err == service.ErrRoleNameTaken,
+		errors.Is(err, service.ErrInvalidPermission):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalError(c, err)
+	}
+}
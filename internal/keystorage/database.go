@@ -0,0 +1,59 @@
+package keystorage
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+	internalCrypto "github.com/imkerbos/ACME-Console/internal/crypto"
+)
+
+// DatabaseBackend keeps keys in the certificate row, encrypted with the master keyring
+type DatabaseBackend struct {
+	encryptor *internalCrypto.Encryptor
+}
+
+// NewDatabaseBackend creates a DatabaseBackend
+func NewDatabaseBackend(encryptor *internalCrypto.Encryptor) (*DatabaseBackend, error) {
+	if encryptor == nil {
+		return nil, errors.New("database key storage requires encryption.master_key")
+	}
+	return &DatabaseBackend{encryptor: encryptor}, nil
+}
+
+func (b *DatabaseBackend) Name() string { return BackendDatabase }
+
+func (b *DatabaseBackend) Exportable() bool { return true }
+
+func (b *DatabaseBackend) Generate(_ context.Context, keyType acme.KeyType, keySize int) (string, error) {
+	keyPEM, err := generateLocal(keyType, keySize)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := b.encryptor.Encrypt(keyPEM)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+	return encrypted, nil
+}
+
+func (b *DatabaseBackend) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	keyPEM, err := b.Export(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return pemSigner(keyPEM)
+}
+
+func (b *DatabaseBackend) Export(_ context.Context, ref string) ([]byte, error) {
+	keyPEM, err := b.encryptor.Decrypt(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return keyPEM, nil
+}
+
+// Delete is a no-op: the key goes away with the certificate row
+func (b *DatabaseBackend) Delete(context.Context, string) error { return nil }
@@ -0,0 +1,294 @@
+//go:build pkcs11
+
+package keystorage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Backend generates keys inside a PKCS#11 token as sensitive,
+// non-extractable objects and signs CSRs there. References are "pkcs11:<hex CKA_ID>".
+type PKCS11Backend struct {
+	cfg PKCS11Config
+	ctx *pkcs11.Ctx
+
+	mu      sync.Mutex // A PKCS#11 session must not be used concurrently
+	session pkcs11.SessionHandle
+}
+
+// NewPKCS11Backend loads the module, opens a session on the token and logs in
+func NewPKCS11Backend(cfg PKCS11Config) (Backend, error) {
+	if cfg.Module == "" || cfg.TokenLabel == "" {
+		return nil, errors.New("PKCS#11 key storage requires a module and a token label")
+	}
+	if cfg.KeyLabel == "" {
+		cfg.KeyLabel = "acme-console"
+	}
+
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil || strings.TrimSpace(info.Label) != cfg.TokenLabel {
+			continue
+		}
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PKCS#11 session: %w", err)
+		}
+		if err := ctx.Login(session, pkcs11.CKU_USER, cfg.PIN); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			return nil, fmt.Errorf("failed to log in to PKCS#11 token: %w", err)
+		}
+		return &PKCS11Backend{cfg: cfg, ctx: ctx, session: session}, nil
+	}
+	return nil, fmt.Errorf("PKCS#11 token %q not found", cfg.TokenLabel)
+}
+
+func (b *PKCS11Backend) Name() string { return BackendPKCS11 }
+
+func (b *PKCS11Backend) Exportable() bool { return false }
+
+func (b *PKCS11Backend) Generate(_ context.Context, keyType acme.KeyType, keySize int) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	common := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, b.cfg.KeyLabel),
+	}
+	public := append([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true)}, common...)
+	private := append([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	}, common...)
+
+	var mech uint
+	switch keyType {
+	case acme.KeyTypeECC:
+		_, params, err := ecCurveParams(keySize)
+		if err != nil {
+			return "", err
+		}
+		mech = pkcs11.CKM_EC_KEY_PAIR_GEN
+		public = append(public, pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params))
+	case acme.KeyTypeRSA:
+		if keySize != 2048 && keySize != 4096 {
+			return "", fmt.Errorf("%w: RSA %d", ErrUnsupportedSpec, keySize)
+		}
+		mech = pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN
+		public = append(public,
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, keySize),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		)
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedSpec, keyType)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, _, err := b.ctx.GenerateKeyPair(b.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mech, nil)}, public, private); err != nil {
+		return "", fmt.Errorf("failed to generate key in token: %w", err)
+	}
+	return "pkcs11:" + hex.EncodeToString(id), nil
+}
+
+func (b *PKCS11Backend) Signer(_ context.Context, ref string) (crypto.Signer, error) {
+	id, err := parsePKCS11Ref(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	priv, err := b.findObject(pkcs11.CKO_PRIVATE_KEY, id)
+	if err != nil {
+		return nil, err
+	}
+	pubObj, err := b.findObject(pkcs11.CKO_PUBLIC_KEY, id)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := b.publicKey(pubObj)
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs11Signer{backend: b, handle: priv, public: pub}, nil
+}
+
+func (b *PKCS11Backend) Export(context.Context, string) ([]byte, error) {
+	return nil, ErrNotExportable
+}
+
+func (b *PKCS11Backend) Delete(_ context.Context, ref string) error {
+	id, err := parsePKCS11Ref(ref)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, class := range []uint{pkcs11.CKO_PRIVATE_KEY, pkcs11.CKO_PUBLIC_KEY} {
+		obj, err := b.findObject(class, id)
+		if err != nil {
+			continue
+		}
+		if err := b.ctx.DestroyObject(b.session, obj); err != nil {
+			return fmt.Errorf("failed to destroy key object: %w", err)
+		}
+	}
+	return nil
+}
+
+// findObject must be called with mu held
+func (b *PKCS11Backend) findObject(class uint, id []byte) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+	}
+	if err := b.ctx.FindObjectsInit(b.session, template); err != nil {
+		return 0, err
+	}
+	objs, _, err := b.ctx.FindObjects(b.session, 1)
+	b.ctx.FindObjectsFinal(b.session)
+	if err != nil {
+		return 0, err
+	}
+	if len(objs) == 0 {
+		return 0, fmt.Errorf("%w: key %x not found in token", ErrInvalidKeyRef, id)
+	}
+	return objs[0], nil
+}
+
+// publicKey must be called with mu held
+func (b *PKCS11Backend) publicKey(obj pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := b.ctx.GetAttributeValue(b.session, obj, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)})
+	if err != nil {
+		return nil, err
+	}
+	// CK_ULONG values come back in native byte order; compare against our own encoding
+	keyType := attrs[0].Value
+	isKeyType := func(want uint) bool {
+		return bytes.Equal(keyType, pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, want).Value)
+	}
+
+	switch {
+	case isKeyType(pkcs11.CKK_EC):
+		attrs, err := b.ctx.GetAttributeValue(b.session, obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		var curve elliptic.Curve
+		for _, size := range []int{256, 384} {
+			c, params, _ := ecCurveParams(size)
+			if string(params) == string(attrs[0].Value) {
+				curve = c
+			}
+		}
+		if curve == nil {
+			return nil, fmt.Errorf("%w: unknown curve", ErrUnsupportedSpec)
+		}
+		return parseECPoint(curve, attrs[1].Value)
+	case isKeyType(pkcs11.CKK_RSA):
+		attrs, err := b.ctx.GetAttributeValue(b.session, obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: key type %x", ErrUnsupportedSpec, keyType)
+	}
+}
+
+// pkcs11Signer signs with a private key object in the token
+type pkcs11Signer struct {
+	backend *PKCS11Backend
+	handle  pkcs11.ObjectHandle
+	public  crypto.PublicKey
+}
+
+func (s *pkcs11Signer) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *pkcs11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var mech uint
+	input := digest
+	switch s.public.(type) {
+	case *ecdsa.PublicKey:
+		mech = pkcs11.CKM_ECDSA
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return nil, fmt.Errorf("%w: RSA-PSS", ErrUnsupportedSpec)
+		}
+		info, err := rsaDigestInfo(opts.HashFunc(), digest)
+		if err != nil {
+			return nil, err
+		}
+		mech, input = pkcs11.CKM_RSA_PKCS, info
+	}
+
+	b := s.backend
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.ctx.SignInit(b.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mech, nil)}, s.handle); err != nil {
+		return nil, fmt.Errorf("failed to start signing: %w", err)
+	}
+	sig, err := b.ctx.Sign(b.session, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign in token: %w", err)
+	}
+
+	if mech == pkcs11.CKM_ECDSA {
+		return ecdsaRawToASN1(sig)
+	}
+	return sig, nil
+}
+
+func parsePKCS11Ref(ref string) ([]byte, error) {
+	id, err := hex.DecodeString(strings.TrimPrefix(ref, "pkcs11:"))
+	if err != nil || len(id) == 0 || !strings.HasPrefix(ref, "pkcs11:") {
+		return nil, ErrInvalidKeyRef
+	}
+	return id, nil
+}
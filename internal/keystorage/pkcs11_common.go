@@ -0,0 +1,90 @@
+package keystorage
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// PKCS11Config configures the PKCS#11 (HSM) backend. It needs a binary built
+// with -tags pkcs11; keys are generated inside the token and never leave it.
+type PKCS11Config struct {
+	Module     string // Path to the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	TokenLabel string
+	PIN        string
+	KeyLabel   string // CKA_LABEL given to generated keys; default "acme-console"
+}
+
+var (
+	oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+)
+
+// ecCurveParams returns the curve for an ECC key size and its CKA_EC_PARAMS encoding
+func ecCurveParams(size int) (elliptic.Curve, []byte, error) {
+	var curve elliptic.Curve
+	var oid asn1.ObjectIdentifier
+	switch size {
+	case 256:
+		curve, oid = elliptic.P256(), oidP256
+	case 384:
+		curve, oid = elliptic.P384(), oidP384
+	default:
+		return nil, nil, fmt.Errorf("%w: ECC %d", ErrUnsupportedSpec, size)
+	}
+	params, err := asn1.Marshal(oid)
+	return curve, params, err
+}
+
+// parseECPoint decodes CKA_EC_POINT, which the standard wraps in a DER OCTET
+// STRING but some tokens return bare.
+func parseECPoint(curve elliptic.Curve, point []byte) (*ecdsa.PublicKey, error) {
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+	byteLen := (curve.Params().BitSize + 7) / 8
+	if len(raw) != 1+2*byteLen || raw[0] != 4 {
+		return nil, errors.New("unsupported EC point encoding")
+	}
+	pub := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(raw[1 : 1+byteLen]),
+		Y:     new(big.Int).SetBytes(raw[1+byteLen:]),
+	}
+	if !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, errors.New("EC point is not on the curve")
+	}
+	return pub, nil
+}
+
+// ecdsaRawToASN1 converts a CKM_ECDSA signature (r || s) to the ASN.1 form Go expects
+func ecdsaRawToASN1(raw []byte) ([]byte, error) {
+	if len(raw) == 0 || len(raw)%2 != 0 {
+		return nil, errors.New("invalid ECDSA signature length")
+	}
+	half := len(raw) / 2
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(raw[:half]),
+		new(big.Int).SetBytes(raw[half:]),
+	})
+}
+
+// DigestInfo prefixes for CKM_RSA_PKCS, which signs a caller-built DigestInfo (RFC 8017 9.2)
+var rsaDigestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+func rsaDigestInfo(hash crypto.Hash, digest []byte) ([]byte, error) {
+	prefix, ok := rsaDigestInfoPrefixes[hash]
+	if !ok || len(digest) != hash.Size() {
+		return nil, fmt.Errorf("unsupported RSA signature hash %v", hash)
+	}
+	return append(append([]byte{}, prefix...), digest...), nil
+}
@@ -0,0 +1,13 @@
+//go:build !pkcs11
+
+package keystorage
+
+import "errors"
+
+// ErrPKCS11Unavailable is returned by binaries built without PKCS#11 support
+var ErrPKCS11Unavailable = errors.New("PKCS#11 key storage requires a binary built with -tags pkcs11")
+
+// NewPKCS11Backend is only available with the pkcs11 build tag
+func NewPKCS11Backend(PKCS11Config) (Backend, error) {
+	return nil, ErrPKCS11Unavailable
+}
@@ -0,0 +1,62 @@
+//go:build pkcs11
+
+package keystorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+)
+
+// TestPKCS11Backend runs against SoftHSM; initialize a token first, e.g.
+//
+//	softhsm2-util --init-token --free --label acme-test --pin 1234 --so-pin 1234
+//	SOFTHSM2_MODULE=/usr/lib/softhsm/libsofthsm2.so go test -tags pkcs11 ./internal/keystorage
+func TestPKCS11Backend(t *testing.T) {
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if module == "" {
+		t.Skip("SOFTHSM2_MODULE not set")
+	}
+	label := os.Getenv("SOFTHSM2_TOKEN")
+	if label == "" {
+		label = "acme-test"
+	}
+	pin := os.Getenv("SOFTHSM2_PIN")
+	if pin == "" {
+		pin = "1234"
+	}
+
+	b, err := NewPKCS11Backend(PKCS11Config{Module: module, TokenLabel: label, PIN: pin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, spec := range []struct {
+		keyType acme.KeyType
+		size    int
+	}{{acme.KeyTypeECC, 256}, {acme.KeyTypeECC, 384}, {acme.KeyTypeRSA, 2048}} {
+		ref, err := b.Generate(ctx, spec.keyType, spec.size)
+		if err != nil {
+			t.Fatalf("Generate %s %d: %v", spec.keyType, spec.size, err)
+		}
+		signer, err := b.Signer(ctx, ref)
+		if err != nil {
+			t.Fatalf("Signer: %v", err)
+		}
+		checkSigner(t, signer)
+
+		if _, err := b.Export(ctx, ref); !errors.Is(err, ErrNotExportable) {
+			t.Errorf("Export err = %v, want ErrNotExportable", err)
+		}
+		if err := b.Delete(ctx, ref); err != nil {
+			t.Errorf("Delete: %v", err)
+		}
+		if _, err := b.Signer(ctx, ref); !errors.Is(err, ErrInvalidKeyRef) {
+			t.Errorf("deleted key: err = %v", err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package keystorage keeps certificate private keys. A backend turns a key into
+// an opaque reference stored in Certificate.KeyPEM and gives it back as a
+// crypto.Signer, and as PEM when the backend allows keys to leave it.
+package keystorage
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+)
+
+var (
+	ErrNotExportable   = errors.New("private key is held by a non-exportable key storage backend")
+	ErrUnknownBackend  = errors.New("key storage backend is not configured")
+	ErrInvalidKeyRef   = errors.New("invalid key reference")
+	ErrUnsupportedSpec = errors.New("key type is not supported by this backend")
+)
+
+// Backend names, stored on each certificate
+const (
+	BackendDatabase = "database"
+	BackendVault    = "vault"
+	BackendPKCS11   = "pkcs11"
+)
+
+// Backend stores certificate private keys
+type Backend interface {
+	// Name identifies the backend on certificates
+	Name() string
+	// Generate creates a key and returns the reference to store
+	Generate(ctx context.Context, keyType acme.KeyType, keySize int) (string, error)
+	// Signer returns a signer for a stored key
+	Signer(ctx context.Context, ref string) (crypto.Signer, error)
+	// Export returns the key as PEM, or ErrNotExportable
+	Export(ctx context.Context, ref string) ([]byte, error)
+	// Delete removes a key that is no longer needed
+	Delete(ctx context.Context, ref string) error
+	// Exportable reports whether Export can succeed
+	Exportable() bool
+}
+
+// Set holds the configured backends and the one new keys go to
+type Set struct {
+	backends map[string]Backend
+	active   string
+}
+
+// NewSet creates a Set whose new keys go to active. Keys created earlier in
+// other backends stay readable as long as those backends are passed too.
+func NewSet(active Backend, others ...Backend) *Set {
+	s := &Set{backends: map[string]Backend{active.Name(): active}, active: active.Name()}
+	for _, b := range others {
+		if _, ok := s.backends[b.Name()]; !ok {
+			s.backends[b.Name()] = b
+		}
+	}
+	return s
+}
+
+// Active returns the backend for new keys
+func (s *Set) Active() Backend {
+	return s.backends[s.active]
+}
+
+// Get returns the backend a certificate's key lives in; certificates created
+// before backends existed have an empty name and live in the database.
+func (s *Set) Get(name string) (Backend, error) {
+	if name == "" {
+		name = BackendDatabase
+	}
+	b, ok := s.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownBackend, name)
+	}
+	return b, nil
+}
+
+// generateLocal creates a software key for backends that only store keys
+func generateLocal(keyType acme.KeyType, keySize int) ([]byte, error) {
+	key, err := acme.GeneratePrivateKey(keyType, keySize)
+	if err != nil {
+		return nil, err
+	}
+	return acme.EncodePrivateKeyPEM(key)
+}
+
+// pemSigner decodes a PEM key into a signer
+func pemSigner(keyPEM []byte) (crypto.Signer, error) {
+	key, err := acme.DecodePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, acme.ErrUnsupportedKey
+	}
+	return signer, nil
+}
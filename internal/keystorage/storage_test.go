@@ -0,0 +1,229 @@
+package keystorage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+	internalCrypto "github.com/imkerbos/ACME-Console/internal/crypto"
+)
+
+// checkSigner signs a digest and verifies it with the signer's public key
+func checkSigner(t *testing.T, signer crypto.Signer) {
+	t.Helper()
+	digest := sha256.Sum256([]byte("csr"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	switch pub := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+			t.Error("ECDSA signature does not verify")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			t.Errorf("RSA signature does not verify: %v", err)
+		}
+	default:
+		t.Fatalf("unexpected public key %T", pub)
+	}
+}
+
+func TestDatabaseBackend(t *testing.T) {
+	enc, _ := internalCrypto.NewEncryptor(strings.Repeat("ab", 32))
+	b, err := NewDatabaseBackend(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	ref, err := b.Generate(ctx, acme.KeyTypeECC, 256)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	keyPEM, err := b.Export(ctx, ref)
+	if err != nil || !bytes.Contains(keyPEM, []byte("EC PRIVATE KEY")) {
+		t.Fatalf("Export = %q, %v", keyPEM, err)
+	}
+	signer, err := b.Signer(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSigner(t, signer)
+}
+
+func TestSet(t *testing.T) {
+	enc, _ := internalCrypto.NewEncryptor(strings.Repeat("ab", 32))
+	db, _ := NewDatabaseBackend(enc)
+	vault, _ := NewVaultBackend(VaultConfig{Address: "http://vault", Token: "t"})
+
+	set := NewSet(vault, db)
+	if set.Active().Name() != BackendVault {
+		t.Errorf("active = %s", set.Active().Name())
+	}
+	if b, err := set.Get(""); err != nil || b.Name() != BackendDatabase {
+		t.Errorf("legacy certificates should use the database backend: %v", err)
+	}
+	if _, err := set.Get(BackendPKCS11); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("err = %v, want ErrUnknownBackend", err)
+	}
+}
+
+// fakeVault is a stand-in for the Transit and KV v2 endpoints the backend uses
+type fakeVault struct {
+	mu      sync.Mutex
+	dataKey map[string][]byte // wrapped -> plaintext data key
+	kv      map[string]string
+}
+
+func newFakeVault(t *testing.T) *httptest.Server {
+	v := &fakeVault{dataKey: map[string][]byte{}, kv: map[string]string{}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		v.mu.Lock()
+		defer v.mu.Unlock()
+
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		reply := func(data any) { json.NewEncoder(w).Encode(map[string]any{"data": data}) }
+
+		switch {
+		case r.URL.Path == "/v1/transit/datakey/plaintext/acme-console":
+			key := make([]byte, 32)
+			rand.Read(key)
+			wrapped := "vault:v1:" + base64.StdEncoding.EncodeToString(key[:8])
+			v.dataKey[wrapped] = key
+			reply(map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key), "ciphertext": wrapped})
+		case r.URL.Path == "/v1/transit/decrypt/acme-console":
+			key, ok := v.dataKey[body["ciphertext"].(string)]
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"errors":["invalid ciphertext"]}`)
+				return
+			}
+			reply(map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)})
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/data/") && r.Method == http.MethodPost:
+			v.kv[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")] = body["data"].(map[string]any)["private_key"].(string)
+			reply(map[string]any{"version": 1})
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+			key, ok := v.kv[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `{"errors":[]}`)
+				return
+			}
+			reply(map[string]any{"data": map[string]string{"private_key": key}})
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/metadata/") && r.Method == http.MethodDelete:
+			delete(v.kv, strings.TrimPrefix(r.URL.Path, "/v1/secret/metadata/"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected vault request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestVaultBackend(t *testing.T) {
+	srv := newFakeVault(t)
+	defer srv.Close()
+	ctx := context.Background()
+
+	for _, mode := range []string{VaultModeTransit, VaultModeKV} {
+		t.Run(mode, func(t *testing.T) {
+			b, err := NewVaultBackend(VaultConfig{Address: srv.URL, Token: "root", Mode: mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ref, err := b.Generate(ctx, acme.KeyTypeRSA, 2048)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if !strings.HasPrefix(ref, mode+":") {
+				t.Errorf("ref = %q", ref)
+			}
+			if strings.Contains(ref, "PRIVATE KEY") {
+				t.Error("reference leaks the key")
+			}
+			signer, err := b.Signer(ctx, ref)
+			if err != nil {
+				t.Fatalf("Signer: %v", err)
+			}
+			checkSigner(t, signer)
+
+			if err := b.Delete(ctx, ref); err != nil {
+				t.Errorf("Delete: %v", err)
+			}
+		})
+	}
+
+	denied, _ := NewVaultBackend(VaultConfig{Address: srv.URL, Token: "wrong"})
+	if _, err := denied.Generate(ctx, acme.KeyTypeECC, 256); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("err = %v, want vault error message", err)
+	}
+}
+
+func TestECHelpers(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	curve, _, err := ecCurveParams(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point := elliptic.Marshal(elliptic.P256(), key.X, key.Y)
+	wrapped, _ := asn1.Marshal(point)
+	for _, encoded := range [][]byte{wrapped, point} {
+		pub, err := parseECPoint(curve, encoded)
+		if err != nil || !pub.Equal(&key.PublicKey) {
+			t.Errorf("parseECPoint: %v", err)
+		}
+	}
+
+	digest := sha256.Sum256([]byte("csr"))
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	raw := make([]byte, 64)
+	r.FillBytes(raw[:32])
+	s.FillBytes(raw[32:])
+	sig, err := ecdsaRawToASN1(raw)
+	if err != nil || !ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig) {
+		t.Errorf("ecdsaRawToASN1: %v", err)
+	}
+}
+
+func TestRSADigestInfo(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	digest := sha256.Sum256([]byte("csr"))
+	info, err := rsaDigestInfo(crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Raw PKCS#1 v1.5 signing of the DigestInfo must equal a normal SHA-256 signature
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.Hash(0), info)
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("DigestInfo signature does not verify: %v", err)
+	}
+	if _, err := rsaDigestInfo(crypto.SHA1, make([]byte, 20)); err == nil {
+		t.Error("SHA-1 should be rejected")
+	}
+}
@@ -0,0 +1,281 @@
+package keystorage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+)
+
+// Vault storage modes
+const (
+	VaultModeTransit = "transit" // Envelope encryption: Transit wraps a per-key data key
+	VaultModeKV      = "kv"      // Keys stored as KV v2 secrets
+)
+
+// VaultConfig configures the HashiCorp Vault backend
+type VaultConfig struct {
+	Address    string
+	Token      string
+	Namespace  string // Vault Enterprise namespace (optional)
+	Mode       string // transit (default) or kv
+	Mount      string // Secrets engine mount; default "transit" or "secret"
+	KeyName    string // Transit key name; default "acme-console"
+	PathPrefix string // KV path prefix; default "acme-console/keys"
+	Timeout    time.Duration
+}
+
+// VaultBackend keeps keys out of the database: either encrypted with a data key
+// that only Vault Transit can unwrap, or as KV v2 secrets. References carry the
+// mode ("transit:" or "kv:") so changing the mode keeps older keys readable.
+type VaultBackend struct {
+	cfg  VaultConfig
+	http *http.Client
+}
+
+// NewVaultBackend creates a VaultBackend
+func NewVaultBackend(cfg VaultConfig) (*VaultBackend, error) {
+	if cfg.Address == "" || cfg.Token == "" {
+		return nil, errors.New("vault key storage requires an address and a token")
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = VaultModeTransit
+	}
+	switch cfg.Mode {
+	case VaultModeTransit:
+		if cfg.Mount == "" {
+			cfg.Mount = "transit"
+		}
+	case VaultModeKV:
+		if cfg.Mount == "" {
+			cfg.Mount = "secret"
+		}
+	default:
+		return nil, fmt.Errorf("unknown vault mode %q", cfg.Mode)
+	}
+	if cfg.KeyName == "" {
+		cfg.KeyName = "acme-console"
+	}
+	if cfg.PathPrefix == "" {
+		cfg.PathPrefix = "acme-console/keys"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15 * time.Second
+	}
+	cfg.Address = strings.TrimRight(cfg.Address, "/")
+
+	return &VaultBackend{cfg: cfg, http: &http.Client{Timeout: cfg.Timeout}}, nil
+}
+
+func (b *VaultBackend) Name() string { return BackendVault }
+
+func (b *VaultBackend) Exportable() bool { return true }
+
+func (b *VaultBackend) Generate(ctx context.Context, keyType acme.KeyType, keySize int) (string, error) {
+	keyPEM, err := generateLocal(keyType, keySize)
+	if err != nil {
+		return "", err
+	}
+
+	if b.cfg.Mode == VaultModeKV {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		path := b.cfg.PathPrefix + "/" + hex.EncodeToString(id)
+		body := map[string]any{"data": map[string]string{"private_key": string(keyPEM)}}
+		if err := b.do(ctx, http.MethodPost, "/v1/"+b.cfg.Mount+"/data/"+path, body, nil); err != nil {
+			return "", fmt.Errorf("failed to store key in vault: %w", err)
+		}
+		return "kv:" + path, nil
+	}
+
+	var dataKey struct {
+		Data struct {
+			Plaintext  string `json:"plaintext"`
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	if err := b.do(ctx, http.MethodPost, "/v1/"+b.cfg.Mount+"/datakey/plaintext/"+b.cfg.KeyName, map[string]int{"bits": 256}, &dataKey); err != nil {
+		return "", fmt.Errorf("failed to get data key from vault: %w", err)
+	}
+	dek, err := base64.StdEncoding.DecodeString(dataKey.Data.Plaintext)
+	if err != nil || len(dek) != 32 {
+		return "", errors.New("vault returned an invalid data key")
+	}
+
+	sealed, err := sealWithDataKey(dek, keyPEM)
+	if err != nil {
+		return "", err
+	}
+	return "transit:" + dataKey.Data.Ciphertext + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *VaultBackend) Signer(ctx context.Context, ref string) (crypto.Signer, error) {
+	keyPEM, err := b.Export(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return pemSigner(keyPEM)
+}
+
+func (b *VaultBackend) Export(ctx context.Context, ref string) ([]byte, error) {
+	if path, ok := strings.CutPrefix(ref, "kv:"); ok {
+		var secret struct {
+			Data struct {
+				Data struct {
+					PrivateKey string `json:"private_key"`
+				} `json:"data"`
+			} `json:"data"`
+		}
+		if err := b.do(ctx, http.MethodGet, "/v1/"+b.cfg.Mount+"/data/"+path, nil, &secret); err != nil {
+			return nil, fmt.Errorf("failed to read key from vault: %w", err)
+		}
+		if secret.Data.Data.PrivateKey == "" {
+			return nil, ErrInvalidKeyRef
+		}
+		return []byte(secret.Data.Data.PrivateKey), nil
+	}
+
+	wrapped, sealed, err := splitTransitRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	var plain struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := b.do(ctx, http.MethodPost, "/v1/"+b.cfg.Mount+"/decrypt/"+b.cfg.KeyName, map[string]string{"ciphertext": wrapped}, &plain); err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	dek, err := base64.StdEncoding.DecodeString(plain.Data.Plaintext)
+	if err != nil {
+		return nil, errors.New("vault returned an invalid data key")
+	}
+	return openWithDataKey(dek, sealed)
+}
+
+// Delete removes KV secrets with all their versions; Transit references hold
+// nothing outside the database.
+func (b *VaultBackend) Delete(ctx context.Context, ref string) error {
+	path, ok := strings.CutPrefix(ref, "kv:")
+	if !ok {
+		return nil
+	}
+	return b.do(ctx, http.MethodDelete, "/v1/"+b.cfg.Mount+"/metadata/"+path, nil, nil)
+}
+
+// splitTransitRef parses "transit:<vault ciphertext>:<base64 sealed key>". The
+// Vault ciphertext contains colons itself, base64 does not.
+func splitTransitRef(ref string) (string, []byte, error) {
+	rest, ok := strings.CutPrefix(ref, "transit:")
+	i := strings.LastIndex(rest, ":")
+	if !ok || i <= 0 {
+		return "", nil, ErrInvalidKeyRef
+	}
+	sealed, err := base64.StdEncoding.DecodeString(rest[i+1:])
+	if err != nil {
+		return "", nil, ErrInvalidKeyRef
+	}
+	return rest[:i], sealed, nil
+}
+
+func sealWithDataKey(dek, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openWithDataKey(dek, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(dek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrInvalidKeyRef
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt private key with data key")
+	}
+	return plaintext, nil
+}
+
+func (b *VaultBackend) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.cfg.Address+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", b.cfg.Token)
+	if b.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode vault response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package keystore writes Java KeyStore (JKS) and JCEKS files holding one
+// private key entry, so Java users can download certificates without keytool.
+package keystore
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+var (
+	ErrInvalidAlias    = errors.New("alias must be 1-255 printable ASCII characters")
+	ErrInvalidPassword = errors.New("password must be at least 6 printable ASCII characters")
+	ErrEmptyChain      = errors.New("certificate chain is empty")
+)
+
+const (
+	magicJKS   = 0xFEEDFEED
+	magicJCEKS = 0xCECECECE
+	version    = 2
+
+	tagPrivateKey = 1
+
+	// JCEKS key protection iterations, matching current JDK defaults
+	jceksIterations = 200000
+)
+
+var (
+	oidJKSKeyProtector   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+	oidPBEWithMD5And3DES = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 19, 1}
+)
+
+// Entry is a private key with its certificate chain, leaf first
+type Entry struct {
+	Alias   string
+	Key     any // *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+	Chain   []*x509.Certificate
+	Created time.Time
+}
+
+// EncodeJKS writes a JKS keystore. The key is protected with the store password.
+func EncodeJKS(entry *Entry, password string) ([]byte, error) {
+	return encode(magicJKS, entry, password, protectJKS)
+}
+
+// EncodeJCEKS writes a JCEKS keystore, whose key protection (PBE with MD5 and
+// triple DES) is stronger than the JKS one.
+func EncodeJCEKS(entry *Entry, password string) ([]byte, error) {
+	return encode(magicJCEKS, entry, password, protectJCEKS)
+}
+
+type protector func(plain []byte, password string) ([]byte, error)
+
+func encode(magic uint32, entry *Entry, password string, protect protector) ([]byte, error) {
+	alias := strings.ToLower(entry.Alias) // Java normalizes aliases to lower case
+	if !printableASCII(alias, 1, 255) {
+		return nil, ErrInvalidAlias
+	}
+	if !printableASCII(password, 6, 1024) {
+		return nil, ErrInvalidPassword
+	}
+	if len(entry.Chain) == 0 {
+		return nil, ErrEmptyChain
+	}
+
+	plain, err := x509.MarshalPKCS8PrivateKey(entry.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	protected, err := protect(plain, password)
+	if err != nil {
+		return nil, err
+	}
+
+	created := entry.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	var buf bytes.Buffer
+	writeUint32(&buf, magic)
+	writeUint32(&buf, version)
+	writeUint32(&buf, 1) // Entry count
+
+	writeUint32(&buf, tagPrivateKey)
+	writeUTF(&buf, alias)
+	writeUint64(&buf, uint64(created.UnixMilli()))
+	writeUint32(&buf, uint32(len(protected)))
+	buf.Write(protected)
+
+	writeUint32(&buf, uint32(len(entry.Chain)))
+	for _, cert := range entry.Chain {
+		writeUTF(&buf, "X.509")
+		writeUint32(&buf, uint32(len(cert.Raw)))
+		buf.Write(cert.Raw)
+	}
+
+	buf.Write(integrityDigest(password, buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+// integrityDigest is the keyed SHA-1 trailer shared by JKS and JCEKS
+func integrityDigest(password string, data []byte) []byte {
+	h := sha1.New()
+	h.Write(passwordUTF16(password))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParameter struct {
+	Salt       []byte
+	Iterations int
+}
+
+// protectJKS implements Sun's proprietary JKS key protector: a SHA-1 based
+// keystream XORed over the key, prefixed by the salt and followed by a check digest.
+func protectJKS(plain []byte, password string) ([]byte, error) {
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	pw := passwordUTF16(password)
+
+	encrypted := make([]byte, 0, len(salt)+len(plain)+sha1.Size)
+	encrypted = append(encrypted, salt...)
+	digest := salt
+	for i := 0; i < len(plain); i += sha1.Size {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < sha1.Size && i+j < len(plain); j++ {
+			encrypted = append(encrypted, plain[i+j]^digest[j])
+		}
+	}
+	check := sha1.New()
+	check.Write(pw)
+	check.Write(plain)
+	encrypted = append(encrypted, check.Sum(nil)...)
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: encrypted,
+	})
+}
+
+// protectJCEKS encrypts the key with PBEWithMD5AndTripleDES
+func protectJCEKS(plain []byte, password string) ([]byte, error) {
+	salt := make([]byte, 8)
+	for {
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		// Equal halves would trigger a salt rewrite on the Java side
+		if !bytes.Equal(salt[:4], salt[4:]) {
+			break
+		}
+	}
+
+	key, iv := deriveMD5TripleDES([]byte(password), salt, jceksIterations)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padded := pkcs5Pad(plain, block.BlockSize())
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	params, err := asn1.Marshal(pbeParameter{Salt: salt, Iterations: jceksIterations})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBEWithMD5And3DES, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+}
+
+// deriveMD5TripleDES is the SunJCE key derivation for PBEWithMD5AndTripleDES:
+// each salt half is hashed with the password iterations times, giving a
+// 24-byte key and an 8-byte IV.
+func deriveMD5TripleDES(password, salt []byte, iterations int) ([]byte, []byte) {
+	derived := make([]byte, 0, 32)
+	half := len(salt) / 2
+	for i := 0; i < 2; i++ {
+		digest := salt[i*half : (i+1)*half]
+		for j := 0; j < iterations; j++ {
+			h := md5.New()
+			h.Write(digest)
+			h.Write(password)
+			digest = h.Sum(nil)
+		}
+		derived = append(derived, digest...)
+	}
+	return derived[:24], derived[24:]
+}
+
+func pkcs5Pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func passwordUTF16(password string) []byte {
+	units := utf16.Encode([]rune(password))
+	out := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.BigEndian.PutUint16(out[2*i:], u)
+	}
+	return out
+}
+
+func printableASCII(s string, minLen, maxLen int) bool {
+	if len(s) < minLen || len(s) > maxLen {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	binary.Write(buf, binary.BigEndian, v)
+}
+
+func writeUint64(buf *bytes.Buffer, v uint64) {
+	binary.Write(buf, binary.BigEndian, v)
+}
+
+// writeUTF writes a Java DataOutput UTF string; aliases are ASCII, where
+// modified UTF-8 and UTF-8 agree
+func writeUTF(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
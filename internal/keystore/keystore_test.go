@@ -0,0 +1,197 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func testEntry(t *testing.T) *Entry {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &Entry{Alias: "Tomcat", Key: key, Chain: []*x509.Certificate{cert}, Created: time.UnixMilli(1700000000000)}
+}
+
+// parsed is what readStore extracts from a one-entry store
+type parsed struct {
+	magic   uint32
+	alias   string
+	created int64
+	keyInfo encryptedPrivateKeyInfo
+	chain   [][]byte
+}
+
+func readStore(t *testing.T, data []byte, password string) *parsed {
+	t.Helper()
+	body, trailer := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if !bytes.Equal(trailer, integrityDigest(password, body)) {
+		t.Fatal("integrity digest mismatch")
+	}
+
+	r := bytes.NewReader(body)
+	u32 := func() uint32 {
+		var v uint32
+		binary.Read(r, binary.BigEndian, &v)
+		return v
+	}
+	utf := func() string {
+		var n uint16
+		binary.Read(r, binary.BigEndian, &n)
+		b := make([]byte, n)
+		r.Read(b)
+		return string(b)
+	}
+	blob := func() []byte {
+		b := make([]byte, u32())
+		r.Read(b)
+		return b
+	}
+
+	p := &parsed{magic: u32()}
+	if v := u32(); v != version {
+		t.Fatalf("version = %d", v)
+	}
+	if n := u32(); n != 1 {
+		t.Fatalf("entries = %d", n)
+	}
+	if tag := u32(); tag != tagPrivateKey {
+		t.Fatalf("tag = %d", tag)
+	}
+	p.alias = utf()
+	binary.Read(r, binary.BigEndian, &p.created)
+	if _, err := asn1.Unmarshal(blob(), &p.keyInfo); err != nil {
+		t.Fatalf("key info: %v", err)
+	}
+	for n := u32(); n > 0; n-- {
+		if typ := utf(); typ != "X.509" {
+			t.Fatalf("cert type = %q", typ)
+		}
+		p.chain = append(p.chain, blob())
+	}
+	if r.Len() != 0 {
+		t.Fatalf("%d trailing bytes", r.Len())
+	}
+	return p
+}
+
+func TestEncodeJKS(t *testing.T) {
+	entry := testEntry(t)
+	data, err := EncodeJKS(entry, "changeit")
+	if err != nil {
+		t.Fatalf("EncodeJKS: %v", err)
+	}
+
+	p := readStore(t, data, "changeit")
+	if p.magic != magicJKS || p.alias != "tomcat" || p.created != 1700000000000 {
+		t.Errorf("header = %x %q %d", p.magic, p.alias, p.created)
+	}
+	if len(p.chain) != 1 || !bytes.Equal(p.chain[0], entry.Chain[0].Raw) {
+		t.Error("chain mismatch")
+	}
+	if !p.keyInfo.Algorithm.Algorithm.Equal(oidJKSKeyProtector) {
+		t.Errorf("algorithm = %v", p.keyInfo.Algorithm.Algorithm)
+	}
+
+	// Undo the key protector
+	enc := p.keyInfo.EncryptedData
+	salt, body, check := enc[:20], enc[20:len(enc)-20], enc[len(enc)-20:]
+	pw := passwordUTF16("changeit")
+	plain := make([]byte, 0, len(body))
+	digest := salt
+	for i := 0; i < len(body); i += 20 {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for j := 0; j < 20 && i+j < len(body); j++ {
+			plain = append(plain, body[i+j]^digest[j])
+		}
+	}
+	h := sha1.New()
+	h.Write(pw)
+	h.Write(plain)
+	if !bytes.Equal(h.Sum(nil), check) {
+		t.Fatal("key check digest mismatch")
+	}
+	want, _ := x509.MarshalPKCS8PrivateKey(entry.Key)
+	if !bytes.Equal(plain, want) {
+		t.Error("decrypted key mismatch")
+	}
+}
+
+func TestEncodeJCEKS(t *testing.T) {
+	entry := testEntry(t)
+	data, err := EncodeJCEKS(entry, "s3cret-pass")
+	if err != nil {
+		t.Fatalf("EncodeJCEKS: %v", err)
+	}
+
+	p := readStore(t, data, "s3cret-pass")
+	if p.magic != magicJCEKS {
+		t.Errorf("magic = %x", p.magic)
+	}
+	if !p.keyInfo.Algorithm.Algorithm.Equal(oidPBEWithMD5And3DES) {
+		t.Fatalf("algorithm = %v", p.keyInfo.Algorithm.Algorithm)
+	}
+	var params pbeParameter
+	if _, err := asn1.Unmarshal(p.keyInfo.Algorithm.Parameters.FullBytes, &params); err != nil {
+		t.Fatalf("params: %v", err)
+	}
+	if len(params.Salt) != 8 || params.Iterations != jceksIterations {
+		t.Errorf("params = %+v", params)
+	}
+
+	key, iv := deriveMD5TripleDES([]byte("s3cret-pass"), params.Salt, params.Iterations)
+	block, _ := des.NewTripleDESCipher(key)
+	plain := make([]byte, len(p.keyInfo.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, p.keyInfo.EncryptedData)
+	plain = plain[:len(plain)-int(plain[len(plain)-1])]
+
+	if _, err := x509.ParsePKCS8PrivateKey(plain); err != nil {
+		t.Errorf("decrypted key does not parse: %v", err)
+	}
+}
+
+func TestEncodeValidation(t *testing.T) {
+	entry := testEntry(t)
+	if _, err := EncodeJKS(entry, "short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("short password: err = %v", err)
+	}
+	if _, err := EncodeJCEKS(entry, "pässwort"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("non-ASCII password: err = %v", err)
+	}
+	entry.Alias = ""
+	if _, err := EncodeJKS(entry, "changeit"); !errors.Is(err, ErrInvalidAlias) {
+		t.Errorf("empty alias: err = %v", err)
+	}
+	entry.Alias, entry.Chain = "a", nil
+	if _, err := EncodeJKS(entry, "changeit"); !errors.Is(err, ErrEmptyChain) {
+		t.Errorf("empty chain: err = %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package mailer
+
+import (
+	"bufio"
+	"mime"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one plain-text conversation and returns the commands and
+// message it received
+func fakeSMTP(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var lines []string
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					data = strings.TrimRight(data, "\r\n")
+					if data == "." {
+						break
+					}
+					lines = append(lines, data)
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSend(t *testing.T) {
+	addr, received := fakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	m, err := New(Config{Host: host, Port: portNum, TLS: TLSNone, From: "ACME Console <acme@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Send("bob@example.com", "Invitation to 生产环境", "Hello\nJoin here: https://console.example.com/invite/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+	conversation := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"MAIL FROM:<acme@example.com>",
+		"RCPT TO:<bob@example.com>",
+		`From: "ACME Console" <acme@example.com>`,
+		"To: <bob@example.com>",
+		"Subject: =?utf-8?q?",
+		"Join here: https://console.example.com/invite/x",
+	} {
+		if !strings.Contains(conversation, want) {
+			t.Errorf("conversation is missing %q:\n%s", want, conversation)
+		}
+	}
+}
+
+func TestBuildMessageHeaders(t *testing.T) {
+	from := &mail.Address{Name: "ACME", Address: "acme@example.com"}
+	to := &mail.Address{Address: "bob@example.com"}
+	msg, err := buildMessage(from, to, "Hi\r\nBcc: eve@example.com", "body", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, _, _ := strings.Cut(string(msg), "\r\n\r\n")
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("subject injected a header:\n%s", header)
+	}
+	var subject string
+	for _, line := range strings.Split(header, "\r\n") {
+		if v, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject = v
+		}
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil || decoded != "Hi\r\nBcc: eve@example.com" {
+		t.Errorf("subject decodes to %q, %v", decoded, err)
+	}
+	if !strings.Contains(header, "Date: Sun, 01 Mar 2026 12:00:00 +0000") {
+		t.Errorf("unexpected date header:\n%s", header)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, cfg := range []Config{
+		{From: "acme@example.com"},
+		{Host: "smtp.example.com", From: "not an address"},
+		{Host: "smtp.example.com", From: "acme@example.com", TLS: "ssl"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+)
+
+// AuditRecorder is implemented by service.AuditService
+type AuditRecorder interface {
+	Record(entry *model.AuditLog)
+}
+
+// Path parameters that hold secrets and never name the audited resource
+var secretParams = map[string]bool{"token": true}
+
+// Audit records the requests whose route ("METHOD /full/path") is listed in
+// actions once the handler has run. Handlers add what the path does not say
+// through utils.SetAuditResource, SetAuditWorkspace and SetAuditChanges.
+func Audit(recorder AuditRecorder, actions map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		action, ok := actions[c.Request.Method+" "+c.FullPath()]
+		if !ok || recorder == nil {
+			return
+		}
+		// Requests without credentials never reached a handler, and polling
+		// agents that already have the latest bundle received nothing
+		status := c.Writer.Status()
+		if (status == http.StatusUnauthorized && utils.GetUserID(c) == 0) || status == http.StatusNotModified {
+			return
+		}
+		recorder.Record(auditEntry(c, action, status))
+	}
+}
+
+func auditEntry(c *gin.Context, action string, status int) *model.AuditLog {
+	resourceType, _, _ := strings.Cut(action, ".")
+	entry := &model.AuditLog{
+		ActorType:    model.AuditActorAnonymous,
+		Action:       action,
+		ResourceType: resourceType,
+		RequestID:    GetRequestID(c),
+		IP:           c.ClientIP(),
+		Outcome:      model.AuditOutcomeSuccess,
+	}
+
+	if userID := utils.GetUserID(c); userID != 0 {
+		entry.ActorType = model.AuditActorUser
+		entry.ActorID = userID
+		entry.ActorName = c.GetString("username")
+		if tokenID := c.GetUint(TokenIDKey); tokenID != 0 {
+			entry.ActorType = model.AuditActorToken
+			entry.TokenID = tokenID
+		}
+	}
+
+	resourceID, workspaceID, changes := utils.AuditDetails(c)
+	if resourceID == "" {
+		// The innermost path parameter names the resource, e.g. :tokenId in
+		// /certificates/:id/deploy-tokens/:tokenId
+		for i := len(c.Params) - 1; i >= 0; i-- {
+			if !secretParams[c.Params[i].Key] {
+				resourceID = c.Params[i].Value
+				break
+			}
+		}
+	}
+	entry.ResourceID = truncateAudit(resourceID, 64)
+
+	if workspaceID == nil {
+		workspaceID = pathWorkspace(c)
+	}
+	entry.WorkspaceID = workspaceID
+
+	if status >= http.StatusBadRequest {
+		entry.Outcome = model.AuditOutcomeFailure
+		entry.Detail = http.StatusText(status)
+	} else if changes != nil {
+		if encoded, err := json.Marshal(changes); err == nil && string(encoded) != "{}" && string(encoded) != "null" {
+			entry.Changes = string(encoded)
+		}
+	}
+	return entry
+}
+
+// pathWorkspace finds the workspace from the authorized certificate or a
+// /workspaces/:id path
+func pathWorkspace(c *gin.Context) *uint {
+	if cert, ok := c.Get(CertificateKey); ok {
+		return cert.(*model.Certificate).WorkspaceID
+	}
+	if strings.HasPrefix(c.FullPath(), "/api/v1/workspaces/:id") {
+		if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil {
+			wid := uint(id)
+			return &wid
+		}
+	}
+	return nil
+}
+
+func truncateAudit(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -1,15 +1,43 @@
 package middleware
 
 import (
+	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/imkerbos/ACME-Console/internal/auth"
 	"github.com/imkerbos/ACME-Console/internal/model"
 	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
 )
 
-func JWTAuth(jwtManager *auth.JWTManager) gin.HandlerFunc {
+// Context keys set for API token requests
+const (
+	TokenScopesKey = "tokenScopes"
+	TokenIDKey     = "tokenID"
+)
+
+// TokenAuthenticator resolves API tokens; implemented by service.APITokenService
+type TokenAuthenticator interface {
+	Authenticate(raw, clientIP string) (*service.TokenIdentity, error)
+}
+
+// Routes a user who must change their password can still use
+var passwordChangeRoutes = map[string]bool{
+	"GET /api/v1/auth/me":               true,
+	"POST /api/v1/auth/change-password": true,
+	"POST /api/v1/auth/logout":          true,
+}
+
+// SessionValidator checks console sessions; implemented by service.SessionService
+type SessionValidator interface {
+	Validate(sessionID, userID uint, clientIP string) error
+}
+
+// JWTAuth authenticates console sessions (JWTs) and API tokens, which are
+// recognized by their prefix
+func JWTAuth(jwtManager *auth.JWTManager, tokens TokenAuthenticator, sessions SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,6 +53,22 @@ func JWTAuth(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if strings.HasPrefix(parts[1], model.APITokenPrefix) {
+			identity, err := tokens.Authenticate(parts[1], c.ClientIP())
+			if err != nil {
+				response.Unauthorized(c, "invalid or expired API token")
+				c.Abort()
+				return
+			}
+			c.Set("userID", identity.UserID)
+			c.Set("username", identity.Username)
+			c.Set("role", identity.Role)
+			c.Set(TokenScopesKey, identity.Scopes)
+			c.Set(TokenIDKey, identity.TokenID)
+			c.Next()
+			return
+		}
+
 		claims, err := jwtManager.Verify(parts[1])
 		if err != nil {
 			if err == auth.ErrExpiredToken {
@@ -36,10 +80,25 @@ func JWTAuth(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		// Revoked sessions stop working before their access tokens expire
+		if err := sessions.Validate(claims.SessionID, claims.UserID, c.ClientIP()); err != nil {
+			if !errors.Is(err, service.ErrPasswordChangeRequired) {
+				response.Unauthorized(c, "session expired or revoked")
+				c.Abort()
+				return
+			}
+			if !passwordChangeRoutes[c.Request.Method+" "+c.FullPath()] {
+				response.Error(c, http.StatusForbidden, response.CodePasswordChangeRequired, "password change required")
+				c.Abort()
+				return
+			}
+		}
+
 		// Set user info in context
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 	}
@@ -64,3 +123,34 @@ func AdminAuth() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireScope limits API tokens to the route groups their scopes allow;
+// console sessions are not scoped
+func RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isToken := c.Get(TokenScopesKey)
+		if !isToken || service.ScopeAllows(scopes.([]string), resource, c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		needed := resource + ":write"
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			needed = resource + ":read"
+		}
+		response.Forbidden(c, "API token lacks the "+needed+" scope")
+		c.Abort()
+	}
+}
+
+// SessionOnly rejects API tokens, so a leaked token cannot mint new credentials
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isToken := c.Get(TokenScopesKey); isToken {
+			response.Forbidden(c, "this endpoint requires a console login")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
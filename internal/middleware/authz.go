@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"github.com/imkerbos/ACME-Console/internal/utils"
+	"gorm.io/gorm"
+)
+
+// CertificateKey is the context key holding the certificate authorized by RequireCertificate
+const CertificateKey = "certificate"
+
+// CertificateAuthorizer is implemented by service.AuthzService
+type CertificateAuthorizer interface {
+	AuthorizeCertificate(certID, userID uint, action service.CertificateAction) (*model.Certificate, error)
+}
+
+// RequireCertificate checks that the current user may perform the action on
+// the certificate in the :id path parameter before the handler runs.
+func RequireCertificate(authz CertificateAuthorizer, action service.CertificateAction) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		certID, err := utils.ParseID(c)
+		if err != nil {
+			response.BadRequest(c, "invalid certificate id")
+			c.Abort()
+			return
+		}
+
+		cert, err := authz.AuthorizeCertificate(certID, utils.GetUserID(c), action)
+		switch {
+		case err == nil:
+			c.Set(CertificateKey, cert)
+			c.Next()
+			return
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.NotFound(c, "certificate not found")
+		case errors.Is(err, service.ErrWorkspaceAccessDenied):
+			response.Forbidden(c, "access denied")
+		default:
+			response.InternalError(c, err)
+		}
+		c.Abort()
+	}
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"bytes"
 	"io"
 	"time"
 
@@ -10,10 +9,10 @@ import (
 	"go.uber.org/zap"
 )
 
-// responseWriter wraps gin.ResponseWriter to capture response body
+// responseWriter wraps gin.ResponseWriter to capture the start of the response body
 type responseWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body *limitedBuffer
 }
 
 func (w *responseWriter) Write(b []byte) (int, error) {
@@ -21,12 +20,28 @@ func (w *responseWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.body.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// teeBody copies what the handler reads from the request body
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
+
 // SkipPaths is a list of paths to skip logging
 var SkipPaths = map[string]bool{
 	"/health": true,
 }
 
-func Logger() gin.HandlerFunc {
+var logRedactor = NewRedactor(DefaultRedactRules)
+
+// Logger logs every request. Bodies are logged according to policies, keyed
+// by "METHOD /full/path"; routes not listed get BodyLogRedacted. Secrets in
+// bodies, query strings and path parameters never reach the log.
+func Logger(policies map[string]BodyLogging) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
 
@@ -37,20 +52,19 @@ func Logger() gin.HandlerFunc {
 		}
 
 		start := time.Now()
-		query := c.Request.URL.RawQuery
 		requestID := GetRequestID(c)
+		policy := policies[c.Request.Method+" "+c.FullPath()]
 
-		// Read request body
-		var requestBody []byte
-		if c.Request.Body != nil {
-			requestBody, _ = io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
+		// Keep the start of the request body as the handler reads it
+		requestBody := &limitedBuffer{max: maxLoggedBody}
+		if c.Request.Body != nil && policy != BodyLogOff {
+			c.Request.Body = teeBody{io.TeeReader(c.Request.Body, requestBody), c.Request.Body}
 		}
 
 		// Wrap response writer to capture response body
 		rw := &responseWriter{
 			ResponseWriter: c.Writer,
-			body:           bytes.NewBuffer(nil),
+			body:           &limitedBuffer{max: maxLoggedBody},
 		}
 		c.Writer = rw
 
@@ -63,21 +77,21 @@ func Logger() gin.HandlerFunc {
 			zap.String("request_id", requestID),
 			zap.Int("status", status),
 			zap.String("method", c.Request.Method),
-			zap.String("path", path),
-			zap.String("query", query),
+			zap.String("path", LogPath(c)),
+			zap.String("query", logRedactor.Query(c.Request.URL.Query())),
 			zap.String("ip", c.ClientIP()),
 			zap.Duration("latency", latency),
 			zap.Int("size", c.Writer.Size()),
 		}
 
-		// Add request body for non-GET requests (limit size)
-		if c.Request.Method != "GET" && len(requestBody) > 0 && len(requestBody) < 4096 {
-			fields = append(fields, zap.String("request_body", string(requestBody)))
+		// Add request body for non-GET requests
+		if c.Request.Method != "GET" && requestBody.total > 0 {
+			fields = append(fields, bodyFields("request", policy, c.ContentType(), requestBody)...)
 		}
 
-		// Add response body for errors (limit size)
-		if status >= 400 && rw.body.Len() < 4096 {
-			fields = append(fields, zap.String("response_body", rw.body.String()))
+		// Add response body for errors
+		if status >= 400 && rw.body.total > 0 {
+			fields = append(fields, bodyFields("response", policy, rw.Header().Get("Content-Type"), rw.body)...)
 		}
 
 		if len(c.Errors) > 0 {
@@ -94,3 +108,20 @@ func Logger() gin.HandlerFunc {
 		}
 	}
 }
+
+// bodyFields describes a body as the policy allows: redacted content, or
+// size and content type when the body is binary, too large or unparseable
+func bodyFields(prefix string, policy BodyLogging, contentType string, body *limitedBuffer) []zap.Field {
+	if policy == BodyLogOff {
+		return nil
+	}
+	if policy == BodyLogRedacted {
+		if logged, ok := logRedactor.Body(contentType, body.buf.Bytes()); ok {
+			return []zap.Field{zap.String(prefix+"_body", logged)}
+		}
+	}
+	return []zap.Field{
+		zap.Int(prefix+"_body_size", body.total),
+		zap.String(prefix+"_content_type", contentType),
+	}
+}
@@ -17,7 +17,7 @@ func Recovery() gin.HandlerFunc {
 				logger.Error("Panic recovered",
 					zap.Any("error", err),
 					zap.String("stack", string(debug.Stack())),
-					zap.String("path", c.Request.URL.Path),
+					zap.String("path", LogPath(c)),
 					zap.String("method", c.Request.Method),
 				)
 
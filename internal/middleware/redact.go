@@ -0,0 +1,320 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+// BodyLogging says how much of a route's request and response bodies the
+// request log may contain
+type BodyLogging int
+
+const (
+	// BodyLogRedacted logs JSON and text bodies with secrets masked (default)
+	BodyLogRedacted BodyLogging = iota
+	// BodyLogMetadata logs only the size and content type
+	BodyLogMetadata
+	// BodyLogOff logs nothing about the bodies
+	BodyLogOff
+)
+
+// Bodies larger than this are logged as metadata only: a truncated JSON
+// document cannot be redacted reliably
+const maxLoggedBody = 4096
+
+const redacted = "[REDACTED]"
+
+// RedactMode is how a matching value is masked
+type RedactMode int
+
+const (
+	// RedactFull replaces the whole value
+	RedactFull RedactMode = iota
+	// RedactURL keeps the scheme and host of a URL, e.g. to tell webhook
+	// targets apart without logging the bot token in the path
+	RedactURL
+)
+
+// RedactRule masks the JSON fields matching Path. A single segment such as
+// "password" or "*_pem" matches that key at any depth; a dotted path such as
+// "targets.ssh_key" must match the full path (array indexes are skipped).
+// Segments use path.Match patterns and ignore case.
+type RedactRule struct {
+	Path    string
+	Mode    RedactMode
+	Strings bool // Only mask string values, e.g. one-time codes but not numeric response codes
+}
+
+// DefaultRedactRules cover the credentials, keys and tokens the API accepts
+// and returns
+var DefaultRedactRules = []RedactRule{
+	{Path: "*password*"},
+	{Path: "*secret*"},
+	{Path: "*token*"},
+	{Path: "*_pem"},
+	{Path: "pem"},
+	{Path: "*private_key*"},
+	{Path: "*passphrase*"},
+	{Path: "*credential*"},
+	{Path: "api_key"},
+	{Path: "*access_key*"},
+	{Path: "code", Strings: true},
+	{Path: "recovery_code*"},
+	{Path: "pin"},
+	{Path: "bot_token"},
+	{Path: "invite"},
+	{Path: "kubeconfig"},
+	{Path: "authorization"},
+	{Path: "cookie"},
+	{Path: "extra_config"},
+	{Path: "webhook_config"},
+	{Path: "webhook_url", Mode: RedactURL},
+	{Path: "url", Mode: RedactURL},
+	{Path: "download_url", Mode: RedactURL},
+}
+
+var pemBlock = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----[\s\S]*?(-----END [A-Z0-9 ]+-----|$)`)
+
+// Redactor masks secrets in bodies, query strings and paths before they are logged
+type Redactor struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	segments []string
+	mode     RedactMode
+	strings  bool
+}
+
+// NewRedactor creates a Redactor for rules
+func NewRedactor(rules []RedactRule) *Redactor {
+	r := &Redactor{}
+	for _, rule := range rules {
+		r.rules = append(r.rules, compiledRule{
+			segments: strings.Split(strings.ToLower(rule.Path), "."),
+			mode:     rule.Mode,
+			strings:  rule.Strings,
+		})
+	}
+	return r
+}
+
+// Body returns a loggable form of a body, or "" with ok=false when only its
+// metadata may be logged (binary, too large or not parseable)
+func (r *Redactor) Body(contentType string, body []byte) (string, bool) {
+	if len(body) == 0 || len(body) > maxLoggedBody || !isText(contentType, body) {
+		return "", false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	trimmed := bytes.TrimSpace(body)
+	if mediaType == "application/json" || (mediaType == "" && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')) {
+		var doc interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return "", false
+		}
+		out, err := json.Marshal(r.value(nil, doc))
+		if err != nil {
+			return "", false
+		}
+		return string(out), true
+	}
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", false
+		}
+		return r.Query(values), true
+	}
+
+	return redactPEM(string(body)), true
+}
+
+// Query returns the encoded query with secret parameters masked
+func (r *Redactor) Query(values url.Values) string {
+	masked := make(url.Values, len(values))
+	for key, vals := range values {
+		rule := r.match([]string{key})
+		for _, v := range vals {
+			if rule != nil {
+				v = mask(v, rule.mode)
+			} else {
+				v = redactPEM(v)
+			}
+			masked[key] = append(masked[key], v)
+		}
+	}
+	// Keep "[REDACTED]" readable in the log
+	return strings.NewReplacer("%5BREDACTED%5D", redacted).Replace(masked.Encode())
+}
+
+func (r *Redactor) value(keyPath []string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			childPath := append(keyPath[:len(keyPath):len(keyPath)], key)
+			if rule := r.match(childPath); rule != nil {
+				if _, isString := child.(string); isString || !rule.strings {
+					val[key] = maskJSON(child, rule.mode)
+					continue
+				}
+			}
+			val[key] = r.value(childPath, child)
+		}
+		return val
+	case []interface{}:
+		for i, child := range val {
+			val[i] = r.value(keyPath, child)
+		}
+		return val
+	case string:
+		return redactPEM(val)
+	default:
+		return val
+	}
+}
+
+// match returns the first rule matching a key path, or nil
+func (r *Redactor) match(keyPath []string) *compiledRule {
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if len(rule.segments) == 1 {
+			if segmentMatch(rule.segments[0], keyPath[len(keyPath)-1]) {
+				return rule
+			}
+			continue
+		}
+		if len(rule.segments) != len(keyPath) {
+			continue
+		}
+		matched := true
+		for i, seg := range rule.segments {
+			if !segmentMatch(seg, keyPath[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule
+		}
+	}
+	return nil
+}
+
+func segmentMatch(pattern, key string) bool {
+	ok, _ := path.Match(pattern, strings.ToLower(key))
+	return ok
+}
+
+// maskJSON masks a matched field; empty values stay visible so the log
+// still shows whether a secret was sent
+func maskJSON(v interface{}, mode RedactMode) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return mask(val, mode)
+	case bool:
+		return val
+	default:
+		return redacted
+	}
+}
+
+func mask(v string, mode RedactMode) string {
+	if v == "" {
+		return ""
+	}
+	if mode == RedactURL {
+		if u, err := url.Parse(v); err == nil && u.Scheme != "" && u.Host != "" {
+			return u.Scheme + "://" + u.Hostname() + "/" + redacted
+		}
+	}
+	return redacted
+}
+
+func redactPEM(s string) string {
+	if !strings.Contains(s, "-----BEGIN ") {
+		return s
+	}
+	return pemBlock.ReplaceAllString(s, "[PEM REDACTED]")
+}
+
+// isText reports whether a body may be logged as text
+func isText(contentType string, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "":
+	case strings.HasPrefix(mediaType, "text/"):
+		// CSV exports and PEM files are downloads, not diagnostics
+		if mediaType == "text/csv" {
+			return false
+		}
+	case mediaType == "application/json", mediaType == "application/x-www-form-urlencoded":
+	default:
+		return false
+	}
+	return utf8.Valid(body) && !bytes.ContainsRune(body, 0)
+}
+
+// Tokens that also appear in paths gin does not route, e.g. the console's
+// /invite/<token> page
+var tokenPrefixes = []string{
+	model.APITokenPrefix,
+	model.DeployTokenPrefix,
+	model.DownloadLinkPrefix,
+	model.WorkspaceInvitationPrefix,
+}
+
+// LogPath returns the request path with secret path parameters masked, e.g.
+// /api/v1/download/[REDACTED]
+func LogPath(c *gin.Context) string {
+	p := c.Request.URL.Path
+	for _, param := range c.Params {
+		if secretParams[param.Key] && param.Value != "" {
+			p = strings.Replace(p, "/"+param.Value, "/"+redacted, 1)
+		}
+	}
+
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		for _, prefix := range tokenPrefixes {
+			if strings.HasPrefix(seg, prefix) {
+				segments[i] = redacted
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// limitedBuffer keeps the first max+1 bytes written to it; one byte more
+// than max shows the body was too large
+type limitedBuffer struct {
+	buf   bytes.Buffer
+	max   int
+	total int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	if room := b.max + 1 - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
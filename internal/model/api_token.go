@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APITokenPrefix marks personal access and service account tokens
+const APITokenPrefix = "acp_"
+
+// API token scopes, one per protected route group. Each grants read access for
+// GET requests and, with the :write suffix, every method.
+const (
+	ScopeAccountRead           = "account:read"
+	ScopeAccountWrite          = "account:write"
+	ScopeWorkspacesRead        = "workspaces:read"
+	ScopeWorkspacesWrite       = "workspaces:write"
+	ScopeCertificatesRead      = "certificates:read"
+	ScopeCertificatesWrite     = "certificates:write"
+	ScopeNotificationsRead     = "notifications:read"
+	ScopeNotificationsWrite    = "notifications:write"
+	ScopeCloudCredentialsRead  = "cloud_credentials:read"
+	ScopeCloudCredentialsWrite = "cloud_credentials:write"
+	ScopeDiscoveryRead         = "discovery:read"
+	ScopeDiscoveryWrite        = "discovery:write"
+	ScopeAdminRead             = "admin:read"
+	ScopeAdminWrite            = "admin:write"
+)
+
+// APITokenScopes lists every scope a token can carry
+var APITokenScopes = []string{
+	ScopeAccountRead, ScopeAccountWrite,
+	ScopeWorkspacesRead, ScopeWorkspacesWrite,
+	ScopeCertificatesRead, ScopeCertificatesWrite,
+	ScopeNotificationsRead, ScopeNotificationsWrite,
+	ScopeCloudCredentialsRead, ScopeCloudCredentialsWrite,
+	ScopeDiscoveryRead, ScopeDiscoveryWrite,
+	ScopeAdminRead, ScopeAdminWrite,
+}
+
+// APIToken is a long-lived bearer credential for scripts and CI. Personal
+// access tokens act as their creator; service account tokens act as the
+// service account user, whose access comes from its workspace membership.
+type APIToken struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"` // Identity the token acts as
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Scopes     string     `gorm:"type:json;not null" json:"scopes"`               // JSON array: ["certificates:read"]
+	TokenHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the raw token
+	TokenHint  string     `gorm:"type:varchar(16);not null" json:"token_hint"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"type:varchar(45)" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  uint       `gorm:"index" json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+func MigrateAPIToken(db *gorm.DB) error {
+	return db.AutoMigrate(&APIToken{})
+}
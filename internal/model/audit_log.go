@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actor types
+const (
+	AuditActorUser      = "user"
+	AuditActorToken     = "token"     // A personal access or service account token
+	AuditActorScheduler = "scheduler" // Background jobs such as automatic renewal
+	AuditActorAnonymous = "anonymous" // Failed logins with an unknown username, link and agent downloads
+)
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// Actions recorded outside of request handling. Actions of API requests are
+// listed with their routes in the router.
+const (
+	AuditActionLogin            = "auth.login"
+	AuditActionLoginFailed      = "auth.login_failed"
+	AuditActionAccountLocked    = "auth.account_locked"
+	AuditActionStepUpFailed     = "auth.step_up_failed" // Wrong password or second factor when re-authenticating
+	AuditActionStepUpLocked     = "auth.step_up_locked"
+	AuditActionRenewalStarted   = "certificate.renewal_started"
+	AuditActionRenewalCompleted = "certificate.renewal_completed"
+	AuditActionRetentionPurge   = "audit_log.purge"
+	AuditActionInvitationAccept = "workspace_invitation.accept" // Through single sign-on started on the invitation page
+)
+
+// AuditLog is an append-only record of who did what to which resource. Rows
+// are only ever removed by the retention setting.
+type AuditLog struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ActorType    string    `gorm:"type:varchar(20);not null" json:"actor_type"`
+	ActorID      uint      `gorm:"index" json:"actor_id,omitempty"` // User, also for tokens (the token's owner or service account)
+	ActorName    string    `gorm:"type:varchar(100)" json:"actor_name"`
+	TokenID      uint      `json:"token_id,omitempty"` // API token used, if any
+	Action       string    `gorm:"type:varchar(64);not null;index" json:"action"`
+	ResourceType string    `gorm:"type:varchar(50);index:idx_audit_resource" json:"resource_type,omitempty"`
+	ResourceID   string    `gorm:"type:varchar(64);index:idx_audit_resource" json:"resource_id,omitempty"`
+	WorkspaceID  *uint     `gorm:"index" json:"workspace_id,omitempty"`
+	RequestID    string    `gorm:"type:varchar(64);index" json:"request_id,omitempty"`
+	IP           string    `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	Outcome      string    `gorm:"type:varchar(20);not null" json:"outcome"`
+	Detail       string    `gorm:"type:text" json:"detail,omitempty"`
+	Changes      string    `gorm:"type:text" json:"changes,omitempty"` // JSON object: {"field": {"old": ..., "new": ...}}
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+func MigrateAuditLog(db *gorm.DB) error {
+	return db.AutoMigrate(&AuditLog{})
+}
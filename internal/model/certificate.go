@@ -28,6 +28,14 @@ const (
 	IssueModeIndependent IssueMode = "independent"
 )
 
+// CertificateSource records how a certificate entered the console
+type CertificateSource string
+
+const (
+	CertificateSourceACME       CertificateSource = "acme"       // Issued through the console
+	CertificateSourceDiscovered CertificateSource = "discovered" // Imported from a network scan, expiry tracking only
+)
+
 type RenewalStatus string
 
 const (
@@ -50,9 +58,11 @@ type Certificate struct {
 	KeySize       int               `gorm:"default:2048" json:"key_size"`            // RSA: 2048/4096, ECC: 256/384
 	IssueMode     IssueMode         `gorm:"type:varchar(20);not null;default:combined" json:"issue_mode"`
 	Status        CertificateStatus `gorm:"type:varchar(20);not null;default:pending" json:"status"`
+	Source        CertificateSource `gorm:"type:varchar(20);not null;default:acme" json:"source"`
 	OrderURL      string            `gorm:"type:varchar(512)" json:"order_url,omitempty"`       // ACME order URL
 	CertPEM       string            `gorm:"type:text" json:"cert_pem,omitempty"`
-	KeyPEM        string            `gorm:"type:text" json:"-"`                                 // Encrypted, never expose in JSON
+	KeyPEM        string            `gorm:"type:text" json:"-"`                                 // Key reference from the key storage backend, never expose in JSON
+	KeyBackend    string            `gorm:"type:varchar(20)" json:"key_backend,omitempty"`      // Key storage backend holding KeyPEM; empty = database
 	ChainPEM      string            `gorm:"type:text" json:"chain_pem,omitempty"`
 	IssuerCertPEM string            `gorm:"type:text" json:"-"`                                 // Issuer certificate
 	SerialNumber  string            `gorm:"type:varchar(64)" json:"serial_number,omitempty"`    // Certificate serial number
@@ -64,6 +74,8 @@ type Certificate struct {
 	RenewalAttempts int             `gorm:"default:0" json:"renewal_attempts"`
 	LastRenewalAt   *time.Time      `json:"last_renewal_at,omitempty"`
 	RenewBeforeDays int             `gorm:"default:30" json:"renew_before_days"`
+	KeyExportCount  int             `gorm:"default:0" json:"key_export_count"`
+	LastKeyExportAt *time.Time      `json:"last_key_export_at,omitempty"`
 	CreatedAt     time.Time         `json:"created_at"`
 	UpdatedAt     time.Time         `json:"updated_at"`
 	Account       *ACMEAccount      `gorm:"foreignKey:AccountID" json:"-"`
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CertificateShare grants the members of another workspace read-only access
+// to a certificate. Keys stay governed by the certificate's own workspace.
+type CertificateShare struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CertificateID uint      `gorm:"not null;uniqueIndex:idx_certificate_share" json:"certificate_id"`
+	WorkspaceID   uint      `gorm:"not null;uniqueIndex:idx_certificate_share;index" json:"workspace_id"`
+	SharedBy      uint      `json:"shared_by"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// Relations
+	Workspace *Workspace `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
+}
+
+func (CertificateShare) TableName() string {
+	return "certificate_shares"
+}
+
+func MigrateCertificateShare(db *gorm.DB) error {
+	return db.AutoMigrate(&CertificateShare{})
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CloudCredential is an access key pair for a cloud vendor, shared by the
+// cloud deployment targets of one workspace (or of one user for personal certificates)
+type CloudCredential struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID     *uint     `gorm:"index" json:"workspace_id,omitempty"` // NULL = personal
+	Name            string    `gorm:"type:varchar(100);not null" json:"name"`
+	Vendor          string    `gorm:"type:varchar(20);not null" json:"vendor"` // aws, aliyun, tencent
+	AccessKeyID     string    `gorm:"type:varchar(128);not null" json:"access_key_id"`
+	SecretAccessKey string    `gorm:"type:text;not null" json:"-"` // Encrypted
+	Region          string    `gorm:"type:varchar(50)" json:"region,omitempty"`
+	CreatedBy       uint      `gorm:"index" json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	Workspace *Workspace `gorm:"foreignKey:WorkspaceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (CloudCredential) TableName() string {
+	return "cloud_credentials"
+}
+
+func MigrateCloudCredential(db *gorm.DB) error {
+	return db.AutoMigrate(&CloudCredential{})
+}
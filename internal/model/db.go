@@ -27,9 +27,15 @@ func InitDB(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	if err := MigrateWorkspace(db); err != nil {
 		return nil, err
 	}
+	if err := MigrateWorkspaceRole(db); err != nil {
+		return nil, err
+	}
 	if err := MigrateWorkspaceMember(db); err != nil {
 		return nil, err
 	}
+	if err := MigrateWorkspaceInvitation(db); err != nil {
+		return nil, err
+	}
 	if err := MigrateACMEAccount(db); err != nil {
 		return nil, err
 	}
@@ -42,6 +48,9 @@ func InitDB(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	if err := MigrateCertificate(db); err != nil {
 		return nil, err
 	}
+	if err := MigrateCertificateShare(db); err != nil {
+		return nil, err
+	}
 	if err := MigrateChallenge(db); err != nil {
 		return nil, err
 	}
@@ -54,6 +63,45 @@ func InitDB(cfg *config.DatabaseConfig) (*gorm.DB, error) {
 	if err := MigrateRenewalLog(db); err != nil {
 		return nil, err
 	}
+	if err := MigrateDiscovery(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateDeployToken(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateCloudCredential(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateDeployment(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateIngressBinding(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateDownloadLink(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateKeyExport(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateAPIToken(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateOIDC(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateMFA(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateSession(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateLoginThrottle(db); err != nil {
+		return nil, err
+	}
+	if err := MigrateAuditLog(db); err != nil {
+		return nil, err
+	}
 
 	// Initialize default settings
 	if err := InitDefaultSettings(db); err != nil {
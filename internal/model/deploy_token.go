@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeployTokenPrefix marks deploy tokens so they are recognizable in configs and secret scanners
+const DeployTokenPrefix = "acd_"
+
+// DeployToken is a read-only credential scoped to fetching one certificate's bundle
+type DeployToken struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CertificateID uint       `gorm:"not null;index" json:"certificate_id"`
+	Name          string     `gorm:"type:varchar(100);not null" json:"name"`
+	TokenHash     string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the raw token
+	TokenHint     string     `gorm:"type:varchar(16);not null" json:"token_hint"`    // First characters, for identification in the UI
+	CreatedBy     uint       `gorm:"index" json:"created_by"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP    string     `gorm:"type:varchar(45)" json:"last_used_ip,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	Certificate *Certificate `gorm:"foreignKey:CertificateID" json:"-"`
+}
+
+func (DeployToken) TableName() string {
+	return "deploy_tokens"
+}
+
+// IsActive reports whether the token can still be used
+func (t *DeployToken) IsActive() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || time.Now().Before(*t.ExpiresAt)
+}
+
+func MigrateDeployToken(db *gorm.DB) error {
+	return db.AutoMigrate(&DeployToken{})
+}
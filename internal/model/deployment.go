@@ -0,0 +1,95 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeploymentTargetType string
+
+const (
+	DeploymentTargetSSH        DeploymentTargetType = "ssh"
+	DeploymentTargetKubernetes DeploymentTargetType = "kubernetes"
+	DeploymentTargetCloud      DeploymentTargetType = "cloud"
+)
+
+type DeploymentTrigger string
+
+const (
+	DeploymentTriggerIssue   DeploymentTrigger = "issue"
+	DeploymentTriggerRenewal DeploymentTrigger = "renewal"
+	DeploymentTriggerManual  DeploymentTrigger = "manual"
+)
+
+// DeploymentTarget is an SSH host, Kubernetes Secret or cloud service the certificate is pushed to after issuance or renewal
+type DeploymentTarget struct {
+	ID            uint                 `gorm:"primaryKey" json:"id"`
+	CertificateID uint                 `gorm:"not null;index" json:"certificate_id"`
+	Name          string               `gorm:"type:varchar(100);not null" json:"name"`
+	Type          DeploymentTargetType `gorm:"type:varchar(20);not null;default:ssh" json:"type"`
+	Enabled       bool                 `gorm:"not null" json:"enabled"`
+
+	// SSH targets
+	Host               string `gorm:"type:varchar(255)" json:"host,omitempty"`
+	Port               int    `json:"port,omitempty"`
+	Username           string `gorm:"type:varchar(100)" json:"username,omitempty"`
+	PrivateKey         string `gorm:"type:text" json:"-"`                                      // Encrypted SSH private key
+	HostKeyFingerprint string `gorm:"type:varchar(100)" json:"host_key_fingerprint,omitempty"` // SHA256:...; pinned on first connect when empty
+	FullChainPath      string `gorm:"type:varchar(500)" json:"fullchain_path,omitempty"`
+	KeyPath            string `gorm:"type:varchar(500)" json:"key_path,omitempty"`
+	PostDeployCommand  string `gorm:"type:varchar(1000)" json:"post_deploy_command,omitempty"`
+
+	// Kubernetes targets
+	Kubeconfig    string `gorm:"type:text" json:"-"` // Encrypted kubeconfig; empty with InCluster uses the pod service account
+	InCluster     bool   `json:"in_cluster,omitempty"`
+	Namespace     string `gorm:"type:varchar(253)" json:"namespace,omitempty"`
+	SecretName    string `gorm:"type:varchar(253)" json:"secret_name,omitempty"`
+	AdoptExisting bool   `json:"adopt_existing,omitempty"` // Overwrite a TLS Secret without the managed-by label
+
+	// Cloud targets
+	Provider          string `gorm:"type:varchar(30)" json:"provider,omitempty"` // aws_acm, aws_iam, aliyun_cdn, aliyun_slb, tencent_ssl
+	CredentialID      *uint  `gorm:"index" json:"credential_id,omitempty"`
+	Region            string `gorm:"type:varchar(50)" json:"region,omitempty"`              // Overrides the credential region
+	CloudResources    string `gorm:"type:text" json:"cloud_resources,omitempty"`            // JSON array: listener ARNs, CDN domains, lb-id:port, instance IDs
+	CloudResourceType string `gorm:"type:varchar(30)" json:"cloud_resource_type,omitempty"` // Tencent Cloud: clb, cdn, ...
+	CloudCertID       string `gorm:"type:varchar(255)" json:"cloud_cert_id,omitempty"`      // Provider ID of the last upload
+	CloudFingerprint  string `gorm:"type:varchar(64)" json:"cloud_fingerprint,omitempty"`   // Fingerprint of the last upload
+	CloudSuperseded   string `gorm:"type:varchar(255)" json:"-"`                            // Previous upload awaiting cleanup
+
+	LastDeployedAt *time.Time `json:"last_deployed_at,omitempty"`
+	LastStatus     string     `gorm:"type:varchar(20)" json:"last_status,omitempty"` // success, failed
+	LastMessage    string     `gorm:"type:text" json:"last_message,omitempty"`
+	CreatedBy      uint       `gorm:"index" json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	Certificate *Certificate `gorm:"foreignKey:CertificateID" json:"-"`
+}
+
+func (DeploymentTarget) TableName() string {
+	return "deployment_targets"
+}
+
+// DeploymentLog records the result of one push to one target
+type DeploymentLog struct {
+	ID            uint              `gorm:"primaryKey" json:"id"`
+	CertificateID uint              `gorm:"not null;index" json:"certificate_id"`
+	TargetID      uint              `gorm:"not null;index" json:"target_id"`
+	TargetName    string            `gorm:"type:varchar(100)" json:"target_name"`
+	Trigger       DeploymentTrigger `gorm:"type:varchar(20);not null" json:"trigger"`
+	Status        string            `gorm:"type:varchar(20);not null" json:"status"` // success, failed
+	Fingerprint   string            `gorm:"type:varchar(64)" json:"fingerprint,omitempty"`
+	Message       string            `gorm:"type:text" json:"message,omitempty"`
+	Output        string            `gorm:"type:text" json:"output,omitempty"` // Post-deploy command output
+	DurationMs    int64             `json:"duration_ms"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+func (DeploymentLog) TableName() string {
+	return "deployment_logs"
+}
+
+func MigrateDeployment(db *gorm.DB) error {
+	return db.AutoMigrate(&DeploymentTarget{}, &DeploymentLog{})
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DiscoveryJobStatus string
+
+const (
+	DiscoveryJobStatusIdle    DiscoveryJobStatus = "idle"
+	DiscoveryJobStatusRunning DiscoveryJobStatus = "running"
+	DiscoveryJobStatusFailed  DiscoveryJobStatus = "failed"
+)
+
+type DiscoveredCertificateStatus string
+
+const (
+	DiscoveredStatusNew     DiscoveredCertificateStatus = "new"     // Seen on the network, not linked to anything
+	DiscoveredStatusManaged DiscoveredCertificateStatus = "managed" // Converted into an ACME-managed certificate
+	DiscoveredStatusTracked DiscoveredCertificateStatus = "tracked" // Imported for expiry tracking only
+	DiscoveredStatusIgnored DiscoveredCertificateStatus = "ignored"
+)
+
+// DiscoveryJob describes a network range to scan for TLS certificates
+type DiscoveryJob struct {
+	ID             uint               `gorm:"primaryKey" json:"id"`
+	WorkspaceID    uint               `gorm:"not null;index" json:"workspace_id"`
+	Name           string             `gorm:"type:varchar(100);not null" json:"name"`
+	CIDRs          string             `gorm:"type:json;not null" json:"cidrs"` // JSON array: ["10.0.0.0/24"]
+	Ports          string             `gorm:"type:json;not null" json:"ports"` // JSON array: [443, 8443]
+	Concurrency    int                `gorm:"default:32" json:"concurrency"`
+	TimeoutSeconds int                `gorm:"default:5" json:"timeout_seconds"`
+	IntervalHours  int                `gorm:"default:0" json:"interval_hours"` // 0 = manual only
+	Status         DiscoveryJobStatus `gorm:"type:varchar(20);not null;default:idle" json:"status"`
+	StartedAt      *time.Time         `json:"started_at,omitempty"` // Lease start of the current or last run
+	LastRunAt      *time.Time         `json:"last_run_at,omitempty"`
+	LastError      string             `gorm:"type:text" json:"last_error,omitempty"`
+	LastFound      int                `gorm:"default:0" json:"last_found"`
+	CreatedBy      uint               `gorm:"index" json:"created_by"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+func (DiscoveryJob) TableName() string {
+	return "discovery_jobs"
+}
+
+// DiscoveredCertificate is a certificate observed on a TLS endpoint during a discovery scan
+type DiscoveredCertificate struct {
+	ID            uint                        `gorm:"primaryKey" json:"id"`
+	JobID         uint                        `gorm:"not null;index" json:"job_id"`
+	WorkspaceID   uint                        `gorm:"not null;index;uniqueIndex:idx_discovered_endpoint" json:"workspace_id"`
+	IP            string                      `gorm:"type:varchar(45);not null;uniqueIndex:idx_discovered_endpoint" json:"ip"`
+	Port          int                         `gorm:"not null;uniqueIndex:idx_discovered_endpoint" json:"port"`
+	ServerName    string                      `gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_discovered_endpoint" json:"server_name,omitempty"` // SNI used for the handshake
+	Fingerprint   string                      `gorm:"type:varchar(64);not null;uniqueIndex:idx_discovered_endpoint" json:"fingerprint"`
+	CommonName    string                      `gorm:"type:varchar(255)" json:"common_name"`
+	Issuer        string                      `gorm:"type:varchar(512)" json:"issuer"`
+	SANs          string                      `gorm:"type:json" json:"sans"` // JSON array of DNS names
+	SerialNumber  string                      `gorm:"type:varchar(64)" json:"serial_number"`
+	NotBefore     *time.Time                  `json:"not_before,omitempty"`
+	ExpiresAt     *time.Time                  `gorm:"index" json:"expires_at,omitempty"`
+	CertPEM       string                      `gorm:"type:text" json:"-"`
+	ChainPEM      string                      `gorm:"type:text" json:"-"`
+	Status        DiscoveredCertificateStatus `gorm:"type:varchar(20);not null;default:new" json:"status"`
+	CertificateID *uint                       `gorm:"index" json:"certificate_id,omitempty"` // Linked certificate after manage/track
+	FirstSeenAt   time.Time                   `json:"first_seen_at"`
+	LastSeenAt    time.Time                   `json:"last_seen_at"`
+	CreatedAt     time.Time                   `json:"created_at"`
+	UpdatedAt     time.Time                   `json:"updated_at"`
+}
+
+func (DiscoveredCertificate) TableName() string {
+	return "discovered_certificates"
+}
+
+func MigrateDiscovery(db *gorm.DB) error {
+	if err := db.AutoMigrate(&DiscoveryJob{}); err != nil {
+		return err
+	}
+	return db.AutoMigrate(&DiscoveredCertificate{})
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DownloadLinkPrefix marks one-time download link tokens
+const DownloadLinkPrefix = "acl_"
+
+// DownloadLink is a single-use, short-lived URL that downloads one certificate
+// in one format without a console login
+type DownloadLink struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CertificateID uint       `gorm:"not null;index" json:"certificate_id"`
+	Format        string     `gorm:"type:varchar(20);not null" json:"format"`
+	Password      string     `gorm:"type:text" json:"-"` // Encrypted bundle password or passphrase
+	Alias         string     `gorm:"type:varchar(255)" json:"alias,omitempty"`
+	Namespace     string     `gorm:"type:varchar(63)" json:"namespace,omitempty"`
+	Reason        string     `gorm:"type:varchar(255)" json:"reason,omitempty"`      // Key export reason, when the policy asks for one
+	TokenHash     string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the raw token
+	TokenHint     string     `gorm:"type:varchar(16);not null" json:"token_hint"`
+	CreatedBy     uint       `gorm:"index" json:"created_by"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	Redemptions []DownloadLinkRedemption `gorm:"foreignKey:LinkID" json:"redemptions,omitempty"`
+}
+
+func (DownloadLink) TableName() string {
+	return "download_links"
+}
+
+// DownloadLinkRedemption records every attempt to use a download link
+type DownloadLinkRedemption struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LinkID    uint      `gorm:"not null;index" json:"link_id"`
+	IP        string    `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent string    `gorm:"type:varchar(255)" json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `gorm:"type:varchar(255)" json:"reason,omitempty"` // Why the attempt was refused or failed
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (DownloadLinkRedemption) TableName() string {
+	return "download_link_redemptions"
+}
+
+func MigrateDownloadLink(db *gorm.DB) error {
+	return db.AutoMigrate(&DownloadLink{}, &DownloadLinkRedemption{})
+}
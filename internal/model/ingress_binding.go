@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IngressBinding links a TLS Secret requested by an annotated Kubernetes
+// Ingress or Gateway to the certificate issued for it
+type IngressBinding struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID   uint       `gorm:"not null;index" json:"workspace_id"`
+	Kind          string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_ingress_binding" json:"kind"` // Ingress, Gateway
+	Namespace     string     `gorm:"type:varchar(63);not null;uniqueIndex:idx_ingress_binding" json:"namespace"`
+	Name          string     `gorm:"type:varchar(253);not null;uniqueIndex:idx_ingress_binding" json:"name"`
+	SecretName    string     `gorm:"type:varchar(253);not null;uniqueIndex:idx_ingress_binding" json:"secret_name"`
+	Hosts         string     `gorm:"type:text" json:"hosts"` // JSON array, sorted
+	CertificateID uint       `gorm:"index" json:"certificate_id"`
+	TargetID      uint       `json:"target_id"` // Deployment target writing the Secret
+	LastError     string     `gorm:"type:varchar(1000)" json:"last_error,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"` // Last certificate request, for retry backoff
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (IngressBinding) TableName() string {
+	return "ingress_bindings"
+}
+
+func MigrateIngressBinding(db *gorm.DB) error {
+	return db.AutoMigrate(&IngressBinding{})
+}
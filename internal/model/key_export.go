@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KeyExport records a download of a certificate's private key
+type KeyExport struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CertificateID uint      `gorm:"not null;index" json:"certificate_id"`
+	UserID        uint      `gorm:"index" json:"user_id"` // For download links, the user who created the link
+	Format        string    `gorm:"type:varchar(20);not null" json:"format"`
+	Reason        string    `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	Via           string    `gorm:"type:varchar(20);not null" json:"via"` // download, download_link
+	IP            string    `gorm:"type:varchar(45)" json:"ip"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (KeyExport) TableName() string {
+	return "key_exports"
+}
+
+func MigrateKeyExport(db *gorm.DB) error {
+	return db.AutoMigrate(&KeyExport{})
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Login throttle scopes
+const (
+	ThrottleScopeUsername = "username"
+	ThrottleScopeIP       = "ip"
+	ThrottleScopeStepUp   = "step_up" // Re-authentication of a signed-in user, keyed by user ID
+)
+
+// LoginThrottle counts recent failed logins for a username or a client IP
+type LoginThrottle struct {
+	ID           uint   `gorm:"primaryKey"`
+	Scope        string `gorm:"type:varchar(10);not null;uniqueIndex:idx_login_throttle_key"`
+	Key          string `gorm:"type:varchar(100);not null;uniqueIndex:idx_login_throttle_key"` // Lowercased username, IP or user ID
+	Failures     int    `gorm:"not null;default:0"`
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+func MigrateLoginThrottle(db *gorm.DB) error {
+	return db.AutoMigrate(&LoginThrottle{})
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Second factor methods
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+	MFAMethodRecovery = "recovery"
+)
+
+// MFA challenge purposes
+const (
+	MFAPurposeLogin    = "login"    // Password accepted, waiting for the second factor
+	MFAPurposeEnroll   = "enroll"   // Password accepted, the policy requires enrolling a second factor first
+	MFAPurposeRegister = "register" // Pending passkey registration
+	MFAPurposeStepUp   = "step_up"  // Re-authentication for sensitive actions
+)
+
+// UserTOTP is a user's authenticator app secret. It only counts as a second
+// factor once a first code confirmed the enrolment.
+type UserTOTP struct {
+	ID           uint      `gorm:"primaryKey" json:"-"`
+	UserID       uint      `gorm:"not null;uniqueIndex" json:"-"`
+	Secret       string    `gorm:"type:text;not null" json:"-"` // Encrypted base32 secret
+	Enabled      bool      `gorm:"default:false" json:"enabled"`
+	LastUsedStep int64     `json:"-"` // Time step of the last accepted code, which cannot be replayed
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totps"
+}
+
+// RecoveryCode is a single-use code for users who lost their second factor
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"type:varchar(64);not null;index"` // SHA-256 of the normalized code
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// WebAuthnCredential is a registered passkey or security key
+type WebAuthnCredential struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"-"`
+	Name         string     `gorm:"type:varchar(100)" json:"name"`
+	CredentialID string     `gorm:"type:varchar(255);not null;uniqueIndex" json:"-"` // base64url
+	Data         string     `gorm:"type:text;not null" json:"-"`                     // JSON credential record, sign counter included
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// MFAChallenge is a short-lived token between the password and the second
+// factor, a pending passkey ceremony, or a step-up grant once verified
+type MFAChallenge struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     uint       `gorm:"not null;index"`
+	Purpose    string     `gorm:"type:varchar(20);not null"`
+	TokenHash  string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	Session    string     `gorm:"type:text"` // Pending WebAuthn ceremony
+	Attempts   int        `gorm:"default:0"`
+	VerifiedAt *time.Time // Step-up grants only
+	ExpiresAt  time.Time  `gorm:"index"`
+	CreatedAt  time.Time
+}
+
+func (MFAChallenge) TableName() string {
+	return "mfa_challenges"
+}
+
+func MigrateMFA(db *gorm.DB) error {
+	return db.AutoMigrate(&UserTOTP{}, &RecoveryCode{}, &WebAuthnCredential{}, &MFAChallenge{})
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to an account at an OpenID Connect provider
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_provider_subject" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_provider_subject" json:"subject"` // "sub" claim
+	Email       string     `gorm:"type:varchar(100)" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCLogin tracks one single sign-on attempt: the state, nonce and PKCE
+// verifier until the provider calls back, then the one-time code the console
+// exchanges for a session so the JWT never appears in a URL.
+type OIDCLogin struct {
+	ID        uint      `gorm:"primaryKey"`
+	Provider  string    `gorm:"type:varchar(50);not null"`
+	StateHash *string   `gorm:"type:varchar(64);uniqueIndex"` // SHA-256 of the state parameter, until the callback
+	Nonce     string    `gorm:"type:varchar(64)"`
+	Verifier  string    `gorm:"type:varchar(128)"`            // PKCE code verifier
+	CodeHash  *string   `gorm:"type:varchar(64);uniqueIndex"` // SHA-256 of the exchange code, set after the callback
+	UserID    *uint     `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+
+	InviteHash string `gorm:"type:varchar(64)"` // SHA-256 of the invitation to accept after the callback
+}
+
+func (OIDCLogin) TableName() string {
+	return "oidc_logins"
+}
+
+func MigrateOIDC(db *gorm.DB) error {
+	return db.AutoMigrate(&UserIdentity{}, &OIDCLogin{})
+}
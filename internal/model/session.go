@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a console login. Access tokens (JWTs) carry the session ID and
+// are only accepted while the session is active; the refresh token rotates
+// on every use.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserID            uint       `gorm:"not null;index" json:"-"`
+	TokenHash         string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the current refresh token
+	PreviousTokenHash string     `gorm:"type:varchar(64);index" json:"-"`                // Rotated-out token; presenting it again means it leaked
+	UserAgent         string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IP                string     `gorm:"type:varchar(45)" json:"ip"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	RefreshedAt       time.Time  `json:"-"`
+	ExpiresAt         time.Time  `gorm:"index" json:"expires_at"` // Extended on every refresh
+	RevokedAt         *time.Time `json:"-"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func (Session) TableName() string {
+	return "user_sessions"
+}
+
+func MigrateSession(db *gorm.DB) error {
+	return db.AutoMigrate(&Session{})
+}
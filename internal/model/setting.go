@@ -16,6 +16,13 @@ const (
 	SettingRenewalEnabled     = "renewal.enabled"      // 全局续期开关
 	SettingRenewalDefaultDays = "renewal.default_days"  // 默认提前续期天数
 	SettingRenewalMaxAttempts = "renewal.max_attempts"  // 最大重试次数
+	SettingRenewalArchived    = "renewal.archived_workspaces" // 已归档工作空间的证书：pause 暂停续期，continue 继续续期
+	SettingMFARequireAdmins          = "security.mfa_require_admins"           // 系统管理员必须启用两步验证
+	SettingMFARequireWorkspaceOwners = "security.mfa_require_workspace_owners" // 工作空间所有者必须启用两步验证
+	SettingPasswordMinLength         = "security.password_min_length"          // 密码最小长度
+	SettingLockoutThreshold          = "security.lockout_threshold"            // 连续登录失败多少次后锁定账户
+	SettingLockoutMinutes            = "security.lockout_minutes"              // 账户锁定时长（分钟）
+	SettingAuditRetentionDays        = "security.audit_retention_days"         // 审计日志保留天数，0 表示永久保留
 )
 
 // Setting 系统配置表
@@ -48,6 +55,13 @@ var defaultSettings = map[string]struct {
 	SettingRenewalEnabled:     {"true", "全局自动续期开关"},
 	SettingRenewalDefaultDays: {"30", "默认提前续期天数"},
 	SettingRenewalMaxAttempts: {"3", "续期最大重试次数"},
+	SettingRenewalArchived:    {"pause", "已归档工作空间的证书是否继续自动续期（pause/continue）"},
+	SettingMFARequireAdmins:          {"false", "系统管理员必须启用两步验证"},
+	SettingMFARequireWorkspaceOwners: {"false", "工作空间所有者必须启用两步验证"},
+	SettingPasswordMinLength:         {"8", "密码最小长度"},
+	SettingLockoutThreshold:          {"10", "连续登录失败多少次后锁定账户"},
+	SettingLockoutMinutes:            {"15", "账户锁定时长（分钟）"},
+	SettingAuditRetentionDays:        {"365", "审计日志保留天数，0 表示永久保留"},
 }
 
 // InitDefaultSettings 初始化默认配置
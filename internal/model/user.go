@@ -13,6 +13,9 @@ const (
 	RoleAdmin = "admin"
 )
 
+// AuthSourceLDAP marks users whose password and profile live in the directory
+const AuthSourceLDAP = "ldap"
+
 type User struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
 	Username  string     `gorm:"type:varchar(50);uniqueIndex;not null" json:"username"`
@@ -24,6 +27,22 @@ type User struct {
 	LastLogin *time.Time `json:"last_login,omitempty"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
+
+	// Service accounts belong to one workspace, cannot log in and only
+	// authenticate with API tokens
+	ServiceAccount bool  `gorm:"default:false;index" json:"service_account"`
+	WorkspaceID    *uint `gorm:"index" json:"workspace_id,omitempty"` // Owning workspace of a service account
+
+	AuthSource string `gorm:"type:varchar(20)" json:"auth_source,omitempty"` // Empty for local accounts
+
+	// Set when the email came from an admin, the directory, an identity
+	// provider or an emailed invitation; cleared when the user changes it.
+	// Only verified addresses are linked to single sign-on identities.
+	EmailVerified bool `gorm:"default:false" json:"email_verified"`
+
+	// Set for the seeded admin and after an admin reset; the user can only
+	// change their password until they do
+	MustChangePassword bool `gorm:"default:false" json:"must_change_password"`
 }
 
 func (User) TableName() string {
@@ -52,6 +71,9 @@ func MigrateUser(db *gorm.DB) error {
 	return db.AutoMigrate(&User{})
 }
 
+// DefaultAdminPassword is the well-known password of the seeded admin
+const DefaultAdminPassword = "admin123"
+
 // CreateDefaultAdmin creates a default admin user if no users exist
 func CreateDefaultAdmin(db *gorm.DB) error {
 	var admin User
@@ -60,7 +82,13 @@ func CreateDefaultAdmin(db *gorm.DB) error {
 	if result.Error == nil {
 		// Admin exists, ensure role is set to admin
 		if admin.Role != RoleAdmin {
-			return db.Model(&admin).Update("role", RoleAdmin).Error
+			if err := db.Model(&admin).Update("role", RoleAdmin).Error; err != nil {
+				return err
+			}
+		}
+		// Installs that never changed the seeded password must do so now
+		if !admin.MustChangePassword && admin.AuthSource == "" && admin.CheckPassword(DefaultAdminPassword) {
+			return db.Model(&admin).Update("must_change_password", true).Error
 		}
 		return nil
 	}
@@ -77,8 +105,10 @@ func CreateDefaultAdmin(db *gorm.DB) error {
 		Nickname: "Administrator",
 		Role:     RoleAdmin,
 		Status:   1,
+
+		MustChangePassword: true,
 	}
-	if err := newAdmin.SetPassword("admin123"); err != nil {
+	if err := newAdmin.SetPassword(DefaultAdminPassword); err != nil {
 		return err
 	}
 
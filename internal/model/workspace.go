@@ -6,6 +6,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// Key export policies
+const (
+	KeyExportPolicyAdmins = "admins"  // Members with cert.key.export (owner and admins by default)
+	KeyExportPolicyNone   = "none"    // Nobody, deploy tokens and deployment targets included
+	KeyExportPolicyStepUp = "step_up" // Also members and operators; never viewers. Every export re-authenticates.
+)
+
+// Workspace status. Archived workspaces are read-only: certificates can be
+// viewed and downloaded, but nothing can be issued or changed.
+const (
+	WorkspaceStatusArchived = 0
+	WorkspaceStatusActive   = 1
+)
+
 type Workspace struct {
 	ID          uint      `gorm:"primaryKey" json:"id"`
 	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
@@ -15,6 +29,14 @@ type Workspace struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
+	// Private key export policy for the workspace's certificates
+	KeyExportPolicy        string `gorm:"type:varchar(20);default:admins" json:"key_export_policy"`
+	KeyExportEncryptedOnly bool   `gorm:"default:false" json:"key_export_encrypted_only"` // Only password-protected formats
+	KeyExportRequireReason bool   `gorm:"default:false" json:"key_export_require_reason"`
+
+	// Ownership transfer offered by the owner, until the nominee accepts it
+	PendingOwnerID *uint `gorm:"index" json:"pending_owner_id,omitempty"`
+
 	// Relations
 	Owner   *User               `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
 	Members []WorkspaceMember   `gorm:"foreignKey:WorkspaceID" json:"members,omitempty"`
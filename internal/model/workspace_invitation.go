@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkspaceInvitationPrefix marks workspace invitation tokens
+const WorkspaceInvitationPrefix = "awi_"
+
+// WorkspaceInvitation lets someone without a membership, or without an
+// account, join a workspace with a preset role. The token is single use.
+type WorkspaceInvitation struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID  uint       `gorm:"not null;index" json:"workspace_id"`
+	Email        string     `gorm:"type:varchar(100)" json:"email"` // Empty for links shared by hand
+	Role         string     `gorm:"type:varchar(20);not null" json:"role"`
+	CustomRoleID *uint      `json:"custom_role_id,omitempty"`                       // Set when Role is custom
+	TokenHash    string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the raw token
+	TokenHint    string     `gorm:"type:varchar(16);not null" json:"token_hint"`
+	InvitedBy    uint       `gorm:"index" json:"invited_by"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	EmailSentAt  *time.Time `json:"email_sent_at,omitempty"`
+	AcceptedAt   *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy   *uint      `json:"accepted_by,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// Relations
+	Workspace  *Workspace     `gorm:"foreignKey:WorkspaceID" json:"-"`
+	Inviter    *User          `gorm:"foreignKey:InvitedBy" json:"-"`
+	CustomRole *WorkspaceRole `gorm:"foreignKey:CustomRoleID" json:"-"`
+}
+
+func (WorkspaceInvitation) TableName() string {
+	return "workspace_invitations"
+}
+
+func MigrateWorkspaceInvitation(db *gorm.DB) error {
+	return db.AutoMigrate(&WorkspaceInvitation{})
+}
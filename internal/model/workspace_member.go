@@ -8,21 +8,34 @@ import (
 
 // WorkspaceRole constants
 const (
-	WorkspaceRoleOwner  = "owner"
-	WorkspaceRoleAdmin  = "admin"
-	WorkspaceRoleMember = "member"
+	WorkspaceRoleOwner    = "owner"
+	WorkspaceRoleAdmin    = "admin"
+	WorkspaceRoleOperator = "operator" // Verify and renew, but not delete or export keys
+	WorkspaceRoleMember   = "member"
+	WorkspaceRoleViewer   = "viewer" // Read-only, never exports keys
+	WorkspaceRoleCustom   = "custom" // Permissions come from CustomRoleID
+)
+
+// Memberships granted by an OIDC or LDAP group mapping follow the user's
+// groups on every login. Memberships without a source are never synced.
+const (
+	MemberSourceOIDC = "oidc"
+	MemberSourceLDAP = "ldap"
 )
 
 type WorkspaceMember struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	WorkspaceID uint      `gorm:"not null;index:idx_workspace_user,unique" json:"workspace_id"`
-	UserID      uint      `gorm:"not null;index:idx_workspace_user,unique" json:"user_id"`
-	Role        string    `gorm:"type:varchar(20);not null;default:member" json:"role"` // owner/admin/member
-	CreatedAt   time.Time `json:"created_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID  uint      `gorm:"not null;index:idx_workspace_user,unique" json:"workspace_id"`
+	UserID       uint      `gorm:"not null;index:idx_workspace_user,unique" json:"user_id"`
+	Role         string    `gorm:"type:varchar(20);not null;default:member" json:"role"` // owner/admin/operator/member/viewer/custom
+	CustomRoleID *uint     `gorm:"index" json:"custom_role_id,omitempty"`                // Set when Role is custom
+	Source       string    `gorm:"type:varchar(20)" json:"source,omitempty"`             // Empty for members added by hand
+	CreatedAt    time.Time `json:"created_at"`
 
 	// Relations
-	Workspace *Workspace `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
-	User      *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Workspace  *Workspace     `gorm:"foreignKey:WorkspaceID" json:"workspace,omitempty"`
+	User       *User          `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	CustomRole *WorkspaceRole `gorm:"foreignKey:CustomRoleID" json:"custom_role,omitempty"`
 }
 
 func (WorkspaceMember) TableName() string {
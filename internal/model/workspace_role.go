@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace permissions. Built-in roles map to fixed sets of these; custom
+// roles pick any combination.
+const (
+	PermissionCertRead           = "cert.read"           // See certificates, their status, logs and challenges
+	PermissionCertKeyExport      = "cert.key.export"     // Download formats that contain the private key
+	PermissionCertIssue          = "cert.issue"          // Create, verify, renew and deploy certificates
+	PermissionCertDelete         = "cert.delete"         // Delete certificates
+	PermissionNotificationManage = "notification.manage" // Edit the workspace's notification channels
+	PermissionMemberManage       = "member.manage"       // Add, update and remove members; define roles
+)
+
+// PermissionCatalog lists every permission a custom role can grant
+var PermissionCatalog = []string{
+	PermissionCertRead,
+	PermissionCertKeyExport,
+	PermissionCertIssue,
+	PermissionCertDelete,
+	PermissionNotificationManage,
+	PermissionMemberManage,
+}
+
+// BuiltinRolePermissions are the permissions of the built-in workspace roles
+var BuiltinRolePermissions = map[string][]string{
+	WorkspaceRoleOwner:    PermissionCatalog,
+	WorkspaceRoleAdmin:    PermissionCatalog,
+	WorkspaceRoleOperator: {PermissionCertRead, PermissionCertIssue},
+	WorkspaceRoleMember:   {PermissionCertRead},
+	WorkspaceRoleViewer:   {PermissionCertRead},
+}
+
+// WorkspaceRole is an admin-defined role of a workspace, assigned to members
+// with Role = WorkspaceRoleCustom
+type WorkspaceRole struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"not null;index:idx_workspace_role_name,unique" json:"workspace_id"`
+	Name        string    `gorm:"type:varchar(50);not null;index:idx_workspace_role_name,unique" json:"name"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	Permissions string    `gorm:"type:json;not null" json:"permissions"` // JSON array: ["cert.read", "cert.issue"]
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (WorkspaceRole) TableName() string {
+	return "workspace_roles"
+}
+
+func MigrateWorkspaceRole(db *gorm.DB) error {
+	return db.AutoMigrate(&WorkspaceRole{})
+}
@@ -8,13 +8,15 @@ import (
 
 // Standard response codes
 const (
-	CodeSuccess       = 0
-	CodeBadRequest    = 40000
-	CodeUnauthorized  = 40100
-	CodeForbidden     = 40300
-	CodeNotFound      = 40400
-	CodeValidation    = 42200
-	CodeInternalError = 50000
+	CodeSuccess                = 0
+	CodeBadRequest             = 40000
+	CodeUnauthorized           = 40100
+	CodeForbidden              = 40300
+	CodePasswordChangeRequired = 40301
+	CodeNotFound               = 40400
+	CodeValidation             = 42200
+	CodeTooManyRequests        = 42900
+	CodeInternalError          = 50000
 )
 
 // Response is the standard API response structure
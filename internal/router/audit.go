@@ -0,0 +1,115 @@
+package router
+
+// auditActions names the audit log action of every route that changes state
+// or hands out certificates and keys, keyed by "METHOD /full/path". The
+// resource type is the part of the action before the dot.
+var auditActions = map[string]string{
+	// One-time links and deploy agents
+	"GET /api/v1/download/:token":      "download_link.redeem",
+	"GET /api/v1/deploy/:token/bundle": "certificate.agent_download",
+
+	// Invitations
+	"POST /api/v1/invitations/:token/signup": "workspace_invitation.accept",
+	"POST /api/v1/invitations/:token/accept": "workspace_invitation.accept",
+
+	// Own account
+	"POST /api/v1/auth/change-password":    "user.password_change",
+	"PUT /api/v1/auth/profile":             "user.profile_update",
+	"POST /api/v1/auth/mfa/totp/enable":    "mfa.totp_enable",
+	"DELETE /api/v1/auth/mfa/totp":         "mfa.totp_disable",
+	"POST /api/v1/auth/mfa/webauthn":       "mfa.passkey_add",
+	"DELETE /api/v1/auth/mfa/webauthn/:id": "mfa.passkey_remove",
+	"POST /api/v1/auth/mfa/recovery-codes": "mfa.recovery_codes_regenerate",
+	"POST /api/v1/auth/step-up":            "auth.step_up",
+	"POST /api/v1/auth/logout":             "auth.logout",
+	"DELETE /api/v1/auth/sessions":         "session.revoke_all",
+	"DELETE /api/v1/auth/sessions/:id":     "session.revoke",
+	"POST /api/v1/tokens":                  "api_token.create",
+	"DELETE /api/v1/tokens/:id":            "api_token.revoke",
+
+	// Workspaces
+	"POST /api/v1/workspaces":                                                   "workspace.create",
+	"PUT /api/v1/workspaces/:id":                                                "workspace.update",
+	"DELETE /api/v1/workspaces/:id":                                             "workspace.delete",
+	"POST /api/v1/workspaces/:id/transfer":                                      "workspace.transfer_request",
+	"DELETE /api/v1/workspaces/:id/transfer":                                    "workspace.transfer_cancel",
+	"POST /api/v1/workspaces/:id/transfer/accept":                               "workspace.transfer_accept",
+	"POST /api/v1/workspaces/:id/members":                                       "workspace_member.add",
+	"PUT /api/v1/workspaces/:id/members/:userId":                                "workspace_member.update",
+	"DELETE /api/v1/workspaces/:id/members/:userId":                             "workspace_member.remove",
+	"POST /api/v1/workspaces/:id/invitations":                                   "workspace_invitation.create",
+	"DELETE /api/v1/workspaces/:id/invitations/:invitationId":                   "workspace_invitation.revoke",
+	"POST /api/v1/workspaces/:id/roles":                                         "workspace_role.create",
+	"PUT /api/v1/workspaces/:id/roles/:roleId":                                  "workspace_role.update",
+	"DELETE /api/v1/workspaces/:id/roles/:roleId":                               "workspace_role.delete",
+	"POST /api/v1/workspaces/:id/service-accounts":                              "service_account.create",
+	"DELETE /api/v1/workspaces/:id/service-accounts/:accountId":                 "service_account.delete",
+	"POST /api/v1/workspaces/:id/service-accounts/:accountId/tokens":            "api_token.create",
+	"DELETE /api/v1/workspaces/:id/service-accounts/:accountId/tokens/:tokenId": "api_token.revoke",
+	"GET /api/v1/workspaces/:id/audit-logs/export":                              "audit_log.export",
+
+	// Certificates
+	"POST /api/v1/certificates":                                    "certificate.create",
+	"DELETE /api/v1/certificates/:id":                              "certificate.delete",
+	"POST /api/v1/certificates/:id/verify":                         "certificate.verify",
+	"GET /api/v1/certificates/:id/download":                        "certificate.download",
+	"POST /api/v1/certificates/:id/download-links":                 "download_link.create",
+	"PUT /api/v1/certificates/:id/auto-renew":                      "certificate.auto_renew_update",
+	"POST /api/v1/certificates/:id/renew":                          "certificate.renew",
+	"POST /api/v1/certificates/:id/move":                           "certificate.move",
+	"POST /api/v1/certificates/:id/shares":                         "certificate.share",
+	"DELETE /api/v1/certificates/:id/shares/:workspaceId":          "certificate.unshare",
+	"POST /api/v1/certificates/:id/redeploy":                       "certificate.redeploy",
+	"POST /api/v1/certificates/:id/deploy-tokens":                  "deploy_token.create",
+	"DELETE /api/v1/certificates/:id/deploy-tokens/:tokenId":       "deploy_token.revoke",
+	"POST /api/v1/certificates/:id/deployment-targets":             "deployment_target.create",
+	"PUT /api/v1/certificates/:id/deployment-targets/:targetId":    "deployment_target.update",
+	"DELETE /api/v1/certificates/:id/deployment-targets/:targetId": "deployment_target.delete",
+
+	// Notifications, cloud credentials and discovery
+	"POST /api/v1/notifications":                     "notification.create",
+	"PUT /api/v1/notifications/:id":                  "notification.update",
+	"DELETE /api/v1/notifications/:id":               "notification.delete",
+	"POST /api/v1/cloud-credentials":                 "cloud_credential.create",
+	"DELETE /api/v1/cloud-credentials/:id":           "cloud_credential.delete",
+	"POST /api/v1/discovery/jobs":                    "discovery_job.create",
+	"DELETE /api/v1/discovery/jobs/:id":              "discovery_job.delete",
+	"POST /api/v1/discovery/jobs/:id/run":            "discovery_job.run",
+	"POST /api/v1/discovery/certificates/:id/manage": "discovered_certificate.manage",
+	"POST /api/v1/discovery/certificates/:id/track":  "discovered_certificate.track",
+	"POST /api/v1/discovery/certificates/:id/ignore": "discovered_certificate.ignore",
+
+	// Administration
+	"POST /api/v1/admin/users":                    "user.create",
+	"PUT /api/v1/admin/users/:id":                 "user.update",
+	"DELETE /api/v1/admin/users/:id":              "user.delete",
+	"POST /api/v1/admin/users/:id/reset-password": "user.password_reset",
+	"DELETE /api/v1/admin/users/:id/mfa":          "user.mfa_reset",
+	"POST /api/v1/admin/users/:id/unlock":         "user.unlock",
+	"PUT /api/v1/admin/settings":                  "setting.update",
+	"PUT /api/v1/admin/settings/acme":             "setting.update",
+	"PUT /api/v1/admin/settings/site":             "setting.update",
+	"PUT /api/v1/admin/settings/security":         "setting.update",
+	"POST /api/v1/admin/encryption/rotate":        "encryption.rotate",
+	"GET /api/v1/admin/audit-logs/export":         "audit_log.export",
+}
+
+// State-changing routes left out of the audit log: logins are recorded by
+// the login guard, the rest only prepare or test something
+var auditExempt = map[string]bool{
+	"POST /api/v1/auth/login":                       true,
+	"POST /api/v1/auth/refresh":                     true,
+	"POST /api/v1/auth/oidc/exchange":               true,
+	"POST /api/v1/auth/mfa/login/verify":            true,
+	"POST /api/v1/auth/mfa/login/webauthn/options":  true,
+	"POST /api/v1/auth/mfa/login/webauthn":          true,
+	"POST /api/v1/auth/mfa/enroll/totp":             true,
+	"POST /api/v1/auth/mfa/enroll/totp/enable":      true,
+	"POST /api/v1/auth/mfa/enroll/webauthn/options": true,
+	"POST /api/v1/auth/mfa/enroll/webauthn":         true,
+	"POST /api/v1/auth/mfa/totp":                    true,
+	"POST /api/v1/auth/mfa/webauthn/options":        true,
+	"POST /api/v1/auth/step-up/options":             true,
+	"POST /api/v1/certificates/:id/pre-verify":      true,
+	"POST /api/v1/notifications/:id/test":           true,
+}
@@ -0,0 +1,41 @@
+package router
+
+import "github.com/imkerbos/ACME-Console/internal/middleware"
+
+// bodyLogPolicies limits what the request log keeps of a route's bodies, keyed
+// by "METHOD /full/path". Every other route logs bodies with secrets redacted.
+var bodyLogPolicies = map[string]middleware.BodyLogging{
+	// Credentials, one-time codes and the tokens issued for them
+	"POST /api/v1/auth/login":                                        middleware.BodyLogOff,
+	"POST /api/v1/auth/refresh":                                      middleware.BodyLogOff,
+	"POST /api/v1/auth/oidc/exchange":                                middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/login/verify":                             middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/login/webauthn/options":                   middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/login/webauthn":                           middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/enroll/totp":                              middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/enroll/totp/enable":                       middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/enroll/webauthn/options":                  middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/enroll/webauthn":                          middleware.BodyLogOff,
+	"POST /api/v1/auth/change-password":                              middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/totp":                                     middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/totp/enable":                              middleware.BodyLogOff,
+	"DELETE /api/v1/auth/mfa/totp":                                   middleware.BodyLogOff,
+	"POST /api/v1/auth/mfa/recovery-codes":                           middleware.BodyLogOff,
+	"POST /api/v1/auth/step-up":                                      middleware.BodyLogOff,
+	"POST /api/v1/admin/users":                                       middleware.BodyLogOff,
+	"POST /api/v1/admin/users/:id/reset-password":                    middleware.BodyLogOff,
+	"POST /api/v1/tokens":                                            middleware.BodyLogOff,
+	"POST /api/v1/workspaces/:id/service-accounts/:accountId/tokens": middleware.BodyLogOff,
+	"POST /api/v1/certificates/:id/deploy-tokens":                    middleware.BodyLogOff,
+	"POST /api/v1/cloud-credentials":                                 middleware.BodyLogOff,
+	"POST /api/v1/invitations/:token/signup":                         middleware.BodyLogOff,
+
+	// Certificates, keys and exports
+	"GET /api/v1/certificates/:id/download":          middleware.BodyLogMetadata,
+	"POST /api/v1/certificates/:id/download-links":   middleware.BodyLogMetadata,
+	"GET /api/v1/download/:token":                    middleware.BodyLogMetadata,
+	"GET /api/v1/deploy/:token/bundle":               middleware.BodyLogMetadata,
+	"GET /api/v1/certificates/:id/challenges/export": middleware.BodyLogMetadata,
+	"GET /api/v1/admin/audit-logs/export":            middleware.BodyLogMetadata,
+	"GET /api/v1/workspaces/:id/audit-logs/export":   middleware.BodyLogMetadata,
+}
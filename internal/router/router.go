@@ -1,6 +1,7 @@
 package router
 
 import (
+	"fmt"
 	"io/fs"
 	"net/http"
 
@@ -9,25 +10,41 @@ import (
 	"github.com/imkerbos/ACME-Console/internal/handler"
 	"github.com/imkerbos/ACME-Console/internal/middleware"
 	"github.com/imkerbos/ACME-Console/internal/response"
+	"github.com/imkerbos/ACME-Console/internal/service"
 )
 
 type Handlers struct {
-	Auth         *handler.AuthHandler
-	Certificate  *handler.CertificateHandler
-	Challenge    *handler.ChallengeHandler
-	User         *handler.UserHandler
-	Setting      *handler.SettingHandler
-	Workspace    *handler.WorkspaceHandler
-	Notification *handler.NotificationHandler
+	Auth            *handler.AuthHandler
+	Certificate     *handler.CertificateHandler
+	Challenge       *handler.ChallengeHandler
+	User            *handler.UserHandler
+	Setting         *handler.SettingHandler
+	Workspace       *handler.WorkspaceHandler
+	Notification    *handler.NotificationHandler
+	Discovery       *handler.DiscoveryHandler
+	Deploy          *handler.DeployHandler
+	Deployment      *handler.DeploymentHandler
+	CloudCredential *handler.CloudCredentialHandler
+	DownloadLink    *handler.DownloadLinkHandler
+	Encryption      *handler.EncryptionHandler
+	APIToken        *handler.APITokenHandler
+	Audit           *handler.AuditHandler
+	Invitation      *handler.InvitationHandler
 }
 
-func Setup(handlers *Handlers, jwtManager *auth.JWTManager, staticFS fs.FS) *gin.Engine {
+func Setup(handlers *Handlers, jwtManager *auth.JWTManager, tokens middleware.TokenAuthenticator, sessions middleware.SessionValidator, authz middleware.CertificateAuthorizer, audit middleware.AuditRecorder, trustedProxies []string, staticFS fs.FS) (*gin.Engine, error) {
 	r := gin.New()
 
+	// Client IPs feed login throttling, session binding and the audit log, so
+	// forwarded headers are only believed from configured proxies
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
+
 	// Global middleware - order matters
 	r.Use(middleware.RequestID())
 	r.Use(middleware.Recovery())
-	r.Use(middleware.Logger())
+	r.Use(middleware.Logger(bodyLogPolicies))
 	r.Use(middleware.CORS())
 
 	// Health check (no auth required)
@@ -37,61 +54,151 @@ func Setup(handlers *Handlers, jwtManager *auth.JWTManager, staticFS fs.FS) *gin
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
+	v1.Use(middleware.Audit(audit, auditActions))
 	{
 		// Auth routes (no auth required)
 		authGroup := v1.Group("/auth")
 		{
 			authGroup.POST("/login", handlers.Auth.Login)
+			authGroup.POST("/refresh", handlers.Auth.Refresh)
+			authGroup.GET("/oidc/providers", handlers.Auth.OIDCProviders)
+			authGroup.GET("/oidc/:provider/login", handlers.Auth.OIDCLogin)
+			authGroup.GET("/oidc/:provider/callback", handlers.Auth.OIDCCallback)
+			authGroup.POST("/oidc/exchange", handlers.Auth.OIDCExchange)
+
+			// Second login step, authenticated by the MFA challenge token
+			authGroup.POST("/mfa/login/verify", handlers.Auth.MFAVerify)
+			authGroup.POST("/mfa/login/webauthn/options", handlers.Auth.MFAPasskeyOptions)
+			authGroup.POST("/mfa/login/webauthn", handlers.Auth.MFAPasskeyVerify)
+			authGroup.POST("/mfa/enroll/totp", handlers.Auth.MFAEnrollTOTP)
+			authGroup.POST("/mfa/enroll/totp/enable", handlers.Auth.MFAEnrollTOTPEnable)
+			authGroup.POST("/mfa/enroll/webauthn/options", handlers.Auth.MFAEnrollPasskeyOptions)
+			authGroup.POST("/mfa/enroll/webauthn", handlers.Auth.MFAEnrollPasskey)
 		}
 
 		// Public settings (no auth required)
 		v1.GET("/settings/site", handlers.Setting.GetSite)
 
+		// Deploy agent bundle (authenticated by deploy token)
+		v1.GET("/deploy/:token/bundle", handlers.Deploy.Bundle)
+
+		// One-time certificate downloads (authenticated by the link token)
+		v1.GET("/download/:token", handlers.DownloadLink.Redeem)
+
+		// Workspace invitations (authenticated by the invitation token)
+		v1.GET("/invitations/:token", handlers.Invitation.Preview)
+		v1.POST("/invitations/:token/signup", handlers.Auth.InvitationSignup)
+
 		// Protected routes
 		protected := v1.Group("")
-		protected.Use(middleware.JWTAuth(jwtManager))
+		protected.Use(middleware.JWTAuth(jwtManager, tokens, sessions))
 		{
 			// Auth routes (auth required)
-			protected.GET("/auth/me", handlers.Auth.GetCurrentUser)
-			protected.POST("/auth/change-password", handlers.Auth.ChangePassword)
-			protected.PUT("/auth/profile", handlers.Auth.UpdateProfile)
+			account := protected.Group("/auth")
+			account.Use(middleware.RequireScope("account"))
+			{
+				account.GET("/me", handlers.Auth.GetCurrentUser)
+				account.POST("/change-password", handlers.Auth.ChangePassword)
+				account.PUT("/profile", handlers.Auth.UpdateProfile)
+			}
+
+			// Second factors and step-up re-authentication (console login only)
+			mfa := protected.Group("/auth")
+			mfa.Use(middleware.SessionOnly())
+			{
+				mfa.GET("/mfa", handlers.Auth.MFAStatus)
+				mfa.POST("/mfa/totp", handlers.Auth.SetupTOTP)
+				mfa.POST("/mfa/totp/enable", handlers.Auth.EnableTOTP)
+				mfa.DELETE("/mfa/totp", handlers.Auth.DisableTOTP)
+				mfa.POST("/mfa/webauthn/options", handlers.Auth.PasskeyOptions)
+				mfa.POST("/mfa/webauthn", handlers.Auth.RegisterPasskey)
+				mfa.DELETE("/mfa/webauthn/:id", handlers.Auth.DeletePasskey)
+				mfa.POST("/mfa/recovery-codes", handlers.Auth.RegenerateRecoveryCodes)
+				mfa.POST("/step-up/options", handlers.Auth.StepUpOptions)
+				mfa.POST("/step-up", handlers.Auth.StepUp)
+			}
+
+			// Console sessions
+			sessionGroup := protected.Group("/auth")
+			sessionGroup.Use(middleware.SessionOnly())
+			{
+				sessionGroup.POST("/logout", handlers.Auth.Logout)
+				sessionGroup.GET("/sessions", handlers.Auth.ListSessions)
+				sessionGroup.DELETE("/sessions", handlers.Auth.RevokeAllSessions)
+				sessionGroup.DELETE("/sessions/:id", handlers.Auth.RevokeSession)
+			}
+
+			// Accepting an invitation with an existing account (console login only)
+			invitations := protected.Group("/invitations")
+			invitations.Use(middleware.SessionOnly())
+			{
+				invitations.POST("/:token/accept", handlers.Invitation.Accept)
+			}
+
+			// Personal access tokens (console login only)
+			apiTokens := protected.Group("/tokens")
+			apiTokens.Use(middleware.SessionOnly())
+			{
+				apiTokens.GET("", handlers.APIToken.ListTokens)
+				apiTokens.POST("", handlers.APIToken.CreateToken)
+				apiTokens.DELETE("/:id", handlers.APIToken.RevokeToken)
+			}
 
 			// Workspace endpoints
 			workspaces := protected.Group("/workspaces")
+			workspaces.Use(middleware.RequireScope("workspaces"))
 			{
 				workspaces.GET("", handlers.Workspace.List)
 				workspaces.POST("", handlers.Workspace.Create)
 				workspaces.GET("/:id", handlers.Workspace.Get)
 				workspaces.PUT("/:id", handlers.Workspace.Update)
 				workspaces.DELETE("/:id", handlers.Workspace.Delete)
+				workspaces.POST("/:id/transfer", handlers.Workspace.RequestTransfer)
+				workspaces.DELETE("/:id/transfer", handlers.Workspace.CancelTransfer)
+				workspaces.POST("/:id/transfer/accept", handlers.Workspace.AcceptTransfer)
 				workspaces.GET("/:id/members", handlers.Workspace.ListMembers)
 				workspaces.POST("/:id/members", handlers.Workspace.AddMember)
 				workspaces.PUT("/:id/members/:userId", handlers.Workspace.UpdateMember)
 				workspaces.DELETE("/:id/members/:userId", handlers.Workspace.RemoveMember)
+				workspaces.GET("/:id/invitations", handlers.Invitation.List)
+				workspaces.POST("/:id/invitations", handlers.Invitation.Create)
+				workspaces.DELETE("/:id/invitations/:invitationId", handlers.Invitation.Revoke)
+				workspaces.GET("/:id/roles", handlers.Workspace.ListRoles)
+				workspaces.POST("/:id/roles", handlers.Workspace.CreateRole)
+				workspaces.PUT("/:id/roles/:roleId", handlers.Workspace.UpdateRole)
+				workspaces.DELETE("/:id/roles/:roleId", handlers.Workspace.DeleteRole)
+				workspaces.GET("/:id/audit-logs", handlers.Audit.ListWorkspace)
+				workspaces.GET("/:id/audit-logs/export", handlers.Audit.ExportWorkspace)
+
+				// Service accounts and their tokens (console login only)
+				serviceAccounts := workspaces.Group("/:id/service-accounts")
+				serviceAccounts.Use(middleware.SessionOnly())
+				{
+					serviceAccounts.GET("", handlers.APIToken.ListServiceAccounts)
+					serviceAccounts.POST("", handlers.APIToken.CreateServiceAccount)
+					serviceAccounts.DELETE("/:accountId", handlers.APIToken.DeleteServiceAccount)
+					serviceAccounts.GET("/:accountId/tokens", handlers.APIToken.ListServiceAccountTokens)
+					serviceAccounts.POST("/:accountId/tokens", handlers.APIToken.CreateServiceAccountToken)
+					serviceAccounts.DELETE("/:accountId/tokens/:tokenId", handlers.APIToken.RevokeServiceAccountToken)
+				}
 			}
 
 			// Certificate endpoints
 			certs := protected.Group("/certificates")
+			certs.Use(middleware.RequireScope("certificates"))
 			{
 				certs.POST("", handlers.Certificate.Create)
 				certs.GET("", handlers.Certificate.List)
-				certs.GET("/:id", handlers.Certificate.Get)
-				certs.DELETE("/:id", handlers.Certificate.Delete)
-				certs.POST("/:id/pre-verify", handlers.Certificate.PreVerify)
-				certs.POST("/:id/verify", handlers.Certificate.Verify)
-				certs.GET("/:id/download", handlers.Certificate.Download)
-				certs.PUT("/:id/auto-renew", handlers.Certificate.EnableAutoRenew)
-				certs.POST("/:id/renew", handlers.Certificate.Renew)
-				certs.GET("/:id/renewal-logs", handlers.Certificate.RenewalLogs)
-				certs.GET("/:id/notification-logs", handlers.Notification.ListLogs)
-
-				// Challenge endpoints (nested under certificates)
-				certs.GET("/:id/challenges", handlers.Challenge.List)
-				certs.GET("/:id/challenges/export", handlers.Challenge.Export)
+
+				// Every per-certificate route is authorized against the certificate's workspace
+				for _, route := range certificateRoutes(handlers) {
+					certs.Handle(route.Method, route.Path, middleware.RequireCertificate(authz, route.Action), route.Handler)
+				}
 			}
 
 			// Notification endpoints
 			notifications := protected.Group("/notifications")
+			notifications.Use(middleware.RequireScope("notifications"))
 			{
 				notifications.GET("", handlers.Notification.List)
 				notifications.POST("", handlers.Notification.Create)
@@ -101,9 +208,32 @@ func Setup(handlers *Handlers, jwtManager *auth.JWTManager, staticFS fs.FS) *gin
 				notifications.POST("/:id/test", handlers.Notification.Test)
 			}
 
+			// Cloud credentials for cloud deployment targets
+			cloudCreds := protected.Group("/cloud-credentials")
+			cloudCreds.Use(middleware.RequireScope("cloud_credentials"))
+			{
+				cloudCreds.GET("", handlers.CloudCredential.List)
+				cloudCreds.POST("", handlers.CloudCredential.Create)
+				cloudCreds.DELETE("/:id", handlers.CloudCredential.Delete)
+			}
+
+			// Network discovery endpoints
+			discovery := protected.Group("/discovery")
+			discovery.Use(middleware.RequireScope("discovery"))
+			{
+				discovery.GET("/jobs", handlers.Discovery.ListJobs)
+				discovery.POST("/jobs", handlers.Discovery.CreateJob)
+				discovery.DELETE("/jobs/:id", handlers.Discovery.DeleteJob)
+				discovery.POST("/jobs/:id/run", handlers.Discovery.RunJob)
+				discovery.GET("/certificates", handlers.Discovery.ListCertificates)
+				discovery.POST("/certificates/:id/manage", handlers.Discovery.Manage)
+				discovery.POST("/certificates/:id/track", handlers.Discovery.Track)
+				discovery.POST("/certificates/:id/ignore", handlers.Discovery.Ignore)
+			}
+
 			// Admin routes (admin role required)
 			admin := protected.Group("/admin")
-			admin.Use(middleware.AdminAuth())
+			admin.Use(middleware.AdminAuth(), middleware.RequireScope("admin"))
 			{
 				// User management
 				users := admin.Group("/users")
@@ -114,6 +244,8 @@ func Setup(handlers *Handlers, jwtManager *auth.JWTManager, staticFS fs.FS) *gin
 					users.PUT("/:id", handlers.User.Update)
 					users.DELETE("/:id", handlers.User.Delete)
 					users.POST("/:id/reset-password", handlers.User.ResetPassword)
+					users.DELETE("/:id/mfa", handlers.User.ResetMFA)
+					users.POST("/:id/unlock", handlers.User.Unlock)
 				}
 
 				// Settings management
@@ -124,7 +256,17 @@ func Setup(handlers *Handlers, jwtManager *auth.JWTManager, staticFS fs.FS) *gin
 					settings.GET("/acme", handlers.Setting.GetACME)
 					settings.PUT("/acme", handlers.Setting.UpdateACME)
 					settings.PUT("/site", handlers.Setting.UpdateSite)
+					settings.GET("/security", handlers.Setting.GetSecurity)
+					settings.PUT("/security", handlers.Setting.UpdateSecurity)
 				}
+
+				// Encryption key rotation
+				admin.GET("/encryption", handlers.Encryption.Status)
+				admin.POST("/encryption/rotate", handlers.Encryption.Rotate)
+
+				// Audit log
+				admin.GET("/audit-logs", handlers.Audit.List)
+				admin.GET("/audit-logs/export", handlers.Audit.Export)
 			}
 		}
 	}
@@ -134,7 +276,59 @@ func Setup(handlers *Handlers, jwtManager *auth.JWTManager, staticFS fs.FS) *gin
 		r.Use(staticFileHandler(staticFS))
 	}
 
-	return r
+	return r, nil
+}
+
+// certificateRoute is a /certificates/:id route and the access it requires
+type certificateRoute struct {
+	Method  string
+	Path    string
+	Action  service.CertificateAction
+	Handler gin.HandlerFunc
+}
+
+// certificateRoutes lists every route below /certificates/:id. Handlers may
+// apply stricter checks on top, e.g. the workspace key export policy.
+func certificateRoutes(h *Handlers) []certificateRoute {
+	view, manage, remove := service.CertificateActionView, service.CertificateActionManage, service.CertificateActionDelete
+	return []certificateRoute{
+		{http.MethodGet, "/:id", view, h.Certificate.Get},
+		{http.MethodDelete, "/:id", remove, h.Certificate.Delete},
+		{http.MethodPost, "/:id/pre-verify", manage, h.Certificate.PreVerify},
+		{http.MethodPost, "/:id/verify", manage, h.Certificate.Verify},
+		{http.MethodGet, "/:id/download", view, h.Certificate.Download},
+		{http.MethodGet, "/:id/download-links", manage, h.DownloadLink.ListLinks},
+		{http.MethodPost, "/:id/download-links", view, h.DownloadLink.CreateLink},
+		{http.MethodGet, "/:id/key-exports", manage, h.Certificate.KeyExports},
+		{http.MethodPut, "/:id/auto-renew", manage, h.Certificate.EnableAutoRenew},
+		{http.MethodPost, "/:id/renew", manage, h.Certificate.Renew},
+		{http.MethodGet, "/:id/renewal-logs", view, h.Certificate.RenewalLogs},
+		{http.MethodGet, "/:id/notification-logs", view, h.Notification.ListLogs},
+
+		// Moving takes the certificate away from its workspace; shares are read-only grants
+		{http.MethodPost, "/:id/move", remove, h.Certificate.Move},
+		{http.MethodGet, "/:id/shares", view, h.Certificate.ListShares},
+		{http.MethodPost, "/:id/shares", manage, h.Certificate.Share},
+		{http.MethodDelete, "/:id/shares/:workspaceId", manage, h.Certificate.Unshare},
+
+		// Deploy tokens for pull-based agents
+		{http.MethodGet, "/:id/deploy-tokens", manage, h.Deploy.ListTokens},
+		{http.MethodPost, "/:id/deploy-tokens", manage, h.Deploy.CreateToken},
+		{http.MethodDelete, "/:id/deploy-tokens/:tokenId", manage, h.Deploy.RevokeToken},
+
+		// Push deployment targets
+		{http.MethodGet, "/:id/deployment-targets", manage, h.Deployment.ListTargets},
+		{http.MethodPost, "/:id/deployment-targets", manage, h.Deployment.CreateTarget},
+		{http.MethodPut, "/:id/deployment-targets/:targetId", manage, h.Deployment.UpdateTarget},
+		{http.MethodDelete, "/:id/deployment-targets/:targetId", manage, h.Deployment.DeleteTarget},
+		{http.MethodPost, "/:id/redeploy", manage, h.Deployment.Redeploy},
+		{http.MethodGet, "/:id/deployment-logs", manage, h.Deployment.ListLogs},
+		{http.MethodGet, "/:id/deployment-status", manage, h.Deployment.ListStatus},
+
+		// Challenge endpoints (nested under certificates)
+		{http.MethodGet, "/:id/challenges", view, h.Challenge.List},
+		{http.MethodGet, "/:id/challenges/export", view, h.Challenge.Export},
+	}
 }
 
 // staticFileHandler serves static files and falls back to index.html for SPA routing
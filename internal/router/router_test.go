@@ -0,0 +1,288 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imkerbos/ACME-Console/internal/auth"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/middleware"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"github.com/imkerbos/ACME-Console/internal/service"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// fakeAuthz applies the real policy to fixed certificates and workspace roles
+type fakeAuthz struct {
+	certs map[uint]*model.Certificate
+	roles map[uint]string // user ID -> built-in role in workspace 10
+}
+
+func (f *fakeAuthz) AuthorizeCertificate(certID, userID uint, action service.CertificateAction) (*model.Certificate, error) {
+	cert, ok := f.certs[certID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var perms service.Permissions
+	if role, ok := f.roles[userID]; ok {
+		perms = service.NewPermissions(model.BuiltinRolePermissions[role])
+	}
+	if !service.CertificatePolicy(cert, userID, perms, action) {
+		return nil, service.ErrWorkspaceAccessDenied
+	}
+	return cert, nil
+}
+
+// fakeTokens accepts a single API token with certificates:read
+type fakeTokens struct{}
+
+func (fakeTokens) Authenticate(raw, _ string) (*service.TokenIdentity, error) {
+	if raw != "acp_good" {
+		return nil, service.ErrAPITokenInvalid
+	}
+	return &service.TokenIdentity{UserID: 1, Username: "admin", Role: model.RoleAdmin, TokenID: 7, Scopes: []string{model.ScopeCertificatesRead}}, nil
+}
+
+// fakeSessions treats session 1 of user 1 as the only active session;
+// session 3 of user 1 belongs to a login that must change its password
+type fakeSessions struct{}
+
+func (fakeSessions) Validate(sessionID, userID uint, _ string) error {
+	if sessionID == 3 && userID == 1 {
+		return service.ErrPasswordChangeRequired
+	}
+	if sessionID != 1 || userID != 1 {
+		return service.ErrSessionInvalid
+	}
+	return nil
+}
+
+// fakeAudit keeps the recorded entries
+type fakeAudit struct {
+	entries []*model.AuditLog
+}
+
+func (f *fakeAudit) Record(entry *model.AuditLog) {
+	f.entries = append(f.entries, entry)
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	logger.Log = zap.NewNop()
+	logger.S = logger.Log.Sugar()
+}
+
+func TestCertificateRoutesCoverEveryIDRoute(t *testing.T) {
+	r, _ := Setup(&Handlers{}, auth.NewJWTManager("secret", time.Hour), nil, fakeSessions{}, &fakeAuthz{}, nil, nil, nil)
+
+	covered := map[string]bool{}
+	for _, route := range certificateRoutes(&Handlers{}) {
+		covered[route.Method+" /api/v1/certificates"+route.Path] = true
+	}
+	for _, route := range r.Routes() {
+		if strings.HasPrefix(route.Path, "/api/v1/certificates/:id") && !covered[route.Method+" "+route.Path] {
+			t.Errorf("%s %s is not in certificateRoutes", route.Method, route.Path)
+		}
+	}
+}
+
+func TestCertificateRouteAuthorization(t *testing.T) {
+	const (
+		owner = iota + 1
+		admin
+		operator
+		member
+		viewer
+		outsider
+		personalOwner
+	)
+	workspaceID, creator := uint(10), uint(personalOwner)
+	authz := &fakeAuthz{
+		certs: map[uint]*model.Certificate{
+			1: {WorkspaceID: &workspaceID, CreatedBy: &creator},
+			2: {CreatedBy: &creator},
+		},
+		roles: map[uint]string{
+			owner:    model.WorkspaceRoleOwner,
+			admin:    model.WorkspaceRoleAdmin,
+			operator: model.WorkspaceRoleOperator,
+			member:   model.WorkspaceRoleMember,
+			viewer:   model.WorkspaceRoleViewer,
+		},
+	}
+
+	const ok, denied = http.StatusNoContent, http.StatusForbidden
+	tests := []struct {
+		name   string
+		certID string
+		userID uint
+		view   int
+		manage int
+		delete int
+	}{
+		{"workspace owner", "1", owner, ok, ok, ok},
+		{"workspace admin", "1", admin, ok, ok, ok},
+		{"workspace operator", "1", operator, ok, ok, denied},
+		{"workspace member", "1", member, ok, denied, denied},
+		{"workspace viewer", "1", viewer, ok, denied, denied},
+		{"non-member", "1", outsider, denied, denied, denied},
+		{"personal owner", "2", personalOwner, ok, ok, ok},
+		{"workspace owner on personal", "2", owner, denied, denied, denied},
+		{"missing certificate", "3", owner, http.StatusNotFound, http.StatusNotFound, http.StatusNotFound},
+		{"invalid id", "abc", owner, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest},
+	}
+
+	for _, route := range certificateRoutes(&Handlers{}) {
+		for _, tt := range tests {
+			t.Run(route.Method+" "+route.Path+"/"+tt.name, func(t *testing.T) {
+				r := gin.New()
+				r.Use(func(c *gin.Context) { c.Set("userID", tt.userID) })
+				r.Handle(route.Method, "/certificates"+route.Path,
+					middleware.RequireCertificate(authz, route.Action),
+					func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+				path := strings.NewReplacer(":id", tt.certID, ":tokenId", "5", ":targetId", "6").Replace(route.Path)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(route.Method, "/certificates"+path, nil))
+
+				want := map[service.CertificateAction]int{
+					service.CertificateActionView:   tt.view,
+					service.CertificateActionManage: tt.manage,
+					service.CertificateActionDelete: tt.delete,
+				}[route.Action]
+				if w.Code != want {
+					t.Errorf("status = %d, want %d", w.Code, want)
+				}
+			})
+		}
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	r, _ := Setup(&Handlers{}, auth.NewJWTManager("secret", time.Hour), fakeTokens{}, fakeSessions{}, &fakeAuthz{}, nil, nil, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/workspaces", "acp_good", http.StatusForbidden},    // No workspaces scope
+		{http.MethodPost, "/api/v1/certificates", "acp_good", http.StatusForbidden}, // Read-only scope
+		{http.MethodGet, "/api/v1/tokens", "acp_good", http.StatusForbidden},        // Tokens cannot manage tokens
+		{http.MethodGet, "/api/v1/admin/users", "acp_good", http.StatusForbidden},   // Admin user, but no admin scope
+		{http.MethodGet, "/api/v1/certificates/9", "acp_good", http.StatusNotFound}, // Scope passes, authz runs
+		{http.MethodGet, "/api/v1/certificates", "acp_revoked", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s with %s = %d, want %d", tt.method, tt.path, tt.token, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRevokedSessionRejected(t *testing.T) {
+	jwtManager := auth.NewJWTManager("secret", time.Hour)
+	r, _ := Setup(&Handlers{}, jwtManager, fakeTokens{}, fakeSessions{}, &fakeAuthz{}, nil, nil, nil)
+
+	tests := []struct {
+		name      string
+		sessionID uint
+		want      int
+	}{
+		{"active session", 1, http.StatusNotFound}, // Authenticated, certificate 9 does not exist
+		{"revoked session", 2, http.StatusUnauthorized},
+		{"token without session", 0, http.StatusUnauthorized},
+		{"password change required", 3, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		token, err := jwtManager.Generate(1, "admin", model.RoleAdmin, tt.sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/certificates/9", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestAuditActionsCoverStateChangingRoutes(t *testing.T) {
+	r, _ := Setup(&Handlers{}, auth.NewJWTManager("secret", time.Hour), nil, fakeSessions{}, &fakeAuthz{}, nil, nil, nil)
+
+	routes := map[string]bool{}
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Path
+		routes[key] = true
+		if route.Method == http.MethodGet || !strings.HasPrefix(route.Path, "/api/v1/") {
+			continue
+		}
+		if auditActions[key] == "" && !auditExempt[key] {
+			t.Errorf("%s is neither audited nor exempt", key)
+		}
+	}
+	for key := range auditActions {
+		if !routes[key] {
+			t.Errorf("audited route %s does not exist", key)
+		}
+	}
+	for key := range auditExempt {
+		if !routes[key] {
+			t.Errorf("exempt route %s does not exist", key)
+		}
+	}
+	for key := range bodyLogPolicies {
+		if !routes[key] {
+			t.Errorf("body log policy route %s does not exist", key)
+		}
+	}
+}
+
+func TestAuditRecordsFailures(t *testing.T) {
+	jwtManager := auth.NewJWTManager("secret", time.Hour)
+	recorder := &fakeAudit{}
+	r, _ := Setup(&Handlers{}, jwtManager, fakeTokens{}, fakeSessions{}, &fakeAuthz{}, recorder, nil, nil)
+
+	session, err := jwtManager.Generate(1, "admin", model.RoleAdmin, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []struct {
+		method, path, token string
+	}{
+		{http.MethodPost, "/api/v1/certificates", "acp_good"},        // Read-only scope
+		{http.MethodDelete, "/api/v1/certificates/9", session},       // Missing certificate
+		{http.MethodDelete, "/api/v1/certificates/9", "acp_revoked"}, // Unauthenticated, not recorded
+		{http.MethodGet, "/api/v1/certificates/9", session},          // Not audited
+	}
+	for _, req := range requests {
+		httpReq := httptest.NewRequest(req.method, req.path, nil)
+		httpReq.Header.Set("Authorization", "Bearer "+req.token)
+		r.ServeHTTP(httptest.NewRecorder(), httpReq)
+	}
+
+	if len(recorder.entries) != 2 {
+		t.Fatalf("recorded %d entries, want 2", len(recorder.entries))
+	}
+	token, user := recorder.entries[0], recorder.entries[1]
+	if token.ActorType != model.AuditActorToken || token.TokenID != 7 || token.Action != "certificate.create" ||
+		token.Outcome != model.AuditOutcomeFailure || token.Detail != "Forbidden" {
+		t.Errorf("token entry = %+v", token)
+	}
+	if user.ActorType != model.AuditActorUser || user.ActorName != "admin" || user.Action != "certificate.delete" ||
+		user.ResourceType != "certificate" || user.ResourceID != "9" || user.Outcome != model.AuditOutcomeFailure {
+		t.Errorf("user entry = %+v", user)
+	}
+}
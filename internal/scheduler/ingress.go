@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/service"
+)
+
+// IngressWatcher periodically resyncs annotated Kubernetes Ingresses and Gateways
+type IngressWatcher struct {
+	ingressSvc *service.IngressService
+	stopChan   chan struct{}
+	interval   time.Duration
+}
+
+// NewIngressWatcher creates a new watcher; interval defaults to one minute
+func NewIngressWatcher(ingressSvc *service.IngressService, interval time.Duration) *IngressWatcher {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &IngressWatcher{
+		ingressSvc: ingressSvc,
+		stopChan:   make(chan struct{}),
+		interval:   interval,
+	}
+}
+
+// Start starts the watcher
+func (w *IngressWatcher) Start() {
+	logger.Info("Starting ingress watcher",
+		logger.String("interval", w.interval.String()),
+	)
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			w.sync()
+			select {
+			case <-ticker.C:
+			case <-w.stopChan:
+				logger.Info("Ingress watcher stopped")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the watcher
+func (w *IngressWatcher) Stop() {
+	close(w.stopChan)
+}
+
+func (w *IngressWatcher) sync() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.interval)
+	defer cancel()
+
+	if err := w.ingressSvc.Sync(ctx); err != nil {
+		logger.Error("Failed to sync ingresses", logger.Err(err))
+	}
+}
@@ -11,15 +11,19 @@ import (
 type Scheduler struct {
 	notificationSvc *service.NotificationService
 	renewalSvc      *service.RenewalService
+	discoverySvc    *service.DiscoveryService
+	auditSvc        *service.AuditService
 	stopChan        chan struct{}
 	interval        time.Duration
 }
 
 // NewScheduler creates a new scheduler
-func NewScheduler(notificationSvc *service.NotificationService, renewalSvc *service.RenewalService) *Scheduler {
+func NewScheduler(notificationSvc *service.NotificationService, renewalSvc *service.RenewalService, discoverySvc *service.DiscoveryService, auditSvc *service.AuditService) *Scheduler {
 	return &Scheduler{
 		notificationSvc: notificationSvc,
 		renewalSvc:      renewalSvc,
+		discoverySvc:    discoverySvc,
+		auditSvc:        auditSvc,
 		stopChan:        make(chan struct{}),
 		interval:        6 * time.Hour, // Check every 6 hours
 	}
@@ -34,6 +38,8 @@ func (s *Scheduler) Start() {
 	// Run immediately on start
 	go s.runNotificationCheck()
 	go s.runRenewalCheck()
+	go s.runDiscoveryJobs()
+	go s.runAuditRetention()
 
 	// Then run periodically
 	ticker := time.NewTicker(s.interval)
@@ -43,6 +49,8 @@ func (s *Scheduler) Start() {
 			case <-ticker.C:
 				s.runNotificationCheck()
 				s.runRenewalCheck()
+				s.runDiscoveryJobs()
+				s.runAuditRetention()
 			case <-s.stopChan:
 				ticker.Stop()
 				logger.Info("Notification scheduler stopped")
@@ -83,3 +91,35 @@ func (s *Scheduler) runRenewalCheck() {
 		logger.Info("Certificate renewal check completed")
 	}
 }
+
+func (s *Scheduler) runDiscoveryJobs() {
+	if s.discoverySvc == nil {
+		return
+	}
+	logger.Info("Starting scheduled discovery jobs")
+
+	if err := s.discoverySvc.RunDueJobs(); err != nil {
+		logger.Error("Failed to run discovery jobs",
+			logger.Err(err),
+		)
+	} else {
+		logger.Info("Scheduled discovery jobs started")
+	}
+}
+
+func (s *Scheduler) runAuditRetention() {
+	if s.auditSvc == nil {
+		return
+	}
+
+	purged, err := s.auditSvc.Purge()
+	if err != nil {
+		logger.Error("Failed to purge expired audit logs",
+			logger.Err(err),
+		)
+	} else if purged > 0 {
+		logger.Info("Expired audit logs purged",
+			logger.Int("count", int(purged)),
+		)
+	}
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPITokenInvalid           = errors.New("invalid or expired API token")
+	ErrAPITokenNotFound          = errors.New("API token not found")
+	ErrInvalidScope              = errors.New("invalid scope")
+	ErrServiceAccountNotFound    = errors.New("service account not found")
+	ErrServiceAccountExists      = errors.New("a service account with this name already exists")
+	ErrInvalidServiceAccountName = errors.New("service account names use lowercase letters, digits and '-' (max 30)")
+)
+
+const (
+	defaultAPITokenDays = 90
+	// Last-used tracking is throttled so busy CI jobs do not write on every request
+	apiTokenTouchInterval = time.Minute
+)
+
+var serviceAccountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,29}$`)
+
+// APITokenService manages personal access tokens and workspace service accounts
+type APITokenService struct {
+	db           *gorm.DB
+	workspaceSvc *WorkspaceService
+}
+
+// NewAPITokenService creates a new APITokenService
+func NewAPITokenService(db *gorm.DB, workspaceSvc *WorkspaceService) *APITokenService {
+	return &APITokenService{
+		db:           db,
+		workspaceSvc: workspaceSvc,
+	}
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // Default 90
+}
+
+// CreateAPITokenResponse carries the raw token, which is only shown once
+type CreateAPITokenResponse struct {
+	Token    string          `json:"token"`
+	APIToken *model.APIToken `json:"api_token"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Description  string `json:"description" binding:"max=100"`
+	Role         string `json:"role" binding:"required,oneof=admin operator member viewer custom"`
+	CustomRoleID *uint  `json:"custom_role_id"`
+}
+
+type ServiceAccountResponse struct {
+	ID           uint   `json:"id"`
+	Username     string `json:"username"`
+	Description  string `json:"description"`
+	Role         string `json:"role"`
+	CustomRoleID *uint  `json:"custom_role_id,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// TokenIdentity is who an authenticated API token acts as
+type TokenIdentity struct {
+	TokenID  uint
+	UserID   uint
+	Username string
+	Role     string
+	Scopes   []string
+}
+
+// ScopeAllows reports whether token scopes permit a request to a route group:
+// <resource>:read covers GET and HEAD, <resource>:write covers every method.
+func ScopeAllows(scopes []string, resource, method string) bool {
+	readOnly := method == http.MethodGet || method == http.MethodHead
+	for _, scope := range scopes {
+		if scope == resource+":write" || (readOnly && scope == resource+":read") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateScopes checks requested scopes against the catalog; admin scopes
+// need a system administrator
+func validateScopes(scopes []string, allowAdmin bool) ([]string, error) {
+	known := NewPermissions(model.APITokenScopes)
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !known.Has(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if !allowAdmin && strings.HasPrefix(scope, "admin:") {
+			return nil, fmt.Errorf("%w: %s requires a system administrator", ErrInvalidScope, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result, nil
+}
+
+func serviceAccountUsername(workspaceID uint, name string) string {
+	return fmt.Sprintf("sa-%d-%s", workspaceID, name)
+}
+
+// CreatePersonalToken issues a token that acts as the calling user
+func (s *APITokenService) CreatePersonalToken(userID uint, req *CreateAPITokenRequest) (*CreateAPITokenResponse, error) {
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return s.createToken(&user, userID, req)
+}
+
+// ListPersonalTokens returns the calling user's tokens
+func (s *APITokenService) ListPersonalTokens(userID uint) ([]model.APIToken, error) {
+	var tokens []model.APIToken
+	if err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokePersonalToken revokes one of the calling user's tokens
+func (s *APITokenService) RevokePersonalToken(userID, tokenID uint) error {
+	return s.revoke(userID, tokenID)
+}
+
+// CreateServiceAccount adds a service account to a workspace. It is a member
+// like any other, so member.manage and the no-escalation rule apply.
+func (s *APITokenService) CreateServiceAccount(workspaceID, actorID uint, req *CreateServiceAccountRequest) (*ServiceAccountResponse, error) {
+	actor, err := s.workspaceSvc.memberManager(workspaceID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	customRoleID, err := s.workspaceSvc.assignableRole(workspaceID, actor, req.Role, req.CustomRoleID)
+	if err != nil {
+		return nil, err
+	}
+	if !serviceAccountNamePattern.MatchString(req.Name) {
+		return nil, ErrInvalidServiceAccountName
+	}
+
+	username := serviceAccountUsername(workspaceID, req.Name)
+	var taken int64
+	s.db.Model(&model.User{}).Where("username = ?", username).Count(&taken)
+	if taken > 0 {
+		return nil, ErrServiceAccountExists
+	}
+
+	// Service accounts never log in; the random password only fills the column
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	user := &model.User{
+		Username:       username,
+		Nickname:       req.Description,
+		Role:           model.RoleUser,
+		Status:         1,
+		ServiceAccount: true,
+		WorkspaceID:    &workspaceID,
+	}
+	if err := user.SetPassword(base64.RawURLEncoding.EncodeToString(secret)); err != nil {
+		return nil, err
+	}
+
+	member := &model.WorkspaceMember{
+		WorkspaceID:  workspaceID,
+		Role:         req.Role,
+		CustomRoleID: customRoleID,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create service account: %w", err)
+		}
+		member.UserID = user.ID
+		return tx.Create(member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Service account created",
+		logger.Uint("workspace_id", workspaceID),
+		logger.String("username", username),
+		logger.Uint("created_by", actorID),
+	)
+	return toServiceAccountResponse(user, member), nil
+}
+
+// ListServiceAccounts returns a workspace's service accounts (member.manage)
+func (s *APITokenService) ListServiceAccounts(workspaceID, actorID uint) ([]ServiceAccountResponse, error) {
+	if _, err := s.workspaceSvc.memberManager(workspaceID, actorID); err != nil {
+		return nil, err
+	}
+
+	var users []model.User
+	if err := s.db.Where("service_account = ? AND workspace_id = ?", true, workspaceID).Order("username ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]ServiceAccountResponse, 0, len(users))
+	for i := range users {
+		var member model.WorkspaceMember
+		if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, users[i].ID).First(&member).Error; err != nil {
+			continue
+		}
+		result = append(result, *toServiceAccountResponse(&users[i], &member))
+	}
+	return result, nil
+}
+
+// DeleteServiceAccount removes a service account along with its membership and tokens
+func (s *APITokenService) DeleteServiceAccount(workspaceID, accountID, actorID uint) error {
+	user, err := s.managedServiceAccount(workspaceID, accountID, actorID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, user.ID).Delete(&model.WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+// CreateServiceAccountToken issues a token for a service account
+func (s *APITokenService) CreateServiceAccountToken(workspaceID, accountID, actorID uint, req *CreateAPITokenRequest) (*CreateAPITokenResponse, error) {
+	user, err := s.managedServiceAccount(workspaceID, accountID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.createToken(user, actorID, req)
+}
+
+// ListServiceAccountTokens returns a service account's tokens
+func (s *APITokenService) ListServiceAccountTokens(workspaceID, accountID, actorID uint) ([]model.APIToken, error) {
+	user, err := s.managedServiceAccount(workspaceID, accountID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListPersonalTokens(user.ID)
+}
+
+// RevokeServiceAccountToken revokes one of a service account's tokens
+func (s *APITokenService) RevokeServiceAccountToken(workspaceID, accountID, tokenID, actorID uint) error {
+	user, err := s.managedServiceAccount(workspaceID, accountID, actorID)
+	if err != nil {
+		return err
+	}
+	return s.revoke(user.ID, tokenID)
+}
+
+// Authenticate resolves a raw API token. Revoked, expired and unknown tokens,
+// and tokens of disabled users, are all ErrAPITokenInvalid.
+func (s *APITokenService) Authenticate(raw, clientIP string) (*TokenIdentity, error) {
+	if !strings.HasPrefix(raw, model.APITokenPrefix) {
+		return nil, ErrAPITokenInvalid
+	}
+
+	var token model.APIToken
+	if err := s.db.Where("token_hash = ?", hashDeployToken(raw)).First(&token).Error; err != nil {
+		return nil, ErrAPITokenInvalid
+	}
+	now := time.Now()
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && now.After(*token.ExpiresAt)) {
+		return nil, ErrAPITokenInvalid
+	}
+
+	var user model.User
+	if err := s.db.First(&user, token.UserID).Error; err != nil || user.Status != 1 {
+		return nil, ErrAPITokenInvalid
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > apiTokenTouchInterval {
+		s.db.Model(&model.APIToken{}).Where("id = ?", token.ID).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": clientIP,
+		})
+	}
+
+	var scopes []string
+	_ = json.Unmarshal([]byte(token.Scopes), &scopes)
+	return &TokenIdentity{
+		TokenID:  token.ID,
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		Scopes:   scopes,
+	}, nil
+}
+
+func (s *APITokenService) createToken(user *model.User, createdBy uint, req *CreateAPITokenRequest) (*CreateAPITokenResponse, error) {
+	scopes, err := validateScopes(req.Scopes, user.IsAdmin() && !user.ServiceAccount)
+	if err != nil {
+		return nil, err
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPITokenDays
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	raw := model.APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	encoded, _ := json.Marshal(scopes)
+	expiresAt := time.Now().AddDate(0, 0, days)
+	token := &model.APIToken{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Scopes:    string(encoded),
+		TokenHash: hashDeployToken(raw),
+		TokenHint: raw[:len(model.APITokenPrefix)+6],
+		ExpiresAt: &expiresAt,
+		CreatedBy: createdBy,
+	}
+	if err := s.db.Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	logger.Info("API token created",
+		logger.Uint("token_id", token.ID),
+		logger.Uint("user_id", user.ID),
+		logger.Uint("created_by", createdBy),
+	)
+	return &CreateAPITokenResponse{Token: raw, APIToken: token}, nil
+}
+
+func (s *APITokenService) revoke(userID, tokenID uint) error {
+	result := s.db.Model(&model.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// managedServiceAccount loads a workspace's service account for a caller with
+// member.manage who holds at least the account's permissions
+func (s *APITokenService) managedServiceAccount(workspaceID, accountID, actorID uint) (*model.User, error) {
+	actor, err := s.workspaceSvc.memberManager(workspaceID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var user model.User
+	if err := s.db.Where("id = ? AND service_account = ? AND workspace_id = ?", accountID, true, workspaceID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServiceAccountNotFound
+		}
+		return nil, err
+	}
+	perms, err := s.workspaceSvc.GetUserPermissions(workspaceID, user.ID)
+	if err != nil && !errors.Is(err, ErrWorkspaceAccessDenied) {
+		return nil, err
+	}
+	if !actor.Covers(perms) {
+		return nil, ErrPermissionEscalation
+	}
+	return &user, nil
+}
+
+func toServiceAccountResponse(user *model.User, member *model.WorkspaceMember) *ServiceAccountResponse {
+	return &ServiceAccountResponse{
+		ID:           user.ID,
+		Username:     user.Username,
+		Description:  user.Nickname,
+		Role:         member.Role,
+		CustomRoleID: member.CustomRoleID,
+		CreatedAt:    user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		resource string
+		method   string
+		want     bool
+	}{
+		{[]string{model.ScopeCertificatesRead}, "certificates", http.MethodGet, true},
+		{[]string{model.ScopeCertificatesRead}, "certificates", http.MethodPost, false},
+		{[]string{model.ScopeCertificatesWrite}, "certificates", http.MethodGet, true}, // write implies read
+		{[]string{model.ScopeCertificatesWrite}, "certificates", http.MethodDelete, true},
+		{[]string{model.ScopeCertificatesWrite}, "workspaces", http.MethodGet, false},
+		{nil, "account", http.MethodGet, false},
+	}
+	for _, tt := range tests {
+		if got := ScopeAllows(tt.scopes, tt.resource, tt.method); got != tt.want {
+			t.Errorf("ScopeAllows(%v, %s, %s) = %v, want %v", tt.scopes, tt.resource, tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	scopes, err := validateScopes([]string{model.ScopeCertificatesRead, model.ScopeCertificatesRead, model.ScopeDiscoveryWrite}, false)
+	if err != nil || len(scopes) != 2 {
+		t.Errorf("validateScopes = %v, %v; want duplicates removed", scopes, err)
+	}
+	if _, err := validateScopes([]string{"certificates:delete"}, true); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("err = %v, want ErrInvalidScope", err)
+	}
+	if _, err := validateScopes([]string{model.ScopeAdminRead}, false); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("non-admins must not get admin scopes, err = %v", err)
+	}
+	if _, err := validateScopes([]string{model.ScopeAdminWrite}, true); err != nil {
+		t.Errorf("admins may request admin scopes: %v", err)
+	}
+}
+
+func TestServiceAccountName(t *testing.T) {
+	for _, name := range []string{"ci", "github-actions", "deploy-2"} {
+		if !serviceAccountNamePattern.MatchString(name) {
+			t.Errorf("%q should be a valid service account name", name)
+		}
+	}
+	for _, name := range []string{"", "-ci", "CI", "ci bot", "a-very-long-service-account-name-x"} {
+		if serviceAccountNamePattern.MatchString(name) {
+			t.Errorf("%q should be rejected", name)
+		}
+	}
+	if got := serviceAccountUsername(7, "ci"); got != "sa-7-ci" {
+		t.Errorf("username = %q", got)
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+// Rows read per query when exporting
+const auditExportBatch = 500
+
+// AuditForwarder streams recorded events elsewhere; implemented by
+// auditsink.Dispatcher. Forward must not block.
+type AuditForwarder interface {
+	Forward(entry model.AuditLog)
+}
+
+// AuditService writes and queries the audit trail
+type AuditService struct {
+	db         *gorm.DB
+	settingSvc *SettingService
+	forwarder  AuditForwarder
+}
+
+// NewAuditService creates a new AuditService
+func NewAuditService(db *gorm.DB, settingSvc *SettingService) *AuditService {
+	return &AuditService{
+		db:         db,
+		settingSvc: settingSvc,
+	}
+}
+
+// SetForwarder streams every event recorded from now on to f
+func (s *AuditService) SetForwarder(f AuditForwarder) {
+	s.forwarder = f
+}
+
+// AuditChange is the old and new value of a changed field
+type AuditChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditChanges maps field names to their change
+type AuditChanges map[string]AuditChange
+
+// DiffFields returns the fields whose values differ between two snapshots.
+// A nil snapshot stands for a resource that did not exist (before) or no
+// longer exists (after).
+func DiffFields(before, after map[string]interface{}) AuditChanges {
+	changes := make(AuditChanges)
+	for key, old := range before {
+		if val, ok := after[key]; !ok || !reflect.DeepEqual(old, val) {
+			changes[key] = AuditChange{Old: old, New: after[key]}
+		}
+	}
+	for key, val := range after {
+		if _, ok := before[key]; !ok {
+			changes[key] = AuditChange{New: val}
+		}
+	}
+	return changes
+}
+
+// AuditFilter narrows an audit log query. Zero values match everything.
+type AuditFilter struct {
+	Action       string // Exact action, or a prefix ending in "." such as "certificate."
+	ActorName    string
+	ResourceType string
+	ResourceID   string
+	WorkspaceID  uint
+	Outcome      string
+	RequestID    string
+	From         *time.Time
+	To           *time.Time
+}
+
+// Record appends an event. Errors are logged, not returned: the audited
+// action has already happened.
+func (s *AuditService) Record(entry *model.AuditLog) {
+	if entry.Outcome == "" {
+		entry.Outcome = model.AuditOutcomeSuccess
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		logger.Error("Failed to write audit log",
+			logger.String("action", entry.Action),
+			logger.Err(err),
+		)
+	}
+	// Forwarded even when the database write failed, so the event survives
+	if s.forwarder != nil {
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		s.forwarder.Forward(*entry)
+	}
+}
+
+// RecordUser appends an event performed by a user
+func (s *AuditService) RecordUser(actor *model.User, action, ip string, entry model.AuditLog) {
+	entry.ActorType = model.AuditActorUser
+	entry.ActorID = actor.ID
+	entry.ActorName = actor.Username
+	entry.Action = action
+	entry.IP = ip
+	s.Record(&entry)
+}
+
+// RecordScheduler appends an event performed by a background job
+func (s *AuditService) RecordScheduler(entry model.AuditLog) {
+	entry.ActorType = model.AuditActorScheduler
+	entry.ActorName = model.AuditActorScheduler
+	s.Record(&entry)
+}
+
+// List returns a page of matching events, newest first
+func (s *AuditService) List(filter AuditFilter, page, pageSize int) ([]model.AuditLog, int64, error) {
+	query := s.filtered(filter)
+
+	var total int64
+	if err := query.Model(&model.AuditLog{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var logs []model.AuditLog
+	err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error
+	return logs, total, err
+}
+
+// ExportCSV writes every matching event as CSV, newest first
+func (s *AuditService) ExportCSV(w io.Writer, filter AuditFilter) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{
+		"time", "actor_type", "actor_id", "actor", "token_id", "action", "resource_type", "resource_id",
+		"workspace_id", "outcome", "ip", "request_id", "detail", "changes",
+	}); err != nil {
+		return err
+	}
+
+	// Keyset pagination, so rows written meanwhile do not shift the pages
+	var lastID uint
+	for {
+		query := s.filtered(filter)
+		if lastID > 0 {
+			query = query.Where("id < ?", lastID)
+		}
+		var logs []model.AuditLog
+		if err := query.Order("id DESC").Limit(auditExportBatch).Find(&logs).Error; err != nil {
+			return err
+		}
+		for _, l := range logs {
+			workspace := ""
+			if l.WorkspaceID != nil {
+				workspace = strconv.FormatUint(uint64(*l.WorkspaceID), 10)
+			}
+			record := []string{
+				l.CreatedAt.UTC().Format(time.RFC3339), l.ActorType, formatOptionalID(l.ActorID), l.ActorName,
+				formatOptionalID(l.TokenID), l.Action, l.ResourceType, l.ResourceID, workspace, l.Outcome,
+				l.IP, l.RequestID, l.Detail, l.Changes,
+			}
+			for i := range record {
+				record[i] = csvSafe(record[i])
+			}
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+		if len(logs) < auditExportBatch {
+			return nil
+		}
+		lastID = logs[len(logs)-1].ID
+	}
+}
+
+// Purge deletes events older than the retention setting and records how
+// many were removed. A retention of 0 days keeps everything.
+func (s *AuditService) Purge() (int64, error) {
+	days := s.settingSvc.GetSecurityConfig().AuditRetentionDays
+	if days <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	result := s.db.Where("created_at < ?", cutoff).Delete(&model.AuditLog{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected > 0 {
+		s.RecordScheduler(model.AuditLog{
+			Action:       model.AuditActionRetentionPurge,
+			ResourceType: "audit_log",
+			Detail:       fmt.Sprintf("removed %d events older than %d days", result.RowsAffected, days),
+		})
+	}
+	return result.RowsAffected, nil
+}
+
+func (s *AuditService) filtered(f AuditFilter) *gorm.DB {
+	query := s.db.Model(&model.AuditLog{})
+	if f.Action != "" {
+		if f.Action[len(f.Action)-1] == '.' {
+			query = query.Where("action LIKE ?", f.Action+"%")
+		} else {
+			query = query.Where("action = ?", f.Action)
+		}
+	}
+	if f.ActorName != "" {
+		query = query.Where("actor_name = ?", f.ActorName)
+	}
+	if f.ResourceType != "" {
+		query = query.Where("resource_type = ?", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		query = query.Where("resource_id = ?", f.ResourceID)
+	}
+	if f.WorkspaceID != 0 {
+		query = query.Where("workspace_id = ?", f.WorkspaceID)
+	}
+	if f.Outcome != "" {
+		query = query.Where("outcome = ?", f.Outcome)
+	}
+	if f.RequestID != "" {
+		query = query.Where("request_id = ?", f.RequestID)
+	}
+	if f.From != nil {
+		query = query.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		query = query.Where("created_at < ?", *f.To)
+	}
+	return query
+}
+
+// csvSafe keeps spreadsheets from evaluating user-controlled values, such as
+// usernames or login failure details, as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func formatOptionalID(id uint) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]interface{}
+		after  map[string]interface{}
+		want   AuditChanges
+	}{
+		{
+			name:   "changed and unchanged",
+			before: map[string]interface{}{"role": "viewer", "name": "ops"},
+			after:  map[string]interface{}{"role": "admin", "name": "ops"},
+			want:   AuditChanges{"role": {Old: "viewer", New: "admin"}},
+		},
+		{
+			name:   "added and removed",
+			before: map[string]interface{}{"custom_role_id": uint(3)},
+			after:  map[string]interface{}{"role": "member"},
+			want: AuditChanges{
+				"custom_role_id": {Old: uint(3)},
+				"role":           {New: "member"},
+			},
+		},
+		{
+			name:  "created",
+			after: map[string]interface{}{"username": "alice"},
+			want:  AuditChanges{"username": {New: "alice"}},
+		},
+		{
+			name:   "deleted",
+			before: map[string]interface{}{"role": "owner"},
+			want:   AuditChanges{"role": {Old: "owner"}},
+		},
+		{
+			name:   "nothing changed",
+			before: map[string]interface{}{"tags": []string{"a"}},
+			after:  map[string]interface{}{"tags": []string{"a"}},
+			want:   AuditChanges{},
+		},
+	}
+	for _, tt := range tests {
+		if got := DiffFields(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DiffFields() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	tests := map[string]string{
+		"alice":             "alice",
+		"":                  "",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-2+3":              "'-2+3",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\tcmd":             "'\tcmd",
+		"\rcmd":             "'\rcmd",
+		"2026-01-02T00:00Z": "2026-01-02T00:00Z",
+		"a=b":               "a=b",
+	}
+	for in, want := range tests {
+		if got := csvSafe(in); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
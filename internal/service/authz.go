@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+// CertificateAction is what a request wants to do with a certificate
+type CertificateAction string
+
+const (
+	CertificateActionView   CertificateAction = "view"   // Read the certificate, its logs and challenges
+	CertificateActionManage CertificateAction = "manage" // Verify, renew or deploy it
+	CertificateActionDelete CertificateAction = "delete"
+)
+
+// certificateActionPermissions maps each action to the workspace permission it needs
+var certificateActionPermissions = map[CertificateAction]string{
+	CertificateActionView:   model.PermissionCertRead,
+	CertificateActionManage: model.PermissionCertIssue,
+	CertificateActionDelete: model.PermissionCertDelete,
+}
+
+// CertificatePolicy decides whether a user may perform an action on a
+// certificate. perms are the user's permissions in the certificate's
+// workspace, nil when they are not a member. Personal certificates belong to
+// their creator alone.
+func CertificatePolicy(cert *model.Certificate, userID uint, perms Permissions, action CertificateAction) bool {
+	permission, ok := certificateActionPermissions[action]
+	if !ok {
+		return false
+	}
+	if cert.WorkspaceID == nil {
+		return cert.CreatedBy != nil && *cert.CreatedBy == userID
+	}
+	return perms.Has(permission)
+}
+
+// SharedCertificatePolicy decides whether a workspace the certificate is
+// shared with grants the action. perms are the user's permissions in that
+// workspace. Shares are read-only.
+func SharedCertificatePolicy(perms Permissions, action CertificateAction) bool {
+	return action == CertificateActionView && perms.Has(model.PermissionCertRead)
+}
+
+// AuthzService resolves a certificate's workspace and applies CertificatePolicy
+type AuthzService struct {
+	db           *gorm.DB
+	workspaceSvc *WorkspaceService
+}
+
+// NewAuthzService creates a new AuthzService
+func NewAuthzService(db *gorm.DB, workspaceSvc *WorkspaceService) *AuthzService {
+	return &AuthzService{
+		db:           db,
+		workspaceSvc: workspaceSvc,
+	}
+}
+
+// AuthorizeCertificate loads a certificate and returns it if the user may
+// perform the action, or ErrWorkspaceAccessDenied.
+func (s *AuthzService) AuthorizeCertificate(certID, userID uint, action CertificateAction) (*model.Certificate, error) {
+	return authorizeCertificate(s.db, s.workspaceSvc, certID, userID, action)
+}
+
+func authorizeCertificate(db *gorm.DB, workspaceSvc *WorkspaceService, certID, userID uint, action CertificateAction) (*model.Certificate, error) {
+	var cert model.Certificate
+	if err := db.First(&cert, certID).Error; err != nil {
+		return nil, fmt.Errorf("certificate not found: %w", err)
+	}
+
+	var perms Permissions
+	if cert.WorkspaceID != nil {
+		var err error
+		perms, err = workspaceSvc.GetUserPermissions(*cert.WorkspaceID, userID)
+		if err != nil && !errors.Is(err, ErrWorkspaceAccessDenied) {
+			return nil, err
+		}
+	}
+	if CertificatePolicy(&cert, userID, perms, action) {
+		return &cert, nil
+	}
+	if action == CertificateActionView {
+		shared, err := sharedWithUser(db, workspaceSvc, cert.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if shared {
+			return &cert, nil
+		}
+	}
+	return nil, ErrWorkspaceAccessDenied
+}
+
+// sharedWithUser reports whether the certificate is shared with a workspace
+// where the user may read certificates
+func sharedWithUser(db *gorm.DB, workspaceSvc *WorkspaceService, certID, userID uint) (bool, error) {
+	var workspaceIDs []uint
+	if err := db.Model(&model.CertificateShare{}).Where("certificate_id = ?", certID).
+		Pluck("workspace_id", &workspaceIDs).Error; err != nil {
+		return false, err
+	}
+	for _, workspaceID := range workspaceIDs {
+		perms, err := workspaceSvc.GetUserPermissions(workspaceID, userID)
+		if errors.Is(err, ErrWorkspaceAccessDenied) || errors.Is(err, ErrWorkspaceNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if SharedCertificatePolicy(perms, CertificateActionView) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
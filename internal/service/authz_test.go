@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestCertificatePolicy(t *testing.T) {
+	workspaceID, creator := uint(10), uint(1)
+	workspaceCert := &model.Certificate{WorkspaceID: &workspaceID, CreatedBy: &creator}
+	personalCert := &model.Certificate{CreatedBy: &creator}
+	role := func(name string) Permissions { return NewPermissions(model.BuiltinRolePermissions[name]) }
+
+	tests := []struct {
+		name   string
+		cert   *model.Certificate
+		userID uint
+		perms  Permissions
+		view   bool
+		manage bool
+		delete bool
+	}{
+		{"workspace owner", workspaceCert, 2, role(model.WorkspaceRoleOwner), true, true, true},
+		{"workspace admin", workspaceCert, 2, role(model.WorkspaceRoleAdmin), true, true, true},
+		{"workspace operator", workspaceCert, 2, role(model.WorkspaceRoleOperator), true, true, false},
+		{"workspace member", workspaceCert, 2, role(model.WorkspaceRoleMember), true, false, false},
+		{"workspace viewer", workspaceCert, 2, role(model.WorkspaceRoleViewer), true, false, false},
+		{"custom delete-only role", workspaceCert, 2, NewPermissions([]string{model.PermissionCertDelete}), false, false, true},
+		{"non-member", workspaceCert, 2, nil, false, false, false},
+		{"creator who left the workspace", workspaceCert, creator, nil, false, false, false},
+		{"personal owner", personalCert, creator, nil, true, true, true},
+		{"other user on personal", personalCert, 2, role(model.WorkspaceRoleOwner), false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for action, want := range map[CertificateAction]bool{
+				CertificateActionView:   tt.view,
+				CertificateActionManage: tt.manage,
+				CertificateActionDelete: tt.delete,
+			} {
+				if got := CertificatePolicy(tt.cert, tt.userID, tt.perms, action); got != want {
+					t.Errorf("%s = %v, want %v", action, got, want)
+				}
+			}
+			if CertificatePolicy(tt.cert, tt.userID, tt.perms, "delete-everything") {
+				t.Error("unknown actions must be denied")
+			}
+		})
+	}
+}
+
+func TestSharedCertificatePolicy(t *testing.T) {
+	role := func(name string) Permissions { return NewPermissions(model.BuiltinRolePermissions[name]) }
+
+	tests := []struct {
+		name  string
+		perms Permissions
+		view  bool
+	}{
+		{"owner of the receiving workspace", role(model.WorkspaceRoleOwner), true},
+		{"viewer of the receiving workspace", role(model.WorkspaceRoleViewer), true},
+		{"archived receiving workspace", role(model.WorkspaceRoleAdmin).ReadOnly(), true},
+		{"custom role without cert.read", NewPermissions([]string{model.PermissionCertIssue}), false},
+		{"non-member", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SharedCertificatePolicy(tt.perms, CertificateActionView); got != tt.view {
+				t.Errorf("view = %v, want %v", got, tt.view)
+			}
+			// Shares never grant more than read access
+			for _, action := range []CertificateAction{CertificateActionManage, CertificateActionDelete} {
+				if SharedCertificatePolicy(tt.perms, action) {
+					t.Errorf("%s must be denied", action)
+				}
+			}
+		})
+	}
+}
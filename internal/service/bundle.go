@@ -0,0 +1,396 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/imkerbos/ACME-Console/internal/acme"
+	"github.com/imkerbos/ACME-Console/internal/keystore"
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+// ErrInvalidBundleOptions reports a password, alias or namespace the format cannot use
+var ErrInvalidBundleOptions = errors.New("invalid download options")
+
+// BundleOptions carries the per-format download parameters
+type BundleOptions struct {
+	Password  string // PFX, JKS and JCEKS password (required); PKCS#8 passphrase (optional)
+	Alias     string // JKS/JCEKS entry alias (default: certificate)
+	Namespace string // Kubernetes Secret namespace (optional)
+}
+
+// keystorePassword returns the PFX, JKS or JCEKS password. There is no default,
+// so a keystore never leaves the console under a well-known password.
+func (o BundleOptions) keystorePassword() (string, error) {
+	if o.Password == "" {
+		return "", fmt.Errorf("%w: a password is required for PFX, JKS, JCEKS and profile downloads", ErrInvalidBundleOptions)
+	}
+	return o.Password, nil
+}
+
+// RequiresPassword reports whether the format is a password-protected keystore
+func RequiresPassword(format string) bool {
+	switch DownloadFormat(format) {
+	case DownloadFormatPFX, DownloadFormatJKS, DownloadFormatJCEKS, DownloadFormatProfile:
+		return true
+	}
+	return false
+}
+
+func (o BundleOptions) alias() string {
+	if o.Alias == "" {
+		return "certificate"
+	}
+	return o.Alias
+}
+
+var dnsLabelRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// parseChainPEM returns every certificate in the full chain, leaf first
+func parseChainPEM(cert *model.Certificate) ([]*x509.Certificate, error) {
+	data := []byte(cert.ChainPEM)
+	if len(data) == 0 {
+		data = []byte(cert.CertPEM)
+	}
+
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		chain = append(chain, c)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	return chain, nil
+}
+
+// createDER returns the leaf certificate in binary DER form
+func createDER(cert *model.Certificate) ([]byte, error) {
+	chain, err := parseChainPEM(cert)
+	if err != nil {
+		return nil, err
+	}
+	return chain[0].Raw, nil
+}
+
+// createPKCS7 builds a certificates-only (degenerate) PKCS#7 SignedData
+// structure holding the full chain, as used by .p7b files.
+func createPKCS7(cert *model.Certificate) ([]byte, error) {
+	chain, err := parseChainPEM(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []byte
+	for _, c := range chain {
+		certs = append(certs, c.Raw...)
+	}
+
+	emptySet := asn1.RawValue{FullBytes: []byte{0x31, 0x00}}
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		ContentInfo:      struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos:      emptySet,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+}
+
+// createPKCS8 re-encodes the private key as PKCS#8 PEM, encrypted with
+// PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC) when a passphrase is given
+func createPKCS8(keyPEM []byte, passphrase string) ([]byte, error) {
+	key, err := acme.DecodePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PKCS#8: %w", err)
+	}
+	if passphrase == "" {
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+	if len(passphrase) < 8 {
+		return nil, fmt.Errorf("%w: passphrase must be at least 8 characters", ErrInvalidBundleOptions)
+	}
+
+	encrypted, err := encryptPKCS8(der, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt PKCS#8: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted}), nil
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const pkcs8Iterations = 600000
+
+func encryptPKCS8(der []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pkcs8Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	plain := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	ciphertext := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plain)
+
+	kdfParams, err := asn1.Marshal(struct {
+		Salt       []byte
+		Iterations int
+		PRF        pkix.AlgorithmIdentifier
+	}{salt, pkcs8Iterations, pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue}})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	pbes2Params, err := asn1.Marshal(struct {
+		KDF    pkix.AlgorithmIdentifier
+		Cipher pkix.AlgorithmIdentifier
+	}{
+		KDF:    pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		Cipher: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: pbes2Params}},
+		EncryptedData: ciphertext,
+	})
+}
+
+// createHAProxyPEM concatenates certificate, chain and key, the single-file layout HAProxy expects
+func createHAProxyPEM(cert *model.Certificate, keyPEM []byte) []byte {
+	chain := cert.ChainPEM
+	if chain == "" {
+		chain = cert.CertPEM
+	}
+	var buf bytes.Buffer
+	buf.WriteString(strings.TrimRight(chain, "\n") + "\n")
+	buf.Write(keyPEM)
+	return buf.Bytes()
+}
+
+// createKeyStore builds a JKS or JCEKS keystore holding the key and full chain
+func createKeyStore(cert *model.Certificate, keyPEM []byte, opts BundleOptions, jceks bool) ([]byte, error) {
+	chain, err := parseChainPEM(cert)
+	if err != nil {
+		return nil, err
+	}
+	key, err := acme.DecodePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %w", err)
+	}
+
+	entry := &keystore.Entry{Alias: opts.alias(), Key: key, Chain: chain}
+	if cert.IssuedAt != nil {
+		entry.Created = *cert.IssuedAt
+	}
+	encode := keystore.EncodeJKS
+	if jceks {
+		encode = keystore.EncodeJCEKS
+	}
+	password, err := opts.keystorePassword()
+	if err != nil {
+		return nil, err
+	}
+	data, err := encode(entry, password)
+	if errors.Is(err, keystore.ErrInvalidAlias) || errors.Is(err, keystore.ErrInvalidPassword) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundleOptions, err)
+	}
+	return data, err
+}
+
+// kubernetesSecretName derives a valid Secret name from the primary domain
+func kubernetesSecretName(cert *model.Certificate) string {
+	var domains []string
+	json.Unmarshal([]byte(cert.Domains), &domains)
+	name := "certificate"
+	if len(domains) > 0 {
+		name = strings.TrimPrefix(strings.ToLower(domains[0]), "*.")
+		name = strings.ReplaceAll(name, ".", "-")
+	}
+	name = strings.Trim(name, "-")
+	if len(name) > 249 {
+		name = name[:249]
+	}
+	return name + "-tls"
+}
+
+// createKubernetesSecret renders a kubernetes.io/tls Secret manifest ready for kubectl apply
+func createKubernetesSecret(cert *model.Certificate, keyPEM []byte, namespace string) ([]byte, error) {
+	if namespace != "" && !dnsLabelRegexp.MatchString(namespace) {
+		return nil, fmt.Errorf("%w: invalid namespace %q", ErrInvalidBundleOptions, namespace)
+	}
+	chain := cert.ChainPEM
+	if chain == "" {
+		chain = cert.CertPEM
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("apiVersion: v1\nkind: Secret\ntype: kubernetes.io/tls\nmetadata:\n")
+	buf.WriteString("  name: " + kubernetesSecretName(cert) + "\n")
+	if namespace != "" {
+		buf.WriteString("  namespace: " + namespace + "\n")
+	}
+	buf.WriteString("  labels:\n    app.kubernetes.io/managed-by: acme-console\n")
+	fmt.Fprintf(&buf, "  annotations:\n    acme-console/certificate-id: \"%d\"\n", cert.ID)
+	if cert.ExpiresAt != nil {
+		buf.WriteString("    acme-console/expires-at: \"" + cert.ExpiresAt.UTC().Format("2006-01-02T15:04:05Z") + "\"\n")
+	}
+	buf.WriteString("data:\n")
+	buf.WriteString("  tls.crt: " + base64.StdEncoding.EncodeToString([]byte(chain)) + "\n")
+	buf.WriteString("  tls.key: " + base64.StdEncoding.EncodeToString(keyPEM) + "\n")
+	return buf.Bytes(), nil
+}
+
+// createProfileZip bundles the files and ready-to-paste configuration
+// snippets for nginx, Apache, HAProxy and IIS
+func createProfileZip(cert *model.Certificate, keyPEM []byte, opts BundleOptions) ([]byte, error) {
+	password, err := opts.keystorePassword()
+	if err != nil {
+		return nil, err
+	}
+	pfx, err := createPFXV2([]byte(cert.CertPEM), keyPEM, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PFX: %w", err)
+	}
+
+	chain := cert.ChainPEM
+	if chain == "" {
+		chain = cert.CertPEM
+	}
+	dir := strings.TrimSuffix(kubernetesSecretName(cert), "-tls")
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"certificate.pem", []byte(cert.CertPEM)},
+		{"chain.pem", []byte(cert.IssuerCertPEM)},
+		{"fullchain.pem", []byte(chain)},
+		{"private.key", keyPEM},
+		{"haproxy.pem", createHAProxyPEM(cert, keyPEM)},
+		{"certificate.pfx", pfx},
+		{"nginx.conf", []byte(fmt.Sprintf(nginxSnippet, dir))},
+		{"apache.conf", []byte(fmt.Sprintf(apacheSnippet, dir))},
+		{"haproxy.cfg", []byte(fmt.Sprintf(haproxySnippet, dir))},
+		{"iis-install.ps1", []byte(iisSnippet)},
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := w.Create(dir + "/" + f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const nginxSnippet = `# Copy this directory to /etc/ssl/%[1]s and include in your server block
+listen 443 ssl;
+http2 on;
+
+ssl_certificate     /etc/ssl/%[1]s/fullchain.pem;
+ssl_certificate_key /etc/ssl/%[1]s/private.key;
+ssl_protocols       TLSv1.2 TLSv1.3;
+ssl_session_cache   shared:SSL:10m;
+`
+
+const apacheSnippet = `# Copy this directory to /etc/ssl/%[1]s and place inside <VirtualHost *:443>
+SSLEngine on
+SSLCertificateFile    /etc/ssl/%[1]s/fullchain.pem
+SSLCertificateKeyFile /etc/ssl/%[1]s/private.key
+SSLProtocol           -all +TLSv1.2 +TLSv1.3
+`
+
+const haproxySnippet = `frontend https
+    bind :443 ssl crt /etc/haproxy/certs/%[1]s.pem alpn h2,http/1.1
+    # haproxy.pem contains certificate, chain and key in one file
+`
+
+const iisSnippet = `# Run in an elevated PowerShell from this directory.
+# Imports certificate.pfx into the machine store and binds it to the Default Web Site.
+$password = Read-Host -AsSecureString "PFX password"
+$cert = Import-PfxCertificate -FilePath .\certificate.pfx -CertStoreLocation Cert:\LocalMachine\My -Password $password
+Import-Module WebAdministration
+New-WebBinding -Name "Default Web Site" -Protocol https -Port 443 -SslFlags 0 -ErrorAction SilentlyContinue
+(Get-WebBinding -Name "Default Web Site" -Protocol https).AddSslCertificate($cert.Thumbprint, "My")
+`
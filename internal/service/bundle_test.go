@@ -0,0 +1,235 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"go.yaml.in/yaml/v3"
+)
+
+// testIssuedCertificate returns a certificate signed by a throwaway CA, with
+// the PEM fields filled like an issued one, and its EC private key PEM
+func testIssuedCertificate(t *testing.T) (*model.Certificate, []byte) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"*.example.com", "example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}))
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	expires := time.Now().Add(time.Hour)
+	return &model.Certificate{
+		ID:            7,
+		Domains:       `["*.example.com","example.com"]`,
+		Status:        model.CertificateStatusReady,
+		CertPEM:       leafPEM,
+		IssuerCertPEM: caPEM,
+		ChainPEM:      leafPEM + caPEM,
+		ExpiresAt:     &expires,
+	}, keyPEM
+}
+
+func TestCreatePKCS7(t *testing.T) {
+	cert, _ := testIssuedCertificate(t)
+	data, err := createPKCS7(cert)
+	if err != nil {
+		t.Fatalf("createPKCS7: %v", err)
+	}
+
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+		t.Fatalf("content info: %v", err)
+	}
+	if !contentInfo.ContentType.Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}) {
+		t.Errorf("content type = %v", contentInfo.ContentType)
+	}
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"tag:0"`
+		SignerInfos      asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		t.Fatalf("signed data: %v", err)
+	}
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		t.Fatalf("certificates: %v", err)
+	}
+	if len(certs) != 2 || certs[0].Subject.CommonName != "example.com" || certs[1].Subject.CommonName != "Test CA" {
+		t.Errorf("unexpected chain: %d certificates", len(certs))
+	}
+}
+
+func TestCreateDERAndPKCS8(t *testing.T) {
+	cert, keyPEM := testIssuedCertificate(t)
+
+	der, err := createDER(cert)
+	if err != nil {
+		t.Fatalf("createDER: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil || leaf.Subject.CommonName != "example.com" {
+		t.Errorf("DER is not the leaf: %v", err)
+	}
+
+	pk8, err := createPKCS8(keyPEM, "")
+	if err != nil {
+		t.Fatalf("createPKCS8: %v", err)
+	}
+	block, _ := pem.Decode(pk8)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("unexpected PEM: %q", pk8)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		t.Errorf("PKCS#8 does not parse: %v", err)
+	}
+
+	enc, err := createPKCS8(keyPEM, "correct horse")
+	if err != nil {
+		t.Fatalf("encrypted createPKCS8: %v", err)
+	}
+	if block, _ := pem.Decode(enc); block == nil || block.Type != "ENCRYPTED PRIVATE KEY" {
+		t.Errorf("unexpected encrypted PEM: %q", enc)
+	}
+	if _, err := createPKCS8(keyPEM, "short"); !errors.Is(err, ErrInvalidBundleOptions) {
+		t.Errorf("short passphrase: err = %v", err)
+	}
+}
+
+func TestCreateHAProxyPEM(t *testing.T) {
+	cert, keyPEM := testIssuedCertificate(t)
+	data := createHAProxyPEM(cert, keyPEM)
+
+	var types []string
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		types = append(types, block.Type)
+	}
+	if strings.Join(types, ",") != "CERTIFICATE,CERTIFICATE,EC PRIVATE KEY" {
+		t.Errorf("blocks = %v", types)
+	}
+}
+
+func TestCreateKubernetesSecret(t *testing.T) {
+	cert, keyPEM := testIssuedCertificate(t)
+	data, err := createKubernetesSecret(cert, keyPEM, "web")
+	if err != nil {
+		t.Fatalf("createKubernetesSecret: %v", err)
+	}
+
+	var manifest struct {
+		Kind     string `yaml:"kind"`
+		Type     string `yaml:"type"`
+		Metadata struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+		Data map[string]string `yaml:"data"`
+	}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.Kind != "Secret" || manifest.Type != "kubernetes.io/tls" {
+		t.Errorf("kind/type = %s/%s", manifest.Kind, manifest.Type)
+	}
+	if manifest.Metadata.Name != "example-com-tls" || manifest.Metadata.Namespace != "web" {
+		t.Errorf("metadata = %+v", manifest.Metadata)
+	}
+	crt, _ := base64.StdEncoding.DecodeString(manifest.Data["tls.crt"])
+	key, _ := base64.StdEncoding.DecodeString(manifest.Data["tls.key"])
+	if string(crt) != cert.ChainPEM || !bytes.Equal(key, keyPEM) {
+		t.Error("data does not round-trip")
+	}
+
+	if _, err := createKubernetesSecret(cert, keyPEM, "Bad_NS"); !errors.Is(err, ErrInvalidBundleOptions) {
+		t.Errorf("invalid namespace: err = %v", err)
+	}
+}
+
+func TestCreateKeyStoreRejectsBadPassword(t *testing.T) {
+	cert, keyPEM := testIssuedCertificate(t)
+	if _, err := createKeyStore(cert, keyPEM, BundleOptions{Password: "123"}, false); !errors.Is(err, ErrInvalidBundleOptions) {
+		t.Errorf("err = %v, want ErrInvalidBundleOptions", err)
+	}
+	if _, err := createKeyStore(cert, keyPEM, BundleOptions{}, true); !errors.Is(err, ErrInvalidBundleOptions) {
+		t.Errorf("missing password: err = %v, want ErrInvalidBundleOptions", err)
+	}
+	if _, err := createKeyStore(cert, keyPEM, BundleOptions{Password: "s3cret-store"}, true); err != nil {
+		t.Errorf("explicit password: %v", err)
+	}
+}
+
+func TestCreateProfileZip(t *testing.T) {
+	cert, keyPEM := testIssuedCertificate(t)
+	if _, err := createProfileZip(cert, keyPEM, BundleOptions{}); !errors.Is(err, ErrInvalidBundleOptions) {
+		t.Errorf("missing password: err = %v, want ErrInvalidBundleOptions", err)
+	}
+	data, err := createProfileZip(cert, keyPEM, BundleOptions{Password: "s3cret-store"})
+	if err != nil {
+		t.Fatalf("createProfileZip: %v", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	for _, f := range r.File {
+		files[f.Name] = true
+	}
+	for _, name := range []string{"fullchain.pem", "private.key", "certificate.pfx", "nginx.conf", "apache.conf", "haproxy.cfg", "iis-install.ps1"} {
+		if !files["example-com/"+name] {
+			t.Errorf("missing %s in %v", name, files)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCertificateMoveInvalid = errors.New("certificate is already in that workspace")
+	ErrCertificateMoveBlocked = errors.New("certificate cannot be moved")
+	ErrShareTargetInvalid     = errors.New("certificate cannot be shared with that workspace")
+	ErrShareNotFound          = errors.New("certificate share not found")
+)
+
+// MoveCertificateRequest names the destination; no workspace makes the
+// certificate private to the user moving it
+type MoveCertificateRequest struct {
+	WorkspaceID *uint `json:"workspace_id"`
+}
+
+// ShareCertificateRequest names the workspace to share a certificate with
+type ShareCertificateRequest struct {
+	WorkspaceID uint `json:"workspace_id" binding:"required"`
+}
+
+// Move hands a certificate to another workspace. The caller has already been
+// authorized to delete it where it is; the user must be able to issue
+// certificates in the destination and to export the key where it is. Certificate-level notification configs,
+// deployment targets and the renewal state move with it.
+func (s *CertificateService) Move(cert *model.Certificate, userID uint, req *MoveCertificateRequest) (AuditChanges, error) {
+	if sameWorkspace(cert.WorkspaceID, req.WorkspaceID) {
+		return nil, ErrCertificateMoveInvalid
+	}
+	if req.WorkspaceID != nil {
+		if err := s.workspaceSvc.checkWritable(*req.WorkspaceID); err != nil {
+			return nil, err
+		}
+		if !s.workspaceSvc.CanManageCertificates(*req.WorkspaceID, userID) {
+			return nil, ErrWorkspaceAccessDenied
+		}
+	}
+	if cert.WorkspaceID != nil {
+		if err := s.checkKeyExportKept(*cert.WorkspaceID, req.WorkspaceID, userID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkMovable(cert, req.WorkspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"workspace_id": req.WorkspaceID}
+	before := map[string]interface{}{"workspace_id": optionalID(cert.WorkspaceID)}
+	after := map[string]interface{}{"workspace_id": optionalID(req.WorkspaceID)}
+	// Private certificates belong to their creator
+	if req.WorkspaceID == nil {
+		updates["created_by"] = userID
+		before["created_by"] = optionalID(cert.CreatedBy)
+		after["created_by"] = userID
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(cert).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.NotificationConfig{}).Where("certificate_id = ?", cert.ID).
+			Update("workspace_id", req.WorkspaceID).Error; err != nil {
+			return err
+		}
+		// The destination no longer needs a share of its own certificate
+		if req.WorkspaceID != nil {
+			return tx.Where("certificate_id = ? AND workspace_id = ?", cert.ID, *req.WorkspaceID).
+				Delete(&model.CertificateShare{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return DiffFields(before, after), nil
+}
+
+// checkMovable rejects moves that would leave the certificate tied to
+// resources of the workspace it leaves
+func (s *CertificateService) checkMovable(cert *model.Certificate, workspaceID *uint, userID uint) error {
+	var bindings int64
+	if err := s.db.Model(&model.IngressBinding{}).Where("certificate_id = ?", cert.ID).Count(&bindings).Error; err != nil {
+		return err
+	}
+	if bindings > 0 {
+		return fmt.Errorf("%w: it is managed by %d ingress binding(s)", ErrCertificateMoveBlocked, bindings)
+	}
+
+	var targets []model.DeploymentTarget
+	if err := s.db.Where("certificate_id = ? AND credential_id IS NOT NULL", cert.ID).Find(&targets).Error; err != nil {
+		return err
+	}
+	for _, target := range targets {
+		var cred model.CloudCredential
+		if err := s.db.First(&cred, *target.CredentialID).Error; err != nil {
+			continue
+		}
+		// Same rule as when the target was created
+		usable := cred.WorkspaceID == nil && workspaceID == nil && cred.CreatedBy == userID
+		if workspaceID != nil {
+			usable = cred.WorkspaceID != nil && *cred.WorkspaceID == *workspaceID
+		}
+		if !usable {
+			return fmt.Errorf("%w: deployment target %q uses a cloud credential of its current owner", ErrCertificateMoveBlocked, target.Name)
+		}
+	}
+	return nil
+}
+
+// checkKeyExportKept guards moves out of a workspace. Whoever holds the
+// certificate afterwards may export its key under the destination's rules, so
+// this takes what a key export from the workspace would, and a workspace that
+// restricts exports only lets the certificate go where the same restrictions
+// apply. A private destination restricts nothing.
+func (s *CertificateService) checkKeyExportKept(sourceID uint, destID *uint, userID uint) error {
+	var source model.Workspace
+	if err := s.db.First(&source, sourceID).Error; err != nil {
+		return ErrWorkspaceNotFound
+	}
+	if !s.workspaceSvc.HasPermission(sourceID, userID, model.PermissionCertKeyExport) {
+		return ErrWorkspaceAccessDenied
+	}
+
+	var dest model.Workspace
+	if destID != nil {
+		if err := s.db.First(&dest, *destID).Error; err != nil {
+			return ErrWorkspaceNotFound
+		}
+	}
+	return keyExportPolicyKept(&source, &dest)
+}
+
+// keyExportPolicyKept reports whether dest restricts private key exports at
+// least as much as source; dest is the zero Workspace for a private destination
+func keyExportPolicyKept(source, dest *model.Workspace) error {
+	if dest.KeyExportPolicy == model.KeyExportPolicyNone {
+		return nil
+	}
+	if source.KeyExportPolicy == model.KeyExportPolicyNone {
+		if dest.ID == 0 {
+			return ErrKeyExportDisabled
+		}
+		return fmt.Errorf("%w: the workspace forbids private key exports and the destination does not", ErrCertificateMoveBlocked)
+	}
+	if (source.KeyExportEncryptedOnly && !dest.KeyExportEncryptedOnly) ||
+		(source.KeyExportRequireReason && !dest.KeyExportRequireReason) {
+		return fmt.Errorf("%w: the workspace restricts private key exports more than the destination", ErrCertificateMoveBlocked)
+	}
+	return nil
+}
+
+// ListShares returns the workspaces a certificate is shared with
+func (s *CertificateService) ListShares(certID uint) ([]model.CertificateShare, error) {
+	var shares []model.CertificateShare
+	if err := s.db.Preload("Workspace").Where("certificate_id = ?", certID).
+		Order("created_at ASC").Find(&shares).Error; err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// Share grants read-only access to a certificate to a workspace the user can
+// read certificates in. Sharing twice is a no-op.
+func (s *CertificateService) Share(cert *model.Certificate, userID uint, req *ShareCertificateRequest) (*model.CertificateShare, error) {
+	if cert.WorkspaceID != nil && *cert.WorkspaceID == req.WorkspaceID {
+		return nil, ErrShareTargetInvalid
+	}
+	if !s.workspaceSvc.CanViewCertificates(req.WorkspaceID, userID) {
+		return nil, ErrShareTargetInvalid
+	}
+
+	share := model.CertificateShare{CertificateID: cert.ID, WorkspaceID: req.WorkspaceID, SharedBy: userID}
+	if err := s.db.Where("certificate_id = ? AND workspace_id = ?", cert.ID, req.WorkspaceID).
+		FirstOrCreate(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// Unshare revokes a workspace's read-only access to a certificate
+func (s *CertificateService) Unshare(certID, workspaceID uint) error {
+	result := s.db.Where("certificate_id = ? AND workspace_id = ?", certID, workspaceID).Delete(&model.CertificateShare{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// sameWorkspace compares two optional workspace IDs
+func sameWorkspace(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// optionalID unwraps an optional ID for audit records
+func optionalID(id *uint) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestKeyExportPolicyKeptPrivate(t *testing.T) {
+	private := &model.Workspace{}
+	tests := []struct {
+		name    string
+		source  model.Workspace
+		wantErr error
+	}{
+		{"permissive", model.Workspace{KeyExportPolicy: model.KeyExportPolicyAdmins}, nil},
+		{"none", model.Workspace{KeyExportPolicy: model.KeyExportPolicyNone}, ErrKeyExportDisabled},
+		{"encrypted only", model.Workspace{KeyExportPolicy: model.KeyExportPolicyAdmins, KeyExportEncryptedOnly: true}, ErrCertificateMoveBlocked},
+		{"reason required", model.Workspace{KeyExportPolicy: model.KeyExportPolicyStepUp, KeyExportRequireReason: true}, ErrCertificateMoveBlocked},
+	}
+	for _, tt := range tests {
+		err := keyExportPolicyKept(&tt.source, private)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyExportPolicyKeptWorkspace(t *testing.T) {
+	ws := func(policy string, encrypted, reason bool) model.Workspace {
+		return model.Workspace{ID: 2, KeyExportPolicy: policy, KeyExportEncryptedOnly: encrypted, KeyExportRequireReason: reason}
+	}
+	admins, none, stepUp := model.KeyExportPolicyAdmins, model.KeyExportPolicyNone, model.KeyExportPolicyStepUp
+	tests := []struct {
+		name   string
+		source model.Workspace
+		dest   model.Workspace
+		ok     bool
+	}{
+		{"permissive to permissive", ws(admins, false, false), ws(stepUp, false, false), true},
+		{"none to permissive", ws(none, false, false), ws(admins, false, false), false},
+		{"none to none", ws(none, false, false), ws(none, false, false), true},
+		{"encrypted only to unrestricted", ws(admins, true, false), ws(admins, false, false), false},
+		{"encrypted only to encrypted only", ws(admins, true, false), ws(stepUp, true, false), true},
+		{"reason required to no reason", ws(admins, false, true), ws(admins, true, false), false},
+		{"reason required to stricter", ws(admins, false, true), ws(admins, true, true), true},
+		{"restricted to none", ws(admins, true, true), ws(none, false, false), true},
+	}
+	for _, tt := range tests {
+		err := keyExportPolicyKept(&tt.source, &tt.dest)
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected err = %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrCertificateMoveBlocked) {
+			t.Errorf("%s: err = %v, want ErrCertificateMoveBlocked", tt.name, err)
+		}
+	}
+}
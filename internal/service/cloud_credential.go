@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCloudCredentialNotFound = errors.New("cloud credential not found")
+	ErrCloudCredentialInUse    = errors.New("cloud credential is used by deployment targets")
+)
+
+// CloudCredentialService manages the access keys used by cloud deployment targets
+type CloudCredentialService struct {
+	db           *gorm.DB
+	workspaceSvc *WorkspaceService
+	encryptor    *crypto.Encryptor
+}
+
+// NewCloudCredentialService creates a new CloudCredentialService
+func NewCloudCredentialService(db *gorm.DB, workspaceSvc *WorkspaceService, encryptor *crypto.Encryptor) *CloudCredentialService {
+	return &CloudCredentialService{db: db, workspaceSvc: workspaceSvc, encryptor: encryptor}
+}
+
+type CreateCloudCredentialRequest struct {
+	WorkspaceID     *uint  `json:"workspace_id"` // Empty for personal certificates
+	Name            string `json:"name" binding:"required,min=1,max=100"`
+	Vendor          string `json:"vendor" binding:"required,oneof=aws aliyun tencent"`
+	AccessKeyID     string `json:"access_key_id" binding:"required,max=128"`
+	SecretAccessKey string `json:"secret_access_key" binding:"required"`
+	Region          string `json:"region" binding:"max=50"`
+}
+
+// List returns the credentials of a workspace, or the user's personal ones when workspaceID is 0
+func (s *CloudCredentialService) List(workspaceID, userID uint) ([]model.CloudCredential, error) {
+	query := s.db.Order("created_at DESC")
+	if workspaceID != 0 {
+		if !s.workspaceSvc.CanManageCertificates(workspaceID, userID) {
+			return nil, ErrWorkspaceAccessDenied
+		}
+		query = query.Where("workspace_id = ?", workspaceID)
+	} else {
+		query = query.Where("workspace_id IS NULL AND created_by = ?", userID)
+	}
+
+	var creds []model.CloudCredential
+	if err := query.Find(&creds).Error; err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// Create stores a credential with its secret encrypted
+func (s *CloudCredentialService) Create(userID uint, req *CreateCloudCredentialRequest) (*model.CloudCredential, error) {
+	if s.encryptor == nil {
+		return nil, ErrDeploymentNotAvailable
+	}
+	if req.WorkspaceID != nil && !s.workspaceSvc.CanManageCertificates(*req.WorkspaceID, userID) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	secret, err := s.encryptor.Encrypt([]byte(req.SecretAccessKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	cred := &model.CloudCredential{
+		WorkspaceID:     req.WorkspaceID,
+		Name:            req.Name,
+		Vendor:          req.Vendor,
+		AccessKeyID:     req.AccessKeyID,
+		SecretAccessKey: secret,
+		Region:          req.Region,
+		CreatedBy:       userID,
+	}
+	if err := s.db.Create(cred).Error; err != nil {
+		return nil, fmt.Errorf("failed to create cloud credential: %w", err)
+	}
+	return cred, nil
+}
+
+// Delete removes a credential that no deployment target uses anymore
+func (s *CloudCredentialService) Delete(id, userID uint) error {
+	var cred model.CloudCredential
+	if err := s.db.First(&cred, id).Error; err != nil {
+		return ErrCloudCredentialNotFound
+	}
+	if !canUseCloudCredential(s.workspaceSvc, &cred, userID) {
+		return ErrWorkspaceAccessDenied
+	}
+
+	var inUse int64
+	if err := s.db.Model(&model.DeploymentTarget{}).Where("credential_id = ?", id).Count(&inUse).Error; err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return ErrCloudCredentialInUse
+	}
+	return s.db.Delete(&cred).Error
+}
+
+func canUseCloudCredential(workspaceSvc *WorkspaceService, cred *model.CloudCredential, userID uint) bool {
+	if cred.WorkspaceID != nil {
+		return workspaceSvc.CanManageCertificates(*cred.WorkspaceID, userID)
+	}
+	return cred.CreatedBy == userID
+}
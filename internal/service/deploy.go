@@ -0,0 +1,261 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeployTokenNotFound = errors.New("deploy token not found")
+	ErrDeployTokenInvalid  = errors.New("invalid or expired deploy token")
+	ErrCertificateNotReady = errors.New("certificate is not ready")
+)
+
+// DeployService manages per-certificate deploy tokens and serves bundles to agents
+type DeployService struct {
+	db           *gorm.DB
+	certSvc      *CertificateService
+	workspaceSvc *WorkspaceService
+	keyExportSvc *KeyExportService // Key export policy and trail
+}
+
+// NewDeployService creates a new DeployService
+func NewDeployService(db *gorm.DB, certSvc *CertificateService, workspaceSvc *WorkspaceService) *DeployService {
+	return &DeployService{
+		db:           db,
+		certSvc:      certSvc,
+		workspaceSvc: workspaceSvc,
+	}
+}
+
+// SetKeyExportService enables the key export policy on deploy tokens
+func (s *DeployService) SetKeyExportService(keyExportSvc *KeyExportService) {
+	s.keyExportSvc = keyExportSvc
+}
+
+type CreateDeployTokenRequest struct {
+	Name           string `json:"name" binding:"required,min=1,max=100"`
+	ExpiresInDays  int    `json:"expires_in_days" binding:"omitempty,min=1,max=3650"` // 0 = never expires
+	Reason         string `json:"reason" binding:"max=255"`                           // Key export reason, if the workspace asks for one
+	ReauthPassword string `json:"reauth_password"`                                    // Login password, for step-up key export policies
+	StepUpToken    string `json:"step_up_token"`                                      // Step-up grant, required instead once 2FA is set up
+}
+
+// CreateDeployTokenResponse carries the raw token, which is only shown once
+type CreateDeployTokenResponse struct {
+	Token       string             `json:"token"`
+	DeployToken *model.DeployToken `json:"deploy_token"`
+}
+
+// DeployBundle is the payload served to deployment agents
+type DeployBundle struct {
+	CertificateID uint       `json:"certificate_id"`
+	Domains       []string   `json:"domains"`
+	Fingerprint   string     `json:"fingerprint"`
+	SerialNumber  string     `json:"serial_number,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	CertPEM       string     `json:"cert_pem"`
+	ChainPEM      string     `json:"chain_pem"`     // Intermediate certificates only
+	FullChainPEM  string     `json:"fullchain_pem"` // Leaf + intermediates
+	KeyPEM        string     `json:"key_pem"`
+}
+
+// CreateToken issues a new deploy token for a certificate. Tokens hand out the
+// private key, so creating one follows the workspace key export policy.
+func (s *DeployService) CreateToken(certID, userID uint, req *CreateDeployTokenRequest) (*CreateDeployTokenResponse, error) {
+	cert, err := s.getManagedCertificate(certID, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Tracked certificates carry no key, so a bundle could never be served
+	if cert.KeyPEM == "" {
+		return nil, ErrCertificateNoPrivateKey
+	}
+	if err := s.keyExportSvc.AuthorizeDelivery(certID, userID, &KeyExportRequest{
+		Reason:         req.Reason,
+		ReauthPassword: req.ReauthPassword,
+		StepUpToken:    req.StepUpToken,
+	}); err != nil {
+		return nil, err
+	}
+
+	raw, err := generateDeployToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token := &model.DeployToken{
+		CertificateID: certID,
+		Name:          req.Name,
+		TokenHash:     hashDeployToken(raw),
+		TokenHint:     raw[:len(model.DeployTokenPrefix)+6],
+		CreatedBy:     userID,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.db.Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create deploy token: %w", err)
+	}
+
+	return &CreateDeployTokenResponse{Token: raw, DeployToken: token}, nil
+}
+
+// ListTokens returns the deploy tokens of a certificate
+func (s *DeployService) ListTokens(certID, userID uint) ([]model.DeployToken, error) {
+	if _, err := s.getManagedCertificate(certID, userID); err != nil {
+		return nil, err
+	}
+
+	var tokens []model.DeployToken
+	if err := s.db.Where("certificate_id = ?", certID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeToken revokes a deploy token; agents using it get 401 on their next poll
+func (s *DeployService) RevokeToken(certID, tokenID, userID uint) error {
+	if _, err := s.getManagedCertificate(certID, userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := s.db.Model(&model.DeployToken{}).
+		Where("id = ? AND certificate_id = ? AND revoked_at IS NULL", tokenID, certID).
+		Update("revoked_at", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeployTokenNotFound
+	}
+	return nil
+}
+
+// GetBundle resolves a raw deploy token and returns the certificate bundle it grants.
+// The ETag changes only when the certificate changes; when it equals ifNoneMatch
+// the bundle is nil and the private key is never decrypted.
+func (s *DeployService) GetBundle(rawToken, clientIP, ifNoneMatch string) (*DeployBundle, string, error) {
+	var token model.DeployToken
+	if err := s.db.Where("token_hash = ?", hashDeployToken(rawToken)).First(&token).Error; err != nil {
+		return nil, "", ErrDeployTokenInvalid
+	}
+	if !token.IsActive() {
+		return nil, "", ErrDeployTokenInvalid
+	}
+
+	now := time.Now()
+	s.db.Model(&token).Updates(map[string]any{
+		"last_used_at": &now,
+		"last_used_ip": clientIP,
+	})
+
+	var cert model.Certificate
+	if err := s.db.First(&cert, token.CertificateID).Error; err != nil {
+		return nil, "", ErrDeployTokenInvalid
+	}
+	if cert.Status != model.CertificateStatusReady {
+		return nil, "", ErrCertificateNotReady
+	}
+	if cert.KeyPEM == "" {
+		return nil, "", ErrCertificateNoPrivateKey
+	}
+
+	if err := s.keyExportSvc.DeliveryAllowed(&cert); err != nil {
+		return nil, "", err
+	}
+	// The token carries its creator's export rights, so it stops working once
+	// they no longer have them
+	if s.keyExportSvc.DelivererAllowed(&cert, token.CreatedBy) != nil {
+		return nil, "", ErrDeployTokenInvalid
+	}
+
+	etag := bundleETag(&cert)
+	if ifNoneMatch != "" && ifNoneMatch == etag {
+		return nil, etag, nil
+	}
+
+	bundle, err := s.buildBundle(&cert)
+	if err != nil {
+		return nil, "", err
+	}
+	s.keyExportSvc.Record(cert.ID, token.CreatedBy, string(DownloadFormatPEM), "deploy token "+token.Name, clientIP, KeyExportViaDeployToken)
+	return bundle, etag, nil
+}
+
+func (s *DeployService) buildBundle(cert *model.Certificate) (*DeployBundle, error) {
+	keyPEM, err := s.certSvc.GetPrivateKeyPEM(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []string
+	json.Unmarshal([]byte(cert.Domains), &domains)
+
+	fullChain := cert.ChainPEM
+	if fullChain == "" {
+		fullChain = cert.CertPEM
+	}
+
+	return &DeployBundle{
+		CertificateID: cert.ID,
+		Domains:       domains,
+		Fingerprint:   certificateFingerprint(cert),
+		SerialNumber:  cert.SerialNumber,
+		ExpiresAt:     cert.ExpiresAt,
+		CertPEM:       cert.CertPEM,
+		ChainPEM:      cert.IssuerCertPEM,
+		FullChainPEM:  fullChain,
+		KeyPEM:        string(keyPEM),
+	}, nil
+}
+
+// getManagedCertificate loads a certificate the user is allowed to manage
+func (s *DeployService) getManagedCertificate(certID, userID uint) (*model.Certificate, error) {
+	return loadManagedCertificate(s.db, s.workspaceSvc, certID, userID)
+}
+
+// loadManagedCertificate loads a certificate and checks that the user can manage it:
+// workspace certificates need a manager role, personal ones must be owned by the user.
+func loadManagedCertificate(db *gorm.DB, workspaceSvc *WorkspaceService, certID, userID uint) (*model.Certificate, error) {
+	return authorizeCertificate(db, workspaceSvc, certID, userID, CertificateActionManage)
+}
+
+// certificateFingerprint returns the stored SHA-256 fingerprint, falling back
+// to a hash of the PEM for certificates issued without one (e.g. mock mode).
+func certificateFingerprint(cert *model.Certificate) string {
+	if cert.Fingerprint != "" {
+		return cert.Fingerprint
+	}
+	sum := sha256.Sum256([]byte(cert.CertPEM))
+	return hex.EncodeToString(sum[:])
+}
+
+func bundleETag(cert *model.Certificate) string {
+	return `"` + certificateFingerprint(cert) + `"`
+}
+
+func generateDeployToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return model.DeployTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashDeployToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
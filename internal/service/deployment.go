@@ -0,0 +1,752 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/cloud"
+	"github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/deployer"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeploymentTargetNotFound = errors.New("deployment target not found")
+	ErrDeploymentNotAvailable   = errors.New("deployment requires an encryption master key")
+	ErrInvalidSSHKey            = errors.New("invalid SSH private key")
+	ErrInvalidRemotePath        = errors.New("remote paths must be absolute")
+	ErrInvalidDeploymentTarget  = errors.New("invalid deployment target")
+	ErrCertificateNoPrivateKey  = errors.New("certificate has no private key to deploy")
+)
+
+// deployTimeout bounds one push (connect, upload, post-deploy command) to one target
+const deployTimeout = 2 * time.Minute
+
+// DeploymentService pushes issued certificates to SSH hosts, Kubernetes Secrets and cloud services
+type DeploymentService struct {
+	db              *gorm.DB
+	certSvc         *CertificateService
+	workspaceSvc    *WorkspaceService
+	notificationSvc *NotificationService
+	encryptor       *crypto.Encryptor // nil in mock mode; targets cannot be stored without it
+	keyExportSvc    *KeyExportService // Key export policy and trail
+	ssh             *deployer.SSHDeployer
+	kube            *deployer.KubernetesDeployer
+
+	inClusterNamespaces []string // Namespaces non-admins may target with the console's service account
+}
+
+// NewDeploymentService creates a new DeploymentService
+func NewDeploymentService(db *gorm.DB, certSvc *CertificateService, workspaceSvc *WorkspaceService, notificationSvc *NotificationService, encryptor *crypto.Encryptor) *DeploymentService {
+	return &DeploymentService{
+		db:              db,
+		certSvc:         certSvc,
+		workspaceSvc:    workspaceSvc,
+		notificationSvc: notificationSvc,
+		encryptor:       encryptor,
+		ssh:             deployer.NewSSHDeployer(),
+		kube:            deployer.NewKubernetesDeployer(),
+	}
+}
+
+// SetKeyExportService enables the key export policy on deployment targets
+func (s *DeploymentService) SetKeyExportService(keyExportSvc *KeyExportService) {
+	s.keyExportSvc = keyExportSvc
+}
+
+// SetInClusterNamespaces sets the namespaces workspace users may sync Secrets
+// to with the console's own service account. System admins may use any.
+func (s *DeploymentService) SetInClusterNamespaces(namespaces []string) {
+	s.inClusterNamespaces = namespaces
+}
+
+// KeyDeliveryRequest carries what the workspace key export policy may ask of
+// whoever sets up or triggers a push of the private key
+type KeyDeliveryRequest struct {
+	Reason         string `json:"reason" binding:"max=255"` // Key export reason, if the workspace asks for one
+	ReauthPassword string `json:"reauth_password"`          // Login password, for step-up key export policies
+	StepUpToken    string `json:"step_up_token"`            // Step-up grant, required instead once 2FA is set up
+}
+
+func (r *KeyDeliveryRequest) keyExportRequest() *KeyExportRequest {
+	return &KeyExportRequest{Reason: r.Reason, ReauthPassword: r.ReauthPassword, StepUpToken: r.StepUpToken}
+}
+
+type DeploymentTargetRequest struct {
+	KeyDeliveryRequest
+
+	Name    string `json:"name" binding:"required,min=1,max=100"`
+	Type    string `json:"type" binding:"omitempty,oneof=ssh kubernetes cloud"` // Default ssh; cannot change on update
+	Enabled *bool  `json:"enabled"`
+
+	// SSH
+	Host               string `json:"host" binding:"max=255"`
+	Port               int    `json:"port" binding:"omitempty,min=1,max=65535"`
+	Username           string `json:"username" binding:"max=100"`
+	PrivateKey         string `json:"private_key"` // Required on create; empty keeps the stored key on update
+	HostKeyFingerprint string `json:"host_key_fingerprint" binding:"max=100"`
+	FullChainPath      string `json:"fullchain_path" binding:"max=500"`
+	KeyPath            string `json:"key_path" binding:"max=500"`
+	PostDeployCommand  string `json:"post_deploy_command" binding:"max=1000"`
+
+	// Kubernetes
+	Kubeconfig    string `json:"kubeconfig"` // Empty keeps the stored kubeconfig on update
+	InCluster     bool   `json:"in_cluster"` // System admins, or namespaces in deployment.in_cluster_namespaces
+	Namespace     string `json:"namespace" binding:"max=253"`
+	SecretName    string `json:"secret_name" binding:"max=253"`
+	AdoptExisting bool   `json:"adopt_existing"` // Take over a TLS Secret the console did not create
+
+	// Cloud
+	Provider          string   `json:"provider" binding:"omitempty,oneof=aws_acm aws_iam aliyun_cdn aliyun_slb tencent_ssl"`
+	CredentialID      *uint    `json:"credential_id"`
+	Region            string   `json:"region" binding:"max=50"`
+	CloudResources    []string `json:"cloud_resources" binding:"max=50,dive,min=1,max=500"`
+	CloudResourceType string   `json:"cloud_resource_type" binding:"max=30"`
+}
+
+type RedeployRequest struct {
+	KeyDeliveryRequest
+	TargetID uint `json:"target_id"` // 0 = all enabled targets
+}
+
+// ListTargets returns the deployment targets of a certificate
+func (s *DeploymentService) ListTargets(certID, userID uint) ([]model.DeploymentTarget, error) {
+	if _, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID); err != nil {
+		return nil, err
+	}
+
+	var targets []model.DeploymentTarget
+	if err := s.db.Where("certificate_id = ?", certID).Order("created_at ASC").Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// DeploymentStatus is the last sync result of one target, without its
+// connection details
+type DeploymentStatus struct {
+	TargetID       uint                       `json:"target_id"`
+	Name           string                     `json:"name"`
+	Type           model.DeploymentTargetType `json:"type"`
+	Enabled        bool                       `json:"enabled"`
+	LastStatus     string                     `json:"last_status,omitempty"`
+	LastMessage    string                     `json:"last_message,omitempty"`
+	LastDeployedAt *time.Time                 `json:"last_deployed_at,omitempty"`
+}
+
+// ListStatus returns the sync status of each deployment target of a certificate
+func (s *DeploymentService) ListStatus(certID, userID uint) ([]DeploymentStatus, error) {
+	targets, err := s.ListTargets(certID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]DeploymentStatus, 0, len(targets))
+	for _, target := range targets {
+		statuses = append(statuses, DeploymentStatus{
+			TargetID:       target.ID,
+			Name:           target.Name,
+			Type:           target.Type,
+			Enabled:        target.Enabled,
+			LastStatus:     target.LastStatus,
+			LastMessage:    target.LastMessage,
+			LastDeployedAt: target.LastDeployedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// CreateTarget adds a deployment target to a certificate
+func (s *DeploymentService) CreateTarget(certID, userID uint, req *DeploymentTargetRequest) (*model.DeploymentTarget, error) {
+	if s.encryptor == nil {
+		return nil, ErrDeploymentNotAvailable
+	}
+	cert, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Source == model.CertificateSourceDiscovered {
+		return nil, ErrCertificateNoPrivateKey
+	}
+	// Targets receive the private key, so they follow the key export policy
+	if err := s.keyExportSvc.AuthorizeDelivery(certID, userID, req.keyExportRequest()); err != nil {
+		return nil, err
+	}
+
+	targetType := model.DeploymentTargetType(req.Type)
+	if targetType == "" {
+		targetType = model.DeploymentTargetSSH
+	}
+	target := &model.DeploymentTarget{
+		CertificateID: certID,
+		Type:          targetType,
+		Enabled:       true,
+		CreatedBy:     userID,
+	}
+	if err := s.applyRequest(target, userID, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(target).Error; err != nil {
+		return nil, fmt.Errorf("failed to create deployment target: %w", err)
+	}
+	return target, nil
+}
+
+// UpdateTarget updates a deployment target
+func (s *DeploymentService) UpdateTarget(certID, targetID, userID uint, req *DeploymentTargetRequest) (*model.DeploymentTarget, error) {
+	if s.encryptor == nil {
+		return nil, ErrDeploymentNotAvailable
+	}
+	target, err := s.getTarget(certID, targetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keyExportSvc.AuthorizeDelivery(certID, userID, req.keyExportRequest()); err != nil {
+		return nil, err
+	}
+
+	if req.Type != "" && model.DeploymentTargetType(req.Type) != target.Type {
+		return nil, fmt.Errorf("%w: type cannot be changed", ErrInvalidDeploymentTarget)
+	}
+	if err := s.applyRequest(target, userID, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Save(target).Error; err != nil {
+		return nil, fmt.Errorf("failed to update deployment target: %w", err)
+	}
+	return target, nil
+}
+
+// DeleteTarget removes a deployment target; its logs are kept
+func (s *DeploymentService) DeleteTarget(certID, targetID, userID uint) error {
+	target, err := s.getTarget(certID, targetID, userID)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(target).Error
+}
+
+// ListLogs returns recent deployment results of a certificate
+func (s *DeploymentService) ListLogs(certID, userID uint, limit int) ([]model.DeploymentLog, error) {
+	if _, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var logs []model.DeploymentLog
+	if err := s.db.Where("certificate_id = ?", certID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to query deployment logs: %w", err)
+	}
+	return logs, nil
+}
+
+// Redeploy pushes the current certificate to one target, or to every enabled
+// target when no target is given, and returns the per-target results.
+func (s *DeploymentService) Redeploy(certID, userID uint, req *RedeployRequest) ([]model.DeploymentLog, error) {
+	if s.encryptor == nil {
+		return nil, ErrDeploymentNotAvailable
+	}
+	cert, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Status != model.CertificateStatusReady {
+		return nil, ErrCertificateNotReady
+	}
+	if err := s.keyExportSvc.AuthorizeDelivery(certID, userID, req.keyExportRequest()); err != nil {
+		return nil, err
+	}
+
+	var targets []model.DeploymentTarget
+	if req.TargetID != 0 {
+		target, err := s.getTarget(certID, req.TargetID, userID)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *target)
+	} else if err := s.db.Where("certificate_id = ? AND enabled = ?", certID, true).Find(&targets).Error; err != nil {
+		return nil, err
+	}
+
+	return s.deploy(cert, targets, model.DeploymentTriggerManual, userID)
+}
+
+// DeployCertificate pushes a freshly issued or renewed certificate to all of
+// its enabled targets. Failures are logged and notified, not returned.
+func (s *DeploymentService) DeployCertificate(certID uint, trigger model.DeploymentTrigger) {
+	if s.encryptor == nil {
+		return
+	}
+
+	var targets []model.DeploymentTarget
+	if err := s.db.Where("certificate_id = ? AND enabled = ?", certID, true).Find(&targets).Error; err != nil {
+		logger.Error("Failed to load deployment targets", logger.Uint("cert_id", certID), logger.Err(err))
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	var cert model.Certificate
+	if err := s.db.First(&cert, certID).Error; err != nil {
+		logger.Error("Failed to load certificate for deployment", logger.Uint("cert_id", certID), logger.Err(err))
+		return
+	}
+
+	logs, err := s.deploy(&cert, targets, trigger, 0)
+	if err != nil {
+		logger.Error("Deployment failed", logger.Uint("cert_id", certID), logger.Err(err))
+		s.notifyFailure(certID, "all targets", err.Error())
+		return
+	}
+	for _, l := range logs {
+		if l.Status != "success" {
+			s.notifyFailure(certID, l.TargetName, l.Message)
+		}
+	}
+}
+
+// deployPayload is the material pushed to every target of one certificate
+type deployPayload struct {
+	cert        *model.Certificate
+	fingerprint string
+	fullChain   []byte
+	key         []byte
+}
+
+// deploy pushes to the targets and records each push as a key export by the
+// user who triggered it, or by the target's creator for automatic pushes
+func (s *DeploymentService) deploy(cert *model.Certificate, targets []model.DeploymentTarget, trigger model.DeploymentTrigger, userID uint) ([]model.DeploymentLog, error) {
+	// The workspace may have disabled key exports since the targets were set up
+	if err := s.keyExportSvc.DeliveryAllowed(cert); err != nil {
+		return nil, err
+	}
+	keyPEM, err := s.certSvc.GetPrivateKeyPEM(cert)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertificateNoPrivateKey, err)
+	}
+	fullChain := cert.ChainPEM
+	if fullChain == "" {
+		fullChain = cert.CertPEM
+	}
+	payload := &deployPayload{
+		cert:        cert,
+		fingerprint: certificateFingerprint(cert),
+		fullChain:   []byte(fullChain),
+		key:         keyPEM,
+	}
+
+	logs := make([]model.DeploymentLog, 0, len(targets))
+	for i := range targets {
+		entry := s.deployTarget(&targets[i], payload)
+		entry.Trigger = trigger
+		entry.Fingerprint = payload.fingerprint
+		if err := s.db.Create(entry).Error; err != nil {
+			logger.Error("Failed to create deployment log", logger.Err(err))
+		}
+		actor := userID
+		if actor == 0 {
+			actor = targets[i].CreatedBy
+		}
+		s.keyExportSvc.Record(cert.ID, actor, string(targets[i].Type), "deployment target "+targets[i].Name, "", KeyExportViaDeployment)
+		logs = append(logs, *entry)
+	}
+	return logs, nil
+}
+
+func (s *DeploymentService) deployTarget(target *model.DeploymentTarget, payload *deployPayload) *model.DeploymentLog {
+	entry := &model.DeploymentLog{
+		CertificateID: target.CertificateID,
+		TargetID:      target.ID,
+		TargetName:    target.Name,
+		Status:        "success",
+	}
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), deployTimeout)
+	defer cancel()
+
+	var hostKey string
+	var cloudState *cloud.State
+	switch target.Type {
+	case model.DeploymentTargetKubernetes:
+		entry.Message, entry.Output = s.syncSecret(ctx, target, payload)
+	case model.DeploymentTargetCloud:
+		entry.Message, entry.Output, cloudState = s.deployCloud(ctx, target, payload)
+	default:
+		entry.Message, entry.Output, hostKey = s.pushSSH(ctx, target, payload)
+	}
+	entry.DurationMs = time.Since(start).Milliseconds()
+
+	if entry.Message != "" {
+		entry.Status = "failed"
+		logger.Error("Failed to deploy certificate",
+			logger.Uint("cert_id", target.CertificateID),
+			logger.Uint("target_id", target.ID),
+			logger.String("error", entry.Message),
+		)
+	} else {
+		entry.Message = "Deployed to " + describeTarget(target)
+	}
+
+	now := time.Now()
+	updates := map[string]any{
+		"last_deployed_at": &now,
+		"last_status":      entry.Status,
+		"last_message":     entry.Message,
+	}
+	// Pin the host key on first successful contact
+	if target.HostKeyFingerprint == "" && hostKey != "" {
+		updates["host_key_fingerprint"] = hostKey
+	}
+	if cloudState != nil {
+		updates["cloud_cert_id"] = cloudState.CertID
+		updates["cloud_fingerprint"] = cloudState.Fingerprint
+		updates["cloud_superseded"] = cloudState.Superseded
+	}
+	s.db.Model(target).Updates(updates)
+
+	return entry
+}
+
+// pushSSH uploads the files and runs the post-deploy command. It returns the
+// error message (empty on success), the command output and the host key seen.
+func (s *DeploymentService) pushSSH(ctx context.Context, target *model.DeploymentTarget, payload *deployPayload) (string, string, string) {
+	privateKey, err := s.encryptor.Decrypt(target.PrivateKey)
+	if err != nil {
+		return fmt.Sprintf("failed to decrypt SSH key: %v", err), "", ""
+	}
+
+	result, err := s.ssh.Push(ctx, &deployer.SSHTarget{
+		Host:               target.Host,
+		Port:               target.Port,
+		User:               target.Username,
+		PrivateKey:         privateKey,
+		HostKeyFingerprint: target.HostKeyFingerprint,
+	}, []deployer.File{
+		{Path: target.KeyPath, Content: payload.key, Mode: 0o600},
+		{Path: target.FullChainPath, Content: payload.fullChain, Mode: 0o644},
+	}, target.PostDeployCommand)
+
+	var output, hostKey string
+	if result != nil {
+		output, hostKey = result.Output, result.HostKeyFingerprint
+	}
+	if err != nil {
+		return err.Error(), output, hostKey
+	}
+	return "", output, hostKey
+}
+
+// syncSecret writes the certificate into a kubernetes.io/tls Secret.
+// It returns the error message (empty on success) and a short summary.
+func (s *DeploymentService) syncSecret(ctx context.Context, target *model.DeploymentTarget, payload *deployPayload) (string, string) {
+	var kubeconfig []byte
+	if target.Kubeconfig != "" {
+		var err error
+		if kubeconfig, err = s.encryptor.Decrypt(target.Kubeconfig); err != nil {
+			return fmt.Sprintf("failed to decrypt kubeconfig: %v", err), ""
+		}
+	}
+
+	// Re-checked on every push: targets outlive their creator's role and the allowlist
+	if target.InCluster && !s.inClusterAllowed(target.Namespace, target.CreatedBy) && !s.ingressManaged(target.ID) {
+		return fmt.Sprintf("in_cluster is not allowed for namespace %q", target.Namespace), ""
+	}
+
+	annotations := map[string]string{deployer.AnnotFingerprint: payload.fingerprint}
+	if payload.cert.ExpiresAt != nil {
+		annotations[deployer.AnnotExpiresAt] = payload.cert.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	created, err := s.kube.SyncSecret(ctx, &deployer.KubernetesTarget{
+		Kubeconfig:    kubeconfig,
+		InCluster:     target.InCluster,
+		Namespace:     target.Namespace,
+		SecretName:    target.SecretName,
+		AdoptExisting: target.AdoptExisting,
+	}, &deployer.TLSSecret{
+		Cert: payload.fullChain,
+		Key:  payload.key,
+		CA:   []byte(payload.cert.IssuerCertPEM),
+		Labels: map[string]string{
+			deployer.LabelManagedBy:   deployer.ManagedByValue,
+			deployer.LabelCertificate: strconv.FormatUint(uint64(payload.cert.ID), 10),
+		},
+		Annotations: annotations,
+	})
+	if err != nil {
+		return err.Error(), ""
+	}
+	if created {
+		return "", "secret created"
+	}
+	return "", "secret updated"
+}
+
+// deployCloud uploads the certificate to the provider and binds it to the
+// configured resources. It returns the error message (empty on success), the
+// step notes and the upload state to remember, nil if nothing was attempted.
+func (s *DeploymentService) deployCloud(ctx context.Context, target *model.DeploymentTarget, payload *deployPayload) (string, string, *cloud.State) {
+	if target.CredentialID == nil {
+		return "no cloud credential configured", "", nil
+	}
+	var cred model.CloudCredential
+	if err := s.db.First(&cred, *target.CredentialID).Error; err != nil {
+		return "cloud credential not found", "", nil
+	}
+	secret, err := s.encryptor.Decrypt(cred.SecretAccessKey)
+	if err != nil {
+		return fmt.Sprintf("failed to decrypt cloud credential: %v", err), "", nil
+	}
+
+	var resources []string
+	if err := json.Unmarshal([]byte(target.CloudResources), &resources); err != nil {
+		return fmt.Sprintf("invalid cloud resources: %v", err), "", nil
+	}
+
+	region := target.Region
+	if region == "" {
+		region = cred.Region
+	}
+	provider, err := cloud.New(target.Provider, cloud.Credentials{
+		AccessKeyID:     cred.AccessKeyID,
+		SecretAccessKey: string(secret),
+		Region:          region,
+	}, cloud.Options{ResourceType: target.CloudResourceType})
+	if err != nil {
+		return err.Error(), "", nil
+	}
+
+	state, notes, err := cloud.Deploy(ctx, provider, &cloud.Certificate{
+		Name:        cloud.CertificateName(payload.cert.ID, payload.fingerprint),
+		Fingerprint: payload.fingerprint,
+		Leaf:        []byte(payload.cert.CertPEM),
+		Chain:       []byte(payload.cert.IssuerCertPEM),
+		FullChain:   payload.fullChain,
+		Key:         payload.key,
+	}, resources, cloud.State{
+		CertID:      target.CloudCertID,
+		Fingerprint: target.CloudFingerprint,
+		Superseded:  target.CloudSuperseded,
+	})
+	output := strings.Join(notes, "\n")
+	if err != nil {
+		return err.Error(), output, &state
+	}
+	return "", output, &state
+}
+
+func describeTarget(target *model.DeploymentTarget) string {
+	switch target.Type {
+	case model.DeploymentTargetKubernetes:
+		return target.Namespace + "/" + target.SecretName
+	case model.DeploymentTargetCloud:
+		return target.Provider
+	}
+	return target.Host
+}
+
+func (s *DeploymentService) applyRequest(target *model.DeploymentTarget, userID uint, req *DeploymentTargetRequest) error {
+	switch target.Type {
+	case model.DeploymentTargetKubernetes:
+		if err := s.applyKubernetes(target, userID, req); err != nil {
+			return err
+		}
+	case model.DeploymentTargetCloud:
+		if err := s.applyCloud(target, req); err != nil {
+			return err
+		}
+	default:
+		if err := s.applySSH(target, req); err != nil {
+			return err
+		}
+	}
+
+	target.Name = req.Name
+	if req.Enabled != nil {
+		target.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+func (s *DeploymentService) applySSH(target *model.DeploymentTarget, req *DeploymentTargetRequest) error {
+	if req.Host == "" || req.Username == "" {
+		return fmt.Errorf("%w: host and username are required", ErrInvalidDeploymentTarget)
+	}
+	if !path.IsAbs(req.FullChainPath) || !path.IsAbs(req.KeyPath) {
+		return ErrInvalidRemotePath
+	}
+
+	if req.PrivateKey != "" {
+		if _, err := ssh.ParsePrivateKey([]byte(req.PrivateKey)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSSHKey, err)
+		}
+		encrypted, err := s.encryptor.Encrypt([]byte(req.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt SSH key: %w", err)
+		}
+		target.PrivateKey = encrypted
+	}
+	if target.PrivateKey == "" {
+		return ErrInvalidSSHKey
+	}
+
+	// Changing the host invalidates the pinned host key unless a new one is given
+	if target.Host != "" && target.Host != req.Host && req.HostKeyFingerprint == "" {
+		target.HostKeyFingerprint = ""
+	}
+	if req.HostKeyFingerprint != "" {
+		target.HostKeyFingerprint = req.HostKeyFingerprint
+	}
+
+	target.Host = req.Host
+	target.Port = req.Port
+	if target.Port == 0 {
+		target.Port = 22
+	}
+	target.Username = req.Username
+	target.FullChainPath = req.FullChainPath
+	target.KeyPath = req.KeyPath
+	target.PostDeployCommand = req.PostDeployCommand
+	return nil
+}
+
+func (s *DeploymentService) applyKubernetes(target *model.DeploymentTarget, userID uint, req *DeploymentTargetRequest) error {
+	if req.Namespace == "" || req.SecretName == "" {
+		return fmt.Errorf("%w: namespace and secret_name are required", ErrInvalidDeploymentTarget)
+	}
+	// The console's service account is not the workspace's to use anywhere
+	if req.InCluster && !s.inClusterAllowed(req.Namespace, userID) {
+		return fmt.Errorf("%w: in_cluster is not allowed for namespace %q", ErrInvalidDeploymentTarget, req.Namespace)
+	}
+
+	if req.Kubeconfig != "" {
+		if err := deployer.ParseKubeconfig([]byte(req.Kubeconfig)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDeploymentTarget, err)
+		}
+		encrypted, err := s.encryptor.Encrypt([]byte(req.Kubeconfig))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt kubeconfig: %w", err)
+		}
+		target.Kubeconfig = encrypted
+	}
+	target.InCluster = req.InCluster
+	if target.InCluster && req.Kubeconfig == "" {
+		target.Kubeconfig = ""
+	}
+	if target.Kubeconfig == "" && !target.InCluster {
+		return fmt.Errorf("%w: kubeconfig is required unless in_cluster is set", ErrInvalidDeploymentTarget)
+	}
+
+	target.Namespace = req.Namespace
+	target.SecretName = req.SecretName
+	target.AdoptExisting = req.AdoptExisting
+	return nil
+}
+
+// ingressManaged reports whether the target belongs to the Ingress controller,
+// whose namespaces are bound in its own configuration
+func (s *DeploymentService) ingressManaged(targetID uint) bool {
+	var count int64
+	s.db.Model(&model.IngressBinding{}).Where("target_id = ?", targetID).Count(&count)
+	return count > 0
+}
+
+// inClusterAllowed reports whether the user may sync Secrets to the namespace
+// with the console's own service account
+func (s *DeploymentService) inClusterAllowed(namespace string, userID uint) bool {
+	for _, allowed := range s.inClusterNamespaces {
+		if allowed == namespace {
+			return true
+		}
+	}
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return false
+	}
+	return user.IsAdmin()
+}
+
+func (s *DeploymentService) applyCloud(target *model.DeploymentTarget, req *DeploymentTargetRequest) error {
+	if req.Provider == "" || req.CredentialID == nil {
+		return fmt.Errorf("%w: provider and credential_id are required", ErrInvalidDeploymentTarget)
+	}
+	if len(req.CloudResources) == 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidDeploymentTarget, cloud.ErrNoResources)
+	}
+
+	var cred model.CloudCredential
+	if err := s.db.First(&cred, *req.CredentialID).Error; err != nil {
+		return ErrCloudCredentialNotFound
+	}
+	if cred.Vendor != cloud.VendorOf(req.Provider) {
+		return fmt.Errorf("%w: provider %s needs a %s credential", ErrInvalidDeploymentTarget, req.Provider, cloud.VendorOf(req.Provider))
+	}
+	// The credential must belong to the same workspace (or owner) as the certificate
+	var cert model.Certificate
+	if err := s.db.First(&cert, target.CertificateID).Error; err != nil {
+		return fmt.Errorf("certificate not found: %w", err)
+	}
+	if cert.WorkspaceID != nil {
+		if cred.WorkspaceID == nil || *cred.WorkspaceID != *cert.WorkspaceID {
+			return ErrCloudCredentialNotFound
+		}
+	} else if cred.WorkspaceID != nil || cert.CreatedBy == nil || cred.CreatedBy != *cert.CreatedBy {
+		return ErrCloudCredentialNotFound
+	}
+
+	resources, err := json.Marshal(req.CloudResources)
+	if err != nil {
+		return err
+	}
+
+	// Uploads made under another provider, account or region cannot be reused or cleaned up
+	if target.Provider != req.Provider || target.CredentialID == nil || *target.CredentialID != *req.CredentialID || target.Region != req.Region {
+		target.CloudCertID = ""
+		target.CloudFingerprint = ""
+		target.CloudSuperseded = ""
+	}
+
+	target.Provider = req.Provider
+	target.CredentialID = req.CredentialID
+	target.Region = req.Region
+	target.CloudResources = string(resources)
+	target.CloudResourceType = req.CloudResourceType
+	return nil
+}
+
+func (s *DeploymentService) getTarget(certID, targetID, userID uint) (*model.DeploymentTarget, error) {
+	if _, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID); err != nil {
+		return nil, err
+	}
+
+	var target model.DeploymentTarget
+	if err := s.db.Where("id = ? AND certificate_id = ?", targetID, certID).First(&target).Error; err != nil {
+		return nil, ErrDeploymentTargetNotFound
+	}
+	return &target, nil
+}
+
+func (s *DeploymentService) notifyFailure(certID uint, targetName, message string) {
+	if s.notificationSvc == nil {
+		return
+	}
+	s.notificationSvc.SendDeploymentNotification(certID, targetName, message)
+}
@@ -0,0 +1,482 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/discovery"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"github.com/imkerbos/ACME-Console/internal/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDiscoveryJobNotFound   = errors.New("discovery job not found")
+	ErrDiscoveryJobRunning    = errors.New("discovery job is already running")
+	ErrDiscoveredCertNotFound = errors.New("discovered certificate not found")
+	ErrDiscoveredCertLinked   = errors.New("discovered certificate is already linked to a certificate")
+	ErrDiscoveredCertNoSANs   = errors.New("discovered certificate has no DNS names")
+)
+
+// DiscoveryService scans networks for TLS certificates the console does not manage yet
+type DiscoveryService struct {
+	db           *gorm.DB
+	workspaceSvc *WorkspaceService
+	certSvc      *CertificateService
+	scanTimeout  time.Duration
+	dueRunning   atomic.Bool
+}
+
+// discoveryLeaseGrace is added to the scan timeout before a running job whose
+// process died is considered abandoned and may be started again
+const discoveryLeaseGrace = 15 * time.Minute
+
+// NewDiscoveryService creates a new DiscoveryService
+func NewDiscoveryService(db *gorm.DB, workspaceSvc *WorkspaceService, certSvc *CertificateService) *DiscoveryService {
+	return &DiscoveryService{
+		db:           db,
+		workspaceSvc: workspaceSvc,
+		certSvc:      certSvc,
+		scanTimeout:  2 * time.Hour,
+	}
+}
+
+type CreateDiscoveryJobRequest struct {
+	WorkspaceID    uint     `json:"workspace_id" binding:"required"`
+	Name           string   `json:"name" binding:"required,min=1,max=100"`
+	CIDRs          []string `json:"cidrs" binding:"required,min=1"`
+	Ports          []int    `json:"ports" binding:"required,min=1,dive,min=1,max=65535"`
+	Concurrency    int      `json:"concurrency" binding:"omitempty,min=1,max=512"`
+	TimeoutSeconds int      `json:"timeout_seconds" binding:"omitempty,min=1,max=60"`
+	IntervalHours  int      `json:"interval_hours" binding:"omitempty,min=0,max=720"`
+}
+
+type ManageDiscoveredRequest struct {
+	Email   string `json:"email" binding:"required,email"`
+	KeyType string `json:"key_type" binding:"omitempty,oneof=RSA ECC"`
+}
+
+// CreateJob creates a discovery job in a workspace (owner/admin only)
+func (s *DiscoveryService) CreateJob(userID uint, req *CreateDiscoveryJobRequest) (*model.DiscoveryJob, error) {
+	if !s.workspaceSvc.CanManageCertificates(req.WorkspaceID, userID) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	// Validate the ranges up front so a bad job never gets stored
+	if _, err := discovery.ExpandCIDRs(req.CIDRs, 0); err != nil {
+		return nil, err
+	}
+
+	cidrsJSON, err := json.Marshal(req.CIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cidrs: %w", err)
+	}
+	portsJSON, err := json.Marshal(req.Ports)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ports: %w", err)
+	}
+
+	job := &model.DiscoveryJob{
+		WorkspaceID:    req.WorkspaceID,
+		Name:           req.Name,
+		CIDRs:          string(cidrsJSON),
+		Ports:          string(portsJSON),
+		Concurrency:    req.Concurrency,
+		TimeoutSeconds: req.TimeoutSeconds,
+		IntervalHours:  req.IntervalHours,
+		Status:         model.DiscoveryJobStatusIdle,
+		CreatedBy:      userID,
+	}
+	if job.Concurrency == 0 {
+		job.Concurrency = discovery.DefaultConcurrency
+	}
+	if job.TimeoutSeconds == 0 {
+		job.TimeoutSeconds = int(discovery.DefaultTimeout / time.Second)
+	}
+
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create discovery job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns the discovery jobs of a workspace (any member)
+func (s *DiscoveryService) ListJobs(workspaceID, userID uint) ([]model.DiscoveryJob, error) {
+	if !s.workspaceSvc.CanViewCertificates(workspaceID, userID) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	var jobs []model.DiscoveryJob
+	if err := s.db.Where("workspace_id = ?", workspaceID).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// DeleteJob deletes a discovery job; results already discovered are kept
+func (s *DiscoveryService) DeleteJob(jobID, userID uint) error {
+	job, err := s.getJob(jobID)
+	if err != nil {
+		return err
+	}
+	if !s.workspaceSvc.CanManageCertificates(job.WorkspaceID, userID) {
+		return ErrWorkspaceAccessDenied
+	}
+	return s.db.Delete(job).Error
+}
+
+// RunJob starts a scan in the background (owner/admin only)
+func (s *DiscoveryService) RunJob(jobID, userID uint) error {
+	job, err := s.getJob(jobID)
+	if err != nil {
+		return err
+	}
+	if !s.workspaceSvc.CanManageCertificates(job.WorkspaceID, userID) {
+		return ErrWorkspaceAccessDenied
+	}
+	if !s.markRunning(job) {
+		return ErrDiscoveryJobRunning
+	}
+
+	go s.runJob(job)
+	return nil
+}
+
+// RunDueJobs starts every scheduled job whose interval has elapsed and returns
+// without waiting. Scans run sequentially in the background to keep network load
+// predictable; a call made while an earlier batch is still scanning does nothing.
+func (s *DiscoveryService) RunDueJobs() error {
+	if !s.dueRunning.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	// Archived workspaces are read-only, so their jobs wait until restored
+	var jobs []model.DiscoveryJob
+	if err := s.db.Where("interval_hours > 0 AND workspace_id IN (?)",
+		s.db.Model(&model.Workspace{}).Select("id").Where("status = ?", model.WorkspaceStatusActive)).
+		Find(&jobs).Error; err != nil {
+		s.dueRunning.Store(false)
+		return fmt.Errorf("failed to query discovery jobs: %w", err)
+	}
+
+	go func() {
+		defer s.dueRunning.Store(false)
+		for i := range jobs {
+			job := &jobs[i]
+			if job.LastRunAt != nil && time.Since(*job.LastRunAt) < time.Duration(job.IntervalHours)*time.Hour {
+				continue
+			}
+			if !s.markRunning(job) {
+				continue
+			}
+			s.runJob(job)
+		}
+	}()
+	return nil
+}
+
+// markRunning atomically moves a job to running; false if another run holds it.
+// A running job whose lease outlived the scan timeout was left behind by a
+// crashed process and is taken over.
+func (s *DiscoveryService) markRunning(job *model.DiscoveryJob) bool {
+	now := time.Now()
+	stale := now.Add(-(s.scanTimeout + discoveryLeaseGrace))
+	result := s.db.Model(&model.DiscoveryJob{}).
+		Where("id = ? AND (status <> ? OR started_at IS NULL OR started_at < ?)", job.ID, model.DiscoveryJobStatusRunning, stale).
+		Updates(map[string]any{"status": model.DiscoveryJobStatusRunning, "started_at": &now})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func (s *DiscoveryService) runJob(job *model.DiscoveryJob) {
+	found, err := s.scan(job)
+
+	now := time.Now()
+	updates := map[string]any{
+		"status":      model.DiscoveryJobStatusIdle,
+		"last_run_at": &now,
+		"last_found":  found,
+		"last_error":  "",
+	}
+	if err != nil {
+		updates["status"] = model.DiscoveryJobStatusFailed
+		updates["last_error"] = err.Error()
+		logger.Error("Discovery job failed", logger.Uint("job_id", job.ID), logger.Err(err))
+	} else {
+		logger.Info("Discovery job completed", logger.Uint("job_id", job.ID), logger.Int("found", found))
+	}
+	s.db.Model(&model.DiscoveryJob{}).Where("id = ?", job.ID).Updates(updates)
+}
+
+func (s *DiscoveryService) scan(job *model.DiscoveryJob) (int, error) {
+	var cidrs []string
+	if err := json.Unmarshal([]byte(job.CIDRs), &cidrs); err != nil {
+		return 0, fmt.Errorf("failed to parse cidrs: %w", err)
+	}
+	var ports []int
+	if err := json.Unmarshal([]byte(job.Ports), &ports); err != nil {
+		return 0, fmt.Errorf("failed to parse ports: %w", err)
+	}
+
+	hosts, err := discovery.ExpandCIDRs(cidrs, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	hints, err := s.sniHints(job.WorkspaceID)
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := discovery.NewScanner(discovery.Options{
+		Concurrency: job.Concurrency,
+		Timeout:     time.Duration(job.TimeoutSeconds) * time.Second,
+		SNIHints:    hints,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.scanTimeout)
+	defer cancel()
+
+	results := scanner.Scan(ctx, hosts, ports)
+	for i := range results {
+		if err := s.saveResult(job, &results[i]); err != nil {
+			return 0, err
+		}
+	}
+	return len(results), nil
+}
+
+// sniHints collects server names from the workspace's known certificates
+func (s *DiscoveryService) sniHints(workspaceID uint) ([]string, error) {
+	var domainsJSON []string
+	if err := s.db.Model(&model.Certificate{}).
+		Where("workspace_id = ?", workspaceID).
+		Pluck("domains", &domainsJSON).Error; err != nil {
+		return nil, fmt.Errorf("failed to load workspace domains: %w", err)
+	}
+
+	var all []string
+	for _, raw := range domainsJSON {
+		var domains []string
+		if err := json.Unmarshal([]byte(raw), &domains); err != nil {
+			continue
+		}
+		all = append(all, domains...)
+	}
+	return discovery.SNIHintsFromDomains(all), nil
+}
+
+// saveResult upserts a scan result keyed by workspace, endpoint, SNI and fingerprint
+func (s *DiscoveryService) saveResult(job *model.DiscoveryJob, r *discovery.Result) error {
+	now := time.Now()
+
+	var existing model.DiscoveredCertificate
+	err := s.db.Where("workspace_id = ? AND ip = ? AND port = ? AND server_name = ? AND fingerprint = ?",
+		job.WorkspaceID, r.IP, r.Port, r.ServerName, r.Fingerprint).First(&existing).Error
+	if err == nil {
+		return s.db.Model(&existing).Updates(map[string]any{
+			"job_id":       job.ID,
+			"last_seen_at": now,
+		}).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	sansJSON, err := json.Marshal(r.Leaf.DNSNames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sans: %w", err)
+	}
+
+	var chainPEM bytes.Buffer
+	pem.Encode(&chainPEM, &pem.Block{Type: "CERTIFICATE", Bytes: r.Leaf.Raw})
+	for _, c := range r.Chain {
+		pem.Encode(&chainPEM, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+
+	notBefore := r.Leaf.NotBefore
+	notAfter := r.Leaf.NotAfter
+	found := &model.DiscoveredCertificate{
+		JobID:        job.ID,
+		WorkspaceID:  job.WorkspaceID,
+		IP:           r.IP,
+		Port:         r.Port,
+		ServerName:   r.ServerName,
+		Fingerprint:  r.Fingerprint,
+		CommonName:   r.Leaf.Subject.CommonName,
+		Issuer:       r.Leaf.Issuer.String(),
+		SANs:         string(sansJSON),
+		SerialNumber: strings.ToUpper(hex.EncodeToString(r.Leaf.SerialNumber.Bytes())),
+		NotBefore:    &notBefore,
+		ExpiresAt:    &notAfter,
+		CertPEM:      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.Leaf.Raw})),
+		ChainPEM:     chainPEM.String(),
+		Status:       model.DiscoveredStatusNew,
+		FirstSeenAt:  now,
+		LastSeenAt:   now,
+	}
+
+	// A fingerprint the workspace already manages is linked right away
+	var managed model.Certificate
+	if err := s.db.Where("workspace_id = ? AND fingerprint = ?", job.WorkspaceID, r.Fingerprint).
+		First(&managed).Error; err == nil {
+		found.CertificateID = &managed.ID
+		found.Status = model.DiscoveredStatusManaged
+		if managed.Source == model.CertificateSourceDiscovered {
+			found.Status = model.DiscoveredStatusTracked
+		}
+	}
+
+	return s.db.Create(found).Error
+}
+
+// ListDiscovered returns the discovered certificate inventory of a workspace
+func (s *DiscoveryService) ListDiscovered(params pagination.Params, workspaceID, userID uint, status string) (*pagination.Result[model.DiscoveredCertificate], error) {
+	if !s.workspaceSvc.CanViewCertificates(workspaceID, userID) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	query := s.db.Model(&model.DiscoveredCertificate{}).Where("workspace_id = ?", workspaceID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var items []model.DiscoveredCertificate
+	if err := query.Order("expires_at ASC").
+		Offset(params.Offset()).
+		Limit(params.Limit()).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	result := pagination.NewResult(items, total, params)
+	return &result, nil
+}
+
+// Manage creates an ACME-managed certificate with the same SANs as a discovered one
+func (s *DiscoveryService) Manage(discoveredID, userID uint, req *ManageDiscoveredRequest) (*CreateCertificateResponse, error) {
+	found, err := s.getDiscoveredForUpdate(discoveredID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sans []string
+	if err := json.Unmarshal([]byte(found.SANs), &sans); err != nil || len(sans) == 0 {
+		return nil, ErrDiscoveredCertNoSANs
+	}
+
+	workspaceID := found.WorkspaceID
+	resp, err := s.certSvc.Create(&CreateCertificateRequest{
+		Name:        found.CommonName,
+		Domains:     sans,
+		Email:       req.Email,
+		KeyType:     req.KeyType,
+		WorkspaceID: &workspaceID,
+	}, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Certificate != nil {
+		s.link(found, resp.Certificate.ID, model.DiscoveredStatusManaged)
+	}
+	return resp, nil
+}
+
+// Track imports a discovered certificate for expiry tracking without issuing a new one
+func (s *DiscoveryService) Track(discoveredID, userID uint) (*model.Certificate, error) {
+	found, err := s.getDiscoveredForUpdate(discoveredID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sans := found.SANs
+	if sans == "" || sans == "null" {
+		sans = "[]"
+	}
+
+	workspaceID := found.WorkspaceID
+	createdBy := userID
+	cert := &model.Certificate{
+		WorkspaceID:  &workspaceID,
+		CreatedBy:    &createdBy,
+		Name:         fmt.Sprintf("%s (%s:%d)", found.CommonName, found.IP, found.Port),
+		Domains:      sans,
+		KeyType:      model.KeyTypeRSA,
+		IssueMode:    model.IssueModeCombined,
+		Status:       model.CertificateStatusReady,
+		Source:       model.CertificateSourceDiscovered,
+		CertPEM:      found.CertPEM,
+		ChainPEM:     found.ChainPEM,
+		SerialNumber: found.SerialNumber,
+		Fingerprint:  found.Fingerprint,
+		IssuedAt:     found.NotBefore,
+		ExpiresAt:    found.ExpiresAt,
+	}
+	if err := s.db.Create(cert).Error; err != nil {
+		return nil, fmt.Errorf("failed to create tracked certificate: %w", err)
+	}
+
+	s.link(found, cert.ID, model.DiscoveredStatusTracked)
+	return cert, nil
+}
+
+// Ignore hides a discovered certificate from the default inventory view
+func (s *DiscoveryService) Ignore(discoveredID, userID uint) error {
+	found, err := s.getDiscoveredForUpdate(discoveredID, userID)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(found).Update("status", model.DiscoveredStatusIgnored).Error
+}
+
+func (s *DiscoveryService) link(found *model.DiscoveredCertificate, certID uint, status model.DiscoveredCertificateStatus) {
+	// Every sighting of the same certificate in the workspace points at the new record
+	s.db.Model(&model.DiscoveredCertificate{}).
+		Where("workspace_id = ? AND fingerprint = ?", found.WorkspaceID, found.Fingerprint).
+		Updates(map[string]any{
+			"certificate_id": certID,
+			"status":         status,
+		})
+}
+
+func (s *DiscoveryService) getDiscoveredForUpdate(discoveredID, userID uint) (*model.DiscoveredCertificate, error) {
+	var found model.DiscoveredCertificate
+	if err := s.db.First(&found, discoveredID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDiscoveredCertNotFound
+		}
+		return nil, err
+	}
+	if !s.workspaceSvc.CanManageCertificates(found.WorkspaceID, userID) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+	if found.CertificateID != nil {
+		return nil, ErrDiscoveredCertLinked
+	}
+	return &found, nil
+}
+
+func (s *DiscoveryService) getJob(jobID uint) (*model.DiscoveryJob, error) {
+	var job model.DiscoveryJob
+	if err := s.db.First(&job, jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDiscoveryJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
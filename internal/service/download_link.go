@@ -0,0 +1,231 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDownloadLinkInvalid     = errors.New("download link is invalid, expired or already used")
+	ErrDownloadLinkUnsupported = errors.New("unsupported download format")
+	ErrDownloadLinkNoPassword  = errors.New("storing a download password requires an encryption master key")
+)
+
+const (
+	defaultDownloadLinkTTL = 15 * time.Minute
+	maxDownloadLinkTTL     = 24 * time.Hour
+)
+
+// DownloadLinkService creates and redeems single-use certificate download links
+type DownloadLinkService struct {
+	db           *gorm.DB
+	certSvc      *CertificateService
+	workspaceSvc *WorkspaceService
+	keyExportSvc *KeyExportService
+	encryptor    *crypto.Encryptor // nil in mock mode; links then cannot carry a password
+}
+
+// NewDownloadLinkService creates a new DownloadLinkService
+func NewDownloadLinkService(db *gorm.DB, certSvc *CertificateService, workspaceSvc *WorkspaceService, keyExportSvc *KeyExportService, encryptor *crypto.Encryptor) *DownloadLinkService {
+	return &DownloadLinkService{
+		db:           db,
+		certSvc:      certSvc,
+		workspaceSvc: workspaceSvc,
+		keyExportSvc: keyExportSvc,
+		encryptor:    encryptor,
+	}
+}
+
+type CreateDownloadLinkRequest struct {
+	Format         string `json:"format" binding:"required,oneof=pem fullchain pfx zip jks jceks p7b der pkcs8 haproxy k8s profile"`
+	Password       string `json:"password" binding:"max=128"` // PFX/JKS/JCEKS password or PKCS#8 passphrase
+	Alias          string `json:"alias" binding:"max=255"`
+	Namespace      string `json:"namespace" binding:"max=63"`
+	TTLMinutes     int    `json:"ttl_minutes" binding:"omitempty,min=1,max=1440"` // Default 15
+	Reason         string `json:"reason" binding:"max=255"`                       // Key export reason, if the workspace asks for one
+	ReauthPassword string `json:"reauth_password"`                                // Login password, for step-up key export policies
+	StepUpToken    string `json:"step_up_token"`                                  // Step-up grant, required instead once 2FA is set up
+}
+
+// CreateDownloadLinkResponse carries the raw token, which is only shown once
+type CreateDownloadLinkResponse struct {
+	Token string              `json:"token"`
+	URL   string              `json:"url"` // Path to hand out, relative to the console origin
+	Link  *model.DownloadLink `json:"link"`
+}
+
+// CreateLink issues a download link. Formats with the private key follow the
+// workspace key export policy, the others need a certificate manager. The bundle
+// is built once up front so a bad password or alias fails here, not on redemption.
+func (s *DownloadLinkService) CreateLink(certID, userID uint, req *CreateDownloadLinkRequest) (*CreateDownloadLinkResponse, error) {
+	var cert *model.Certificate
+	var err error
+	if IsPrivateKeyFormat(req.Format) {
+		if err = s.keyExportSvc.Authorize(certID, userID, &KeyExportRequest{
+			Format:         req.Format,
+			Password:       req.Password,
+			Reason:         req.Reason,
+			ReauthPassword: req.ReauthPassword,
+			StepUpToken:    req.StepUpToken,
+		}); err != nil {
+			return nil, err
+		}
+		cert, err = s.certSvc.GetByID(certID)
+	} else {
+		cert, err = loadManagedCertificate(s.db, s.workspaceSvc, certID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cert.Status != model.CertificateStatusReady {
+		return nil, ErrCertificateNotReady
+	}
+	if req.Password != "" && s.encryptor == nil {
+		return nil, ErrDownloadLinkNoPassword
+	}
+
+	opts := BundleOptions{Password: req.Password, Alias: req.Alias, Namespace: req.Namespace}
+	if _, _, err := s.certSvc.GetCertificateBundle(certID, req.Format, opts); err != nil {
+		if errors.Is(err, ErrInvalidBundleOptions) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrDownloadLinkUnsupported, err)
+	}
+
+	raw, err := generateDownloadLinkToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	ttl := defaultDownloadLinkTTL
+	if req.TTLMinutes > 0 {
+		ttl = min(time.Duration(req.TTLMinutes)*time.Minute, maxDownloadLinkTTL)
+	}
+
+	link := &model.DownloadLink{
+		CertificateID: certID,
+		Format:        req.Format,
+		Alias:         req.Alias,
+		Namespace:     req.Namespace,
+		Reason:        truncate(req.Reason, 255),
+		TokenHash:     hashDeployToken(raw),
+		TokenHint:     raw[:len(model.DownloadLinkPrefix)+6],
+		CreatedBy:     userID,
+		ExpiresAt:     time.Now().Add(ttl),
+	}
+	if req.Password != "" {
+		if link.Password, err = s.encryptor.Encrypt([]byte(req.Password)); err != nil {
+			return nil, fmt.Errorf("failed to encrypt password: %w", err)
+		}
+	}
+
+	if err := s.db.Create(link).Error; err != nil {
+		return nil, fmt.Errorf("failed to create download link: %w", err)
+	}
+
+	return &CreateDownloadLinkResponse{
+		Token: raw,
+		URL:   "/api/v1/download/" + raw,
+		Link:  link,
+	}, nil
+}
+
+// ListLinks returns the download links of a certificate with their redemption attempts
+func (s *DownloadLinkService) ListLinks(certID, userID uint) ([]model.DownloadLink, error) {
+	if _, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID); err != nil {
+		return nil, err
+	}
+
+	var links []model.DownloadLink
+	if err := s.db.Preload("Redemptions").
+		Where("certificate_id = ?", certID).
+		Order("created_at DESC").
+		Limit(100).
+		Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// Redeem consumes a link and returns the bundle with its file name and format.
+// The link is marked used before the bundle is built, so two concurrent
+// requests cannot both succeed. Every attempt on a known link is recorded.
+func (s *DownloadLinkService) Redeem(rawToken, clientIP, userAgent string) ([]byte, string, string, error) {
+	var link model.DownloadLink
+	if err := s.db.Where("token_hash = ?", hashDeployToken(rawToken)).First(&link).Error; err != nil {
+		return nil, "", "", ErrDownloadLinkInvalid
+	}
+
+	now := time.Now()
+	result := s.db.Model(&model.DownloadLink{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", link.ID, now).
+		Update("used_at", &now)
+	if result.Error != nil {
+		return nil, "", "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		reason := "expired"
+		if link.UsedAt != nil {
+			reason = "already used"
+		}
+		s.recordRedemption(&link, clientIP, userAgent, false, reason)
+		return nil, "", "", ErrDownloadLinkInvalid
+	}
+
+	opts := BundleOptions{Alias: link.Alias, Namespace: link.Namespace}
+	if link.Password != "" {
+		password, err := s.encryptor.Decrypt(link.Password)
+		if err != nil {
+			s.recordRedemption(&link, clientIP, userAgent, false, "failed to decrypt password")
+			return nil, "", "", fmt.Errorf("failed to decrypt password: %w", err)
+		}
+		opts.Password = string(password)
+	}
+
+	data, filename, err := s.certSvc.GetCertificateBundle(link.CertificateID, link.Format, opts)
+	if err != nil {
+		s.recordRedemption(&link, clientIP, userAgent, false, truncate(err.Error(), 255))
+		return nil, "", "", err
+	}
+
+	s.recordRedemption(&link, clientIP, userAgent, true, "")
+	if IsPrivateKeyFormat(link.Format) {
+		s.keyExportSvc.Record(link.CertificateID, link.CreatedBy, link.Format, link.Reason, clientIP, KeyExportViaDownloadLink)
+	}
+	return data, filename, link.Format, nil
+}
+
+func (s *DownloadLinkService) recordRedemption(link *model.DownloadLink, clientIP, userAgent string, success bool, reason string) {
+	entry := &model.DownloadLinkRedemption{
+		LinkID:    link.ID,
+		IP:        clientIP,
+		UserAgent: truncate(userAgent, 255),
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		logger.Error("Failed to record download link redemption", logger.Uint("link_id", link.ID), logger.Err(err))
+	}
+	logger.Info("Download link redeemed",
+		logger.Uint("link_id", link.ID),
+		logger.Uint("cert_id", link.CertificateID),
+		logger.String("ip", clientIP),
+		logger.String("result", map[bool]string{true: "success", false: reason}[success]),
+	)
+}
+
+func generateDownloadLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return model.DownloadLinkPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+// mappableRoles are the workspace roles external groups may grant, strongest first
+var mappableRoles = []string{
+	model.WorkspaceRoleAdmin,
+	model.WorkspaceRoleOperator,
+	model.WorkspaceRoleMember,
+	model.WorkspaceRoleViewer,
+}
+
+// groupGlobalRole maps groups to RoleAdmin or RoleUser. Without admin groups
+// the identity provider does not manage roles and ok is false.
+func groupGlobalRole(adminGroups, groups []string) (role string, ok bool) {
+	if len(adminGroups) == 0 {
+		return "", false
+	}
+	for _, group := range adminGroups {
+		if containsString(groups, group) {
+			return model.RoleAdmin, true
+		}
+	}
+	return model.RoleUser, true
+}
+
+// groupWorkspaceRoles returns the strongest mapped role per workspace
+func groupWorkspaceRoles(mappings []config.WorkspaceMapping, groups []string) map[uint]string {
+	rank := func(role string) int {
+		for i, r := range mappableRoles {
+			if r == role {
+				return i
+			}
+		}
+		return len(mappableRoles)
+	}
+
+	roles := make(map[uint]string)
+	for _, m := range mappings {
+		if rank(m.Role) == len(mappableRoles) || !containsString(groups, m.Group) {
+			continue
+		}
+		if current, ok := roles[m.WorkspaceID]; !ok || rank(m.Role) < rank(current) {
+			roles[m.WorkspaceID] = m.Role
+		}
+	}
+	return roles
+}
+
+// syncGroupMemberships adds, updates and removes the memberships a source
+// granted in the workspaces it maps. Memberships from anywhere else, including
+// those added by hand, are left alone.
+func syncGroupMemberships(tx *gorm.DB, userID uint, source string, mappings []config.WorkspaceMapping, groups []string) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+	want := groupWorkspaceRoles(mappings, groups)
+	managed := make([]uint, 0, len(mappings))
+	for _, m := range mappings {
+		managed = append(managed, m.WorkspaceID)
+	}
+
+	var members []model.WorkspaceMember
+	if err := tx.Where("user_id = ? AND workspace_id IN ?", userID, managed).Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		role, wanted := want[member.WorkspaceID]
+		delete(want, member.WorkspaceID)
+		if member.Source != source {
+			continue
+		}
+		switch {
+		case !wanted:
+			if err := tx.Delete(&member).Error; err != nil {
+				return err
+			}
+		case member.Role != role:
+			if err := tx.Model(&member).Updates(map[string]interface{}{"role": role, "custom_role_id": nil}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for workspaceID, role := range want {
+		var count int64
+		tx.Model(&model.Workspace{}).Where("id = ?", workspaceID).Count(&count)
+		if count == 0 {
+			logger.Warn("Group mapping points to a missing workspace",
+				logger.String("source", source),
+				logger.Uint("workspace_id", workspaceID),
+			)
+			continue
+		}
+		member := &model.WorkspaceMember{
+			WorkspaceID: workspaceID,
+			UserID:      userID,
+			Role:        role,
+			Source:      source,
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return fmt.Errorf("failed to add workspace member: %w", err)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestGroupRoleMapping(t *testing.T) {
+	adminGroups := []string{"pki-admins"}
+	mappings := []config.WorkspaceMapping{
+		{Group: "platform", WorkspaceID: 1, Role: model.WorkspaceRoleViewer},
+		{Group: "pki-admins", WorkspaceID: 1, Role: model.WorkspaceRoleAdmin},
+		{Group: "platform", WorkspaceID: 2, Role: model.WorkspaceRoleOperator},
+		{Group: "platform", WorkspaceID: 3, Role: model.WorkspaceRoleOwner}, // Never mappable
+		{Group: "finance", WorkspaceID: 4, Role: model.WorkspaceRoleMember},
+	}
+
+	if role, ok := groupGlobalRole(adminGroups, []string{"platform", "pki-admins"}); !ok || role != model.RoleAdmin {
+		t.Errorf("global role = %q, %v; want admin", role, ok)
+	}
+	if role, ok := groupGlobalRole(adminGroups, []string{"platform"}); !ok || role != model.RoleUser {
+		t.Errorf("global role = %q, %v; want user", role, ok)
+	}
+	if _, ok := groupGlobalRole(nil, []string{"pki-admins"}); ok {
+		t.Error("without admin_groups the provider must not manage roles")
+	}
+
+	got := groupWorkspaceRoles(mappings, []string{"platform", "pki-admins"})
+	want := map[uint]string{1: model.WorkspaceRoleAdmin, 2: model.WorkspaceRoleOperator}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("workspace roles = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/deployer"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+// Annotations read from Ingress and Gateway resources
+const (
+	AnnotationWorkspace = "acme-console/workspace" // Workspace ID; required to opt in
+	AnnotationEmail     = "acme-console/email"     // ACME account email
+	AnnotationKeyType   = "acme-console/key-type"  // RSA or ECC
+)
+
+// ingressRetryBackoff keeps a failing resource from creating an ACME order on every resync
+const ingressRetryBackoff = time.Hour
+
+// IngressOptions configures the Ingress/Gateway controller
+type IngressOptions struct {
+	Kubeconfig []byte // Empty = in-cluster service account
+	Namespace  string // Empty = all namespaces
+	Email      string // Default ACME email
+	Workspaces []config.IngressWorkspaceBinding
+}
+
+// IngressService issues certificates for annotated Ingresses and Gateways in
+// their workspace and attaches a Kubernetes deployment target that writes the
+// referenced Secret once the certificate is ready.
+type IngressService struct {
+	db        *gorm.DB
+	certSvc   *CertificateService
+	encryptor *crypto.Encryptor
+	kube      *deployer.KubernetesDeployer
+	opts      IngressOptions
+}
+
+// NewIngressService creates a new IngressService
+func NewIngressService(db *gorm.DB, certSvc *CertificateService, encryptor *crypto.Encryptor, opts IngressOptions) *IngressService {
+	return &IngressService{
+		db:        db,
+		certSvc:   certSvc,
+		encryptor: encryptor,
+		kube:      deployer.NewKubernetesDeployer(),
+		opts:      opts,
+	}
+}
+
+// Sync reconciles the annotated resources of the cluster with their certificates:
+// new or changed hosts get a new certificate, removed resources release theirs,
+// and pending certificates are verified once their DNS records are in place.
+func (s *IngressService) Sync(ctx context.Context) error {
+	if s.encryptor == nil {
+		return ErrDeploymentNotAvailable
+	}
+
+	requests, err := s.kube.ListTLSRequests(ctx, s.cluster(), AnnotationWorkspace)
+	if err != nil {
+		return err
+	}
+
+	var bindings []model.IngressBinding
+	if err := s.db.Find(&bindings).Error; err != nil {
+		return fmt.Errorf("failed to load ingress bindings: %w", err)
+	}
+	byKey := make(map[string]*model.IngressBinding, len(bindings))
+	for i := range bindings {
+		byKey[bindingKey(&bindings[i])] = &bindings[i]
+	}
+
+	seen := make(map[string]bool, len(requests))
+	for i := range requests {
+		req := &requests[i]
+		seen[req.Source()] = true
+		s.reconcile(req, byKey[req.Source()])
+	}
+
+	for key, binding := range byKey {
+		if !seen[key] {
+			s.release(binding)
+		}
+	}
+
+	s.verifyPending()
+	return nil
+}
+
+func (s *IngressService) reconcile(req *deployer.TLSRequest, binding *model.IngressBinding) {
+	if binding == nil {
+		binding = &model.IngressBinding{
+			Kind:       req.Kind,
+			Namespace:  req.Namespace,
+			Name:       req.Name,
+			SecretName: req.SecretName,
+		}
+	}
+
+	err := s.ensureCertificate(req, binding)
+	if err != nil {
+		logger.Error("Failed to reconcile ingress certificate",
+			logger.String("source", req.Source()),
+			logger.Err(err),
+		)
+		binding.LastError = truncate(err.Error(), 1000)
+	} else {
+		binding.LastError = ""
+	}
+
+	if err := s.db.Save(binding).Error; err != nil {
+		logger.Error("Failed to save ingress binding", logger.String("source", req.Source()), logger.Err(err))
+	}
+}
+
+// ensureCertificate requests a certificate unless the binding already has one
+// for the same workspace and hosts
+func (s *IngressService) ensureCertificate(req *deployer.TLSRequest, binding *model.IngressBinding) error {
+	wsID, err := strconv.ParseUint(strings.TrimSpace(req.Annotations[AnnotationWorkspace]), 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %q", AnnotationWorkspace, req.Annotations[AnnotationWorkspace])
+	}
+	// Anyone who can annotate a resource could otherwise issue in any workspace
+	if !namespaceBound(s.opts.Workspaces, req.Namespace, uint(wsID)) {
+		return fmt.Errorf("namespace %s is not bound to workspace %d in ingress.workspaces", req.Namespace, wsID)
+	}
+	var ws model.Workspace
+	if err := s.db.First(&ws, wsID).Error; err != nil {
+		return fmt.Errorf("workspace %d not found", wsID)
+	}
+	if ws.Status == model.WorkspaceStatusArchived {
+		return fmt.Errorf("workspace %d is archived", wsID)
+	}
+
+	hosts, err := json.Marshal(req.Hosts)
+	if err != nil {
+		return err
+	}
+
+	unchanged := binding.WorkspaceID == ws.ID && binding.Hosts == string(hosts)
+	if unchanged && binding.CertificateID != 0 {
+		var cert model.Certificate
+		if err := s.db.First(&cert, binding.CertificateID).Error; err == nil {
+			if cert.Status == model.CertificateStatusFailed {
+				return fmt.Errorf("certificate %d failed; delete it or change the hosts to request a new one", cert.ID)
+			}
+			return nil
+		}
+		// The certificate was deleted: request a new one
+	}
+	if unchanged && binding.LastError != "" && binding.LastAttemptAt != nil && time.Since(*binding.LastAttemptAt) < ingressRetryBackoff {
+		return errors.New(binding.LastError)
+	}
+
+	email := req.Annotations[AnnotationEmail]
+	if email == "" {
+		email = s.opts.Email
+	}
+	if email == "" {
+		return fmt.Errorf("no ACME email: set the %s annotation or ingress.email", AnnotationEmail)
+	}
+	keyType := strings.ToUpper(req.Annotations[AnnotationKeyType])
+	if keyType != "" && keyType != "RSA" && keyType != "ECC" {
+		return fmt.Errorf("invalid %s annotation: %q", AnnotationKeyType, keyType)
+	}
+
+	now := time.Now()
+	binding.LastAttemptAt = &now
+	binding.WorkspaceID = ws.ID
+	binding.Hosts = string(hosts)
+
+	// Certificates are owned by the workspace owner, like any other certificate in the workspace
+	resp, err := s.certSvc.Create(&CreateCertificateRequest{
+		Name:        req.Source(),
+		Domains:     req.Hosts,
+		Email:       email,
+		KeyType:     keyType,
+		WorkspaceID: &binding.WorkspaceID,
+		IssueMode:   string(model.IssueModeCombined),
+	}, ws.OwnerID)
+	if err != nil {
+		return fmt.Errorf("failed to request certificate: %w", err)
+	}
+	cert := resp.Certificate
+
+	if err := s.certSvc.EnableAutoRenew(cert.ID, true, 0); err != nil {
+		logger.Error("Failed to enable auto-renew", logger.Uint("cert_id", cert.ID), logger.Err(err))
+	}
+
+	target, err := s.createSecretTarget(cert.ID, req, ws.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	// The previous certificate keeps serving from the Secret until the new one
+	// is issued; only its target is removed so it no longer writes there.
+	if binding.TargetID != 0 {
+		s.db.Delete(&model.DeploymentTarget{}, binding.TargetID)
+	}
+	binding.CertificateID = cert.ID
+	binding.TargetID = target.ID
+
+	logger.Info("Requested certificate for ingress",
+		logger.String("source", req.Source()),
+		logger.Uint("cert_id", cert.ID),
+	)
+	return nil
+}
+
+func (s *IngressService) createSecretTarget(certID uint, req *deployer.TLSRequest, createdBy uint) (*model.DeploymentTarget, error) {
+	target := &model.DeploymentTarget{
+		CertificateID: certID,
+		Name:          truncate(fmt.Sprintf("%s %s/%s", req.Kind, req.Namespace, req.Name), 100),
+		Type:          model.DeploymentTargetKubernetes,
+		Enabled:       true,
+		InCluster:     len(s.opts.Kubeconfig) == 0,
+		Namespace:     req.Namespace,
+		SecretName:    req.SecretName,
+		CreatedBy:     createdBy,
+	}
+	if !target.InCluster {
+		encrypted, err := s.encryptor.Encrypt(s.opts.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt kubeconfig: %w", err)
+		}
+		target.Kubeconfig = encrypted
+	}
+	if err := s.db.Create(target).Error; err != nil {
+		return nil, fmt.Errorf("failed to create deployment target: %w", err)
+	}
+	return target, nil
+}
+
+// release forgets a resource that was deleted or lost its annotation. The
+// certificate stays in the workspace; only the Secret is no longer written.
+func (s *IngressService) release(binding *model.IngressBinding) {
+	if binding.TargetID != 0 {
+		s.db.Delete(&model.DeploymentTarget{}, binding.TargetID)
+	}
+	if err := s.db.Delete(binding).Error; err != nil {
+		logger.Error("Failed to delete ingress binding", logger.Uint("binding_id", binding.ID), logger.Err(err))
+		return
+	}
+	logger.Info("Released ingress certificate",
+		logger.String("source", bindingKey(binding)),
+		logger.Uint("cert_id", binding.CertificateID),
+	)
+}
+
+// verifyPending finalizes pending certificates whose DNS records resolve.
+// Issuance triggers the deployment that writes the Secret.
+func (s *IngressService) verifyPending() {
+	var certIDs []uint
+	if err := s.db.Model(&model.Certificate{}).
+		Where("status = ? AND id IN (?)", model.CertificateStatusPending,
+			s.db.Model(&model.IngressBinding{}).Select("certificate_id")).
+		Pluck("id", &certIDs).Error; err != nil {
+		logger.Error("Failed to load pending ingress certificates", logger.Err(err))
+		return
+	}
+
+	for _, id := range certIDs {
+		if _, ready, err := s.certSvc.PreVerifyDNS(id); err != nil || !ready {
+			continue
+		}
+		if _, err := s.certSvc.Verify(id); err != nil {
+			logger.Error("Failed to verify ingress certificate", logger.Uint("cert_id", id), logger.Err(err))
+		}
+	}
+}
+
+func (s *IngressService) cluster() *deployer.KubernetesTarget {
+	return &deployer.KubernetesTarget{
+		Kubeconfig: s.opts.Kubeconfig,
+		InCluster:  len(s.opts.Kubeconfig) == 0,
+		Namespace:  s.opts.Namespace,
+	}
+}
+
+// namespaceBound reports whether resources in the namespace may issue in the workspace
+func namespaceBound(bindings []config.IngressWorkspaceBinding, namespace string, workspaceID uint) bool {
+	for _, b := range bindings {
+		if b.Namespace == namespace && b.WorkspaceID == workspaceID {
+			return true
+		}
+	}
+	return false
+}
+
+func bindingKey(b *model.IngressBinding) string {
+	req := deployer.TLSRequest{Kind: b.Kind, Namespace: b.Namespace, Name: b.Name, SecretName: b.SecretName}
+	return req.Source()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/config"
+)
+
+func TestNamespaceBound(t *testing.T) {
+	bindings := []config.IngressWorkspaceBinding{
+		{Namespace: "team-a", WorkspaceID: 2},
+		{Namespace: "shared", WorkspaceID: 2},
+		{Namespace: "shared", WorkspaceID: 3},
+	}
+
+	cases := []struct {
+		namespace   string
+		workspaceID uint
+		want        bool
+	}{
+		{"team-a", 2, true},
+		{"team-a", 3, false},
+		{"shared", 3, true},
+		{"team-b", 2, false},
+	}
+	for _, tc := range cases {
+		if got := namespaceBound(bindings, tc.namespace, tc.workspaceID); got != tc.want {
+			t.Errorf("namespaceBound(%q, %d) = %v, want %v", tc.namespace, tc.workspaceID, got, tc.want)
+		}
+	}
+	if namespaceBound(nil, "team-a", 2) {
+		t.Error("no bindings should bind nothing")
+	}
+}
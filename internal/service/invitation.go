@@ -0,0 +1,516 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvitationInvalid       = errors.New("invitation is invalid, expired or already used")
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvitationEmailRequired = errors.New("an email address is required to send the invitation")
+	ErrMailNotConfigured       = errors.New("email delivery is not configured, share the invitation link instead")
+	ErrUsernameTaken           = errors.New("username already exists")
+)
+
+const (
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	maxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+// Mailer is implemented by mailer.Mailer
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// InvitationService invites people to workspaces by email or by a link. The
+// link is the same either way: whoever opens it can sign up, log in with an
+// existing account or use single sign-on, and joins with the invited role.
+type InvitationService struct {
+	db           *gorm.DB
+	workspaceSvc *WorkspaceService
+	settingSvc   *SettingService
+	auditSvc     *AuditService
+	mailer       Mailer
+	consoleURL   string
+}
+
+// NewInvitationService creates a new InvitationService
+func NewInvitationService(db *gorm.DB, workspaceSvc *WorkspaceService, settingSvc *SettingService, auditSvc *AuditService) *InvitationService {
+	return &InvitationService{
+		db:           db,
+		workspaceSvc: workspaceSvc,
+		settingSvc:   settingSvc,
+		auditSvc:     auditSvc,
+	}
+}
+
+// SetMailer enables invitation emails. consoleURL is the console's public
+// origin, which the emailed links point to.
+func (s *InvitationService) SetMailer(mailer Mailer, consoleURL string) {
+	s.mailer = mailer
+	s.consoleURL = strings.TrimRight(consoleURL, "/")
+}
+
+// MailEnabled reports whether invitations can be emailed
+func (s *InvitationService) MailEnabled() bool {
+	return s.mailer != nil
+}
+
+type CreateInvitationRequest struct {
+	Email         string `json:"email" binding:"omitempty,email,max=100"`
+	Role          string `json:"role" binding:"required,oneof=admin operator member viewer custom"`
+	CustomRoleID  *uint  `json:"custom_role_id"`                                   // Required when role is custom
+	ExpiresInDays int    `json:"expires_in_days" binding:"omitempty,min=1,max=30"` // Default 7
+	SendEmail     bool   `json:"send_email"`                                       // Email the link to Email
+}
+
+type InvitationResponse struct {
+	ID             uint   `json:"id"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	CustomRoleID   *uint  `json:"custom_role_id,omitempty"`
+	CustomRoleName string `json:"custom_role_name,omitempty"`
+	TokenHint      string `json:"token_hint"`
+	InvitedBy      uint   `json:"invited_by"`
+	InviterName    string `json:"inviter_name"`
+	EmailSent      bool   `json:"email_sent"`
+	Expired        bool   `json:"expired"`
+	ExpiresAt      string `json:"expires_at"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// CreateInvitationResponse carries the raw token, which is only shown once
+type CreateInvitationResponse struct {
+	Token      string             `json:"token"`
+	URL        string             `json:"url"`                   // Page to hand out, relative to the console origin
+	EmailError string             `json:"email_error,omitempty"` // The invitation exists, but the email could not be sent
+	Invitation InvitationResponse `json:"invitation"`
+}
+
+// InvitationPreview is what the invitation page shows before anyone logs in
+type InvitationPreview struct {
+	WorkspaceName  string `json:"workspace_name"`
+	Role           string `json:"role"`
+	CustomRoleName string `json:"custom_role_name,omitempty"`
+	InviterName    string `json:"inviter_name"`
+	Email          string `json:"email"`
+	ExpiresAt      string `json:"expires_at"`
+}
+
+type InvitationSignupRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Password string `json:"password" binding:"required"`
+	Nickname string `json:"nickname" binding:"max=100"`
+	Email    string `json:"email" binding:"omitempty,email,max=100"` // Default: the invited address
+}
+
+// Create invites someone to a workspace (member.manage). Like AddMember, the
+// role's permissions must be a subset of the caller's own. When the email
+// cannot be sent the invitation is kept, so its link can be shared instead.
+// Returns the invitation as changes.
+func (s *InvitationService) Create(workspaceID, userID uint, req *CreateInvitationRequest) (*CreateInvitationResponse, AuditChanges, error) {
+	actor, err := s.workspaceSvc.memberManager(workspaceID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	customRoleID, err := s.workspaceSvc.assignableRole(workspaceID, actor, req.Role, req.CustomRoleID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if req.SendEmail {
+		if email == "" {
+			return nil, nil, ErrInvitationEmailRequired
+		}
+		if s.mailer == nil {
+			return nil, nil, ErrMailNotConfigured
+		}
+	}
+	if email != "" {
+		var count int64
+		s.db.Model(&model.WorkspaceMember{}).
+			Joins("JOIN users ON users.id = workspace_members.user_id").
+			Where("workspace_members.workspace_id = ? AND users.email = ?", workspaceID, email).
+			Count(&count)
+		if count > 0 {
+			return nil, nil, ErrUserAlreadyMember
+		}
+	}
+
+	raw, err := generateInvitationToken()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	ttl := defaultInvitationTTL
+	if req.ExpiresInDays > 0 {
+		ttl = min(time.Duration(req.ExpiresInDays)*24*time.Hour, maxInvitationTTL)
+	}
+
+	invitation := &model.WorkspaceInvitation{
+		WorkspaceID:  workspaceID,
+		Email:        email,
+		Role:         req.Role,
+		CustomRoleID: customRoleID,
+		TokenHash:    hashDeployToken(raw),
+		TokenHint:    raw[:len(model.WorkspaceInvitationPrefix)+6],
+		InvitedBy:    userID,
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+	if err := s.db.Create(invitation).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	resp := &CreateInvitationResponse{Token: raw, URL: invitationPath(raw)}
+	if req.SendEmail {
+		if err := s.sendEmail(invitation, raw); err != nil {
+			logger.Warn("Failed to send invitation email",
+				logger.Uint("invitation_id", invitation.ID),
+				logger.Uint("workspace_id", workspaceID),
+				logger.Err(err),
+			)
+			resp.EmailError = err.Error()
+		} else {
+			now := time.Now()
+			invitation.EmailSentAt = &now
+			s.db.Model(invitation).Update("email_sent_at", &now)
+		}
+	}
+
+	if err := s.db.Preload("Inviter").Preload("CustomRole").First(invitation, invitation.ID).Error; err != nil {
+		return nil, nil, err
+	}
+	resp.Invitation = newInvitationResponse(invitation)
+	return resp, DiffFields(nil, invitationFields(invitation)), nil
+}
+
+// List returns a workspace's pending invitations, expired ones included
+// until they are revoked (member.manage)
+func (s *InvitationService) List(workspaceID, userID uint) ([]InvitationResponse, error) {
+	if _, err := s.workspaceSvc.memberManager(workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	var invitations []model.WorkspaceInvitation
+	if err := s.db.Preload("Inviter").Preload("CustomRole").
+		Where("workspace_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", workspaceID).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]InvitationResponse, 0, len(invitations))
+	for i := range invitations {
+		result = append(result, newInvitationResponse(&invitations[i]))
+	}
+	return result, nil
+}
+
+// Revoke withdraws a pending invitation (member.manage) and returns it as changes
+func (s *InvitationService) Revoke(workspaceID, userID, invitationID uint) (AuditChanges, error) {
+	if _, err := s.workspaceSvc.memberManager(workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	var invitation model.WorkspaceInvitation
+	if err := s.db.Where("id = ? AND workspace_id = ?", invitationID, workspaceID).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+
+	result := s.db.Model(&model.WorkspaceInvitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitation.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvitationNotFound
+	}
+	return DiffFields(invitationFields(&invitation), nil), nil
+}
+
+// Preview describes a pending invitation to whoever holds its token
+func (s *InvitationService) Preview(rawToken string) (*InvitationPreview, error) {
+	invitation, err := s.pending(s.db, hashDeployToken(rawToken))
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &InvitationPreview{
+		Role:      invitation.Role,
+		Email:     invitation.Email,
+		ExpiresAt: invitation.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if invitation.Workspace != nil {
+		preview.WorkspaceName = invitation.Workspace.Name
+	}
+	if invitation.Inviter != nil {
+		preview.InviterName = displayName(invitation.Inviter)
+	}
+	if invitation.CustomRole != nil {
+		preview.CustomRoleName = invitation.CustomRole.Name
+	}
+	return preview, nil
+}
+
+// Accept adds a logged-in user to the invitation's workspace and returns the
+// invitation and the new membership as changes
+func (s *InvitationService) Accept(rawToken string, userID uint) (*model.WorkspaceInvitation, AuditChanges, error) {
+	var invitation *model.WorkspaceInvitation
+	var member *model.WorkspaceMember
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = s.pending(tx, hashDeployToken(rawToken)); err != nil {
+			return err
+		}
+		member, err = s.accept(tx, invitation, userID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return invitation, DiffFields(nil, memberFields(member)), nil
+}
+
+// Signup creates a local account for the invitee and adds it to the
+// workspace. The password must already have passed the password policy.
+func (s *InvitationService) Signup(rawToken string, req *InvitationSignupRequest) (*model.User, *model.WorkspaceInvitation, error) {
+	var user *model.User
+	var invitation *model.WorkspaceInvitation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = s.pending(tx, hashDeployToken(rawToken)); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.User{}).Where("username = ?", req.Username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUsernameTaken
+		}
+
+		email := req.Email
+		if email == "" {
+			email = invitation.Email
+		}
+		user = &model.User{
+			Username: req.Username,
+			Nickname: req.Nickname,
+			Email:    email,
+			Role:     model.RoleUser,
+			Status:   1,
+
+			// The invitation reached its recipient at the invited address
+			EmailVerified: invitation.Email != "" && strings.EqualFold(email, invitation.Email),
+		}
+		if err := user.SetPassword(req.Password); err != nil {
+			return err
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+
+		_, err = s.accept(tx, invitation, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Info("User signed up from invitation",
+		logger.Uint("invitation_id", invitation.ID),
+		logger.Uint("workspace_id", invitation.WorkspaceID),
+		logger.String("username", user.Username),
+	)
+	return user, invitation, nil
+}
+
+// acceptHash is Accept for a single sign-on login that started on the
+// invitation page. A user who is already a member just logs in.
+func (s *InvitationService) acceptHash(tokenHash string, user *model.User, clientIP string) error {
+	var invitation *model.WorkspaceInvitation
+	var member *model.WorkspaceMember
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = s.pending(tx, tokenHash); err != nil {
+			return err
+		}
+		member, err = s.accept(tx, invitation, user.ID)
+		return err
+	})
+	if errors.Is(err, ErrUserAlreadyMember) {
+		return nil
+	}
+	if s.auditSvc != nil && invitation != nil {
+		entry := model.AuditLog{
+			ResourceType: "workspace_invitation",
+			ResourceID:   strconv.FormatUint(uint64(invitation.ID), 10),
+			WorkspaceID:  &invitation.WorkspaceID,
+		}
+		if err != nil {
+			entry.Outcome = model.AuditOutcomeFailure
+			entry.Detail = err.Error()
+		} else if changes, jsonErr := json.Marshal(DiffFields(nil, memberFields(member))); jsonErr == nil {
+			entry.Changes = string(changes)
+		}
+		s.auditSvc.RecordUser(user, model.AuditActionInvitationAccept, clientIP, entry)
+	}
+	return err
+}
+
+// pending loads an invitation that can still be accepted
+func (s *InvitationService) pending(tx *gorm.DB, tokenHash string) (*model.WorkspaceInvitation, error) {
+	var invitation model.WorkspaceInvitation
+	err := tx.Preload("Workspace").Preload("Inviter").Preload("CustomRole").
+		Where("token_hash = ?", tokenHash).First(&invitation).Error
+	if err != nil {
+		return nil, ErrInvitationInvalid
+	}
+	if invitation.AcceptedAt != nil || invitation.RevokedAt != nil || time.Now().After(invitation.ExpiresAt) ||
+		invitation.Workspace == nil {
+		return nil, ErrInvitationInvalid
+	}
+	// A custom role deleted since the invitation was sent takes it with it
+	if invitation.Role == model.WorkspaceRoleCustom && invitation.CustomRole == nil {
+		return nil, ErrInvitationInvalid
+	}
+	return &invitation, nil
+}
+
+// accept consumes the invitation and adds the membership. The invitation is
+// marked accepted first, so two concurrent requests cannot both use it.
+func (s *InvitationService) accept(tx *gorm.DB, invitation *model.WorkspaceInvitation, userID uint) (*model.WorkspaceMember, error) {
+	var user model.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.ServiceAccount || user.Status != 1 {
+		return nil, ErrInvitationInvalid
+	}
+
+	var count int64
+	if err := tx.Model(&model.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ?", invitation.WorkspaceID, userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUserAlreadyMember
+	}
+
+	now := time.Now()
+	result := tx.Model(&model.WorkspaceInvitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+		Updates(map[string]interface{}{"accepted_at": now, "accepted_by": userID})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvitationInvalid
+	}
+	invitation.AcceptedAt, invitation.AcceptedBy = &now, &userID
+
+	member := &model.WorkspaceMember{
+		WorkspaceID:  invitation.WorkspaceID,
+		UserID:       userID,
+		Role:         invitation.Role,
+		CustomRoleID: invitation.CustomRoleID,
+	}
+	if err := tx.Create(member).Error; err != nil {
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+	return member, nil
+}
+
+func (s *InvitationService) sendEmail(invitation *model.WorkspaceInvitation, raw string) error {
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, invitation.WorkspaceID).Error; err != nil {
+		return err
+	}
+	var inviter model.User
+	if err := s.db.First(&inviter, invitation.InvitedBy).Error; err != nil {
+		return err
+	}
+
+	site := s.settingSvc.GetSiteConfig().Title
+	subject := fmt.Sprintf("You are invited to join %s on %s", workspace.Name, site)
+	body := fmt.Sprintf(
+		"%s invited you to join the workspace \"%s\" on %s as %s.\n\n"+
+			"Open this link to accept the invitation, with an existing account or a new one:\n\n%s\n\n"+
+			"The link can be used once and expires on %s.\n"+
+			"If you did not expect this invitation, you can ignore this email.\n",
+		displayName(&inviter), workspace.Name, site, invitation.Role,
+		s.consoleURL+invitationPath(raw),
+		invitation.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"),
+	)
+	return s.mailer.Send(invitation.Email, subject, body)
+}
+
+// invitationFields is the audited state of an invitation
+func invitationFields(invitation *model.WorkspaceInvitation) map[string]interface{} {
+	fields := map[string]interface{}{
+		"email":          invitation.Email,
+		"role":           invitation.Role,
+		"custom_role_id": nil,
+	}
+	if invitation.CustomRoleID != nil {
+		fields["custom_role_id"] = *invitation.CustomRoleID
+	}
+	return fields
+}
+
+func newInvitationResponse(invitation *model.WorkspaceInvitation) InvitationResponse {
+	resp := InvitationResponse{
+		ID:           invitation.ID,
+		Email:        invitation.Email,
+		Role:         invitation.Role,
+		CustomRoleID: invitation.CustomRoleID,
+		TokenHint:    invitation.TokenHint,
+		InvitedBy:    invitation.InvitedBy,
+		EmailSent:    invitation.EmailSentAt != nil,
+		Expired:      time.Now().After(invitation.ExpiresAt),
+		ExpiresAt:    invitation.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"),
+		CreatedAt:    invitation.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if invitation.Inviter != nil {
+		resp.InviterName = displayName(invitation.Inviter)
+	}
+	if invitation.CustomRole != nil {
+		resp.CustomRoleName = invitation.CustomRole.Name
+	}
+	return resp
+}
+
+// invitationPath is the console page that accepts an invitation
+func invitationPath(raw string) string {
+	return "/invite/" + raw
+}
+
+func displayName(user *model.User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
+
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return model.WorkspaceInvitationPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestNewInvitationResponse(t *testing.T) {
+	sent := time.Now().Add(-time.Hour)
+	roleID := uint(5)
+	invitation := &model.WorkspaceInvitation{
+		ID:           3,
+		Email:        "bob@example.com",
+		Role:         model.WorkspaceRoleCustom,
+		CustomRoleID: &roleID,
+		TokenHint:    "awi_abcdef",
+		ExpiresAt:    time.Now().Add(-time.Minute),
+		EmailSentAt:  &sent,
+		Inviter:      &model.User{Username: "alice"},
+		CustomRole:   &model.WorkspaceRole{Name: "Auditors"},
+	}
+
+	resp := newInvitationResponse(invitation)
+	if !resp.Expired || !resp.EmailSent {
+		t.Errorf("expired = %v, email sent = %v", resp.Expired, resp.EmailSent)
+	}
+	if resp.InviterName != "alice" || resp.CustomRoleName != "Auditors" {
+		t.Errorf("inviter = %q, custom role = %q", resp.InviterName, resp.CustomRoleName)
+	}
+
+	invitation.Inviter.Nickname = "Alice A."
+	invitation.ExpiresAt = time.Now().Add(time.Hour)
+	invitation.EmailSentAt = nil
+	resp = newInvitationResponse(invitation)
+	if resp.Expired || resp.EmailSent || resp.InviterName != "Alice A." {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestGenerateInvitationToken(t *testing.T) {
+	a, err := generateInvitationToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := generateInvitationToken()
+	if a == b || !strings.HasPrefix(a, model.WorkspaceInvitationPrefix) || len(a) != len(model.WorkspaceInvitationPrefix)+43 {
+		t.Errorf("unexpected tokens %q, %q", a, b)
+	}
+	if invitationPath(a) != "/invite/"+a {
+		t.Errorf("invitationPath() = %s", invitationPath(a))
+	}
+}
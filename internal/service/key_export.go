@@ -0,0 +1,320 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/keystorage"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrKeyExportDisabled        = errors.New("private key export is disabled for this workspace")
+	ErrKeyExportReauthRequired  = errors.New("re-authenticate to export the private key")
+	ErrKeyExportReasonRequired  = errors.New("a reason is required to export the private key")
+	ErrKeyExportReauthLocked    = errors.New("too many failed re-authentication attempts, try again later")
+	ErrKeyExportEncryptedOnly   = errors.New("this workspace only allows password-protected key exports (pfx, jks, jceks or pkcs8 with a password)")
+	ErrKeyExportInvalidPassword = errors.New("invalid password")
+
+	// ErrKeyNotExportable is returned for keys kept in a non-exportable backend such as an HSM
+	ErrKeyNotExportable = keystorage.ErrNotExportable
+)
+
+// Ways a private key leaves the console, recorded on each export
+const (
+	KeyExportViaDownload     = "download"
+	KeyExportViaDownloadLink = "download_link"
+	KeyExportViaDeployToken  = "deploy_token" // Bundle pulled by a deployment agent
+	KeyExportViaDeployment   = "deployment"   // Pushed to an SSH host, Kubernetes Secret or cloud service
+)
+
+// KeyExportService enforces the workspace key export policy and keeps the export trail
+type KeyExportService struct {
+	db           *gorm.DB
+	workspaceSvc *WorkspaceService
+	mfaSvc       *MFAService
+	loginGuard   *LoginGuardService
+}
+
+// NewKeyExportService creates a new KeyExportService
+func NewKeyExportService(db *gorm.DB, workspaceSvc *WorkspaceService, mfaSvc *MFAService, loginGuard *LoginGuardService) *KeyExportService {
+	return &KeyExportService{
+		db:           db,
+		workspaceSvc: workspaceSvc,
+		mfaSvc:       mfaSvc,
+		loginGuard:   loginGuard,
+	}
+}
+
+// KeyExportRequest describes an attempted export of a private-key-bearing format
+type KeyExportRequest struct {
+	Format         string
+	Password       string // Bundle password or PKCS#8 passphrase
+	Reason         string
+	ReauthPassword string // The user's own login password, for step-up policies
+	StepUpToken    string // A step-up grant, required instead of the password once 2FA is set up
+}
+
+// IsPrivateKeyFormat reports whether a download format contains the private key
+func IsPrivateKeyFormat(format string) bool {
+	switch DownloadFormat(format) {
+	case DownloadFormatPFX, DownloadFormatZIP, DownloadFormatJKS, DownloadFormatJCEKS,
+		DownloadFormatPKCS8, DownloadFormatHAProxy, DownloadFormatK8s, DownloadFormatProfile:
+		return true
+	}
+	return false
+}
+
+// isEncryptedExport reports whether a key export is protected by a password the
+// user chose
+func isEncryptedExport(format, password string) bool {
+	switch DownloadFormat(format) {
+	case DownloadFormatPFX, DownloadFormatJKS, DownloadFormatJCEKS, DownloadFormatPKCS8:
+		return password != ""
+	}
+	return false
+}
+
+// Authorize checks that the user may export the certificate's private key in the
+// requested format. Every export takes a fresh step-up. Personal certificates can
+// only be exported by their owner; workspace certificates follow the workspace
+// policy.
+func (s *KeyExportService) Authorize(certID, userID uint, req *KeyExportRequest) error {
+	var cert model.Certificate
+	if err := s.db.First(&cert, certID).Error; err != nil {
+		return fmt.Errorf("certificate not found: %w", err)
+	}
+
+	if cert.WorkspaceID == nil {
+		if cert.CreatedBy == nil || *cert.CreatedBy != userID {
+			return ErrWorkspaceAccessDenied
+		}
+		return s.reauthenticate(userID, req)
+	}
+
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, *cert.WorkspaceID).Error; err != nil {
+		return ErrWorkspaceNotFound
+	}
+
+	role, err := s.workspaceSvc.GetUserRole(workspace.ID, userID)
+	if err != nil {
+		return err
+	}
+	perms, err := s.workspaceSvc.GetUserPermissions(workspace.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	switch workspace.KeyExportPolicy {
+	case model.KeyExportPolicyNone:
+		return ErrKeyExportDisabled
+	case model.KeyExportPolicyStepUp:
+		if !stepUpKeyExportAllowed(role, perms) {
+			return ErrWorkspaceAccessDenied
+		}
+	default:
+		if !perms.Has(model.PermissionCertKeyExport) {
+			return ErrWorkspaceAccessDenied
+		}
+	}
+	if err := s.reauthenticate(userID, req); err != nil {
+		return err
+	}
+
+	if workspace.KeyExportEncryptedOnly && !isEncryptedExport(req.Format, req.Password) {
+		return ErrKeyExportEncryptedOnly
+	}
+	if workspace.KeyExportRequireReason && strings.TrimSpace(req.Reason) == "" {
+		return ErrKeyExportReasonRequired
+	}
+	return nil
+}
+
+// AuthorizeDelivery checks that the user may set up unattended delivery of the
+// private key: deploy tokens and deployment targets. The key leaves unencrypted
+// and repeatedly, so it always takes cert.key.export and a step-up, and
+// workspaces that disable exports or only allow encrypted ones refuse it.
+func (s *KeyExportService) AuthorizeDelivery(certID, userID uint, req *KeyExportRequest) error {
+	var cert model.Certificate
+	if err := s.db.First(&cert, certID).Error; err != nil {
+		return fmt.Errorf("certificate not found: %w", err)
+	}
+
+	if cert.WorkspaceID == nil {
+		if cert.CreatedBy == nil || *cert.CreatedBy != userID {
+			return ErrWorkspaceAccessDenied
+		}
+		return s.reauthenticate(userID, req)
+	}
+
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, *cert.WorkspaceID).Error; err != nil {
+		return ErrWorkspaceNotFound
+	}
+	perms, err := s.workspaceSvc.GetUserPermissions(workspace.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	if workspace.KeyExportPolicy == model.KeyExportPolicyNone {
+		return ErrKeyExportDisabled
+	}
+	if !perms.Has(model.PermissionCertKeyExport) {
+		return ErrWorkspaceAccessDenied
+	}
+	if err := s.reauthenticate(userID, req); err != nil {
+		return err
+	}
+	if workspace.KeyExportEncryptedOnly {
+		return ErrKeyExportEncryptedOnly
+	}
+	if workspace.KeyExportRequireReason && strings.TrimSpace(req.Reason) == "" {
+		return ErrKeyExportReasonRequired
+	}
+	return nil
+}
+
+// DeliveryAllowed reports whether the key of a certificate may still be
+// delivered by existing deploy tokens and targets, e.g. after the workspace
+// disabled exports
+func (s *KeyExportService) DeliveryAllowed(cert *model.Certificate) error {
+	if cert.WorkspaceID == nil {
+		return nil
+	}
+	var workspace model.Workspace
+	if err := s.db.Select("id", "key_export_policy", "key_export_encrypted_only").First(&workspace, *cert.WorkspaceID).Error; err != nil {
+		return ErrWorkspaceNotFound
+	}
+	if workspace.KeyExportPolicy == model.KeyExportPolicyNone {
+		return ErrKeyExportDisabled
+	}
+	if workspace.KeyExportEncryptedOnly {
+		return ErrKeyExportEncryptedOnly
+	}
+	return nil
+}
+
+// DelivererAllowed reports whether the user who set up a key delivery, such as
+// a deploy token, could still set it up: the account exists and is active, and
+// still owns the private certificate or holds cert.key.export in its workspace
+func (s *KeyExportService) DelivererAllowed(cert *model.Certificate, userID uint) error {
+	var user *model.User
+	var found model.User
+	if err := s.db.Select("id", "status").First(&found, userID).Error; err == nil {
+		user = &found
+	}
+	var perms Permissions
+	if cert.WorkspaceID != nil && user != nil {
+		// Not a member any more leaves no permissions
+		perms, _ = s.workspaceSvc.GetUserPermissions(*cert.WorkspaceID, userID)
+	}
+	return delivererAllowed(user, cert, perms)
+}
+
+// delivererAllowed is DelivererAllowed for a loaded user, nil once deleted
+func delivererAllowed(user *model.User, cert *model.Certificate, perms Permissions) error {
+	if user == nil || user.Status != 1 {
+		return ErrWorkspaceAccessDenied
+	}
+	if cert.WorkspaceID == nil {
+		if cert.CreatedBy == nil || *cert.CreatedBy != user.ID {
+			return ErrWorkspaceAccessDenied
+		}
+		return nil
+	}
+	if !perms.Has(model.PermissionCertKeyExport) {
+		return ErrWorkspaceAccessDenied
+	}
+	return nil
+}
+
+// stepUpKeyExportAllowed reports who may export under the step-up policy:
+// holders of cert.key.export, plus the built-in member and operator roles.
+// Step-up itself is required of everyone.
+// Viewers, and custom roles without the permission, never can.
+func stepUpKeyExportAllowed(role string, perms Permissions) bool {
+	if perms.Has(model.PermissionCertKeyExport) {
+		return true
+	}
+	return role == model.WorkspaceRoleMember || role == model.WorkspaceRoleOperator
+}
+
+// reauthenticate accepts a step-up grant, or the login password from users
+// without a second factor. Wrong passwords count toward the same per-user
+// lockout as failed step-ups.
+func (s *KeyExportService) reauthenticate(userID uint, req *KeyExportRequest) error {
+	if req.StepUpToken != "" {
+		if s.mfaSvc.CheckStepUp(userID, req.StepUpToken) != nil {
+			return ErrKeyExportReauthRequired
+		}
+		return nil
+	}
+	password := req.ReauthPassword
+	if password == "" || s.mfaSvc.Enrolled(userID) {
+		return ErrKeyExportReauthRequired
+	}
+	if s.loginGuard.StepUpWait(userID) > 0 {
+		return ErrKeyExportReauthLocked
+	}
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return ErrKeyExportInvalidPassword
+	}
+	if !user.CheckPassword(password) {
+		s.loginGuard.StepUpFail(&user, "", "wrong password for private key export")
+		return ErrKeyExportInvalidPassword
+	}
+	s.loginGuard.StepUpSucceed(userID)
+	return nil
+}
+
+// Record counts a completed key export on the certificate and logs it
+func (s *KeyExportService) Record(certID, userID uint, format, reason, clientIP, via string) {
+	now := time.Now()
+	if err := s.db.Model(&model.Certificate{}).Where("id = ?", certID).Updates(map[string]interface{}{
+		"key_export_count":   gorm.Expr("key_export_count + 1"),
+		"last_key_export_at": now,
+	}).Error; err != nil {
+		logger.Error("Failed to update key export counter", logger.Uint("cert_id", certID), logger.Err(err))
+	}
+
+	entry := &model.KeyExport{
+		CertificateID: certID,
+		UserID:        userID,
+		Format:        format,
+		Reason:        truncate(strings.TrimSpace(reason), 255),
+		Via:           via,
+		IP:            clientIP,
+	}
+	if err := s.db.Create(entry).Error; err != nil {
+		logger.Error("Failed to record key export", logger.Uint("cert_id", certID), logger.Err(err))
+	}
+
+	logger.Info("Private key exported",
+		logger.Uint("cert_id", certID),
+		logger.Uint("user_id", userID),
+		logger.String("format", format),
+		logger.String("via", via),
+	)
+}
+
+// ListExports returns the key export trail of a certificate (certificate managers only)
+func (s *KeyExportService) ListExports(certID, userID uint) ([]model.KeyExport, error) {
+	if _, err := loadManagedCertificate(s.db, s.workspaceSvc, certID, userID); err != nil {
+		return nil, err
+	}
+
+	var exports []model.KeyExport
+	if err := s.db.Where("certificate_id = ?", certID).
+		Order("created_at DESC").
+		Limit(100).
+		Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
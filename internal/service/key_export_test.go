@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestIsPrivateKeyFormat(t *testing.T) {
+	for _, format := range []string{"pfx", "zip", "jks", "jceks", "pkcs8", "haproxy", "k8s", "profile"} {
+		if !IsPrivateKeyFormat(format) {
+			t.Errorf("%s should carry the private key", format)
+		}
+	}
+	for _, format := range []string{"pem", "fullchain", "der", "p7b", ""} {
+		if IsPrivateKeyFormat(format) {
+			t.Errorf("%s should not carry the private key", format)
+		}
+	}
+}
+
+func TestIsEncryptedExport(t *testing.T) {
+	tests := []struct {
+		format   string
+		password string
+		want     bool
+	}{
+		{"pfx", "s3cret-pass", true},
+		{"pfx", "", false}, // No password, refused before the bundle is built
+		{"jks", "s3cret-pass", true},
+		{"pkcs8", "s3cret-pass", true},
+		{"pkcs8", "", false},
+		{"zip", "s3cret-pass", false},
+		{"k8s", "", false},
+	}
+	for _, tt := range tests {
+		if got := isEncryptedExport(tt.format, tt.password); got != tt.want {
+			t.Errorf("isEncryptedExport(%q, %q) = %v, want %v", tt.format, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestDelivererAllowed(t *testing.T) {
+	workspaceID, ownerID := uint(3), uint(7)
+	active := &model.User{ID: ownerID, Status: 1}
+	workspaceCert := &model.Certificate{WorkspaceID: &workspaceID}
+	privateCert := &model.Certificate{CreatedBy: &ownerID}
+	exporter := NewPermissions([]string{model.PermissionCertRead, model.PermissionCertKeyExport})
+	reader := NewPermissions([]string{model.PermissionCertRead})
+
+	tests := []struct {
+		name  string
+		user  *model.User
+		cert  *model.Certificate
+		perms Permissions
+		ok    bool
+	}{
+		{"exporter", active, workspaceCert, exporter, true},
+		{"creator deleted", nil, workspaceCert, nil, false},
+		{"creator disabled", &model.User{ID: ownerID, Status: 0}, workspaceCert, exporter, false},
+		{"lost cert.key.export", active, workspaceCert, reader, false},
+		{"removed from workspace", active, workspaceCert, nil, false},
+		{"private owner", active, privateCert, nil, true},
+		{"private certificate moved to someone else", &model.User{ID: 8, Status: 1}, privateCert, nil, false},
+	}
+	for _, tt := range tests {
+		err := delivererAllowed(tt.user, tt.cert, tt.perms)
+		if tt.ok != (err == nil) {
+			t.Errorf("%s: err = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEncryptionNotConfigured = errors.New("encryption is not configured")
+	ErrKeyRotationRunning      = errors.New("key rotation is already running")
+)
+
+const keyRotationBatchSize = 100
+
+// encryptedColumn is a database column holding encryptor ciphertexts
+type encryptedColumn struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Where  string `json:"-"` // Optional filter for rows whose value is not an encryptor ciphertext
+}
+
+// encryptedColumns lists every column written with the encryptor; new secrets must be added here
+var encryptedColumns = []encryptedColumn{
+	{Table: "certificates", Column: "key_pem", Where: "key_backend IS NULL OR key_backend IN ('', 'database')"},
+	{Table: "acme_accounts", Column: "private_key"},
+	{Table: "deployment_targets", Column: "private_key"},
+	{Table: "deployment_targets", Column: "kubeconfig"},
+	{Table: "cloud_credentials", Column: "secret_access_key"},
+	{Table: "download_links", Column: "password"},
+	{Table: "user_totps", Column: "secret"},
+}
+
+// KeyRotationProgress counts the work on one encrypted column
+type KeyRotationProgress struct {
+	encryptedColumn
+	Remaining int64 `json:"remaining"` // Values not yet on the active key
+	Rotated   int   `json:"rotated"`
+	Failed    int   `json:"failed"`
+}
+
+// KeyRotationStatus describes the current or last rotation run
+type KeyRotationStatus struct {
+	ActiveKey  string                `json:"active_key"`
+	Running    bool                  `json:"running"`
+	StartedAt  *time.Time            `json:"started_at,omitempty"`
+	FinishedAt *time.Time            `json:"finished_at,omitempty"`
+	Columns    []KeyRotationProgress `json:"columns"`
+	Error      string                `json:"error,omitempty"`
+}
+
+// KeyRotationService re-encrypts stored secrets with the active encryption key.
+// A run only touches values not yet on the active key, so an interrupted run
+// is resumed by starting it again.
+type KeyRotationService struct {
+	db        *gorm.DB
+	encryptor *crypto.Encryptor // nil in mock mode
+
+	mu     sync.Mutex
+	status KeyRotationStatus
+}
+
+// NewKeyRotationService creates a new KeyRotationService
+func NewKeyRotationService(db *gorm.DB, encryptor *crypto.Encryptor) *KeyRotationService {
+	return &KeyRotationService{db: db, encryptor: encryptor}
+}
+
+// Status returns the progress of the current or last run. Outside a run, the
+// remaining counts are read fresh from the database.
+func (s *KeyRotationService) Status() (*KeyRotationStatus, error) {
+	if s.encryptor == nil {
+		return nil, ErrEncryptionNotConfigured
+	}
+
+	s.mu.Lock()
+	status := s.status
+	status.Columns = append([]KeyRotationProgress(nil), s.status.Columns...)
+	s.mu.Unlock()
+
+	status.ActiveKey = s.encryptor.ActiveKeyID()
+	if !status.Running {
+		columns := make([]KeyRotationProgress, 0, len(encryptedColumns))
+		for i, col := range encryptedColumns {
+			remaining, err := s.countRemaining(col)
+			if err != nil {
+				return nil, err
+			}
+			progress := KeyRotationProgress{encryptedColumn: col, Remaining: remaining}
+			if i < len(status.Columns) {
+				progress.Rotated, progress.Failed = status.Columns[i].Rotated, status.Columns[i].Failed
+			}
+			columns = append(columns, progress)
+		}
+		status.Columns = columns
+	}
+	return &status, nil
+}
+
+// Start launches a rotation run in the background
+func (s *KeyRotationService) Start() error {
+	if s.encryptor == nil {
+		return ErrEncryptionNotConfigured
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Running {
+		return ErrKeyRotationRunning
+	}
+
+	now := time.Now()
+	s.status = KeyRotationStatus{Running: true, StartedAt: &now}
+	for _, col := range encryptedColumns {
+		remaining, _ := s.countRemaining(col)
+		s.status.Columns = append(s.status.Columns, KeyRotationProgress{encryptedColumn: col, Remaining: remaining})
+	}
+
+	go s.run()
+	return nil
+}
+
+func (s *KeyRotationService) run() {
+	activeKey := s.encryptor.ActiveKeyID()
+	logger.Info("Encryption key rotation started", logger.String("active_key", activeKey))
+
+	var runErr error
+	for i, col := range encryptedColumns {
+		if err := s.rotateColumn(i, col); err != nil {
+			runErr = err
+			logger.Error("Key rotation aborted",
+				logger.String("table", col.Table),
+				logger.String("column", col.Column),
+				logger.Err(err),
+			)
+			break
+		}
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	s.status.Running = false
+	s.status.FinishedAt = &now
+	if runErr != nil {
+		s.status.Error = runErr.Error()
+	}
+	s.mu.Unlock()
+
+	logger.Info("Encryption key rotation finished", logger.String("active_key", activeKey))
+}
+
+// rotateColumn walks a column in primary key order, rewrapping each value. An
+// update only applies if the value is unchanged, so a secret rewritten
+// concurrently by the application is left alone.
+func (s *KeyRotationService) rotateColumn(index int, col encryptedColumn) error {
+	var lastID uint
+	for {
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		if err := s.pendingQuery(col).
+			Select("id, "+col.Column+" AS value").
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(keyRotationBatchSize).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			rotated := false
+			rewrapped, err := s.encryptor.Rewrap(row.Value)
+			if err != nil {
+				logger.Error("Failed to rewrap secret",
+					logger.String("table", col.Table),
+					logger.Uint("id", row.ID),
+					logger.String("key_id", s.encryptor.KeyID(row.Value)),
+					logger.Err(err),
+				)
+			} else {
+				result := s.db.Table(col.Table).
+					Where("id = ? AND "+col.Column+" = ?", row.ID, row.Value).
+					Update(col.Column, rewrapped)
+				if result.Error != nil {
+					return result.Error
+				}
+				rotated = result.RowsAffected > 0
+			}
+
+			s.mu.Lock()
+			progress := &s.status.Columns[index]
+			if rotated {
+				progress.Rotated++
+			} else if err != nil {
+				progress.Failed++
+			}
+			if progress.Remaining > 0 {
+				progress.Remaining--
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *KeyRotationService) countRemaining(col encryptedColumn) (int64, error) {
+	var count int64
+	err := s.pendingQuery(col).Count(&count).Error
+	return count, err
+}
+
+// pendingQuery selects the non-empty values of a column not framed with the active key
+func (s *KeyRotationService) pendingQuery(col encryptedColumn) *gorm.DB {
+	prefix := s.encryptor.ActiveKeyID() + ":"
+	query := s.db.Table(col.Table).
+		Where(col.Column+" IS NOT NULL AND "+col.Column+" <> ''").
+		Where("SUBSTR("+col.Column+", 1, ?) <> ?", len(prefix), prefix)
+	if col.Where != "" {
+		query = query.Where(col.Where)
+	}
+	return query
+}
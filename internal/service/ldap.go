@@ -0,0 +1,327 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLDAPInvalidCredentials = errors.New("invalid username or password")
+	ErrLDAPUserNotFound       = errors.New("user not found in directory")
+	ErrLDAPUnavailable        = errors.New("directory is unavailable, please try again later")
+	ErrLDAPAccountDisabled    = errors.New("user is disabled")
+	ErrLDAPUsernameTaken      = errors.New("username belongs to a local account")
+)
+
+const (
+	defaultLDAPUserFilter = "(sAMAccountName=%s)"
+	defaultLDAPTimeout    = 10 * time.Second
+
+	// Active Directory userAccountControl ACCOUNTDISABLE flag
+	uacAccountDisable = 0x2
+)
+
+// LDAPService authenticates users against LDAP or Active Directory and
+// mirrors their profile, status, role and workspace memberships on login
+type LDAPService struct {
+	db        *gorm.DB
+	cfg       *config.LDAPConfig
+	tlsConfig *tls.Config
+	timeout   time.Duration
+
+	// Group names are compared case-insensitively, like DNs in the directory
+	adminGroups []string
+	mappings    []config.WorkspaceMapping
+}
+
+// NewLDAPService creates a new LDAPService. It fails when the CA file cannot be read.
+func NewLDAPService(db *gorm.DB, cfg *config.LDAPConfig) (*LDAPService, error) {
+	s := &LDAPService{
+		db:      db,
+		cfg:     cfg,
+		timeout: defaultLDAPTimeout,
+	}
+	if !cfg.Enabled {
+		return s, nil
+	}
+
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldap timeout: %w", err)
+		}
+		s.timeout = timeout
+	}
+
+	s.tlsConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ldap CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("ldap CA file contains no certificates")
+		}
+		s.tlsConfig.RootCAs = pool
+	}
+
+	for _, group := range cfg.AdminGroups {
+		s.adminGroups = append(s.adminGroups, strings.ToLower(group))
+	}
+	for _, m := range cfg.Workspaces {
+		m.Group = strings.ToLower(m.Group)
+		s.mappings = append(s.mappings, m)
+	}
+	return s, nil
+}
+
+// Enabled reports whether LDAP login is configured
+func (s *LDAPService) Enabled() bool {
+	return s != nil && s.cfg.Enabled
+}
+
+// LDAPEntry is the directory data the console mirrors
+type LDAPEntry struct {
+	DN       string
+	Nickname string
+	Email    string
+	Groups   []string // Lowercased group DNs and their CNs
+	Disabled bool
+}
+
+// Authenticate checks the password against the directory and returns the
+// provisioned console user
+func (s *LDAPService) Authenticate(username, password string) (*model.User, error) {
+	entry, err := s.lookup(username, password)
+	if errors.Is(err, ErrLDAPAccountDisabled) {
+		s.db.Model(&model.User{}).
+			Where("username = ? AND auth_source = ?", username, model.AuthSourceLDAP).
+			Update("status", 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.provision(username, entry)
+}
+
+// lookup finds the user's entry and verifies the password with a bind as that entry
+func (s *LDAPService) lookup(username, password string) (*LDAPEntry, error) {
+	// Many servers accept a bind with an empty password as anonymous
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := s.dial()
+	if err != nil {
+		logger.Error("LDAP connection failed", logger.Err(err))
+		return nil, ErrLDAPUnavailable
+	}
+	defer conn.Close()
+
+	entry, err := s.search(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	// Disabled AD accounts fail to bind anyway; report them before trying
+	if entry.Disabled {
+		return entry, ErrLDAPAccountDisabled
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		logger.Error("LDAP user bind failed", logger.String("dn", entry.DN), logger.Err(err))
+		return nil, ErrLDAPUnavailable
+	}
+	return entry, nil
+}
+
+func (s *LDAPService) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(s.cfg.URL,
+		ldap.DialWithTLSConfig(s.tlsConfig),
+		ldap.DialWithDialer(&net.Dialer{Timeout: s.timeout}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(s.timeout)
+
+	if s.cfg.StartTLS && !strings.HasPrefix(strings.ToLower(s.cfg.URL), "ldaps://") {
+		tlsConfig := s.tlsConfig.Clone()
+		if u, err := url.Parse(s.cfg.URL); err == nil && tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// search binds as the service account and finds the user's entry
+func (s *LDAPService) search(conn *ldap.Conn, username string) (*LDAPEntry, error) {
+	if s.cfg.BindDN != "" {
+		if err := conn.Bind(s.cfg.BindDN, s.cfg.BindPassword); err != nil {
+			logger.Error("LDAP service bind failed", logger.Err(err))
+			return nil, ErrLDAPUnavailable
+		}
+	}
+
+	filter := s.cfg.UserFilter
+	if filter == "" {
+		filter = defaultLDAPUserFilter
+	}
+	nicknameAttr := ldapAttribute(s.cfg.NicknameAttribute, "displayName")
+	emailAttr := ldapAttribute(s.cfg.EmailAttribute, "mail")
+	groupAttr := ldapAttribute(s.cfg.GroupAttribute, "memberOf")
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		s.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(s.timeout.Seconds()), false,
+		fmt.Sprintf(filter, ldap.EscapeFilter(username)),
+		[]string{nicknameAttr, emailAttr, groupAttr, "userAccountControl"},
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrLDAPUserNotFound
+		}
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			logger.Warn("LDAP user filter matches several entries", logger.String("username", username))
+			return nil, ErrLDAPInvalidCredentials
+		}
+		logger.Error("LDAP search failed", logger.Err(err))
+		return nil, ErrLDAPUnavailable
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrLDAPUserNotFound
+	case 1:
+	default:
+		logger.Warn("LDAP user filter matches several entries", logger.String("username", username))
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	e := result.Entries[0]
+	entry := &LDAPEntry{
+		DN:       e.DN,
+		Nickname: e.GetAttributeValue(nicknameAttr),
+		Email:    e.GetAttributeValue(emailAttr),
+		Groups:   ldapGroupNames(e.GetAttributeValues(groupAttr)),
+	}
+	if uac, err := strconv.ParseInt(e.GetAttributeValue("userAccountControl"), 10, 64); err == nil {
+		entry.Disabled = uac&uacAccountDisable != 0
+	}
+	return entry, nil
+}
+
+// provision creates or updates the console user for a directory entry
+func (s *LDAPService) provision(username string, entry *LDAPEntry) (*model.User, error) {
+	var user model.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		err := tx.Where("username = ?", username).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Directory users never use the local password
+			secret, err := randomSecret()
+			if err != nil {
+				return err
+			}
+			user = model.User{
+				Username:   username,
+				Nickname:   entry.Nickname,
+				Email:      entry.Email,
+				Role:       model.RoleUser,
+				Status:     1,
+				AuthSource: model.AuthSourceLDAP,
+
+				EmailVerified: entry.Email != "",
+			}
+			if err := user.SetPassword(secret); err != nil {
+				return err
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+			logger.Info("User provisioned from LDAP", logger.String("username", username))
+		case err != nil:
+			return err
+		case user.AuthSource != model.AuthSourceLDAP || user.ServiceAccount:
+			return ErrLDAPUsernameTaken
+		case user.Status != 1:
+			// Status only ever follows the directory toward disabled; a user
+			// disabled here, by an admin or a directory sync, stays disabled
+			// until an admin enables them again
+			return ErrLDAPAccountDisabled
+		}
+
+		updates := map[string]interface{}{
+			"last_login": now,
+		}
+		if entry.Nickname != "" {
+			updates["nickname"] = entry.Nickname
+		}
+		if entry.Email != "" {
+			updates["email"] = entry.Email
+			updates["email_verified"] = true
+		}
+		if role, ok := groupGlobalRole(s.adminGroups, entry.Groups); ok {
+			updates["role"] = role
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return syncGroupMemberships(tx, user.ID, model.MemberSourceLDAP, s.mappings, entry.Groups)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func ldapAttribute(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
+
+// ldapGroupNames lowercases group DNs and adds each group's CN, so mappings
+// may name either "cn=pki-admins,ou=groups,dc=example,dc=org" or "pki-admins"
+func ldapGroupNames(dns []string) []string {
+	names := make([]string, 0, len(dns)*2)
+	for _, dn := range dns {
+		names = append(names, strings.ToLower(dn))
+		parsed, err := ldap.ParseDN(dn)
+		if err != nil || len(parsed.RDNs) == 0 {
+			continue
+		}
+		for _, attr := range parsed.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				names = append(names, strings.ToLower(attr.Value))
+			}
+		}
+	}
+	return names
+}
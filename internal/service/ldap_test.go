@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/jimlambrt/gldap"
+	"github.com/jimlambrt/gldap/testdirectory"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+	logger.S = logger.Log.Sugar()
+}
+
+const testPeopleDN = "ou=people,dc=example,dc=org"
+
+// startTestDirectory runs an in-process LDAP server with a service account,
+// an active user and a disabled AD user
+func startTestDirectory(t *testing.T, opt ...testdirectory.Option) (*testdirectory.Directory, string) {
+	t.Helper()
+	td := testdirectory.Start(t, append(opt, testdirectory.WithLogger(t, hclog.NewNullLogger()))...)
+	td.SetUsers(
+		gldap.NewEntry("cn=svc,"+testPeopleDN, map[string][]string{
+			"password": {"svc-secret"},
+		}),
+		gldap.NewEntry("cn=alice,"+testPeopleDN, map[string][]string{
+			"password":           {"alice-secret"},
+			"displayName":        {"Alice Liddell"},
+			"mail":               {"alice@example.org"},
+			"memberOf":           {"CN=PKI-Admins,OU=Groups,DC=example,DC=org"},
+			"userAccountControl": {"512"},
+		}),
+		gldap.NewEntry("cn=mallory,"+testPeopleDN, map[string][]string{
+			"password":           {"mallory-secret"},
+			"userAccountControl": {"514"}, // NORMAL_ACCOUNT | ACCOUNTDISABLE
+		}),
+	)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte(td.Cert()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return td, caFile
+}
+
+func newTestLDAPService(t *testing.T, cfg config.LDAPConfig) *LDAPService {
+	t.Helper()
+	cfg.Enabled = true
+	cfg.BindDN = "cn=svc," + testPeopleDN
+	cfg.BindPassword = "svc-secret"
+	cfg.BaseDN = testPeopleDN
+	cfg.UserFilter = "(cn=%s)"
+	s, err := NewLDAPService(nil, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLDAPLookupOverLDAPS(t *testing.T) {
+	td, caFile := startTestDirectory(t)
+	s := newTestLDAPService(t, config.LDAPConfig{
+		URL:    fmt.Sprintf("ldaps://%s:%d", td.Host(), td.Port()),
+		CAFile: caFile,
+	})
+
+	entry, err := s.lookup("alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	want := &LDAPEntry{
+		DN:       "cn=alice," + testPeopleDN,
+		Nickname: "Alice Liddell",
+		Email:    "alice@example.org",
+		Groups:   []string{"cn=pki-admins,ou=groups,dc=example,dc=org", "pki-admins"},
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entry = %+v, want %+v", entry, want)
+	}
+
+	tests := []struct {
+		username, password string
+		want               error
+	}{
+		{"alice", "wrong", ErrLDAPInvalidCredentials},
+		{"alice", "", ErrLDAPInvalidCredentials}, // Never an anonymous bind
+		{"nobody", "secret", ErrLDAPUserNotFound},
+		{"mallory", "mallory-secret", ErrLDAPAccountDisabled},
+	}
+	for _, tt := range tests {
+		if _, err := s.lookup(tt.username, tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("lookup(%s, %q) err = %v, want %v", tt.username, tt.password, err, tt.want)
+		}
+	}
+}
+
+func TestLDAPLookupWithStartTLS(t *testing.T) {
+	td, caFile := startTestDirectory(t, testdirectory.WithNoTLS(t))
+	s := newTestLDAPService(t, config.LDAPConfig{
+		URL:      fmt.Sprintf("ldap://%s:%d", td.Host(), td.Port()),
+		StartTLS: true,
+		CAFile:   caFile,
+	})
+
+	if _, err := s.lookup("alice", "alice-secret"); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+}
+
+func TestLDAPRejectsUntrustedCertificate(t *testing.T) {
+	td, _ := startTestDirectory(t)
+	s := newTestLDAPService(t, config.LDAPConfig{
+		URL: fmt.Sprintf("ldaps://%s:%d", td.Host(), td.Port()),
+	})
+
+	if _, err := s.lookup("alice", "alice-secret"); !errors.Is(err, ErrLDAPUnavailable) {
+		t.Errorf("err = %v, want ErrLDAPUnavailable", err)
+	}
+}
+
+func TestLDAPGroupMapping(t *testing.T) {
+	s := newTestLDAPService(t, config.LDAPConfig{
+		AdminGroups: []string{"PKI-Admins"},
+		Workspaces: []config.WorkspaceMapping{
+			{Group: "CN=Platform,OU=Groups,DC=example,DC=org", WorkspaceID: 3, Role: "operator"},
+		},
+	})
+	groups := ldapGroupNames([]string{"cn=platform,ou=groups,dc=example,dc=org", "CN=PKI-Admins,OU=Groups,DC=example,DC=org"})
+
+	// Mappings match group DNs or CNs in any case
+	if role, ok := groupGlobalRole(s.adminGroups, groups); !ok || role != "admin" {
+		t.Errorf("global role = %q, %v; want admin", role, ok)
+	}
+	if got := groupWorkspaceRoles(s.mappings, groups); !reflect.DeepEqual(got, map[uint]string{3: "operator"}) {
+		t.Errorf("workspace roles = %v", got)
+	}
+}
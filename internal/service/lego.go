@@ -19,6 +19,7 @@ import (
 
 	"github.com/imkerbos/ACME-Console/internal/acme"
 	internalCrypto "github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/keystorage"
 	"github.com/imkerbos/ACME-Console/internal/model"
 	"gorm.io/gorm"
 	"software.sslmate.com/src/go-pkcs12"
@@ -29,20 +30,23 @@ import (
 type LegoService struct {
 	db         *gorm.DB
 	settingSvc *SettingService
-	encryptor  *internalCrypto.Encryptor
+	encryptor  *internalCrypto.Encryptor // ACME account keys
+	keys       *keystorage.Set           // Certificate private keys
 }
 
 // NewLegoServiceWithSettings creates a new LegoService with database-based settings
-func NewLegoServiceWithSettings(db *gorm.DB, settingSvc *SettingService, encryptor *internalCrypto.Encryptor) *LegoService {
+func NewLegoServiceWithSettings(db *gorm.DB, settingSvc *SettingService, encryptor *internalCrypto.Encryptor, keys *keystorage.Set) *LegoService {
 	return &LegoService{
 		db:         db,
 		settingSvc: settingSvc,
 		encryptor:  encryptor,
+		keys:       keys,
 	}
 }
 
 // CreateOrder creates a new certificate order with the ACME CA.
-// This generates a private key, creates an order, and stores challenges for user DNS setup.
+// This generates a private key in the active key storage backend, creates an
+// order, and stores challenges for user DNS setup.
 func (s *LegoService) CreateOrder(certID uint, email string, domains []string, keyType string, keySize int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
 	defer cancel()
@@ -62,21 +66,12 @@ func (s *LegoService) CreateOrder(certID uint, email string, domains []string, k
 		return fmt.Errorf("invalid key size: %w", err)
 	}
 
-	certKey, err := acme.GeneratePrivateKey(kt, keySize)
+	backend := s.keys.Active()
+	keyRef, err := backend.Generate(ctx, kt, keySize)
 	if err != nil {
 		return fmt.Errorf("failed to generate certificate key: %w", err)
 	}
 
-	// Encode and encrypt the private key
-	keyPEM, err := acme.EncodePrivateKeyPEM(certKey)
-	if err != nil {
-		return fmt.Errorf("failed to encode private key: %w", err)
-	}
-	encryptedKey, err := s.encryptor.Encrypt(keyPEM)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt private key: %w", err)
-	}
-
 	// Create ACME client
 	client, err := s.createClientFromAccount(account)
 	if err != nil {
@@ -140,10 +135,11 @@ func (s *LegoService) CreateOrder(certID uint, email string, domains []string, k
 
 	// Update certificate with account, key, and order info
 	if err := s.db.Model(&model.Certificate{}).Where("id = ?", certID).Updates(map[string]any{
-		"account_id": account.ID,
-		"key_pem":    encryptedKey,
-		"key_size":   keySize,
-		"order_url":  order.URI,
+		"account_id":  account.ID,
+		"key_pem":     keyRef,
+		"key_backend": backend.Name(),
+		"key_size":    keySize,
+		"order_url":   order.URI,
 	}).Error; err != nil {
 		return fmt.Errorf("failed to update certificate: %w", err)
 	}
@@ -287,15 +283,14 @@ func (s *LegoService) FinalizeOrder(certID uint) error {
 		return fmt.Errorf("order is not ready, status: %s", order.Status)
 	}
 
-	// Decrypt private key
-	keyPEM, err := s.encryptor.Decrypt(cert.KeyPEM)
+	// Sign the CSR where the key lives; it may never leave an HSM
+	backend, err := s.keys.Get(cert.KeyBackend)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt private key: %w", err)
+		return err
 	}
-
-	certKey, err := acme.DecodePrivateKeyPEM(keyPEM)
+	certKey, err := backend.Signer(ctx, cert.KeyPEM)
 	if err != nil {
-		return fmt.Errorf("failed to decode private key: %w", err)
+		return fmt.Errorf("failed to load private key: %w", err)
 	}
 
 	// Create CSR (domains already parsed above for timeout calculation)
@@ -371,10 +366,18 @@ const (
 	DownloadFormatFullChain DownloadFormat = "fullchain"
 	DownloadFormatPFX       DownloadFormat = "pfx"
 	DownloadFormatZIP       DownloadFormat = "zip"
+	DownloadFormatJKS       DownloadFormat = "jks"
+	DownloadFormatJCEKS     DownloadFormat = "jceks"
+	DownloadFormatP7B       DownloadFormat = "p7b"
+	DownloadFormatDER       DownloadFormat = "der"
+	DownloadFormatPKCS8     DownloadFormat = "pkcs8"
+	DownloadFormatHAProxy   DownloadFormat = "haproxy"
+	DownloadFormatK8s       DownloadFormat = "k8s"
+	DownloadFormatProfile   DownloadFormat = "profile"
 )
 
 // GetCertificateBundle returns the certificate in the specified format.
-func (s *LegoService) GetCertificateBundle(certID uint, format DownloadFormat, password string) ([]byte, string, error) {
+func (s *LegoService) GetCertificateBundle(certID uint, format DownloadFormat, opts BundleOptions) ([]byte, string, error) {
 	var cert model.Certificate
 	if err := s.db.First(&cert, certID).Error; err != nil {
 		return nil, "", fmt.Errorf("certificate not found: %w", err)
@@ -384,10 +387,34 @@ func (s *LegoService) GetCertificateBundle(certID uint, format DownloadFormat, p
 		return nil, "", fmt.Errorf("certificate is not ready")
 	}
 
-	// Decrypt private key
-	keyPEM, err := s.encryptor.Decrypt(cert.KeyPEM)
+	// Tracked certificates (e.g. imported from discovery) have no private key,
+	// and keys in non-exportable backends must stay there
+	exportable, err := s.keyExportable(&cert)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to decrypt private key: %w", err)
+		return nil, "", err
+	}
+	if !exportable {
+		switch format {
+		case DownloadFormatPEM:
+			return []byte(cert.CertPEM), "certificate.pem", nil
+		case DownloadFormatFullChain:
+			return []byte(cert.ChainPEM), "fullchain.pem", nil
+		case DownloadFormatDER, DownloadFormatP7B:
+			return certificateOnlyBundle(&cert, format)
+		case DownloadFormatPFX, DownloadFormatZIP, DownloadFormatJKS, DownloadFormatJCEKS,
+			DownloadFormatPKCS8, DownloadFormatHAProxy, DownloadFormatK8s, DownloadFormatProfile:
+			if cert.KeyPEM != "" {
+				return nil, "", fmt.Errorf("format %s: %w", format, ErrKeyNotExportable)
+			}
+			return nil, "", fmt.Errorf("format %s requires a private key, which this certificate does not have", format)
+		default:
+			return nil, "", fmt.Errorf("unsupported format: %s", format)
+		}
+	}
+
+	keyPEM, err := s.GetPrivateKeyPEM(&cert)
+	if err != nil {
+		return nil, "", err
 	}
 
 	switch format {
@@ -398,8 +425,9 @@ func (s *LegoService) GetCertificateBundle(certID uint, format DownloadFormat, p
 		return []byte(cert.ChainPEM), "fullchain.pem", nil
 
 	case DownloadFormatPFX:
-		if password == "" {
-			password = "changeit"
+		password, err := opts.keystorePassword()
+		if err != nil {
+			return nil, "", err
 		}
 		pfxData, err := createPFXV2([]byte(cert.CertPEM), keyPEM, password)
 		if err != nil {
@@ -414,11 +442,102 @@ func (s *LegoService) GetCertificateBundle(certID uint, format DownloadFormat, p
 		}
 		return zipData, "certificate.zip", nil
 
+	case DownloadFormatDER, DownloadFormatP7B:
+		return certificateOnlyBundle(&cert, format)
+
+	case DownloadFormatJKS, DownloadFormatJCEKS:
+		jceks := format == DownloadFormatJCEKS
+		data, err := createKeyStore(&cert, keyPEM, opts, jceks)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create keystore: %w", err)
+		}
+		if jceks {
+			return data, "certificate.jceks", nil
+		}
+		return data, "certificate.jks", nil
+
+	case DownloadFormatPKCS8:
+		data, err := createPKCS8(keyPEM, opts.Password)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "private.pkcs8.key", nil
+
+	case DownloadFormatHAProxy:
+		return createHAProxyPEM(&cert, keyPEM), "haproxy.pem", nil
+
+	case DownloadFormatK8s:
+		data, err := createKubernetesSecret(&cert, keyPEM, opts.Namespace)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, kubernetesSecretName(&cert) + ".yaml", nil
+
+	case DownloadFormatProfile:
+		data, err := createProfileZip(&cert, keyPEM, opts)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create profile: %w", err)
+		}
+		return data, "server-profile.zip", nil
+
 	default:
 		return nil, "", fmt.Errorf("unsupported format: %s", format)
 	}
 }
 
+// certificateOnlyBundle returns the formats that need no private key
+func certificateOnlyBundle(cert *model.Certificate, format DownloadFormat) ([]byte, string, error) {
+	if format == DownloadFormatDER {
+		data, err := createDER(cert)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "certificate.der", nil
+	}
+	data, err := createPKCS7(cert)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create PKCS#7: %w", err)
+	}
+	return data, "chain.p7b", nil
+}
+
+// GetPrivateKeyPEM returns the private key of an issued certificate, or
+// ErrKeyNotExportable when its backend keeps keys inside.
+func (s *LegoService) GetPrivateKeyPEM(cert *model.Certificate) ([]byte, error) {
+	if cert.KeyPEM == "" {
+		return nil, fmt.Errorf("certificate has no private key")
+	}
+	backend, err := s.keys.Get(cert.KeyBackend)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Export(context.Background(), cert.KeyPEM)
+}
+
+// keyExportable reports whether a certificate's private key can be exported
+func (s *LegoService) keyExportable(cert *model.Certificate) (bool, error) {
+	if cert.KeyPEM == "" {
+		return false, nil
+	}
+	backend, err := s.keys.Get(cert.KeyBackend)
+	if err != nil {
+		return false, err
+	}
+	return backend.Exportable(), nil
+}
+
+// DeleteKey removes a certificate's key from its backend, e.g. an HSM object
+func (s *LegoService) DeleteKey(cert *model.Certificate) error {
+	if cert.KeyPEM == "" {
+		return nil
+	}
+	backend, err := s.keys.Get(cert.KeyBackend)
+	if err != nil {
+		return err
+	}
+	return backend.Delete(context.Background(), cert.KeyPEM)
+}
+
 // getOrCreateAccount gets an existing ACME account or creates a new one.
 func (s *LegoService) getOrCreateAccount(ctx context.Context, email string) (*model.ACMEAccount, error) {
 	if email == "" {
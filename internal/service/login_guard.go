@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Failed attempts allowed before each further attempt has to wait
+	throttleFreeAttempts = 3
+	throttleMaxDelay     = 30 * time.Second
+	// A single IP may fail this many times the per-username threshold, which
+	// still stops password spraying across many usernames
+	ipThresholdFactor = 5
+)
+
+// LoginGuardService slows down and locks out repeated failed logins, per
+// username and per client IP
+type LoginGuardService struct {
+	db         *gorm.DB
+	settingSvc *SettingService
+	auditSvc   *AuditService
+}
+
+// NewLoginGuardService creates a new LoginGuardService
+func NewLoginGuardService(db *gorm.DB, settingSvc *SettingService, auditSvc *AuditService) *LoginGuardService {
+	return &LoginGuardService{
+		db:         db,
+		settingSvc: settingSvc,
+		auditSvc:   auditSvc,
+	}
+}
+
+// Wait returns how long the caller must wait before trying this username
+// from this IP again, zero when a login may be attempted now
+func (s *LoginGuardService) Wait(username, clientIP string) time.Duration {
+	policy := s.settingSvc.GetSecurityConfig()
+	now := time.Now()
+
+	var throttles []model.LoginThrottle
+	s.db.Where("(scope = ? AND `key` = ?) OR (scope = ? AND `key` = ?)",
+		model.ThrottleScopeUsername, throttleKey(username),
+		model.ThrottleScopeIP, clientIP,
+	).Find(&throttles)
+
+	var wait time.Duration
+	for _, t := range throttles {
+		if d := throttleWait(t, now, lockoutWindow(policy)); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Fail records a failed login. user is nil for unknown usernames.
+func (s *LoginGuardService) Fail(username, clientIP string, user *model.User, reason string) {
+	policy := s.settingSvc.GetSecurityConfig()
+
+	locked := s.fail(model.ThrottleScopeUsername, throttleKey(username), policy.LockoutThreshold, policy)
+	s.fail(model.ThrottleScopeIP, clientIP, policy.LockoutThreshold*ipThresholdFactor, policy)
+
+	entry := model.AuditLog{
+		ActorType:    model.AuditActorAnonymous,
+		ActorName:    truncate(username, 100),
+		Action:       model.AuditActionLoginFailed,
+		ResourceType: "user",
+		IP:           clientIP,
+		Outcome:      model.AuditOutcomeFailure,
+		Detail:       reason,
+	}
+	if user != nil {
+		entry.ActorType = model.AuditActorUser
+		entry.ActorID = user.ID
+		entry.ResourceID = fmt.Sprint(user.ID)
+	}
+	s.auditSvc.Record(&entry)
+
+	if locked {
+		entry.ID = 0
+		entry.Action = model.AuditActionAccountLocked
+		entry.Detail = fmt.Sprintf("%d failed logins, locked for %d minutes", policy.LockoutThreshold, policy.LockoutMinutes)
+		s.auditSvc.Record(&entry)
+	}
+}
+
+// fail counts one failure and reports whether it locked the key
+func (s *LoginGuardService) fail(scope, key string, threshold int, policy SecuritySettings) bool {
+	if key == "" {
+		return false
+	}
+	locked := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var t model.LoginThrottle
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND `key` = ?", scope, key).First(&t).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now()
+		wasLocked := t.LockedUntil != nil && now.Before(*t.LockedUntil)
+		t.Scope, t.Key = scope, key
+		t = recordFailure(t, now, threshold, lockoutWindow(policy))
+		locked = !wasLocked && t.LockedUntil != nil
+		return tx.Save(&t).Error
+	})
+	return err == nil && locked
+}
+
+// Succeed clears the username's failures. The IP counter is left alone so
+// one valid account cannot reset it.
+func (s *LoginGuardService) Succeed(user *model.User, clientIP string) {
+	s.Unlock(user.Username)
+	s.auditSvc.RecordUser(user, model.AuditActionLogin, clientIP, model.AuditLog{
+		ResourceType: "user",
+		ResourceID:   fmt.Sprint(user.ID),
+	})
+}
+
+// Unlock clears a username's failed attempts and lockout
+func (s *LoginGuardService) Unlock(username string) error {
+	return s.db.Where("scope = ? AND `key` = ?", model.ThrottleScopeUsername, throttleKey(username)).
+		Delete(&model.LoginThrottle{}).Error
+}
+
+// StepUpWait returns how long a signed-in user must wait before the next
+// step-up or re-authentication attempt. Failures are counted apart from logins
+// so a stolen session cannot lock the owner out of signing in.
+func (s *LoginGuardService) StepUpWait(userID uint) time.Duration {
+	policy := s.settingSvc.GetSecurityConfig()
+
+	var t model.LoginThrottle
+	if err := s.db.Where("scope = ? AND `key` = ?", model.ThrottleScopeStepUp, stepUpKey(userID)).
+		First(&t).Error; err != nil {
+		return 0
+	}
+	return throttleWait(t, time.Now(), lockoutWindow(policy))
+}
+
+// StepUpFail records a wrong password or second factor given to re-authenticate
+func (s *LoginGuardService) StepUpFail(user *model.User, clientIP, reason string) {
+	policy := s.settingSvc.GetSecurityConfig()
+	locked := s.fail(model.ThrottleScopeStepUp, stepUpKey(user.ID), policy.LockoutThreshold, policy)
+
+	entry := model.AuditLog{
+		ResourceType: "user",
+		ResourceID:   fmt.Sprint(user.ID),
+		Outcome:      model.AuditOutcomeFailure,
+		Detail:       reason,
+	}
+	s.auditSvc.RecordUser(user, model.AuditActionStepUpFailed, clientIP, entry)
+	if locked {
+		entry.Detail = fmt.Sprintf("%d failed re-authentications, locked for %d minutes", policy.LockoutThreshold, policy.LockoutMinutes)
+		s.auditSvc.RecordUser(user, model.AuditActionStepUpLocked, clientIP, entry)
+	}
+}
+
+// StepUpSucceed clears a user's failed re-authentications
+func (s *LoginGuardService) StepUpSucceed(userID uint) {
+	s.db.Where("scope = ? AND `key` = ?", model.ThrottleScopeStepUp, stepUpKey(userID)).
+		Delete(&model.LoginThrottle{})
+}
+
+// LockedUntil returns the active lockouts among the given usernames
+func (s *LoginGuardService) LockedUntil(usernames []string) map[string]time.Time {
+	byKey := make(map[string]string, len(usernames))
+	keys := make([]string, 0, len(usernames))
+	for _, u := range usernames {
+		byKey[throttleKey(u)] = u
+		keys = append(keys, throttleKey(u))
+	}
+
+	locked := make(map[string]time.Time)
+	if len(keys) == 0 {
+		return locked
+	}
+	var throttles []model.LoginThrottle
+	s.db.Where("scope = ? AND `key` IN ? AND locked_until > ?", model.ThrottleScopeUsername, keys, time.Now()).
+		Find(&throttles)
+	for _, t := range throttles {
+		locked[byKey[t.Key]] = *t.LockedUntil
+	}
+	return locked
+}
+
+func throttleKey(username string) string {
+	return truncate(strings.ToLower(strings.TrimSpace(username)), 100)
+}
+
+func stepUpKey(userID uint) string {
+	return fmt.Sprint(userID)
+}
+
+func lockoutWindow(policy SecuritySettings) time.Duration {
+	return time.Duration(policy.LockoutMinutes) * time.Minute
+}
+
+// throttleDelay is the wait before the next attempt after n failures:
+// none for the first few, then doubling up to throttleMaxDelay
+func throttleDelay(failures int) time.Duration {
+	if failures < throttleFreeAttempts {
+		return 0
+	}
+	shift := failures - throttleFreeAttempts
+	if shift > 5 {
+		return throttleMaxDelay
+	}
+	d := time.Second << shift
+	if d > throttleMaxDelay {
+		return throttleMaxDelay
+	}
+	return d
+}
+
+// throttleWait is how long a key must still wait at now. Failures older
+// than the window are forgotten.
+func throttleWait(t model.LoginThrottle, now time.Time, window time.Duration) time.Duration {
+	if t.LockedUntil != nil {
+		if now.Before(*t.LockedUntil) {
+			return t.LockedUntil.Sub(now)
+		}
+		return 0
+	}
+	if now.Sub(t.LastFailedAt) > window {
+		return 0
+	}
+	if d := throttleDelay(t.Failures) - now.Sub(t.LastFailedAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// recordFailure returns the throttle state after one more failure at now.
+// Reaching the threshold locks the key for the window; an expired lock or
+// stale failures start the count over.
+func recordFailure(t model.LoginThrottle, now time.Time, threshold int, window time.Duration) model.LoginThrottle {
+	expired := (t.LockedUntil != nil && !now.Before(*t.LockedUntil)) ||
+		(t.LockedUntil == nil && now.Sub(t.LastFailedAt) > window)
+	if expired {
+		t.Failures = 0
+		t.LockedUntil = nil
+	}
+
+	t.Failures++
+	t.LastFailedAt = now
+	if t.LockedUntil == nil && t.Failures >= threshold {
+		until := now.Add(window)
+		t.LockedUntil = &until
+	}
+	return t
+}
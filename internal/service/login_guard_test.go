@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{7, 16 * time.Second},
+		{8, throttleMaxDelay},
+		{50, throttleMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := throttleDelay(tt.failures); got != tt.want {
+			t.Errorf("throttleDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRecordFailureLocksAtThreshold(t *testing.T) {
+	const threshold = 5
+	window := 15 * time.Minute
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var th model.LoginThrottle
+	for i := 1; i < threshold; i++ {
+		th = recordFailure(th, now, threshold, window)
+		if th.LockedUntil != nil {
+			t.Fatalf("locked after %d failures", i)
+		}
+	}
+	th = recordFailure(th, now, threshold, window)
+	if th.LockedUntil == nil || !th.LockedUntil.Equal(now.Add(window)) {
+		t.Fatalf("LockedUntil = %v, want %v", th.LockedUntil, now.Add(window))
+	}
+	if got := throttleWait(th, now.Add(time.Minute), window); got != window-time.Minute {
+		t.Errorf("wait while locked = %v, want %v", got, window-time.Minute)
+	}
+
+	// Once the lock expires the next failure starts a new count
+	later := now.Add(window + time.Second)
+	if got := throttleWait(th, later, window); got != 0 {
+		t.Errorf("wait after lock = %v, want 0", got)
+	}
+	th = recordFailure(th, later, threshold, window)
+	if th.Failures != 1 || th.LockedUntil != nil {
+		t.Errorf("after expiry: failures = %d, locked = %v", th.Failures, th.LockedUntil)
+	}
+}
+
+func TestThrottleWaitForgetsStaleFailures(t *testing.T) {
+	window := 15 * time.Minute
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	th := model.LoginThrottle{Failures: 4, LastFailedAt: now}
+
+	if got := throttleWait(th, now.Add(500*time.Millisecond), window); got != 1500*time.Millisecond {
+		t.Errorf("wait = %v, want 1.5s", got)
+	}
+	if got := throttleWait(th, now.Add(3*time.Second), window); got != 0 {
+		t.Errorf("wait after delay = %v, want 0", got)
+	}
+
+	th = recordFailure(th, now.Add(window+time.Minute), 10, window)
+	if th.Failures != 1 {
+		t.Errorf("failures after a quiet window = %d, want 1", th.Failures)
+	}
+}
+
+// A stolen session guessing step-up codes or the re-authentication password
+// is slowed down after a few misses and locked out at the login threshold
+func TestStepUpFailuresLockOut(t *testing.T) {
+	policy := SecuritySettings{LockoutThreshold: 10, LockoutMinutes: 15}
+	window := lockoutWindow(policy)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	th := model.LoginThrottle{Scope: model.ThrottleScopeStepUp, Key: stepUpKey(42)}
+	for i := 1; i <= policy.LockoutThreshold; i++ {
+		// Every guess waits out the throttle first, as StepUpWait enforces
+		now = now.Add(throttleWait(th, now, window))
+		th = recordFailure(th, now, policy.LockoutThreshold, window)
+		if i == throttleFreeAttempts && throttleWait(th, now, window) == 0 {
+			t.Errorf("no delay after %d failed step-ups", i)
+		}
+		if i < policy.LockoutThreshold && th.LockedUntil != nil {
+			t.Fatalf("locked after %d failed step-ups", i)
+		}
+	}
+	if th.LockedUntil == nil {
+		t.Fatalf("not locked after %d failed step-ups", policy.LockoutThreshold)
+	}
+	if got := throttleWait(th, now, window); got != window {
+		t.Errorf("wait after lockout = %v, want %v", got, window)
+	}
+	if stepUpKey(42) != "42" {
+		t.Errorf("stepUpKey(42) = %q", stepUpKey(42))
+	}
+}
@@ -0,0 +1,945 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/crypto"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMFAChallengeInvalid  = errors.New("verification expired, please log in again")
+	ErrMFAInvalidCode       = errors.New("invalid verification code")
+	ErrMFAMethodUnavailable = errors.New("this verification method is not available")
+	ErrMFANotEnrolled       = errors.New("two-factor authentication is not set up")
+	ErrMFAAlreadyEnabled    = errors.New("authenticator app is already enabled")
+	ErrMFARequired          = errors.New("two-factor authentication is required for your account")
+	ErrMFAUserDisabled      = errors.New("user is disabled")
+	ErrMFAStepUpRequired    = errors.New("re-authenticate to continue")
+	ErrMFASecondFactor      = errors.New("use your second factor to re-authenticate")
+	ErrWebAuthnFailed       = errors.New("passkey verification failed")
+	ErrPasskeyNotFound      = errors.New("passkey not found")
+)
+
+// StepUpPassword is the step-up method for users without a second factor
+const StepUpPassword = "password"
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	mfaEnrollTTL      = 15 * time.Minute
+	stepUpTTL         = 5 * time.Minute
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // Accepted steps either side of now, for clock drift
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAService manages second factors (authenticator apps, passkeys and
+// recovery codes), the second login step and step-up re-authentication
+type MFAService struct {
+	db         *gorm.DB
+	settingSvc *SettingService
+	encryptor  *crypto.Encryptor  // nil in mock mode; TOTP is then unavailable
+	webAuthn   *webauthn.WebAuthn // nil unless the relying party is configured
+	issuer     string
+}
+
+// NewMFAService creates a new MFAService. It fails on an invalid WebAuthn configuration.
+func NewMFAService(db *gorm.DB, cfg *config.MFAConfig, settingSvc *SettingService, encryptor *crypto.Encryptor) (*MFAService, error) {
+	s := &MFAService{
+		db:         db,
+		settingSvc: settingSvc,
+		encryptor:  encryptor,
+		issuer:     cfg.Issuer,
+	}
+	if s.issuer == "" {
+		s.issuer = "ACME Console"
+	}
+
+	if cfg.WebAuthn.RPID != "" {
+		displayName := cfg.WebAuthn.RPDisplayName
+		if displayName == "" {
+			displayName = s.issuer
+		}
+		w, err := webauthn.New(&webauthn.Config{
+			RPID:          cfg.WebAuthn.RPID,
+			RPDisplayName: displayName,
+			RPOrigins:     cfg.WebAuthn.RPOrigins,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid webauthn config: %w", err)
+		}
+		s.webAuthn = w
+	}
+	return s, nil
+}
+
+// Methods returns the second factors users can enroll
+func (s *MFAService) Methods() []string {
+	methods := []string{}
+	if s.encryptor != nil {
+		methods = append(methods, model.MFAMethodTOTP)
+	}
+	if s.webAuthn != nil {
+		methods = append(methods, model.MFAMethodWebAuthn)
+	}
+	return methods
+}
+
+// userMethods returns the second factors a user can log in with
+func (s *MFAService) userMethods(userID uint) []string {
+	methods := []string{}
+	var count int64
+	s.db.Model(&model.UserTOTP{}).Where("user_id = ? AND enabled = ?", userID, true).Count(&count)
+	if count > 0 && s.encryptor != nil {
+		methods = append(methods, model.MFAMethodTOTP)
+	}
+	s.db.Model(&model.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	if count > 0 && s.webAuthn != nil {
+		methods = append(methods, model.MFAMethodWebAuthn)
+	}
+	s.db.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	if count > 0 {
+		methods = append(methods, model.MFAMethodRecovery)
+	}
+	return methods
+}
+
+// Enrolled reports whether the user has a confirmed second factor
+func (s *MFAService) Enrolled(userID uint) bool {
+	var count int64
+	s.db.Model(&model.UserTOTP{}).Where("user_id = ? AND enabled = ?", userID, true).Count(&count)
+	if count > 0 {
+		return true
+	}
+	s.db.Model(&model.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	return count > 0
+}
+
+// Required reports whether the security policy makes a second factor
+// mandatory for the user. Nothing is enforced while no method is available.
+func (s *MFAService) Required(user *model.User) bool {
+	if len(s.Methods()) == 0 {
+		return false
+	}
+	policy := s.settingSvc.GetSecurityConfig()
+	if policy.MFARequireAdmins && user.IsAdmin() {
+		return true
+	}
+	if policy.MFARequireWorkspaceOwners {
+		var count int64
+		s.db.Model(&model.WorkspaceMember{}).
+			Where("user_id = ? AND role = ?", user.ID, model.WorkspaceRoleOwner).
+			Count(&count)
+		return count > 0
+	}
+	return false
+}
+
+// MFAStatus is the user's own view of their second factors
+type MFAStatus struct {
+	TOTPEnabled       bool                       `json:"totp_enabled"`
+	Passkeys          []model.WebAuthnCredential `json:"passkeys"`
+	RecoveryCodesLeft int64                      `json:"recovery_codes_left"`
+	Required          bool                       `json:"required"`
+	Methods           []string                   `json:"methods"` // Methods available to enroll
+}
+
+// Status returns the user's second factors
+func (s *MFAService) Status(user *model.User) (*MFAStatus, error) {
+	status := &MFAStatus{
+		Passkeys: []model.WebAuthnCredential{},
+		Required: s.Required(user),
+		Methods:  s.Methods(),
+	}
+	var totp model.UserTOTP
+	if err := s.db.Where("user_id = ?", user.ID).First(&totp).Error; err == nil {
+		status.TOTPEnabled = totp.Enabled
+	}
+	if err := s.db.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&status.Passkeys).Error; err != nil {
+		return nil, err
+	}
+	s.db.Model(&model.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&status.RecoveryCodesLeft)
+	return status, nil
+}
+
+// MFALoginChallenge is returned instead of a session when the password was
+// right but a second factor is still needed
+type MFALoginChallenge struct {
+	MFARequired        bool     `json:"mfa_required"`
+	EnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	MFAToken           string   `json:"mfa_token"`
+	Methods            []string `json:"methods"`
+	ExpiresIn          int64    `json:"expires_in"`
+}
+
+// BeginLogin returns the challenge a user who passed the first factor must
+// complete, or nil when they may log in right away
+func (s *MFAService) BeginLogin(user *model.User) (*MFALoginChallenge, error) {
+	purpose, ttl, methods := model.MFAPurposeLogin, mfaChallengeTTL, s.userMethods(user.ID)
+	if len(methods) == 0 {
+		if !s.Required(user) {
+			return nil, nil
+		}
+		purpose, ttl, methods = model.MFAPurposeEnroll, mfaEnrollTTL, s.Methods()
+	}
+
+	token, err := s.newChallenge(&model.MFAChallenge{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MFALoginChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: purpose == model.MFAPurposeEnroll,
+		MFAToken:           token,
+		Methods:            methods,
+		ExpiresIn:          int64(ttl.Seconds()),
+	}, nil
+}
+
+// LoginChallengeUser returns the user a login challenge is for, without using it up
+func (s *MFAService) LoginChallengeUser(token string) (*model.User, error) {
+	c, err := s.challenge(token, model.MFAPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	return s.activeUser(c.UserID)
+}
+
+// VerifyLogin completes a login challenge with a TOTP or recovery code
+func (s *MFAService) VerifyLogin(token, method, code string) (*model.User, error) {
+	c, err := s.challenge(token, model.MFAPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyCode(c.UserID, method, code); err != nil {
+		s.failAttempt(c)
+		return nil, err
+	}
+	return s.finish(c)
+}
+
+// BeginPasskeyLogin returns the assertion options for a login challenge
+func (s *MFAService) BeginPasskeyLogin(token string) (*protocol.CredentialAssertion, error) {
+	c, err := s.challenge(token, model.MFAPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	return s.beginPasskeyAssertion(c)
+}
+
+// VerifyPasskeyLogin completes a login challenge with a passkey assertion
+func (s *MFAService) VerifyPasskeyLogin(token string, response []byte) (*model.User, error) {
+	c, err := s.challenge(token, model.MFAPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyPasskeyAssertion(c, response); err != nil {
+		s.failAttempt(c)
+		return nil, err
+	}
+	return s.finish(c)
+}
+
+// EnrollmentUser returns the user behind an enrolment challenge, who may set
+// up a second factor before having a session
+func (s *MFAService) EnrollmentUser(token string) (*model.User, error) {
+	c, err := s.challenge(token, model.MFAPurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	return s.activeUser(c.UserID)
+}
+
+// CompleteEnrollment ends an enrolment challenge once a second factor is set up
+func (s *MFAService) CompleteEnrollment(token string) (*model.User, error) {
+	c, err := s.challenge(token, model.MFAPurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Enrolled(c.UserID) {
+		return nil, ErrMFANotEnrolled
+	}
+	return s.finish(c)
+}
+
+// TOTPSetup is shown once while enrolling an authenticator app
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"` // otpauth:// URI, for QR codes
+}
+
+// SetupTOTP generates a new authenticator app secret. It only becomes a
+// second factor once EnableTOTP confirms a first code.
+func (s *MFAService) SetupTOTP(user *model.User) (*TOTPSetup, error) {
+	if s.encryptor == nil {
+		return nil, ErrMFAMethodUnavailable
+	}
+	var count int64
+	s.db.Model(&model.UserTOTP{}).Where("user_id = ? AND enabled = ?", user.ID, true).Count(&count)
+	if count > 0 {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	secret := totpEncoding.EncodeToString(key)
+	encrypted, err := s.encryptor.EncryptString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.UserTOTP{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.UserTOTP{UserID: user.ID, Secret: encrypted}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPSetup{Secret: secret, URL: totpURL(s.issuer, user.Username, secret)}, nil
+}
+
+// EnableTOTP confirms the authenticator app with a first code. Recovery
+// codes are returned when this is the user's first second factor.
+func (s *MFAService) EnableTOTP(userID uint, code string) ([]string, error) {
+	first := !s.Enrolled(userID)
+	if err := s.verifyTOTP(userID, code, false); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&model.UserTOTP{}).Where("user_id = ?", userID).Update("enabled", true).Error; err != nil {
+		return nil, err
+	}
+	logger.Info("Authenticator app enabled", logger.Uint("user_id", userID))
+	if !first {
+		return nil, nil
+	}
+	return s.RegenerateRecoveryCodes(userID)
+}
+
+// DisableTOTP removes the authenticator app after a step-up
+func (s *MFAService) DisableTOTP(user *model.User, stepUpToken string) error {
+	if err := s.CheckStepUp(user.ID, stepUpToken); err != nil {
+		return err
+	}
+	return s.removeFactor(user, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("user_id = ?", user.ID).Delete(&model.UserTOTP{})
+	})
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create
+func (s *MFAService) BeginPasskeyRegistration(user *model.User) (*protocol.CredentialCreation, error) {
+	if s.webAuthn == nil {
+		return nil, ErrMFAMethodUnavailable
+	}
+	wu, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+	creation, session, err := s.webAuthn.BeginRegistration(wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.creds).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+
+	// One pending registration per user
+	s.db.Where("user_id = ? AND purpose = ?", user.ID, model.MFAPurposeRegister).Delete(&model.MFAChallenge{})
+	if _, err := s.newChallenge(&model.MFAChallenge{
+		UserID:    user.ID,
+		Purpose:   model.MFAPurposeRegister,
+		Session:   string(data),
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}); err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation and
+// stores the passkey. Recovery codes are returned when this is the user's
+// first second factor.
+func (s *MFAService) FinishPasskeyRegistration(user *model.User, name string, response []byte) (*model.WebAuthnCredential, []string, error) {
+	if s.webAuthn == nil {
+		return nil, nil, ErrMFAMethodUnavailable
+	}
+	var c model.MFAChallenge
+	if err := s.db.Where("user_id = ? AND purpose = ? AND expires_at > ?", user.ID, model.MFAPurposeRegister, time.Now()).
+		Order("id DESC").First(&c).Error; err != nil {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(c.Session), &session); err != nil {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, nil, ErrWebAuthnFailed
+	}
+	wu, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	cred, err := s.webAuthn.CreateCredential(wu, session, parsed)
+	if err != nil {
+		logger.Warn("Passkey registration failed", logger.Uint("user_id", user.ID), logger.Err(err))
+		return nil, nil, ErrWebAuthnFailed
+	}
+	if err := s.consume(&c); err != nil {
+		return nil, nil, err
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return nil, nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	first := !s.Enrolled(user.ID)
+	record := &model.WebAuthnCredential{
+		UserID:       user.ID,
+		Name:         truncate(name, 100),
+		CredentialID: base64.RawURLEncoding.EncodeToString(cred.ID),
+		Data:         string(data),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to save passkey: %w", err)
+	}
+	logger.Info("Passkey registered", logger.Uint("user_id", user.ID))
+
+	if !first {
+		return record, nil, nil
+	}
+	codes, err := s.RegenerateRecoveryCodes(user.ID)
+	return record, codes, err
+}
+
+// DeletePasskey removes a passkey after a step-up
+func (s *MFAService) DeletePasskey(user *model.User, id uint, stepUpToken string) error {
+	if err := s.CheckStepUp(user.ID, stepUpToken); err != nil {
+		return err
+	}
+	var count int64
+	s.db.Model(&model.WebAuthnCredential{}).Where("id = ? AND user_id = ?", id, user.ID).Count(&count)
+	if count == 0 {
+		return ErrPasskeyNotFound
+	}
+	return s.removeFactor(user, func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id = ? AND user_id = ?", id, user.ID).Delete(&model.WebAuthnCredential{})
+	})
+}
+
+// removeFactor deletes a second factor. The last one cannot be removed while
+// the policy requires two-factor authentication, and recovery codes go with it.
+func (s *MFAService) removeFactor(user *model.User, remove func(tx *gorm.DB) *gorm.DB) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := remove(tx).Error; err != nil {
+			return err
+		}
+		var totps, passkeys int64
+		tx.Model(&model.UserTOTP{}).Where("user_id = ? AND enabled = ?", user.ID, true).Count(&totps)
+		tx.Model(&model.WebAuthnCredential{}).Where("user_id = ?", user.ID).Count(&passkeys)
+		if totps+passkeys > 0 {
+			return nil
+		}
+		if s.Required(user) {
+			return ErrMFARequired
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&model.RecoveryCode{}).Error
+	})
+}
+
+// ResetUser removes every second factor of a user, for admins helping users
+// who lost their devices
+func (s *MFAService) ResetUser(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return deleteUserMFA(tx, userID)
+	})
+}
+
+// deleteUserMFA removes a user's second factors and pending challenges
+func deleteUserMFA(tx *gorm.DB, userID uint) error {
+	for _, table := range []interface{}{&model.UserTOTP{}, &model.WebAuthnCredential{}, &model.RecoveryCode{}, &model.MFAChallenge{}} {
+		if err := tx.Where("user_id = ?", userID).Delete(table).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes. The codes are
+// only stored hashed, so this is the one time they are shown.
+func (s *MFAService) RegenerateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]model.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, model.RecoveryCode{UserID: userID, CodeHash: hashDeployToken(normalizeRecoveryCode(code))})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodesWithStepUp is RegenerateRecoveryCodes for users
+// managing their own factors
+func (s *MFAService) RegenerateRecoveryCodesWithStepUp(userID uint, stepUpToken string) ([]string, error) {
+	if err := s.CheckStepUp(userID, stepUpToken); err != nil {
+		return nil, err
+	}
+	if !s.Enrolled(userID) {
+		return nil, ErrMFANotEnrolled
+	}
+	return s.RegenerateRecoveryCodes(userID)
+}
+
+// StepUpGrant is a verified re-authentication, sent back in the
+// X-Step-Up-Token header for sensitive actions
+type StepUpGrant struct {
+	StepUpToken string `json:"step_up_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// StepUpWithCode re-authenticates with a TOTP or recovery code
+func (s *MFAService) StepUpWithCode(userID uint, method, code string) (*StepUpGrant, error) {
+	if err := s.verifyCode(userID, method, code); err != nil {
+		return nil, err
+	}
+	return s.grantStepUp(userID)
+}
+
+// StepUpWithPassword grants a step-up to users without a second factor once
+// the caller has checked their password
+func (s *MFAService) StepUpWithPassword(userID uint) (*StepUpGrant, error) {
+	if s.Enrolled(userID) {
+		return nil, ErrMFASecondFactor
+	}
+	return s.grantStepUp(userID)
+}
+
+// BeginStepUpPasskey prepares a passkey assertion for re-authentication
+func (s *MFAService) BeginStepUpPasskey(userID uint) (string, *protocol.CredentialAssertion, error) {
+	c := &model.MFAChallenge{
+		UserID:    userID,
+		Purpose:   model.MFAPurposeStepUp,
+		ExpiresAt: time.Now().Add(mfaChallengeTTL),
+	}
+	token, err := s.newChallenge(c)
+	if err != nil {
+		return "", nil, err
+	}
+	assertion, err := s.beginPasskeyAssertion(c)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, assertion, nil
+}
+
+// StepUpWithPasskey re-authenticates with the assertion for a BeginStepUpPasskey challenge
+func (s *MFAService) StepUpWithPasskey(userID uint, token string, response []byte) (*StepUpGrant, error) {
+	c, err := s.challenge(token, model.MFAPurposeStepUp)
+	if err != nil {
+		return nil, err
+	}
+	if c.UserID != userID {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if err := s.verifyPasskeyAssertion(c, response); err != nil {
+		s.failAttempt(c)
+		return nil, err
+	}
+	if err := s.consume(c); err != nil {
+		return nil, err
+	}
+	return s.grantStepUp(userID)
+}
+
+// CheckStepUp verifies a step-up token. Grants stay valid for a few minutes,
+// so one re-authentication covers a short series of sensitive actions.
+func (s *MFAService) CheckStepUp(userID uint, token string) error {
+	if token == "" {
+		return ErrMFAStepUpRequired
+	}
+	var count int64
+	s.db.Model(&model.MFAChallenge{}).
+		Where("token_hash = ? AND user_id = ? AND purpose = ? AND verified_at IS NOT NULL AND expires_at > ?",
+			hashDeployToken(token), userID, model.MFAPurposeStepUp, time.Now()).
+		Count(&count)
+	if count == 0 {
+		return ErrMFAStepUpRequired
+	}
+	return nil
+}
+
+func (s *MFAService) grantStepUp(userID uint) (*StepUpGrant, error) {
+	now := time.Now()
+	token, err := s.newChallenge(&model.MFAChallenge{
+		UserID:     userID,
+		Purpose:    model.MFAPurposeStepUp,
+		VerifiedAt: &now,
+		ExpiresAt:  now.Add(stepUpTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &StepUpGrant{StepUpToken: token, ExpiresIn: int64(stepUpTTL.Seconds())}, nil
+}
+
+// newChallenge stores a challenge under a fresh random token and returns the token
+func (s *MFAService) newChallenge(c *model.MFAChallenge) (string, error) {
+	token, err := randomSecret()
+	if err != nil {
+		return "", err
+	}
+	c.TokenHash = hashDeployToken(token)
+
+	s.db.Where("expires_at < ?", time.Now()).Delete(&model.MFAChallenge{})
+	if err := s.db.Create(c).Error; err != nil {
+		return "", fmt.Errorf("failed to create challenge: %w", err)
+	}
+	return token, nil
+}
+
+// challenge loads a pending challenge that has not run out of time or attempts
+func (s *MFAService) challenge(token string, purpose string) (*model.MFAChallenge, error) {
+	if token == "" {
+		return nil, ErrMFAChallengeInvalid
+	}
+	var c model.MFAChallenge
+	err := s.db.Where("token_hash = ? AND purpose = ? AND verified_at IS NULL AND expires_at > ?",
+		hashDeployToken(token), purpose, time.Now()).First(&c).Error
+	if err != nil || c.Attempts >= mfaMaxAttempts {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return &c, nil
+}
+
+func (s *MFAService) failAttempt(c *model.MFAChallenge) {
+	s.db.Model(&model.MFAChallenge{}).Where("id = ?", c.ID).UpdateColumn("attempts", gorm.Expr("attempts + 1"))
+}
+
+// consume deletes a challenge; only the first of concurrent callers succeeds
+func (s *MFAService) consume(c *model.MFAChallenge) error {
+	result := s.db.Where("id = ?", c.ID).Delete(&model.MFAChallenge{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAChallengeInvalid
+	}
+	return nil
+}
+
+// finish consumes a completed login or enrolment challenge and returns its user
+func (s *MFAService) finish(c *model.MFAChallenge) (*model.User, error) {
+	if err := s.consume(c); err != nil {
+		return nil, err
+	}
+	return s.activeUser(c.UserID)
+}
+
+func (s *MFAService) activeUser(userID uint) (*model.User, error) {
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+	if user.Status != 1 {
+		return nil, ErrMFAUserDisabled
+	}
+	return &user, nil
+}
+
+func (s *MFAService) verifyCode(userID uint, method, code string) error {
+	switch method {
+	case model.MFAMethodTOTP:
+		return s.verifyTOTP(userID, code, true)
+	case model.MFAMethodRecovery:
+		return s.useRecoveryCode(userID, code)
+	default:
+		return ErrMFAMethodUnavailable
+	}
+}
+
+// verifyTOTP checks a code against the enabled secret, or the pending one
+// while enrolling. A code is accepted at most once.
+func (s *MFAService) verifyTOTP(userID uint, code string, enabled bool) error {
+	if s.encryptor == nil {
+		return ErrMFAMethodUnavailable
+	}
+	var totp model.UserTOTP
+	if err := s.db.Where("user_id = ? AND enabled = ?", userID, enabled).First(&totp).Error; err != nil {
+		return ErrMFANotEnrolled
+	}
+	secret, err := s.encryptor.DecryptString(totp.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return fmt.Errorf("invalid totp secret: %w", err)
+	}
+
+	step, ok := validateTOTP(key, code, time.Now())
+	if !ok || step <= totp.LastUsedStep {
+		return ErrMFAInvalidCode
+	}
+	result := s.db.Model(&model.UserTOTP{}).
+		Where("id = ? AND last_used_step < ?", totp.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+func (s *MFAService) useRecoveryCode(userID uint, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrMFAInvalidCode
+	}
+	result := s.db.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashDeployToken(normalized)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAInvalidCode
+	}
+	logger.Info("Recovery code used", logger.Uint("user_id", userID))
+	return nil
+}
+
+// webAuthnUser adapts a console user and their passkeys to the webauthn library
+type webAuthnUser struct {
+	user  *model.User
+	creds []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return webAuthnUserHandle(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Nickname != "" {
+		return u.user.Nickname
+	}
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.creds
+}
+
+// webAuthnUserHandle is the opaque user handle stored on authenticators
+func webAuthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+func (s *MFAService) loadWebAuthnUser(user *model.User) (*webAuthnUser, error) {
+	var records []model.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", user.ID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	wu := &webAuthnUser{user: user}
+	for _, record := range records {
+		var cred webauthn.Credential
+		if err := json.Unmarshal([]byte(record.Data), &cred); err != nil {
+			logger.Error("Invalid stored passkey", logger.Uint("id", record.ID), logger.Err(err))
+			continue
+		}
+		wu.creds = append(wu.creds, cred)
+	}
+	return wu, nil
+}
+
+// beginPasskeyAssertion starts a passkey ceremony and keeps it on the challenge
+func (s *MFAService) beginPasskeyAssertion(c *model.MFAChallenge) (*protocol.CredentialAssertion, error) {
+	if s.webAuthn == nil {
+		return nil, ErrMFAMethodUnavailable
+	}
+	user, err := s.activeUser(c.UserID)
+	if err != nil {
+		return nil, err
+	}
+	wu, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+	if len(wu.creds) == 0 {
+		return nil, ErrMFANotEnrolled
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(wu)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&model.MFAChallenge{}).Where("id = ?", c.ID).Update("session", string(data)).Error; err != nil {
+		return nil, err
+	}
+	c.Session = string(data)
+	return assertion, nil
+}
+
+// verifyPasskeyAssertion checks an assertion against the challenge's ceremony
+// and stores the authenticator's new signature counter
+func (s *MFAService) verifyPasskeyAssertion(c *model.MFAChallenge, response []byte) error {
+	if s.webAuthn == nil {
+		return ErrMFAMethodUnavailable
+	}
+	var session webauthn.SessionData
+	if c.Session == "" || json.Unmarshal([]byte(c.Session), &session) != nil {
+		return ErrMFAChallengeInvalid
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return ErrWebAuthnFailed
+	}
+	user, err := s.activeUser(c.UserID)
+	if err != nil {
+		return err
+	}
+	wu, err := s.loadWebAuthnUser(user)
+	if err != nil {
+		return err
+	}
+
+	cred, err := s.webAuthn.ValidateLogin(wu, session, parsed)
+	if err != nil {
+		logger.Warn("Passkey verification failed", logger.Uint("user_id", user.ID), logger.Err(err))
+		return ErrWebAuthnFailed
+	}
+	// A counter going backwards suggests a cloned authenticator
+	if cred.Authenticator.CloneWarning {
+		logger.Warn("Passkey signature counter went backwards", logger.Uint("user_id", user.ID))
+		return ErrWebAuthnFailed
+	}
+
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.db.Model(&model.WebAuthnCredential{}).
+		Where("user_id = ? AND credential_id = ?", user.ID, base64.RawURLEncoding.EncodeToString(cred.ID)).
+		Updates(map[string]interface{}{"data": string(data), "last_used_at": now}).Error
+}
+
+// totpCode computes the RFC 6238 code for a time step (HMAC-SHA1, 6 digits)
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// validateTOTP accepts codes for the current time step or one either side,
+// and returns the step the code belongs to
+func validateTOTP(key []byte, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpURL is the otpauth:// URI authenticator apps import from QR codes
+func totpURL(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + q.Encode()
+}
+
+// newRecoveryCode returns a code like "k3m9x-q2w7p"
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode ignores case, dashes and spaces in typed codes
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 appendix B, SHA-1, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/totpPeriod); got != tt.want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code := totpCode(key, current+offset)
+		step, ok := validateTOTP(key, code[:3]+" "+code[3:], now)
+		if !ok || step != current+offset {
+			t.Errorf("code for step %+d: step = %d, ok = %v", offset, step, ok)
+		}
+	}
+	for _, code := range []string{totpCode(key, current-2), totpCode(key, current+2), "", "12345", "1234567"} {
+		if _, ok := validateTOTP(key, code, now); ok {
+			t.Errorf("code %q must be rejected", code)
+		}
+	}
+}
+
+func TestTOTPURL(t *testing.T) {
+	raw := totpURL("ACME Console", "alice", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/ACME Console:alice" ||
+		q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "ACME Console" || q.Get("digits") != "6" {
+		t.Errorf("unexpected URL %s", raw)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 11 || code[5] != '-' || code != strings.ToLower(code) {
+			t.Errorf("unexpected code format %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	// Typed codes match whatever the case, dashes or spacing
+	if normalizeRecoveryCode(" K3M9X-Q2W7P ") != normalizeRecoveryCode("k3m9xq2w7p") {
+		t.Error("normalized codes differ")
+	}
+}
+
+func TestMFAMethods(t *testing.T) {
+	s, err := NewMFAService(nil, &config.MFAConfig{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Methods()) != 0 {
+		t.Errorf("methods = %v, want none without encryption or webauthn", s.Methods())
+	}
+	// Nothing can be enforced without a method to enroll
+	if s.Required(&model.User{Role: model.RoleAdmin}) {
+		t.Error("2FA must not be required without any method")
+	}
+
+	s, err = NewMFAService(nil, &config.MFAConfig{WebAuthn: config.WebAuthnConfig{
+		RPID:      "console.example.com",
+		RPOrigins: []string{"https://console.example.com"},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Methods(), []string{model.MFAMethodWebAuthn}) {
+		t.Errorf("methods = %v, want webauthn", s.Methods())
+	}
+
+	if _, err := NewMFAService(nil, &config.MFAConfig{WebAuthn: config.WebAuthnConfig{RPID: "console.example.com"}}, nil, nil); err == nil {
+		t.Error("webauthn without origins must fail")
+	}
+}
@@ -516,6 +516,40 @@ func (s *NotificationService) SendRenewalNotification(certID uint, eventType str
 	}
 }
 
+// SendDeploymentNotification notifies all enabled configs for a certificate that pushing it to a target failed.
+func (s *NotificationService) SendDeploymentNotification(certID uint, targetName, errMsg string) {
+	var cert model.Certificate
+	if err := s.db.First(&cert, certID).Error; err != nil {
+		fmt.Printf("SendDeploymentNotification: failed to load cert %d: %v\n", certID, err)
+		return
+	}
+
+	var configs []model.NotificationConfig
+	s.db.Where("enabled = ? AND (certificate_id = ? OR certificate_id IS NULL)", true, certID).
+		Find(&configs)
+
+	domains := s.parseDomains(cert.Domains)
+	domainStr := ""
+	if len(domains) > 0 {
+		domainStr = domains[0]
+	}
+
+	payload := map[string]any{
+		"event":     "deployment_failed",
+		"cert_id":   cert.ID,
+		"domains":   domains,
+		"target":    targetName,
+		"message":   fmt.Sprintf("Certificate #%d (%s) deployment to %s failed: %s", cert.ID, domainStr, targetName, errMsg),
+		"timestamp": time.Now().Unix(),
+	}
+
+	for _, config := range configs {
+		if err := s.sendHTTPPost(config.WebhookURL, payload); err != nil {
+			fmt.Printf("SendDeploymentNotification: failed to send to config %d: %v\n", config.ID, err)
+		}
+	}
+}
+
 // TestWebhook sends a test notification
 func (s *NotificationService) TestWebhook(configID uint) error {
 	config, err := s.GetConfig(configID)
@@ -0,0 +1,545 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/imkerbos/ACME-Console/internal/config"
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("unknown single sign-on provider")
+	ErrOIDCLoginExpired     = errors.New("single sign-on session is invalid or expired, please try again")
+	ErrOIDCLoginFailed      = errors.New("single sign-on failed")
+	ErrOIDCNotProvisioned   = errors.New("no console account is linked to this identity")
+	ErrOIDCUserDisabled     = errors.New("user is disabled")
+)
+
+// PasswordLoginAdmins restricts local password login to system administrators
+const PasswordLoginAdmins = "admins"
+
+const (
+	OIDCLoginTTL    = 10 * time.Minute // From redirect to provider callback
+	oidcExchangeTTL = time.Minute      // From callback to the console picking up the session
+	oidcHTTPTimeout = 10 * time.Second
+)
+
+// OIDCService logs users in with OpenID Connect (authorization code + PKCE)
+// and provisions them from ID token claims
+type OIDCService struct {
+	db          *gorm.DB
+	cfg         *config.OIDCConfig
+	invitations *InvitationService
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider // Discovered lazily so an unreachable provider does not block startup
+}
+
+// NewOIDCService creates a new OIDCService
+func NewOIDCService(db *gorm.DB, cfg *config.OIDCConfig) *OIDCService {
+	return &OIDCService{
+		db:        db,
+		cfg:       cfg,
+		providers: make(map[string]*oidcProvider),
+	}
+}
+
+// OIDCProviderInfo is what the login page shows for a provider
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OIDCClaims are the ID token (and userinfo) claims the console uses
+type OIDCClaims struct {
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// SetInvitations lets a login that starts on an invitation page create the
+// account and accept the invitation
+func (s *OIDCService) SetInvitations(invitations *InvitationService) {
+	s.invitations = invitations
+}
+
+// Providers lists the configured providers
+func (s *OIDCService) Providers() []OIDCProviderInfo {
+	result := make([]OIDCProviderInfo, 0, len(s.cfg.Providers))
+	for _, p := range s.cfg.Providers {
+		name := p.DisplayName
+		if name == "" {
+			name = p.Name
+		}
+		result = append(result, OIDCProviderInfo{Name: p.Name, DisplayName: name})
+	}
+	return result
+}
+
+// PasswordLoginAllowed reports whether the user may log in with a local password
+func (s *OIDCService) PasswordLoginAllowed(user *model.User) bool {
+	return s.cfg.PasswordLogin != PasswordLoginAdmins || user.IsAdmin()
+}
+
+// AdminsOnlyPasswordLogin reports whether local password login is limited to administrators
+func (s *OIDCService) AdminsOnlyPasswordLogin() bool {
+	return s.cfg.PasswordLogin == PasswordLoginAdmins
+}
+
+// LoginURL starts a login and returns the provider's authorization URL and
+// the state, which the caller binds to the browser. With an invitation token
+// the user joins that workspace once logged in, and is provisioned even where
+// the provider does not auto-provision.
+func (s *OIDCService) LoginURL(ctx context.Context, name, invitation string) (string, string, error) {
+	p, err := s.provider(ctx, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	var inviteHash string
+	if invitation != "" {
+		if s.invitations == nil {
+			return "", "", ErrInvitationInvalid
+		}
+		inviteHash = hashDeployToken(invitation)
+		if _, err := s.invitations.pending(s.db, inviteHash); err != nil {
+			return "", "", err
+		}
+	}
+
+	state, err := randomSecret()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomSecret()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	s.db.Where("expires_at < ?", time.Now()).Delete(&model.OIDCLogin{})
+	stateHash := hashDeployToken(state)
+	login := &model.OIDCLogin{
+		Provider:  name,
+		StateHash: &stateHash,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		ExpiresAt: time.Now().Add(OIDCLoginTTL),
+
+		InviteHash: inviteHash,
+	}
+	if err := s.db.Create(login).Error; err != nil {
+		return "", "", fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+	return p.authCodeURL(state, nonce, verifier), state, nil
+}
+
+// Callback completes a login: it redeems the authorization code, provisions
+// the user, accepts the invitation the login started from, if any, and returns
+// a one-time code for Exchange. browserState is the state LoginURL bound to
+// the browser; a callback from any other browser is refused, so a victim
+// cannot be logged in to an attacker's account.
+func (s *OIDCService) Callback(ctx context.Context, name, state, browserState, code, clientIP string) (string, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return "", ErrOIDCLoginExpired
+	}
+	var login model.OIDCLogin
+	err := s.db.Where("state_hash = ? AND provider = ?", hashDeployToken(state), name).First(&login).Error
+	if err != nil {
+		return "", ErrOIDCLoginExpired
+	}
+	// Each state is good for one callback
+	if result := s.db.Delete(&login); result.Error != nil || result.RowsAffected == 0 || time.Now().After(login.ExpiresAt) {
+		return "", ErrOIDCLoginExpired
+	}
+
+	p, err := s.provider(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	claims, err := p.exchange(ctx, code, login.Verifier, login.Nonce)
+	if err != nil {
+		logger.Warn("OIDC login failed", logger.String("provider", name), logger.Err(err))
+		return "", ErrOIDCLoginFailed
+	}
+
+	user, err := s.provision(p.cfg, claims, login.InviteHash != "")
+	if err != nil {
+		return "", err
+	}
+	if login.InviteHash != "" {
+		if err := s.invitations.acceptHash(login.InviteHash, user, clientIP); err != nil {
+			return "", err
+		}
+	}
+
+	exchange, err := randomSecret()
+	if err != nil {
+		return "", err
+	}
+	codeHash := hashDeployToken(exchange)
+	err = s.db.Create(&model.OIDCLogin{
+		Provider:  name,
+		CodeHash:  &codeHash,
+		UserID:    &user.ID,
+		ExpiresAt: time.Now().Add(oidcExchangeTTL),
+	}).Error
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("OIDC login",
+		logger.String("provider", name),
+		logger.Uint("user_id", user.ID),
+		logger.String("username", user.Username),
+	)
+	return exchange, nil
+}
+
+// Exchange redeems a one-time code from Callback for the logged-in user
+func (s *OIDCService) Exchange(code string) (*model.User, error) {
+	var login model.OIDCLogin
+	if err := s.db.Where("code_hash = ?", hashDeployToken(code)).First(&login).Error; err != nil {
+		return nil, ErrOIDCLoginExpired
+	}
+	if result := s.db.Delete(&login); result.Error != nil || result.RowsAffected == 0 || time.Now().After(login.ExpiresAt) || login.UserID == nil {
+		return nil, ErrOIDCLoginExpired
+	}
+
+	var user model.User
+	if err := s.db.First(&user, *login.UserID).Error; err != nil {
+		return nil, ErrOIDCLoginExpired
+	}
+	if user.Status != 1 {
+		return nil, ErrOIDCUserDisabled
+	}
+	return &user, nil
+}
+
+// provision finds or creates the user for the claims and applies role and
+// workspace mappings. Invited users are created even without auto_provision.
+func (s *OIDCService) provision(cfg config.OIDCProviderConfig, claims *OIDCClaims, invited bool) (*model.User, error) {
+	var user model.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var identity model.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", cfg.Name, claims.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, identity.UserID).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := s.findOrCreateUser(tx, cfg, claims, invited, &user); err != nil {
+				return err
+			}
+			identity = model.UserIdentity{UserID: user.ID, Provider: cfg.Name, Subject: claims.Subject}
+		default:
+			return err
+		}
+
+		if user.ServiceAccount {
+			return ErrOIDCNotProvisioned
+		}
+		if user.Status != 1 {
+			return ErrOIDCUserDisabled
+		}
+
+		identity.Email = claims.Email
+		identity.LastLoginAt = &now
+		if err := tx.Save(&identity).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"last_login": now}
+		if role, ok := groupGlobalRole(cfg.AdminGroups, claims.Groups); ok && role != user.Role {
+			updates["role"] = role
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return syncGroupMemberships(tx, user.ID, model.MemberSourceOIDC, cfg.Workspaces, claims.Groups)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *OIDCService) findOrCreateUser(tx *gorm.DB, cfg config.OIDCProviderConfig, claims *OIDCClaims, invited bool, user *model.User) error {
+	// Only addresses the console trusts are linked: anyone can type any
+	// address into their profile or a signup form
+	if cfg.LinkByEmail && claims.EmailVerified && claims.Email != "" {
+		err := tx.Where("email = ? AND email_verified = ? AND service_account = ?", claims.Email, true, false).
+			Order("id ASC").First(user).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	if !cfg.AutoProvision && !invited {
+		return ErrOIDCNotProvisioned
+	}
+
+	username, err := availableUsername(tx, claims.Username)
+	if err != nil {
+		return err
+	}
+	// SSO users have no usable local password until an admin sets one
+	secret, err := randomSecret()
+	if err != nil {
+		return err
+	}
+	*user = model.User{
+		Username: username,
+		Nickname: claims.Name,
+		Email:    claims.Email,
+		Role:     model.RoleUser,
+		Status:   1,
+
+		EmailVerified: claims.EmailVerified && claims.Email != "",
+	}
+	if err := user.SetPassword(secret); err != nil {
+		return err
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	logger.Info("User provisioned from OIDC",
+		logger.String("provider", cfg.Name),
+		logger.String("username", username),
+	)
+	return nil
+}
+
+// availableUsername returns base, or base with a numeric suffix if it is taken
+func availableUsername(tx *gorm.DB, base string) (string, error) {
+	if len(base) > 45 {
+		base = base[:45]
+	}
+	candidate := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := tx.Model(&model.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
+
+func (s *OIDCService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.providers[name]; ok {
+		return p, nil
+	}
+	for _, cfg := range s.cfg.Providers {
+		if cfg.Name != name {
+			continue
+		}
+		p, err := newOIDCProvider(ctx, cfg)
+		if err != nil {
+			logger.Error("Failed to load OIDC provider", logger.String("provider", name), logger.Err(err))
+			return nil, ErrOIDCLoginFailed
+		}
+		s.providers[name] = p
+		return p, nil
+	}
+	return nil, ErrOIDCProviderNotFound
+}
+
+// oidcProvider is one discovered provider
+type oidcProvider struct {
+	cfg      config.OIDCProviderConfig
+	client   *http.Client
+	provider *oidc.Provider
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(ctx context.Context, cfg config.OIDCProviderConfig) (*oidcProvider, error) {
+	client := &http.Client{Timeout: oidcHTTPTimeout}
+	// Keys are fetched later with this context, so it must outlive the request
+	keyCtx := oidc.ClientContext(context.Background(), client)
+
+	var provider *oidc.Provider
+	if cfg.AuthURL != "" && cfg.TokenURL != "" && cfg.JWKSURL != "" {
+		provider = (&oidc.ProviderConfig{
+			IssuerURL:   cfg.Issuer,
+			AuthURL:     cfg.AuthURL,
+			TokenURL:    cfg.TokenURL,
+			UserInfoURL: cfg.UserInfoURL,
+			JWKSURL:     cfg.JWKSURL,
+		}).NewProvider(keyCtx)
+	} else {
+		discoverCtx, cancel := context.WithTimeout(oidc.ClientContext(ctx, client), oidcHTTPTimeout)
+		defer cancel()
+		discovered, err := oidc.NewProvider(discoverCtx, cfg.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		// Re-create the provider from the discovered endpoints so key fetching
+		// is not tied to the discovery request's context
+		var endpoints struct {
+			UserInfoURL string `json:"userinfo_endpoint"`
+			JWKSURL     string `json:"jwks_uri"`
+		}
+		if err := discovered.Claims(&endpoints); err != nil {
+			return nil, err
+		}
+		provider = (&oidc.ProviderConfig{
+			IssuerURL:   cfg.Issuer,
+			AuthURL:     discovered.Endpoint().AuthURL,
+			TokenURL:    discovered.Endpoint().TokenURL,
+			UserInfoURL: endpoints.UserInfoURL,
+			JWKSURL:     endpoints.JWKSURL,
+		}).NewProvider(keyCtx)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &oidcProvider{
+		cfg:      cfg,
+		client:   client,
+		provider: provider,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+func (p *oidcProvider) authCodeURL(state, nonce, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// exchange redeems an authorization code and returns the verified claims
+func (p *oidcProvider) exchange(ctx context.Context, code, verifier, nonce string) (*OIDCClaims, error) {
+	ctx = oidc.ClientContext(ctx, p.client)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	// Some providers only put profile and group claims in userinfo
+	if p.provider.UserInfoEndpoint() != "" {
+		info, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			logger.Warn("OIDC userinfo request failed", logger.String("provider", p.cfg.Name), logger.Err(err))
+		} else if info.Subject == idToken.Subject {
+			extra := map[string]interface{}{}
+			if err := info.Claims(&extra); err == nil {
+				for k, v := range extra {
+					if _, ok := claims[k]; !ok {
+						claims[k] = v
+					}
+				}
+			}
+		}
+	}
+	return parseOIDCClaims(p.cfg, idToken.Subject, claims), nil
+}
+
+func parseOIDCClaims(cfg config.OIDCProviderConfig, subject string, raw map[string]interface{}) *OIDCClaims {
+	str := func(key string) string {
+		v, _ := raw[key].(string)
+		return v
+	}
+
+	claims := &OIDCClaims{
+		Subject: subject,
+		Email:   str("email"),
+		Name:    str("name"),
+	}
+	switch v := raw["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = v
+	case string:
+		claims.EmailVerified = v == "true"
+	}
+
+	usernameClaim := cfg.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	claims.Username = str(usernameClaim)
+	if claims.Username == "" {
+		claims.Username = claims.Email
+	}
+	if claims.Username == "" {
+		claims.Username = subject
+	}
+
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch v := raw[groupsClaim].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				claims.Groups = append(claims.Groups, s)
+			}
+		}
+	case string:
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				claims.Groups = append(claims.Groups, g)
+			}
+		}
+	}
+	return claims
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/imkerbos/ACME-Console/internal/config"
+)
+
+// mockOIDCProvider is a minimal OpenID provider: discovery, JWKS, a token
+// endpoint that checks PKCE, and userinfo
+type mockOIDCProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"userinfo_endpoint":                     m.URL + "/userinfo",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token": m.sign(t, map[string]interface{}{
+				"iss":                m.URL,
+				"sub":                "user-1",
+				"aud":                "console",
+				"exp":                time.Now().Add(time.Hour).Unix(),
+				"iat":                time.Now().Unix(),
+				"nonce":              m.nonce,
+				"preferred_username": "alice",
+				"email":              "alice@example.com",
+				"email_verified":     true,
+			}),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"sub":    "user-1",
+			"name":   "Alice",
+			"groups": []string{"platform", "pki-admins"},
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockOIDCProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: m.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(claims)
+	object, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := object.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// authorize records what the browser would send to the authorization endpoint
+func (m *mockOIDCProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if !strings.HasPrefix(authURL, m.URL+"/authorize") || q.Get("code_challenge_method") != "S256" || q.Get("state") != "state" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+	m.challenge = q.Get("code_challenge")
+	m.nonce = q.Get("nonce")
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	p, err := newOIDCProvider(context.Background(), config.OIDCProviderConfig{
+		Name:        "mock",
+		Issuer:      mock.URL,
+		ClientID:    "console",
+		RedirectURL: "https://console.example.com/api/v1/auth/oidc/mock/callback",
+	})
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+
+	mock.authorize(t, p.authCodeURL("state", "nonce-1", "verifier-verifier-verifier-verifier-verifier"))
+
+	if _, err := p.exchange(context.Background(), "good-code", "wrong-verifier-wrong-verifier-wrong-verifier", "nonce-1"); err == nil {
+		t.Error("a wrong PKCE verifier must fail")
+	}
+	if _, err := p.exchange(context.Background(), "good-code", "verifier-verifier-verifier-verifier-verifier", "other-nonce"); err == nil {
+		t.Error("a nonce mismatch must fail")
+	}
+
+	claims, err := p.exchange(context.Background(), "good-code", "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	want := &OIDCClaims{
+		Subject:       "user-1",
+		Username:      "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",                            // From userinfo
+		Groups:        []string{"platform", "pki-admins"}, // From userinfo
+	}
+	if !reflect.DeepEqual(claims, want) {
+		t.Errorf("claims = %+v, want %+v", claims, want)
+	}
+}
+
+func TestOIDCProviderRejectsForeignSignature(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	p, err := newOIDCProvider(context.Background(), config.OIDCProviderConfig{Name: "mock", Issuer: mock.URL, ClientID: "console"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens signed by any other key fail verification
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := (&mockOIDCProvider{Server: mock.Server, key: other}).sign(t, map[string]interface{}{
+		"iss": mock.URL, "sub": "user-1", "aud": "console", "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if _, err := p.verifier.Verify(context.Background(), forged); err == nil {
+		t.Error("forged id_token must not verify")
+	}
+}
+
+func TestParseOIDCClaims(t *testing.T) {
+	cfg := config.OIDCProviderConfig{UsernameClaim: "upn", GroupsClaim: "roles"}
+	claims := parseOIDCClaims(cfg, "sub-1", map[string]interface{}{
+		"upn":            "bob@corp.example",
+		"email":          "bob@example.com",
+		"email_verified": "true",
+		"roles":          "ops, pki",
+	})
+	if claims.Username != "bob@corp.example" || !claims.EmailVerified || !reflect.DeepEqual(claims.Groups, []string{"ops", "pki"}) {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Username falls back to email, then the subject
+	if c := parseOIDCClaims(config.OIDCProviderConfig{}, "sub-2", map[string]interface{}{"email": "x@example.com"}); c.Username != "x@example.com" {
+		t.Errorf("username = %q, want email", c.Username)
+	}
+	if c := parseOIDCClaims(config.OIDCProviderConfig{}, "sub-3", map[string]interface{}{}); c.Username != "sub-3" {
+		t.Errorf("username = %q, want subject", c.Username)
+	}
+}
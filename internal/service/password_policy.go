@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	ErrPasswordTooShort         = errors.New("password is too short")
+	ErrPasswordTooLong          = errors.New("password must be at most 72 bytes")
+	ErrPasswordBreached         = errors.New("password appears in a list of breached passwords, choose another one")
+	ErrPasswordContainsUsername = errors.New("password must not contain the username")
+	ErrPasswordUnchanged        = errors.New("new password must differ from the current one")
+)
+
+// bcrypt ignores everything after 72 bytes
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// PasswordPolicyService checks new passwords against the length setting and
+// breached-password lists
+type PasswordPolicyService struct {
+	settingSvc *SettingService
+	common     map[string]struct{} // Lowercase built-in list
+	breached   map[string]struct{} // Uppercase SHA-1 hex from the configured list
+}
+
+// NewPasswordPolicyService creates a new PasswordPolicyService. breachedList
+// is an optional file of passwords or SHA-1 hashes, one per line.
+func NewPasswordPolicyService(settingSvc *SettingService, breachedList string) (*PasswordPolicyService, error) {
+	s := &PasswordPolicyService{
+		settingSvc: settingSvc,
+		common:     make(map[string]struct{}),
+		breached:   make(map[string]struct{}),
+	}
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			s.common[line] = struct{}{}
+		}
+	}
+
+	if breachedList != "" {
+		f, err := os.Open(breachedList)
+		if err != nil {
+			return nil, fmt.Errorf("open breached password list: %w", err)
+		}
+		defer f.Close()
+		if err := s.loadBreached(f); err != nil {
+			return nil, fmt.Errorf("read breached password list: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// loadBreached reads one password or SHA-1 hash ("HASH" or "HASH:count")
+// per line
+func (s *PasswordPolicyService) loadBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			s.breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		s.breached[passwordSHA1(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate checks a new password for the given username. Every error is a
+// policy violation to show to the user.
+func (s *PasswordPolicyService) Validate(password, username string) error {
+	minLength := 8
+	if s.settingSvc != nil {
+		minLength = s.settingSvc.GetSecurityConfig().PasswordMinLength
+	}
+	return s.check(password, username, minLength)
+}
+
+func (s *PasswordPolicyService) check(password, username string, minLength int) error {
+	if len([]rune(password)) < minLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrPasswordTooShort, minLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return ErrPasswordContainsUsername
+	}
+	if _, ok := s.common[strings.ToLower(password)]; ok {
+		return ErrPasswordBreached
+	}
+	if _, ok := s.breached[passwordSHA1(password)]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+func passwordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	s, err := NewPasswordPolicyService(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.loadBreached(strings.NewReader(
+		"correct horse battery\n" +
+			// SHA-1 of "Tr0ub4dor&3" in the Pwned Passwords download format
+			passwordSHA1("Tr0ub4dor&3") + ":42\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{"short", ErrPasswordTooShort},
+		{strings.Repeat("x", 73), ErrPasswordTooLong},
+		{"alice-rocks-2026", ErrPasswordContainsUsername},
+		{"ADMIN123", ErrPasswordBreached}, // Built-in list, any case
+		{"Password123", ErrPasswordBreached},
+		{"correct horse battery", ErrPasswordBreached},
+		{"Tr0ub4dor&3", ErrPasswordBreached},
+		{"tr0ub4dor&3", nil}, // Configured lists match exactly
+		{"plum-Orbit-7-canal", nil},
+	}
+	for _, tt := range tests {
+		if err := s.check(tt.password, "Alice", 8); !errors.Is(err, tt.want) {
+			t.Errorf("check(%q) = %v, want %v", tt.password, err, tt.want)
+		}
+	}
+}
+
+func TestPasswordPolicyMissingList(t *testing.T) {
+	if _, err := NewPasswordPolicyService(nil, "/nonexistent/breached.txt"); err == nil {
+		t.Error("a configured list that cannot be read must fail")
+	}
+}
@@ -17,15 +17,19 @@ type RenewalService struct {
 	certSvc         *CertificateService
 	notificationSvc *NotificationService
 	settingSvc      *SettingService
+	deploymentSvc   *DeploymentService
+	auditSvc        *AuditService
 }
 
 // NewRenewalService creates a new RenewalService
-func NewRenewalService(db *gorm.DB, certSvc *CertificateService, notificationSvc *NotificationService, settingSvc *SettingService) *RenewalService {
+func NewRenewalService(db *gorm.DB, certSvc *CertificateService, notificationSvc *NotificationService, settingSvc *SettingService, deploymentSvc *DeploymentService, auditSvc *AuditService) *RenewalService {
 	return &RenewalService{
 		db:              db,
 		certSvc:         certSvc,
 		notificationSvc: notificationSvc,
 		settingSvc:      settingSvc,
+		deploymentSvc:   deploymentSvc,
+		auditSvc:        auditSvc,
 	}
 }
 
@@ -64,15 +68,22 @@ func (s *RenewalService) CheckAndRenew() error {
 
 // processIdleCertificates finds certificates with auto_renew=true, renewal_status=idle,
 // and expiring within renew_before_days. Initiates renewal by creating a new ACME order.
+// Certificates of archived workspaces are skipped unless the setting keeps renewing them;
+// renewals already under way finish either way.
 func (s *RenewalService) processIdleCertificates() error {
 	var certs []model.Certificate
 	now := time.Now()
 
-	if err := s.db.Where(
+	query := s.db.Where(
 		"auto_renew = ? AND renewal_status = ? AND status = ? AND expires_at IS NOT NULL AND expires_at <= ?",
 		true, model.RenewalStatusIdle, model.CertificateStatusReady,
 		now.AddDate(0, 0, 90), // broad filter, we check renew_before_days per cert
-	).Find(&certs).Error; err != nil {
+	)
+	if !s.renewArchived() {
+		archived := s.db.Model(&model.Workspace{}).Select("id").Where("status = ?", model.WorkspaceStatusArchived)
+		query = query.Where("workspace_id IS NULL OR workspace_id NOT IN (?)", archived)
+	}
+	if err := query.Find(&certs).Error; err != nil {
 		return fmt.Errorf("failed to query idle certificates: %w", err)
 	}
 
@@ -100,7 +111,10 @@ func (s *RenewalService) processIdleCertificates() error {
 				logger.Err(err),
 			)
 			s.logRenewal(cert.ID, "initiated", "failed", err.Error(), cert.ExpiresAt, nil)
+			s.auditScheduled(&cert, model.AuditActionRenewalStarted, err)
+			continue
 		}
+		s.auditScheduled(&cert, model.AuditActionRenewalStarted, nil)
 	}
 
 	return nil
@@ -199,6 +213,7 @@ func (s *RenewalService) processDNSReadyCertificates() error {
 		if !s.certSvc.useLego || s.certSvc.legoSvc == nil {
 			s.updateRenewalStatus(cert.ID, model.RenewalStatusFailed, true)
 			s.logRenewal(cert.ID, "completed", "failed", "lego service not available", oldExpiresAt, nil)
+			s.auditScheduled(&cert, model.AuditActionRenewalCompleted, fmt.Errorf("lego service not available"))
 			continue
 		}
 
@@ -209,6 +224,7 @@ func (s *RenewalService) processDNSReadyCertificates() error {
 			s.updateRenewalStatus(cert.ID, model.RenewalStatusFailed, true)
 			s.logRenewal(cert.ID, "completed", "failed", err.Error(), oldExpiresAt, nil)
 			s.sendRenewalNotification(cert.ID, "renewal_failed")
+			s.auditScheduled(&cert, model.AuditActionRenewalCompleted, err)
 			continue
 		}
 
@@ -225,7 +241,10 @@ func (s *RenewalService) processDNSReadyCertificates() error {
 
 		s.logRenewal(cert.ID, "completed", "success", "Certificate renewed", oldExpiresAt, renewed.ExpiresAt)
 		s.sendRenewalNotification(cert.ID, "renewal_completed")
+		s.auditScheduled(&cert, model.AuditActionRenewalCompleted, nil)
 		logger.Info("Certificate renewed successfully", logger.Uint("cert_id", cert.ID))
+
+		s.deploy(cert.ID, model.DeploymentTriggerRenewal)
 	}
 
 	return nil
@@ -281,6 +300,25 @@ func (s *RenewalService) logRenewal(certID uint, action, status, message string,
 }
 
 // sendRenewalNotification sends a renewal-related notification.
+// auditScheduled records a renewal step taken by the scheduler
+func (s *RenewalService) auditScheduled(cert *model.Certificate, action string, err error) {
+	if s.auditSvc == nil {
+		return
+	}
+	entry := model.AuditLog{
+		Action:       action,
+		ResourceType: "certificate",
+		ResourceID:   strconv.FormatUint(uint64(cert.ID), 10),
+		WorkspaceID:  cert.WorkspaceID,
+		Outcome:      model.AuditOutcomeSuccess,
+	}
+	if err != nil {
+		entry.Outcome = model.AuditOutcomeFailure
+		entry.Detail = err.Error()
+	}
+	s.auditSvc.RecordScheduler(entry)
+}
+
 func (s *RenewalService) sendRenewalNotification(certID uint, eventType string) {
 	if s.notificationSvc == nil {
 		return
@@ -288,6 +326,20 @@ func (s *RenewalService) sendRenewalNotification(certID uint, eventType string)
 	s.notificationSvc.SendRenewalNotification(certID, eventType)
 }
 
+// OnCertificateIssued pushes a newly issued certificate to its deployment targets
+// in the background, so the verify request is not held up by SSH.
+func (s *RenewalService) OnCertificateIssued(certID uint) {
+	go s.deploy(certID, model.DeploymentTriggerIssue)
+}
+
+// deploy pushes the certificate to its deployment targets, if any.
+func (s *RenewalService) deploy(certID uint, trigger model.DeploymentTrigger) {
+	if s.deploymentSvc == nil {
+		return
+	}
+	s.deploymentSvc.DeployCertificate(certID, trigger)
+}
+
 // isRenewalEnabled checks the global renewal.enabled setting.
 func (s *RenewalService) isRenewalEnabled() bool {
 	val := s.settingSvc.Get(model.SettingRenewalEnabled)
@@ -297,6 +349,12 @@ func (s *RenewalService) isRenewalEnabled() bool {
 	return val == "true"
 }
 
+// renewArchived reads the renewal.archived_workspaces setting; renewals are
+// paused unless it is "continue".
+func (s *RenewalService) renewArchived() bool {
+	return s.settingSvc.Get(model.SettingRenewalArchived) == "continue"
+}
+
 // getMaxAttempts reads the renewal.max_attempts setting.
 func (s *RenewalService) getMaxAttempts() int {
 	val := s.settingSvc.Get(model.SettingRenewalMaxAttempts)
@@ -321,6 +379,10 @@ func (s *RenewalService) TriggerRenewal(certID uint) error {
 		return fmt.Errorf("certificate is not in ready status")
 	}
 
+	if cert.Source == model.CertificateSourceDiscovered {
+		return fmt.Errorf("tracked certificates cannot be renewed, manage it from discovery first")
+	}
+
 	s.db.Model(&cert).Updates(map[string]any{
 		"renewal_status":   model.RenewalStatusIdle,
 		"renewal_attempts": 0,
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/logger"
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionInvalid  = errors.New("session expired or revoked")
+	ErrSessionNotFound = errors.New("session not found")
+	// The session is valid but may only be used to change the password
+	ErrPasswordChangeRequired = errors.New("password change required")
+)
+
+const (
+	// Last-seen tracking is throttled so every request does not write
+	sessionTouchInterval = time.Minute
+	// Two tabs refreshing at once both present the same token; the loser is
+	// rejected but not treated as token theft within this window
+	sessionRotationGrace = 30 * time.Second
+)
+
+// SessionService tracks console logins so they can be listed and revoked
+type SessionService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+// NewSessionService creates a new SessionService. Sessions end after ttl
+// without a refresh.
+func NewSessionService(db *gorm.DB, ttl time.Duration) *SessionService {
+	return &SessionService{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// Create starts a session and returns it with its first refresh token
+func (s *SessionService) Create(userID uint, userAgent, clientIP string) (*model.Session, string, error) {
+	token, err := randomSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &model.Session{
+		UserID:      userID,
+		TokenHash:   hashDeployToken(token),
+		UserAgent:   truncate(userAgent, 255),
+		IP:          clientIP,
+		LastSeenAt:  now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(s.ttl),
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, "", err
+	}
+
+	s.db.Where("expires_at < ?", now).Delete(&model.Session{})
+	return session, token, nil
+}
+
+// Refresh rotates a refresh token and returns the session, its user and the
+// new token. Presenting a token that was already rotated out revokes the
+// session: either the client or someone else holds a stolen copy.
+func (s *SessionService) Refresh(token, userAgent, clientIP string) (*model.Session, *model.User, string, error) {
+	hash := hashDeployToken(token)
+	now := time.Now()
+
+	var session model.Session
+	if err := s.db.Where("token_hash = ?", hash).First(&session).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, "", err
+		}
+		var reused model.Session
+		if s.db.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error == nil &&
+			now.Sub(reused.RefreshedAt) > sessionRotationGrace {
+			logger.Warn("Refresh token reused, revoking session",
+				logger.Uint("session_id", reused.ID),
+				logger.Uint("user_id", reused.UserID),
+				logger.String("ip", clientIP),
+			)
+			s.db.Model(&model.Session{}).Where("id = ?", reused.ID).Update("revoked_at", now)
+		}
+		return nil, nil, "", ErrSessionInvalid
+	}
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, nil, "", ErrSessionInvalid
+	}
+
+	var user model.User
+	if err := s.db.First(&user, session.UserID).Error; err != nil || user.Status != 1 {
+		return nil, nil, "", ErrSessionInvalid
+	}
+
+	newToken, err := randomSecret()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	// Conditional on the old hash, so concurrent refreshes cannot both win
+	result := s.db.Model(&model.Session{}).Where("id = ? AND token_hash = ?", session.ID, hash).Updates(map[string]interface{}{
+		"token_hash":          hashDeployToken(newToken),
+		"previous_token_hash": hash,
+		"user_agent":          truncate(userAgent, 255),
+		"ip":                  clientIP,
+		"last_seen_at":        now,
+		"refreshed_at":        now,
+		"expires_at":          now.Add(s.ttl),
+	})
+	if result.Error != nil {
+		return nil, nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, "", ErrSessionInvalid
+	}
+	return &session, &user, newToken, nil
+}
+
+// Validate checks the session behind an access token is still active and
+// its user still enabled. Users who must change their password get
+// ErrPasswordChangeRequired.
+func (s *SessionService) Validate(sessionID, userID uint, clientIP string) error {
+	if sessionID == 0 {
+		return ErrSessionInvalid
+	}
+
+	now := time.Now()
+	var session struct {
+		ID                 uint
+		LastSeenAt         time.Time
+		MustChangePassword bool
+	}
+	err := s.db.Table("user_sessions").
+		Select("user_sessions.id, user_sessions.last_seen_at, users.must_change_password").
+		Joins("JOIN users ON users.id = user_sessions.user_id AND users.status = ?", 1).
+		Where("user_sessions.id = ? AND user_sessions.user_id = ? AND user_sessions.revoked_at IS NULL AND user_sessions.expires_at > ?",
+			sessionID, userID, now).
+		Take(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionInvalid
+		}
+		return err
+	}
+
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		s.db.Model(&model.Session{}).Where("id = ?", session.ID).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"ip":           clientIP,
+		})
+	}
+	if session.MustChangePassword {
+		return ErrPasswordChangeRequired
+	}
+	return nil
+}
+
+// List returns a user's active sessions, most recently used first
+func (s *SessionService) List(userID uint) ([]model.Session, error) {
+	var sessions []model.Session
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke ends one of a user's sessions
+func (s *SessionService) Revoke(userID, sessionID uint) error {
+	result := s.db.Model(&model.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAll ends every session of a user except the given one (0 for none)
+func (s *SessionService) RevokeAll(userID, exceptID uint) error {
+	return s.db.Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"strconv"
 	"sync"
 	"time"
 
@@ -157,6 +158,57 @@ func (s *SettingService) GetSiteConfig() SiteSettings {
 	}
 }
 
+// SecuritySettings 安全策略配置
+type SecuritySettings struct {
+	MFARequireAdmins          bool `json:"mfa_require_admins"`
+	MFARequireWorkspaceOwners bool `json:"mfa_require_workspace_owners"`
+	PasswordMinLength         int  `json:"password_min_length"`
+	LockoutThreshold          int  `json:"lockout_threshold"`
+	LockoutMinutes            int  `json:"lockout_minutes"`
+	AuditRetentionDays        int  `json:"audit_retention_days"`
+}
+
+// GetSecurityConfig 获取安全策略配置
+func (s *SettingService) GetSecurityConfig() SecuritySettings {
+	return SecuritySettings{
+		MFARequireAdmins:          s.Get(model.SettingMFARequireAdmins) == "true",
+		MFARequireWorkspaceOwners: s.Get(model.SettingMFARequireWorkspaceOwners) == "true",
+		PasswordMinLength:         s.getInt(model.SettingPasswordMinLength, 8),
+		LockoutThreshold:          s.getInt(model.SettingLockoutThreshold, 10),
+		LockoutMinutes:            s.getInt(model.SettingLockoutMinutes, 15),
+		AuditRetentionDays:        s.getRetentionDays(),
+	}
+}
+
+// getRetentionDays 读取审计日志保留天数，0 表示永久保留
+func (s *SettingService) getRetentionDays() int {
+	n, err := strconv.Atoi(s.Get(model.SettingAuditRetentionDays))
+	if err != nil || n < 0 {
+		return 365
+	}
+	return n
+}
+
+// Changes 返回批量更新将改变的配置项及其新旧值
+func (s *SettingService) Changes(settings map[string]string) AuditChanges {
+	before := make(map[string]interface{}, len(settings))
+	after := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		before[key] = s.Get(key)
+		after[key] = value
+	}
+	return DiffFields(before, after)
+}
+
+// getInt 读取正整数配置，未设置或无效时返回默认值
+func (s *SettingService) getInt(key string, defaultValue int) int {
+	n, err := strconv.Atoi(s.Get(key))
+	if err != nil || n <= 0 {
+		return defaultValue
+	}
+	return n
+}
+
 // GetDNSTimeout 获取 DNS 超时时间
 func (s *SettingService) GetDNSTimeout() time.Duration {
 	timeout := s.GetWithDefault(model.SettingDNSTimeout, "10s")
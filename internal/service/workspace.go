@@ -16,6 +16,10 @@ var (
 	ErrCannotChangeOwnerRole = errors.New("cannot change owner role")
 	ErrUserAlreadyMember     = errors.New("user is already a member")
 	ErrInvalidRole           = errors.New("invalid role")
+	ErrWorkspaceArchived     = errors.New("workspace is archived")
+	ErrTransferTargetInvalid = errors.New("the new owner must be an admin member of the workspace")
+	ErrNoPendingTransfer     = errors.New("no ownership transfer is pending")
+	ErrReassignTargetInvalid = errors.New("invalid user to reassign ownership to")
 )
 
 type WorkspaceService struct {
@@ -34,40 +38,56 @@ type CreateWorkspaceRequest struct {
 }
 
 type UpdateWorkspaceRequest struct {
-	Name        string `json:"name" binding:"omitempty,min=1,max=100"`
-	Description string `json:"description" binding:"max=500"`
-	Status      *int   `json:"status" binding:"omitempty,oneof=0 1"`
+	Name                   string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description            string `json:"description" binding:"max=500"`
+	Status                 *int   `json:"status" binding:"omitempty,oneof=0 1"`
+	KeyExportPolicy        string `json:"key_export_policy" binding:"omitempty,oneof=admins none step_up"`
+	KeyExportEncryptedOnly *bool  `json:"key_export_encrypted_only"`
+	KeyExportRequireReason *bool  `json:"key_export_require_reason"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uint `json:"user_id" binding:"required"` // An admin member
 }
 
 type AddMemberRequest struct {
-	UserID uint   `json:"user_id" binding:"required"`
-	Role   string `json:"role" binding:"required,oneof=admin member"`
+	UserID       uint   `json:"user_id" binding:"required"`
+	Role         string `json:"role" binding:"required,oneof=admin operator member viewer custom"`
+	CustomRoleID *uint  `json:"custom_role_id"` // Required when role is custom
 }
 
 type UpdateMemberRequest struct {
-	Role string `json:"role" binding:"required,oneof=admin member"`
+	Role         string `json:"role" binding:"required,oneof=admin operator member viewer custom"`
+	CustomRoleID *uint  `json:"custom_role_id"`
 }
 
 type WorkspaceResponse struct {
-	ID          uint   `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	OwnerID     uint   `json:"owner_id"`
-	Status      int    `json:"status"`
-	Role        string `json:"role"`        // Current user's role in this workspace
-	MemberCount int    `json:"member_count"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	ID                     uint     `json:"id"`
+	Name                   string   `json:"name"`
+	Description            string   `json:"description"`
+	OwnerID                uint     `json:"owner_id"`
+	Status                 int      `json:"status"`
+	Role                   string   `json:"role"`        // Current user's role in this workspace
+	Permissions            []string `json:"permissions"` // Current user's permissions in this workspace
+	MemberCount            int      `json:"member_count"`
+	KeyExportPolicy        string   `json:"key_export_policy"`
+	KeyExportEncryptedOnly bool     `json:"key_export_encrypted_only"`
+	KeyExportRequireReason bool     `json:"key_export_require_reason"`
+	PendingOwnerID         *uint    `json:"pending_owner_id,omitempty"` // Nominated by the owner, not yet accepted
+	CreatedAt              string   `json:"created_at"`
+	UpdatedAt              string   `json:"updated_at"`
 }
 
 type MemberResponse struct {
-	ID        uint   `json:"id"`
-	UserID    uint   `json:"user_id"`
-	Username  string `json:"username"`
-	Nickname  string `json:"nickname"`
-	Email     string `json:"email"`
-	Role      string `json:"role"`
-	CreatedAt string `json:"created_at"`
+	ID             uint   `json:"id"`
+	UserID         uint   `json:"user_id"`
+	Username       string `json:"username"`
+	Nickname       string `json:"nickname"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	CustomRoleID   *uint  `json:"custom_role_id,omitempty"`
+	CustomRoleName string `json:"custom_role_name,omitempty"`
+	CreatedAt      string `json:"created_at"`
 }
 
 // Create creates a new workspace and adds the creator as owner
@@ -77,6 +97,8 @@ func (s *WorkspaceService) Create(userID uint, req *CreateWorkspaceRequest) (*mo
 		Description: req.Description,
 		OwnerID:     userID,
 		Status:      1,
+
+		KeyExportPolicy: model.KeyExportPolicyAdmins,
 	}
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
@@ -116,11 +138,11 @@ func (s *WorkspaceService) ListUserWorkspaces(userID uint) ([]WorkspaceResponse,
 		return []WorkspaceResponse{}, nil
 	}
 
-	// Build workspace ID to role map
-	workspaceRoles := make(map[uint]string)
+	// Build workspace ID to member map
+	workspaceMembers := make(map[uint]*model.WorkspaceMember)
 	workspaceIDs := make([]uint, 0, len(members))
-	for _, m := range members {
-		workspaceRoles[m.WorkspaceID] = m.Role
+	for i, m := range members {
+		workspaceMembers[m.WorkspaceID] = &members[i]
 		workspaceIDs = append(workspaceIDs, m.WorkspaceID)
 	}
 
@@ -150,16 +172,30 @@ func (s *WorkspaceService) ListUserWorkspaces(userID uint) ([]WorkspaceResponse,
 	// Build response
 	result := make([]WorkspaceResponse, 0, len(workspaces))
 	for _, w := range workspaces {
+		member := workspaceMembers[w.ID]
+		perms, err := s.memberPermissions(member)
+		if err != nil {
+			return nil, err
+		}
+		if w.Status == model.WorkspaceStatusArchived {
+			perms = perms.ReadOnly()
+		}
 		result = append(result, WorkspaceResponse{
 			ID:          w.ID,
 			Name:        w.Name,
 			Description: w.Description,
 			OwnerID:     w.OwnerID,
 			Status:      w.Status,
-			Role:        workspaceRoles[w.ID],
+			Role:        member.Role,
+			Permissions: perms.List(),
 			MemberCount: countMap[w.ID],
 			CreatedAt:   w.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 			UpdatedAt:   w.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+
+			KeyExportPolicy:        w.KeyExportPolicy,
+			KeyExportEncryptedOnly: w.KeyExportEncryptedOnly,
+			KeyExportRequireReason: w.KeyExportRequireReason,
+			PendingOwnerID:         w.PendingOwnerID,
 		})
 	}
 
@@ -168,7 +204,11 @@ func (s *WorkspaceService) ListUserWorkspaces(userID uint) ([]WorkspaceResponse,
 
 // GetByID returns a workspace if the user has access
 func (s *WorkspaceService) GetByID(workspaceID, userID uint) (*WorkspaceResponse, error) {
-	role, err := s.GetUserRole(workspaceID, userID)
+	member, err := s.getMember(workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := s.memberPermissions(member)
 	if err != nil {
 		return nil, err
 	}
@@ -180,6 +220,9 @@ func (s *WorkspaceService) GetByID(workspaceID, userID uint) (*WorkspaceResponse
 		}
 		return nil, err
 	}
+	if workspace.Status == model.WorkspaceStatusArchived {
+		perms = perms.ReadOnly()
+	}
 
 	// Get member count
 	var memberCount int64
@@ -191,17 +234,23 @@ func (s *WorkspaceService) GetByID(workspaceID, userID uint) (*WorkspaceResponse
 		Description: workspace.Description,
 		OwnerID:     workspace.OwnerID,
 		Status:      workspace.Status,
-		Role:        role,
+		Role:        member.Role,
+		Permissions: perms.List(),
 		MemberCount: int(memberCount),
 		CreatedAt:   workspace.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:   workspace.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+
+		KeyExportPolicy:        workspace.KeyExportPolicy,
+		KeyExportEncryptedOnly: workspace.KeyExportEncryptedOnly,
+		KeyExportRequireReason: workspace.KeyExportRequireReason,
+		PendingOwnerID:         workspace.PendingOwnerID,
 	}, nil
 }
 
-// Update updates a workspace (owner/admin only)
-func (s *WorkspaceService) Update(workspaceID, userID uint, req *UpdateWorkspaceRequest) error {
+// Update updates a workspace (owner/admin only) and returns the changed fields
+func (s *WorkspaceService) Update(workspaceID, userID uint, req *UpdateWorkspaceRequest) (AuditChanges, error) {
 	if !s.CanManageWorkspace(workspaceID, userID) {
-		return ErrWorkspaceAccessDenied
+		return nil, ErrWorkspaceAccessDenied
 	}
 
 	updates := make(map[string]interface{})
@@ -214,12 +263,48 @@ func (s *WorkspaceService) Update(workspaceID, userID uint, req *UpdateWorkspace
 	if req.Status != nil {
 		updates["status"] = *req.Status
 	}
+	if req.KeyExportPolicy != "" {
+		updates["key_export_policy"] = req.KeyExportPolicy
+	}
+	if req.KeyExportEncryptedOnly != nil {
+		updates["key_export_encrypted_only"] = *req.KeyExportEncryptedOnly
+	}
+	if req.KeyExportRequireReason != nil {
+		updates["key_export_require_reason"] = *req.KeyExportRequireReason
+	}
 
 	if len(updates) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, workspaceID).Error; err != nil {
+		return nil, err
+	}
+	// An archived workspace only accepts being restored
+	if workspace.Status == model.WorkspaceStatusArchived {
+		restoring := req.Status != nil && *req.Status == model.WorkspaceStatusActive
+		if !restoring || len(updates) > 1 {
+			return nil, ErrWorkspaceArchived
+		}
+	}
+	current := map[string]interface{}{
+		"name":                      workspace.Name,
+		"description":               workspace.Description,
+		"status":                    workspace.Status,
+		"key_export_policy":         workspace.KeyExportPolicy,
+		"key_export_encrypted_only": workspace.KeyExportEncryptedOnly,
+		"key_export_require_reason": workspace.KeyExportRequireReason,
+	}
+	before := make(map[string]interface{}, len(updates))
+	for key := range updates {
+		before[key] = current[key]
 	}
 
-	return s.db.Model(&model.Workspace{}).Where("id = ?", workspaceID).Updates(updates).Error
+	if err := s.db.Model(&model.Workspace{}).Where("id = ?", workspaceID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return DiffFields(before, updates), nil
 }
 
 // Delete deletes a workspace (owner only)
@@ -249,6 +334,10 @@ func (s *WorkspaceService) Delete(workspaceID, userID uint) error {
 		if err := tx.Where("workspace_id = ?", workspaceID).Delete(&model.WorkspaceMember{}).Error; err != nil {
 			return err
 		}
+		// Drop certificates shared with it
+		if err := tx.Where("workspace_id = ?", workspaceID).Delete(&model.CertificateShare{}).Error; err != nil {
+			return err
+		}
 		// Delete workspace
 		return tx.Delete(&workspace).Error
 	})
@@ -262,17 +351,21 @@ func (s *WorkspaceService) ListMembers(workspaceID, userID uint) ([]MemberRespon
 	}
 
 	var members []model.WorkspaceMember
-	if err := s.db.Preload("User").Where("workspace_id = ?", workspaceID).Order("created_at ASC").Find(&members).Error; err != nil {
+	if err := s.db.Preload("User").Preload("CustomRole").Where("workspace_id = ?", workspaceID).Order("created_at ASC").Find(&members).Error; err != nil {
 		return nil, err
 	}
 
 	result := make([]MemberResponse, 0, len(members))
 	for _, m := range members {
 		resp := MemberResponse{
-			ID:        m.ID,
-			UserID:    m.UserID,
-			Role:      m.Role,
-			CreatedAt: m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ID:           m.ID,
+			UserID:       m.UserID,
+			Role:         m.Role,
+			CustomRoleID: m.CustomRoleID,
+			CreatedAt:    m.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		}
+		if m.CustomRole != nil {
+			resp.CustomRoleName = m.CustomRole.Name
 		}
 		if m.User != nil {
 			resp.Username = m.User.Username
@@ -285,126 +378,221 @@ func (s *WorkspaceService) ListMembers(workspaceID, userID uint) ([]MemberRespon
 	return result, nil
 }
 
-// AddMember adds a user to a workspace (owner/admin only)
-func (s *WorkspaceService) AddMember(workspaceID, currentUserID uint, req *AddMemberRequest) error {
-	if !s.CanManageMembers(workspaceID, currentUserID) {
-		return ErrWorkspaceAccessDenied
+// AddMember adds a user to a workspace (member.manage). The role's permissions
+// must be a subset of the caller's own. Returns the membership as changes.
+func (s *WorkspaceService) AddMember(workspaceID, currentUserID uint, req *AddMemberRequest) (AuditChanges, error) {
+	actor, err := s.memberManager(workspaceID, currentUserID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate role
-	if req.Role != model.WorkspaceRoleAdmin && req.Role != model.WorkspaceRoleMember {
-		return ErrInvalidRole
+	customRoleID, err := s.assignableRole(workspaceID, actor, req.Role, req.CustomRoleID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if user exists
 	var user model.User
 	if err := s.db.First(&user, req.UserID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("user not found")
+			return nil, fmt.Errorf("user not found")
 		}
-		return err
+		return nil, err
 	}
 
 	// Check if already a member
 	var existing model.WorkspaceMember
-	err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, req.UserID).First(&existing).Error
+	err = s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, req.UserID).First(&existing).Error
 	if err == nil {
-		return ErrUserAlreadyMember
+		return nil, ErrUserAlreadyMember
 	}
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return err
+		return nil, err
 	}
 
 	member := &model.WorkspaceMember{
-		WorkspaceID: workspaceID,
-		UserID:      req.UserID,
-		Role:        req.Role,
+		WorkspaceID:  workspaceID,
+		UserID:       req.UserID,
+		Role:         req.Role,
+		CustomRoleID: customRoleID,
 	}
 
-	return s.db.Create(member).Error
+	if err := s.db.Create(member).Error; err != nil {
+		return nil, err
+	}
+	return DiffFields(nil, memberFields(member)), nil
 }
 
-// UpdateMember updates a member's role (owner/admin only)
-func (s *WorkspaceService) UpdateMember(workspaceID, currentUserID, memberUserID uint, req *UpdateMemberRequest) error {
-	if !s.CanManageMembers(workspaceID, currentUserID) {
-		return ErrWorkspaceAccessDenied
+// UpdateMember updates a member's role (member.manage, within the caller's own
+// permissions) and returns the changed fields
+func (s *WorkspaceService) UpdateMember(workspaceID, currentUserID, memberUserID uint, req *UpdateMemberRequest) (AuditChanges, error) {
+	actor, err := s.memberManager(workspaceID, currentUserID)
+	if err != nil {
+		return nil, err
 	}
 
 	var member model.WorkspaceMember
 	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, memberUserID).First(&member).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrMemberNotFound
+			return nil, ErrMemberNotFound
 		}
-		return err
+		return nil, err
 	}
 
 	// Cannot change owner's role
 	if member.Role == model.WorkspaceRoleOwner {
-		return ErrCannotChangeOwnerRole
+		return nil, ErrCannotChangeOwnerRole
+	}
+
+	// Nobody can demote a member who holds more than they do
+	current, err := s.memberPermissions(&member)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Covers(current) {
+		return nil, ErrPermissionEscalation
 	}
 
 	// Validate role
-	if req.Role != model.WorkspaceRoleAdmin && req.Role != model.WorkspaceRoleMember {
-		return ErrInvalidRole
+	customRoleID, err := s.assignableRole(workspaceID, actor, req.Role, req.CustomRoleID)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.db.Model(&member).Update("role", req.Role).Error
+	before := memberFields(&member)
+	if err := s.db.Model(&member).Updates(map[string]interface{}{
+		"role":           req.Role,
+		"custom_role_id": customRoleID,
+	}).Error; err != nil {
+		return nil, err
+	}
+	member.Role, member.CustomRoleID = req.Role, customRoleID
+	return DiffFields(before, memberFields(&member)), nil
 }
 
-// RemoveMember removes a user from a workspace (owner/admin only)
-func (s *WorkspaceService) RemoveMember(workspaceID, currentUserID, memberUserID uint) error {
-	if !s.CanManageMembers(workspaceID, currentUserID) {
-		return ErrWorkspaceAccessDenied
+// RemoveMember removes a user from a workspace (member.manage, within the
+// caller's own permissions) and returns the removed membership as changes
+func (s *WorkspaceService) RemoveMember(workspaceID, currentUserID, memberUserID uint) (AuditChanges, error) {
+	actor, err := s.memberManager(workspaceID, currentUserID)
+	if err != nil {
+		return nil, err
 	}
 
 	var member model.WorkspaceMember
 	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, memberUserID).First(&member).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrMemberNotFound
+			return nil, ErrMemberNotFound
 		}
-		return err
+		return nil, err
 	}
 
 	// Cannot remove owner
 	if member.Role == model.WorkspaceRoleOwner {
-		return ErrCannotRemoveOwner
+		return nil, ErrCannotRemoveOwner
+	}
+	current, err := s.memberPermissions(&member)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Covers(current) {
+		return nil, ErrPermissionEscalation
+	}
+
+	if err := s.db.Delete(&member).Error; err != nil {
+		return nil, err
+	}
+	return DiffFields(memberFields(&member), nil), nil
+}
+
+// memberFields is the audited state of a membership
+func memberFields(member *model.WorkspaceMember) map[string]interface{} {
+	fields := map[string]interface{}{
+		"role":           member.Role,
+		"custom_role_id": nil,
+	}
+	if member.CustomRoleID != nil {
+		fields["custom_role_id"] = *member.CustomRoleID
 	}
+	return fields
+}
 
-	return s.db.Delete(&member).Error
+// memberManager returns the caller's permissions if they hold member.manage
+func (s *WorkspaceService) memberManager(workspaceID, userID uint) (Permissions, error) {
+	actor, err := s.GetUserPermissions(workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Has(model.PermissionMemberManage) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+	return actor, nil
 }
 
 // GetUserRole returns the user's role in a workspace
 func (s *WorkspaceService) GetUserRole(workspaceID, userID uint) (string, error) {
-	var member model.WorkspaceMember
-	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", ErrWorkspaceAccessDenied
-		}
+	member, err := s.getMember(workspaceID, userID)
+	if err != nil {
 		return "", err
 	}
 	return member.Role, nil
 }
 
-// CanManageWorkspace checks if user can manage workspace settings (owner/admin)
-func (s *WorkspaceService) CanManageWorkspace(workspaceID, userID uint) bool {
-	role, err := s.GetUserRole(workspaceID, userID)
+// GetUserPermissions returns the user's permissions in a workspace, or
+// ErrWorkspaceAccessDenied when they are not a member
+func (s *WorkspaceService) GetUserPermissions(workspaceID, userID uint) (Permissions, error) {
+	member, err := s.getMember(workspaceID, userID)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return role == model.WorkspaceRoleOwner || role == model.WorkspaceRoleAdmin
+	perms, err := s.memberPermissions(member)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := s.isArchived(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if archived {
+		return perms.ReadOnly(), nil
+	}
+	return perms, nil
 }
 
-// CanManageMembers checks if user can manage members (owner/admin)
-func (s *WorkspaceService) CanManageMembers(workspaceID, userID uint) bool {
-	role, err := s.GetUserRole(workspaceID, userID)
+// isArchived reports whether a workspace is archived
+func (s *WorkspaceService) isArchived(workspaceID uint) (bool, error) {
+	var workspace model.Workspace
+	if err := s.db.Select("id", "status").First(&workspace, workspaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrWorkspaceNotFound
+		}
+		return false, err
+	}
+	return workspace.Status == model.WorkspaceStatusArchived, nil
+}
+
+// checkWritable returns ErrWorkspaceArchived for archived workspaces
+func (s *WorkspaceService) checkWritable(workspaceID uint) error {
+	archived, err := s.isArchived(workspaceID)
 	if err != nil {
-		return false
+		return err
 	}
-	return role == model.WorkspaceRoleOwner || role == model.WorkspaceRoleAdmin
+	if archived {
+		return ErrWorkspaceArchived
+	}
+	return nil
 }
 
-// CanManageCertificates checks if user can manage certificates (owner/admin)
-func (s *WorkspaceService) CanManageCertificates(workspaceID, userID uint) bool {
+// HasPermission checks a single workspace permission
+func (s *WorkspaceService) HasPermission(workspaceID, userID uint, permission string) bool {
+	perms, err := s.GetUserPermissions(workspaceID, userID)
+	return err == nil && perms.Has(permission)
+}
+
+// CanManageWorkspace checks if user can manage workspace settings (owner/admin).
+// Workspace settings include the key export policy, so they stay with the
+// built-in administrator roles rather than a delegable permission.
+func (s *WorkspaceService) CanManageWorkspace(workspaceID, userID uint) bool {
 	role, err := s.GetUserRole(workspaceID, userID)
 	if err != nil {
 		return false
@@ -412,8 +600,33 @@ func (s *WorkspaceService) CanManageCertificates(workspaceID, userID uint) bool
 	return role == model.WorkspaceRoleOwner || role == model.WorkspaceRoleAdmin
 }
 
-// CanViewCertificates checks if user can view certificates (any member)
+// CanManageMembers checks if user can manage members and roles (member.manage)
+func (s *WorkspaceService) CanManageMembers(workspaceID, userID uint) bool {
+	return s.HasPermission(workspaceID, userID, model.PermissionMemberManage)
+}
+
+// CanManageCertificates checks if user can issue, renew and deploy certificates (cert.issue)
+func (s *WorkspaceService) CanManageCertificates(workspaceID, userID uint) bool {
+	return s.HasPermission(workspaceID, userID, model.PermissionCertIssue)
+}
+
+// CanViewCertificates checks if user can view certificates (cert.read)
 func (s *WorkspaceService) CanViewCertificates(workspaceID, userID uint) bool {
-	_, err := s.GetUserRole(workspaceID, userID)
-	return err == nil
+	return s.HasPermission(workspaceID, userID, model.PermissionCertRead)
+}
+
+// CanManageNotifications checks if user can edit notification channels (notification.manage)
+func (s *WorkspaceService) CanManageNotifications(workspaceID, userID uint) bool {
+	return s.HasPermission(workspaceID, userID, model.PermissionNotificationManage)
+}
+
+func (s *WorkspaceService) getMember(workspaceID, userID uint) (*model.WorkspaceMember, error) {
+	var member model.WorkspaceMember
+	if err := s.db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkspaceAccessDenied
+		}
+		return nil, err
+	}
+	return &member, nil
 }
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrRoleInUse            = errors.New("role is still assigned to members")
+	ErrRoleNameTaken        = errors.New("a role with this name already exists")
+	ErrInvalidPermission    = errors.New("unknown permission")
+	ErrPermissionEscalation = errors.New("cannot grant permissions you do not have")
+)
+
+// Permissions is a set of workspace permissions
+type Permissions map[string]bool
+
+// NewPermissions builds a permission set from a list
+func NewPermissions(list []string) Permissions {
+	p := make(Permissions, len(list))
+	for _, perm := range list {
+		p[perm] = true
+	}
+	return p
+}
+
+// Has reports whether the set contains a permission
+func (p Permissions) Has(permission string) bool {
+	return p[permission]
+}
+
+// Covers reports whether every permission in other is also in p
+func (p Permissions) Covers(other Permissions) bool {
+	for perm := range other {
+		if !p[perm] {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the permissions in catalog order
+func (p Permissions) List() []string {
+	list := make([]string, 0, len(p))
+	for _, perm := range model.PermissionCatalog {
+		if p[perm] {
+			list = append(list, perm)
+		}
+	}
+	return list
+}
+
+// archivedPermissions are the only permissions left in an archived workspace
+var archivedPermissions = NewPermissions([]string{model.PermissionCertRead, model.PermissionCertKeyExport})
+
+// ReadOnly returns the part of p that still applies in an archived workspace
+func (p Permissions) ReadOnly() Permissions {
+	ro := make(Permissions, len(archivedPermissions))
+	for perm := range p {
+		if archivedPermissions[perm] {
+			ro[perm] = true
+		}
+	}
+	return ro
+}
+
+// validatePermissions normalizes a requested permission list against the catalog
+func validatePermissions(list []string) (Permissions, error) {
+	known := NewPermissions(model.PermissionCatalog)
+	perms := make(Permissions, len(list))
+	for _, perm := range list {
+		if !known.Has(perm) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPermission, perm)
+		}
+		perms[perm] = true
+	}
+	if len(perms) == 0 {
+		return nil, fmt.Errorf("%w: a role needs at least one permission", ErrInvalidPermission)
+	}
+	return perms, nil
+}
+
+func parseRolePermissions(role *model.WorkspaceRole) Permissions {
+	var list []string
+	_ = json.Unmarshal([]byte(role.Permissions), &list)
+	return NewPermissions(list)
+}
+
+// memberPermissions resolves a member's built-in or custom role
+func (s *WorkspaceService) memberPermissions(member *model.WorkspaceMember) (Permissions, error) {
+	if member.Role != model.WorkspaceRoleCustom {
+		return NewPermissions(model.BuiltinRolePermissions[member.Role]), nil
+	}
+	if member.CustomRoleID == nil {
+		return Permissions{}, nil
+	}
+	var role model.WorkspaceRole
+	if err := s.db.Where("id = ? AND workspace_id = ?", *member.CustomRoleID, member.WorkspaceID).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Permissions{}, nil
+		}
+		return nil, err
+	}
+	return parseRolePermissions(&role), nil
+}
+
+// assignableRole validates a role given to a member and returns its custom role
+// ID. The owner role is never assignable, and nobody can hand out permissions
+// they do not hold themselves.
+func (s *WorkspaceService) assignableRole(workspaceID uint, actor Permissions, role string, customRoleID *uint) (*uint, error) {
+	var perms Permissions
+	switch role {
+	case model.WorkspaceRoleOwner:
+		return nil, ErrInvalidRole
+	case model.WorkspaceRoleCustom:
+		if customRoleID == nil {
+			return nil, ErrInvalidRole
+		}
+		var custom model.WorkspaceRole
+		if err := s.db.Where("id = ? AND workspace_id = ?", *customRoleID, workspaceID).First(&custom).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrRoleNotFound
+			}
+			return nil, err
+		}
+		perms = parseRolePermissions(&custom)
+	default:
+		builtin, ok := model.BuiltinRolePermissions[role]
+		if !ok {
+			return nil, ErrInvalidRole
+		}
+		perms, customRoleID = NewPermissions(builtin), nil
+	}
+
+	if !actor.Covers(perms) {
+		return nil, ErrPermissionEscalation
+	}
+	return customRoleID, nil
+}
+
+// WorkspaceRoleRequest creates or updates a custom role
+type WorkspaceRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=1,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// RoleResponse describes a built-in or custom role
+type RoleResponse struct {
+	ID          *uint    `json:"id,omitempty"` // Custom roles only
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+	MemberCount int      `json:"member_count"`
+}
+
+// RolesResponse lists a workspace's roles along with the permission catalog
+type RolesResponse struct {
+	Roles       []RoleResponse `json:"roles"`
+	Permissions []string       `json:"permissions"`
+}
+
+// ListRoles returns the built-in and custom roles of a workspace (any member)
+func (s *WorkspaceService) ListRoles(workspaceID, userID uint) (*RolesResponse, error) {
+	if _, err := s.GetUserRole(workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	type countResult struct {
+		Role         string
+		CustomRoleID *uint
+		Count        int
+	}
+	var counts []countResult
+	if err := s.db.Model(&model.WorkspaceMember{}).
+		Select("role, custom_role_id, count(*) as count").
+		Where("workspace_id = ?", workspaceID).
+		Group("role, custom_role_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	builtinCounts := make(map[string]int)
+	customCounts := make(map[uint]int)
+	for _, c := range counts {
+		if c.CustomRoleID != nil {
+			customCounts[*c.CustomRoleID] += c.Count
+		} else {
+			builtinCounts[c.Role] += c.Count
+		}
+	}
+
+	roles := make([]RoleResponse, 0, len(model.BuiltinRolePermissions))
+	for _, name := range []string{
+		model.WorkspaceRoleOwner, model.WorkspaceRoleAdmin, model.WorkspaceRoleOperator,
+		model.WorkspaceRoleMember, model.WorkspaceRoleViewer,
+	} {
+		roles = append(roles, RoleResponse{
+			Name:        name,
+			Builtin:     true,
+			Permissions: model.BuiltinRolePermissions[name],
+			MemberCount: builtinCounts[name],
+		})
+	}
+
+	var custom []model.WorkspaceRole
+	if err := s.db.Where("workspace_id = ?", workspaceID).Order("name ASC").Find(&custom).Error; err != nil {
+		return nil, err
+	}
+	for i := range custom {
+		role := &custom[i]
+		roles = append(roles, RoleResponse{
+			ID:          &role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: parseRolePermissions(role).List(),
+			MemberCount: customCounts[role.ID],
+		})
+	}
+
+	return &RolesResponse{Roles: roles, Permissions: model.PermissionCatalog}, nil
+}
+
+// CreateRole defines a custom role (member.manage, limited to the caller's own permissions)
+func (s *WorkspaceService) CreateRole(workspaceID, userID uint, req *WorkspaceRoleRequest) (*model.WorkspaceRole, error) {
+	perms, err := s.checkRoleRequest(workspaceID, userID, 0, req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, _ := json.Marshal(perms.List())
+	role := &model.WorkspaceRole{
+		WorkspaceID: workspaceID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Permissions: string(encoded),
+	}
+	if err := s.db.Create(role).Error; err != nil {
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	return role, nil
+}
+
+// UpdateRole changes a custom role; members holding it pick up the change immediately
+func (s *WorkspaceService) UpdateRole(workspaceID, roleID, userID uint, req *WorkspaceRoleRequest) error {
+	role, err := s.getCustomRole(workspaceID, roleID)
+	if err != nil {
+		return err
+	}
+	perms, err := s.checkRoleRequest(workspaceID, userID, roleID, req)
+	if err != nil {
+		return err
+	}
+	// Editing a role also changes what its current holders can do
+	actor, _ := s.GetUserPermissions(workspaceID, userID)
+	if !actor.Covers(parseRolePermissions(role)) {
+		return ErrPermissionEscalation
+	}
+
+	encoded, _ := json.Marshal(perms.List())
+	return s.db.Model(role).Updates(map[string]interface{}{
+		"name":        strings.TrimSpace(req.Name),
+		"description": req.Description,
+		"permissions": string(encoded),
+	}).Error
+}
+
+// DeleteRole removes a custom role that no member holds any more
+func (s *WorkspaceService) DeleteRole(workspaceID, roleID, userID uint) error {
+	if !s.CanManageMembers(workspaceID, userID) {
+		return ErrWorkspaceAccessDenied
+	}
+	role, err := s.getCustomRole(workspaceID, roleID)
+	if err != nil {
+		return err
+	}
+
+	var inUse int64
+	s.db.Model(&model.WorkspaceMember{}).Where("workspace_id = ? AND custom_role_id = ?", workspaceID, roleID).Count(&inUse)
+	if inUse > 0 {
+		return ErrRoleInUse
+	}
+	return s.db.Delete(role).Error
+}
+
+func (s *WorkspaceService) getCustomRole(workspaceID, roleID uint) (*model.WorkspaceRole, error) {
+	var role model.WorkspaceRole
+	if err := s.db.Where("id = ? AND workspace_id = ?", roleID, workspaceID).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// checkRoleRequest validates a custom role definition for the acting user
+func (s *WorkspaceService) checkRoleRequest(workspaceID, userID, roleID uint, req *WorkspaceRoleRequest) (Permissions, error) {
+	actor, err := s.GetUserPermissions(workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Has(model.PermissionMemberManage) {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	perms, err := validatePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Covers(perms) {
+		return nil, ErrPermissionEscalation
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if _, builtin := model.BuiltinRolePermissions[strings.ToLower(name)]; builtin || strings.EqualFold(name, model.WorkspaceRoleCustom) {
+		return nil, ErrRoleNameTaken
+	}
+	var taken int64
+	s.db.Model(&model.WorkspaceRole{}).Where("workspace_id = ? AND name = ? AND id <> ?", workspaceID, name, roleID).Count(&taken)
+	if taken > 0 {
+		return nil, ErrRoleNameTaken
+	}
+	return perms, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+)
+
+func TestBuiltinRoles(t *testing.T) {
+	role := func(name string) Permissions { return NewPermissions(model.BuiltinRolePermissions[name]) }
+
+	if role(model.WorkspaceRoleViewer).Has(model.PermissionCertKeyExport) {
+		t.Error("viewers must not export keys")
+	}
+	operator := role(model.WorkspaceRoleOperator)
+	if !operator.Has(model.PermissionCertIssue) || operator.Has(model.PermissionCertDelete) {
+		t.Errorf("operator = %v, want issue without delete", operator.List())
+	}
+	if !role(model.WorkspaceRoleAdmin).Covers(NewPermissions(model.PermissionCatalog)) {
+		t.Error("admins hold every permission")
+	}
+	if len(role(model.WorkspaceRoleCustom)) != 0 {
+		t.Error("custom roles take their permissions from the role definition")
+	}
+}
+
+func TestPermissionsCovers(t *testing.T) {
+	manager := NewPermissions([]string{model.PermissionMemberManage, model.PermissionCertRead})
+	if !manager.Covers(NewPermissions([]string{model.PermissionCertRead})) {
+		t.Error("a subset should be covered")
+	}
+	if manager.Covers(NewPermissions([]string{model.PermissionCertRead, model.PermissionCertKeyExport})) {
+		t.Error("granting cert.key.export would escalate")
+	}
+}
+
+func TestPermissionsReadOnly(t *testing.T) {
+	admin := NewPermissions(model.BuiltinRolePermissions[model.WorkspaceRoleAdmin]).ReadOnly()
+	if got := admin.List(); len(got) != 2 || got[0] != model.PermissionCertRead || got[1] != model.PermissionCertKeyExport {
+		t.Errorf("archived admin = %v, want read and key export only", got)
+	}
+	viewer := NewPermissions(model.BuiltinRolePermissions[model.WorkspaceRoleViewer]).ReadOnly()
+	if viewer.Has(model.PermissionCertKeyExport) {
+		t.Error("archiving must not grant permissions")
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	perms, err := validatePermissions([]string{model.PermissionCertIssue, model.PermissionCertRead, model.PermissionCertRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := perms.List(); len(got) != 2 || got[0] != model.PermissionCertRead {
+		t.Errorf("List() = %v, want catalog order without duplicates", got)
+	}
+	if _, err := validatePermissions([]string{"cert.everything"}); !errors.Is(err, ErrInvalidPermission) {
+		t.Errorf("err = %v, want ErrInvalidPermission", err)
+	}
+	if _, err := validatePermissions(nil); !errors.Is(err, ErrInvalidPermission) {
+		t.Errorf("err = %v, want ErrInvalidPermission for an empty role", err)
+	}
+}
+
+func TestStepUpKeyExportAllowed(t *testing.T) {
+	role := func(name string) Permissions { return NewPermissions(model.BuiltinRolePermissions[name]) }
+	tests := []struct {
+		role  string
+		perms Permissions
+		want  bool
+	}{
+		{model.WorkspaceRoleAdmin, role(model.WorkspaceRoleAdmin), true},
+		{model.WorkspaceRoleOperator, role(model.WorkspaceRoleOperator), true},
+		{model.WorkspaceRoleMember, role(model.WorkspaceRoleMember), true},
+		{model.WorkspaceRoleViewer, role(model.WorkspaceRoleViewer), false},
+		{model.WorkspaceRoleCustom, NewPermissions([]string{model.PermissionCertRead}), false},
+		{model.WorkspaceRoleCustom, NewPermissions([]string{model.PermissionCertKeyExport}), true},
+	}
+	for _, tt := range tests {
+		if got := stepUpKeyExportAllowed(tt.role, tt.perms); got != tt.want {
+			t.Errorf("stepUpKeyExportAllowed(%s, %v) = %v, want %v", tt.role, tt.perms.List(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imkerbos/ACME-Console/internal/model"
+	"gorm.io/gorm"
+)
+
+// RequestTransfer lets the owner offer the workspace to one of its admins.
+// Ownership moves once the nominee accepts; a new offer replaces the old one.
+func (s *WorkspaceService) RequestTransfer(workspaceID, userID uint, req *TransferOwnershipRequest) (AuditChanges, error) {
+	workspace, err := s.ownedWorkspace(workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserID == userID {
+		return nil, ErrTransferTargetInvalid
+	}
+	target, err := s.getMember(workspaceID, req.UserID)
+	if err != nil {
+		if errors.Is(err, ErrWorkspaceAccessDenied) {
+			return nil, ErrTransferTargetInvalid
+		}
+		return nil, err
+	}
+	if target.Role != model.WorkspaceRoleAdmin {
+		return nil, ErrTransferTargetInvalid
+	}
+
+	var previous interface{}
+	if workspace.PendingOwnerID != nil {
+		previous = *workspace.PendingOwnerID
+	}
+	if err := s.db.Model(workspace).Update("pending_owner_id", req.UserID).Error; err != nil {
+		return nil, err
+	}
+	return DiffFields(
+		map[string]interface{}{"pending_owner_id": previous},
+		map[string]interface{}{"pending_owner_id": req.UserID},
+	), nil
+}
+
+// CancelTransfer withdraws a pending offer; the owner cancels it, the nominee
+// declines it
+func (s *WorkspaceService) CancelTransfer(workspaceID, userID uint) (AuditChanges, error) {
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, workspaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	if workspace.PendingOwnerID == nil {
+		return nil, ErrNoPendingTransfer
+	}
+	if workspace.OwnerID != userID && *workspace.PendingOwnerID != userID {
+		return nil, ErrWorkspaceAccessDenied
+	}
+
+	if err := s.db.Model(&workspace).Update("pending_owner_id", nil).Error; err != nil {
+		return nil, err
+	}
+	return DiffFields(
+		map[string]interface{}{"pending_owner_id": *workspace.PendingOwnerID},
+		map[string]interface{}{"pending_owner_id": nil},
+	), nil
+}
+
+// AcceptTransfer makes the nominee the owner. The previous owner stays on as
+// an admin. The nominee must still be an admin when accepting.
+func (s *WorkspaceService) AcceptTransfer(workspaceID, userID uint) (AuditChanges, error) {
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, workspaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	if workspace.PendingOwnerID == nil || *workspace.PendingOwnerID != userID {
+		return nil, ErrNoPendingTransfer
+	}
+	member, err := s.getMember(workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Role != model.WorkspaceRoleAdmin {
+		return nil, ErrTransferTargetInvalid
+	}
+
+	previousOwner := workspace.OwnerID
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Conditional so that a concurrent cancel or second accept loses
+		result := tx.Model(&model.Workspace{}).
+			Where("id = ? AND owner_id = ? AND pending_owner_id = ?", workspaceID, previousOwner, userID).
+			Updates(map[string]interface{}{"owner_id": userID, "pending_owner_id": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoPendingTransfer
+		}
+		if err := tx.Model(&model.WorkspaceMember{}).
+			Where("workspace_id = ? AND user_id = ?", workspaceID, previousOwner).
+			Updates(map[string]interface{}{"role": model.WorkspaceRoleAdmin, "custom_role_id": nil}).Error; err != nil {
+			return err
+		}
+		// Ownership is not synced from identity provider groups
+		return tx.Model(member).Updates(map[string]interface{}{
+			"role":           model.WorkspaceRoleOwner,
+			"custom_role_id": nil,
+			"source":         "",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return DiffFields(
+		map[string]interface{}{"owner_id": previousOwner},
+		map[string]interface{}{"owner_id": userID},
+	), nil
+}
+
+// OwnedCounts reports how many workspaces and private certificates a user
+// owns, which must be reassigned before the user can be deleted
+func (s *WorkspaceService) OwnedCounts(userID uint) (workspaces, certificates int64, err error) {
+	if err = s.db.Model(&model.Workspace{}).Where("owner_id = ?", userID).Count(&workspaces).Error; err != nil {
+		return 0, 0, err
+	}
+	err = s.db.Model(&model.Certificate{}).Where("workspace_id IS NULL AND created_by = ?", userID).Count(&certificates).Error
+	return workspaces, certificates, err
+}
+
+// UserReassignment is what deleting a user hands over to another user
+type UserReassignment struct {
+	ToUserID            uint  `json:"to_user_id,omitempty"`
+	Workspaces          int64 `json:"workspaces"`
+	PrivateCertificates int64 `json:"private_certificates"`
+}
+
+// CheckRemoveUser validates that a user can be deleted: whatever they own
+// needs an active user to take it over. toUserID may be 0 when they own
+// nothing.
+func (s *WorkspaceService) CheckRemoveUser(userID, toUserID uint) (*UserReassignment, error) {
+	workspaces, certificates, err := s.OwnedCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	plan := &UserReassignment{Workspaces: workspaces, PrivateCertificates: certificates}
+	if workspaces == 0 && certificates == 0 {
+		return plan, nil
+	}
+
+	if toUserID == 0 || toUserID == userID {
+		return nil, fmt.Errorf("%w: the user owns %d workspace(s) and %d private certificate(s)",
+			ErrReassignTargetInvalid, workspaces, certificates)
+	}
+	var target model.User
+	if err := s.db.First(&target, toUserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReassignTargetInvalid
+		}
+		return nil, err
+	}
+	if target.ServiceAccount || target.Status != 1 {
+		return nil, ErrReassignTargetInvalid
+	}
+	plan.ToUserID = toUserID
+	return plan, nil
+}
+
+// RemoveUser hands everything a user owns to another user, then deletes the
+// user's memberships and the user and revokes the user's access, in one
+// transaction
+func (s *WorkspaceService) RemoveUser(user *model.User, toUserID uint) (*UserReassignment, error) {
+	plan, err := s.CheckRemoveUser(user.ID, toUserID)
+	if err != nil {
+		return nil, err
+	}
+	owns := plan.ToUserID != 0
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if owns {
+			var owned []model.Workspace
+			if err := tx.Where("owner_id = ?", user.ID).Find(&owned).Error; err != nil {
+				return err
+			}
+			for _, workspace := range owned {
+				if err := reassignOwner(tx, workspace.ID, plan.ToUserID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&model.Certificate{}).
+				Where("workspace_id IS NULL AND created_by = ?", user.ID).
+				Update("created_by", plan.ToUserID).Error; err != nil {
+				return err
+			}
+		}
+
+		// Offers made to the user lapse
+		if err := tx.Model(&model.Workspace{}).Where("pending_owner_id = ?", user.ID).
+			Update("pending_owner_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+
+		if err := revokeUserAccess(tx, user.ID, time.Now()); err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// revokeUserAccess ends every way a user could still sign in or pull keys:
+// SSO links, second factors, sessions, deploy tokens and unused download links
+func revokeUserAccess(tx *gorm.DB, userID uint, now time.Time) error {
+	// Drop SSO links so the identity can be provisioned afresh
+	if err := tx.Where("user_id = ?", userID).Delete(&model.UserIdentity{}).Error; err != nil {
+		return err
+	}
+	if err := deleteUserMFA(tx, userID); err != nil {
+		return err
+	}
+	if err := tx.Model(&model.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&model.DeployToken{}).Where("created_by = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.DownloadLink{}).Where("created_by = ? AND used_at IS NULL AND expires_at > ?", userID, now).
+		Update("expires_at", now).Error
+}
+
+// reassignOwner makes a user the owner of a workspace, adding them as a
+// member if needed
+func reassignOwner(tx *gorm.DB, workspaceID, userID uint) error {
+	if err := tx.Model(&model.Workspace{}).Where("id = ?", workspaceID).
+		Updates(map[string]interface{}{"owner_id": userID, "pending_owner_id": nil}).Error; err != nil {
+		return err
+	}
+
+	var member model.WorkspaceMember
+	err := tx.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&model.WorkspaceMember{
+			WorkspaceID: workspaceID,
+			UserID:      userID,
+			Role:        model.WorkspaceRoleOwner,
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+	// Ownership is not synced from identity provider groups
+	return tx.Model(&member).Updates(map[string]interface{}{
+		"role":           model.WorkspaceRoleOwner,
+		"custom_role_id": nil,
+		"source":         "",
+	}).Error
+}
+
+// ownedWorkspace loads a workspace the user owns
+func (s *WorkspaceService) ownedWorkspace(workspaceID, userID uint) (*model.Workspace, error) {
+	var workspace model.Workspace
+	if err := s.db.First(&workspace, workspaceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkspaceNotFound
+		}
+		return nil, err
+	}
+	if workspace.OwnerID != userID {
+		return nil, ErrWorkspaceAccessDenied
+	}
+	return &workspace, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder collects the statements gorm would run
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+func TestRevokeUserAccess(t *testing.T) {
+	rec := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/acme", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := revokeUserAccess(db, 42, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"DELETE FROM `user_identities` WHERE user_id = 42",
+		"DELETE FROM `user_totps` WHERE user_id = 42",
+		"UPDATE `user_sessions` SET `revoked_at`=",
+		"UPDATE `deploy_tokens` SET `revoked_at`=",
+		"UPDATE `download_links` SET `expires_at`=",
+	}
+	all := strings.Join(rec.statements, "\n")
+	for _, w := range want {
+		if !strings.Contains(all, w) {
+			t.Errorf("missing %q in:\n%s", w, all)
+		}
+	}
+	for _, stmt := range rec.statements {
+		if strings.HasPrefix(stmt, "UPDATE `deploy_tokens`") && !strings.Contains(stmt, "created_by = 42 AND revoked_at IS NULL") {
+			t.Errorf("deploy tokens not scoped to the user: %s", stmt)
+		}
+		if strings.HasPrefix(stmt, "UPDATE `download_links`") && !strings.Contains(stmt, "created_by = 42 AND used_at IS NULL") {
+			t.Errorf("download links not scoped to the user's unused links: %s", stmt)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys handlers use to complete the audit record of their request
+const (
+	auditResourceKey  = "auditResourceID"
+	auditWorkspaceKey = "auditWorkspaceID"
+	auditChangesKey   = "auditChanges"
+)
+
+// SetAuditResource names the affected resource when the path does not,
+// e.g. the ID of a newly created one
+func SetAuditResource(c *gin.Context, id uint) {
+	c.Set(auditResourceKey, strconv.FormatUint(uint64(id), 10))
+}
+
+// SetAuditWorkspace sets the workspace the request acted in
+func SetAuditWorkspace(c *gin.Context, id *uint) {
+	if id != nil {
+		c.Set(auditWorkspaceKey, *id)
+	}
+}
+
+// SetAuditChanges attaches a before/after diff to the audit record
+func SetAuditChanges(c *gin.Context, changes interface{}) {
+	c.Set(auditChangesKey, changes)
+}
+
+// AuditDetails returns what the handler set for the audit record
+func AuditDetails(c *gin.Context) (resourceID string, workspaceID *uint, changes interface{}) {
+	resourceID = c.GetString(auditResourceKey)
+	if id, ok := c.Get(auditWorkspaceKey); ok {
+		wid := id.(uint)
+		workspaceID = &wid
+	}
+	changes, _ = c.Get(auditChangesKey)
+	return resourceID, workspaceID, changes
+}
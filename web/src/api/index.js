@@ -20,13 +20,56 @@ api.interceptors.request.use(
   error => Promise.reject(error)
 )
 
+function clearSession() {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refresh_token')
+  localStorage.removeItem('user')
+}
+
+// Access tokens are short-lived; a single refresh is shared by every request
+// that failed while it was in flight. Refresh tokens rotate on each use.
+let refreshing = null
+
+function refreshSession() {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem('refresh_token')
+    refreshing = axios.post('/api/v1/auth/refresh', { refresh_token: refreshToken })
+      .then(({ data }) => {
+        localStorage.setItem('token', data.data.token)
+        localStorage.setItem('refresh_token', data.data.refresh_token)
+        return data.data.token
+      })
+      .finally(() => { refreshing = null })
+  }
+  return refreshing
+}
+
 // Response interceptor
 api.interceptors.response.use(
   response => response.data,
-  error => {
+  async error => {
+    const config = error.config
+    if (error.response?.status === 401 && config?.headers?.Authorization && !config._retried &&
+        localStorage.getItem('refresh_token')) {
+      config._retried = true
+      try {
+        const token = await refreshSession()
+        config.headers.Authorization = `Bearer ${token}`
+        return api(config)
+      } catch (e) {
+        // Session ended: fall through to the login redirect
+      }
+    }
     if (error.response?.status === 401) {
-      localStorage.removeItem('token')
-      localStorage.removeItem('user')
+      clearSession()
+      window.location.href = '/login'
+    }
+    // An administrator set or reset the password: it must be changed first
+    if (error.response?.data?.code === 40301 && window.location.pathname !== '/login') {
+      const user = JSON.parse(localStorage.getItem('user') || 'null')
+      if (user) {
+        localStorage.setItem('user', JSON.stringify({ ...user, must_change_password: true }))
+      }
       window.location.href = '/login'
     }
     const message = error.response?.data?.message || error.message || 'Request failed'
@@ -40,6 +83,59 @@ export const authApi = {
     return api.post('/auth/login', { username, password })
   },
 
+  logout() {
+    return api.post('/auth/logout')
+  },
+
+  listSessions() {
+    return api.get('/auth/sessions')
+  },
+
+  revokeSession(id) {
+    return api.delete(`/auth/sessions/${id}`)
+  },
+
+  revokeAllSessions() {
+    return api.delete('/auth/sessions')
+  },
+
+  getSSOProviders() {
+    return api.get('/auth/oidc/providers')
+  },
+
+  exchangeSSOCode(code) {
+    return api.post('/auth/oidc/exchange', { code })
+  },
+
+  // Second login step, authenticated by the MFA challenge token
+  verifyMFA(mfaToken, method, code) {
+    return api.post('/auth/mfa/login/verify', { mfa_token: mfaToken, method, code })
+  },
+
+  passkeyLoginOptions(mfaToken) {
+    return api.post('/auth/mfa/login/webauthn/options', { mfa_token: mfaToken })
+  },
+
+  passkeyLogin(mfaToken, credential) {
+    return api.post('/auth/mfa/login/webauthn', { mfa_token: mfaToken, credential })
+  },
+
+  enrollTOTP(mfaToken) {
+    return api.post('/auth/mfa/enroll/totp', { mfa_token: mfaToken })
+  },
+
+  enrollTOTPEnable(mfaToken, code) {
+    return api.post('/auth/mfa/enroll/totp/enable', { mfa_token: mfaToken, code })
+  },
+
+  enrollPasskeyOptions(mfaToken) {
+    return api.post('/auth/mfa/enroll/webauthn/options', { mfa_token: mfaToken })
+  },
+
+  enrollPasskey(mfaToken, name, credential) {
+    return api.post('/auth/mfa/enroll/webauthn', { mfa_token: mfaToken, name, credential })
+  },
+
   getCurrentUser() {
     return api.get('/auth/me')
   },
@@ -56,6 +152,51 @@ export const authApi = {
   }
 }
 
+// Two-factor authentication and step-up re-authentication
+const stepUpHeaders = stepUpToken => ({ headers: { 'X-Step-Up-Token': stepUpToken } })
+
+export const mfaApi = {
+  status() {
+    return api.get('/auth/mfa')
+  },
+
+  setupTOTP() {
+    return api.post('/auth/mfa/totp')
+  },
+
+  enableTOTP(code) {
+    return api.post('/auth/mfa/totp/enable', { code })
+  },
+
+  disableTOTP(stepUpToken) {
+    return api.delete('/auth/mfa/totp', stepUpHeaders(stepUpToken))
+  },
+
+  passkeyOptions() {
+    return api.post('/auth/mfa/webauthn/options')
+  },
+
+  registerPasskey(name, credential) {
+    return api.post('/auth/mfa/webauthn', { name, credential })
+  },
+
+  deletePasskey(id, stepUpToken) {
+    return api.delete(`/auth/mfa/webauthn/${id}`, stepUpHeaders(stepUpToken))
+  },
+
+  regenerateRecoveryCodes(stepUpToken) {
+    return api.post('/auth/mfa/recovery-codes', null, stepUpHeaders(stepUpToken))
+  },
+
+  stepUpOptions() {
+    return api.post('/auth/step-up/options')
+  },
+
+  stepUp(data) {
+    return api.post('/auth/step-up', data)
+  }
+}
+
 // User API (admin only)
 export const userApi = {
   list(params = {}) {
@@ -74,12 +215,21 @@ export const userApi = {
     return api.put(`/admin/users/${id}`, data)
   },
 
-  delete(id) {
-    return api.delete(`/admin/users/${id}`)
+  // reassignTo takes over the workspaces and private certificates the user owns
+  delete(id, reassignTo) {
+    return api.delete(`/admin/users/${id}`, { params: reassignTo ? { reassign_to: reassignTo } : {} })
   },
 
   resetPassword(id, password) {
     return api.post(`/admin/users/${id}/reset-password`, { password })
+  },
+
+  resetMFA(id) {
+    return api.delete(`/admin/users/${id}/mfa`)
+  },
+
+  unlock(id) {
+    return api.post(`/admin/users/${id}/unlock`)
   }
 }
 
@@ -118,9 +268,10 @@ export const certificateApi = {
     return api.post(`/certificates/${id}/pre-verify`, {}, { timeout: 60000 })
   },
 
-  download(id, format = 'zip', password = '') {
+  // Formats with the private key need a step-up grant; password-protected
+  // formats are only offered through download links
+  download(id, format = 'zip', stepUpToken = '') {
     const params = { format }
-    if (password) params.password = password
 
     // Create a new axios instance without response interceptor for blob downloads
     const downloadApi = axios.create({
@@ -142,6 +293,7 @@ export const certificateApi = {
 
     return downloadApi.get(`/certificates/${id}/download`, {
       params,
+      headers: stepUpToken ? { 'X-Step-Up-Token': stepUpToken } : {},
       responseType: 'blob'
     })
   },
@@ -169,6 +321,28 @@ export const certificateApi = {
 
   getRenewalLogs(id, limit = 50) {
     return api.get(`/certificates/${id}/renewal-logs`, { params: { limit } })
+  },
+
+  // Last sync result of each deployment target
+  getDeploymentStatus(id) {
+    return api.get(`/certificates/${id}/deployment-status`)
+  },
+
+  // workspaceId null makes the certificate private
+  move(id, workspaceId) {
+    return api.post(`/certificates/${id}/move`, { workspace_id: workspaceId })
+  },
+
+  listShares(id) {
+    return api.get(`/certificates/${id}/shares`)
+  },
+
+  share(id, workspaceId) {
+    return api.post(`/certificates/${id}/shares`, { workspace_id: workspaceId })
+  },
+
+  unshare(id, workspaceId) {
+    return api.delete(`/certificates/${id}/shares/${workspaceId}`)
   }
 }
 
@@ -208,6 +382,45 @@ export const workspaceApi = {
 
   removeMember(id, userId) {
     return api.delete(`/workspaces/${id}/members/${userId}`)
+  },
+
+  listInvitations(id) {
+    return api.get(`/workspaces/${id}/invitations`)
+  },
+
+  createInvitation(id, data) {
+    return api.post(`/workspaces/${id}/invitations`, data)
+  },
+
+  revokeInvitation(id, invitationId) {
+    return api.delete(`/workspaces/${id}/invitations/${invitationId}`)
+  },
+
+  requestTransfer(id, userId) {
+    return api.post(`/workspaces/${id}/transfer`, { user_id: userId })
+  },
+
+  cancelTransfer(id) {
+    return api.delete(`/workspaces/${id}/transfer`)
+  },
+
+  acceptTransfer(id) {
+    return api.post(`/workspaces/${id}/transfer/accept`)
+  }
+}
+
+// Invitation API, addressed by the token from the invitation link
+export const invitationApi = {
+  get(token) {
+    return api.get(`/invitations/${encodeURIComponent(token)}`)
+  },
+
+  accept(token) {
+    return api.post(`/invitations/${encodeURIComponent(token)}/accept`)
+  },
+
+  signup(token, data) {
+    return api.post(`/invitations/${encodeURIComponent(token)}/signup`, data)
   }
 }
 
@@ -219,6 +432,14 @@ export const settingApi = {
 
   updateSite(data) {
     return api.put('/admin/settings/site', data)
+  },
+
+  getSecurity() {
+    return api.get('/admin/settings/security')
+  },
+
+  updateSecurity(data) {
+    return api.put('/admin/settings/security', data)
   }
 }
 
@@ -253,4 +474,58 @@ export const notificationApi = {
   }
 }
 
+// Network discovery API: scan jobs and the certificates they found
+export const discoveryApi = {
+  listJobs(workspaceId) {
+    return api.get('/discovery/jobs', { params: { workspace_id: workspaceId } })
+  },
+
+  createJob(data) {
+    return api.post('/discovery/jobs', data)
+  },
+
+  deleteJob(id) {
+    return api.delete(`/discovery/jobs/${id}`)
+  },
+
+  runJob(id) {
+    return api.post(`/discovery/jobs/${id}/run`)
+  },
+
+  listCertificates(params = {}) {
+    return api.get('/discovery/certificates', { params })
+  },
+
+  manage(id, data) {
+    return api.post(`/discovery/certificates/${id}/manage`, data)
+  },
+
+  track(id) {
+    return api.post(`/discovery/certificates/${id}/track`)
+  },
+
+  ignore(id) {
+    return api.post(`/discovery/certificates/${id}/ignore`)
+  }
+}
+
+// Audit log API; workspace owners and admins read their workspace's events
+export const auditApi = {
+  list(params = {}) {
+    return api.get('/admin/audit-logs', { params })
+  },
+
+  export(params = {}) {
+    return api.get('/admin/audit-logs/export', { params, responseType: 'blob', timeout: 120000 })
+  },
+
+  listWorkspace(id, params = {}) {
+    return api.get(`/workspaces/${id}/audit-logs`, { params })
+  },
+
+  exportWorkspace(id, params = {}) {
+    return api.get(`/workspaces/${id}/audit-logs/export`, { params, responseType: 'blob', timeout: 120000 })
+  }
+}
+
 export default api
@@ -0,0 +1,58 @@
+// Passkey helpers: the server sends and expects binary fields as base64url
+
+function toBuffer(value) {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4)
+  return Uint8Array.from(atob(padded), c => c.charCodeAt(0)).buffer
+}
+
+function toBase64url(buffer) {
+  const bytes = new Uint8Array(buffer)
+  let binary = ''
+  bytes.forEach(b => { binary += String.fromCharCode(b) })
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+export const passkeysSupported = () => typeof window !== 'undefined' && !!window.PublicKeyCredential
+
+// Runs navigator.credentials.create with options from the server and
+// returns the attestation to send back
+export async function createPasskey(options) {
+  const publicKey = { ...options.publicKey }
+  publicKey.challenge = toBuffer(publicKey.challenge)
+  publicKey.user = { ...publicKey.user, id: toBuffer(publicKey.user.id) }
+  publicKey.excludeCredentials = (publicKey.excludeCredentials || []).map(c => ({ ...c, id: toBuffer(c.id) }))
+
+  const credential = await navigator.credentials.create({ publicKey })
+  return {
+    id: credential.id,
+    rawId: toBase64url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64url(credential.response.clientDataJSON),
+      attestationObject: toBase64url(credential.response.attestationObject),
+      transports: credential.response.getTransports ? credential.response.getTransports() : []
+    }
+  }
+}
+
+// Runs navigator.credentials.get with options from the server and returns
+// the assertion to send back
+export async function getPasskey(options) {
+  const publicKey = { ...options.publicKey }
+  publicKey.challenge = toBuffer(publicKey.challenge)
+  publicKey.allowCredentials = (publicKey.allowCredentials || []).map(c => ({ ...c, id: toBuffer(c.id) }))
+
+  const credential = await navigator.credentials.get({ publicKey })
+  return {
+    id: credential.id,
+    rawId: toBase64url(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64url(credential.response.clientDataJSON),
+      authenticatorData: toBase64url(credential.response.authenticatorData),
+      signature: toBase64url(credential.response.signature),
+      userHandle: credential.response.userHandle ? toBase64url(credential.response.userHandle) : null
+    }
+  }
+}
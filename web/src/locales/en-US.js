@@ -29,12 +29,14 @@ export default {
   nav: {
     certificates: 'Certificates',
     workspaces: 'Workspaces',
+    discovery: 'Discovery',
     users: 'Users',
     profile: 'Profile',
     logout: 'Logout',
     system: 'System',
     settings: 'Settings',
-    about: 'About'
+    about: 'About',
+    auditLog: 'Audit Log'
   },
 
   auth: {
@@ -47,8 +49,63 @@ export default {
     loginTitle: 'ACME Console',
     loginSubtitle: 'Certificate Management System',
     defaultCredentials: 'Default: admin / admin123',
+    changePasswordRequired: 'Choose a new password',
+    changePasswordRequiredDesc: 'Your password was set by an administrator. Choose a new one to continue.',
     invalidCredentials: 'Invalid username or password',
-    userDisabled: 'User is disabled'
+    userDisabled: 'User is disabled',
+    or: 'or',
+    signInWith: 'Sign in with {name}',
+    ssoFailed: 'Single sign-on failed: {message}',
+    adminPasswordOnly: 'Password login is only available to administrators'
+  },
+
+  mfa: {
+    title: 'Two-factor authentication',
+    cardTitle: 'Two-Factor Authentication',
+    cardDesc: 'Protect your account with an authenticator app or a passkey',
+    unavailable: 'Two-factor authentication is not configured on this server',
+    requiredForAccount: 'Your account is required to keep a second factor',
+    authenticatorApp: 'Authenticator app',
+    authenticatorCode: 'Authenticator app code',
+    recoveryCode: 'Recovery code',
+    enabled: 'Enabled',
+    notEnabled: 'Not set up',
+    setUp: 'Set up',
+    remove: 'Remove',
+    verify: 'Verify',
+    verifying: 'Verifying...',
+    passkey: 'Passkey',
+    passkeys: 'Passkeys',
+    passkeyName: 'Passkey name (optional)',
+    addPasskey: 'Add passkey',
+    noPasskeys: 'No passkeys registered',
+    usePasskey: 'Use a passkey',
+    useRecoveryCode: 'Use a recovery code instead',
+    useAuthenticatorApp: 'Use an authenticator app',
+    backToLogin: 'Back to login',
+    enrollRequired: 'Set up two-factor authentication',
+    enrollRequiredDesc: 'Your account requires a second factor. Set one up to continue.',
+    scanSecret: 'Add this key to your authenticator app, or open the link on your phone, then enter the 6-digit code it shows.',
+    recoveryCodes: 'Recovery codes',
+    recoveryCodesLeft: '{count} unused',
+    regenerate: 'Generate new codes',
+    saveRecoveryCodes: 'Save your recovery codes',
+    recoveryCodesDesc: 'Each code can be used once if you lose access to your second factor. They will not be shown again.',
+    savedCodes: 'I have saved these codes',
+    continue: 'Continue',
+    stepUpTitle: 'Confirm it is you',
+    stepUpKeyExport: 'Exporting a private key requires confirming your identity.'
+  },
+
+  session: {
+    title: 'Active Sessions',
+    description: 'Devices currently logged in to your account',
+    current: 'This device',
+    lastSeen: 'last active {time}',
+    unknownDevice: 'Unknown device',
+    revoke: 'Log out',
+    logoutAll: 'Log out all sessions',
+    logoutAllConfirm: 'Log out every device, including this one?'
   },
 
   user: {
@@ -73,11 +130,19 @@ export default {
     deleteConfirm: 'Are you sure you want to delete this user?',
     cannotDeleteSelf: 'Cannot delete yourself',
     cannotDeleteLastAdmin: 'Cannot delete the last admin',
+    reassignTo: 'Reassign Owned Items To',
+    reassignToHint: 'Workspaces owned by this user and their private certificates are handed over to the selected user. Required if the user owns any.',
     userCreated: 'User created successfully',
     userUpdated: 'User updated successfully',
     userDeleted: 'User deleted successfully',
     passwordReset: 'Password reset successfully',
-    searchPlaceholder: 'Search username, nickname or email...'
+    searchPlaceholder: 'Search username, nickname or email...',
+    locked: 'Locked',
+    lockedUntil: 'Locked after failed logins until {time}',
+    unlock: 'Unlock',
+    mustChangePasswordHint: 'The user has to choose a new password at the next login',
+    resetMFA: 'Reset 2FA',
+    resetMFAConfirm: 'Remove every second factor of this user? They can log in with their password alone until they set up a new one.'
   },
 
   profile: {
@@ -89,6 +154,7 @@ export default {
     profileUpdated: 'Profile updated successfully',
     changePassword: 'Change Password',
     changePasswordDesc: 'Update your password to keep your account secure',
+    passwordManagedByDirectory: 'Your password is managed by your organization directory (LDAP / Active Directory)',
     currentPassword: 'Current Password',
     newPassword: 'New Password',
     confirmNewPassword: 'Confirm New Password',
@@ -153,7 +219,23 @@ export default {
     issueModeIndependent: 'Independent',
     issueModeDefault: 'Default',
     issueModeCombinedDesc: 'All domains merged into one SAN certificate, ideal for single-server deployments',
-    issueModeIndependentDesc: 'Each domain gets its own certificate, ideal for multi-server deployments'
+    issueModeIndependentDesc: 'Each domain gets its own certificate, ideal for multi-server deployments',
+    workspaceSharing: 'Workspace & Sharing',
+    moveTo: 'Move To',
+    move: 'Move',
+    moveHint: 'Notification settings, deployment targets and renewal settings move with the certificate.',
+    moveConfirm: 'Move this certificate to {workspace}? Members of its current workspace lose access unless it is shared with them.',
+    sharedWith: 'Shared With',
+    notShared: 'Not shared with other workspaces',
+    shareWith: 'Share With',
+    share: 'Share',
+    unshare: 'Stop sharing',
+    shareHint: 'Members of shared workspaces can view and download the certificate, but not its private key, and cannot change it.',
+    shared: 'Shared',
+    sharedHint: 'Shared read-only from another workspace',
+    sharedReadOnly: 'Shared with you, read-only',
+    deploymentStatus: 'Deployment Status',
+    neverDeployed: 'Not deployed yet'
   },
 
   renewal: {
@@ -237,6 +319,8 @@ export default {
     roleOwner: 'Owner',
     roleAdmin: 'Admin',
     roleMember: 'Member',
+    roleOperator: 'Operator',
+    roleViewer: 'Viewer',
     status: 'Status',
     active: 'Active',
     archived: 'Archived',
@@ -264,7 +348,51 @@ export default {
     searchPlaceholder: 'Search workspaces...',
     certificates: 'Certificates',
     viewCertificates: 'View Certificates',
-    notifications: 'Expiry Notifications'
+    notifications: 'Expiry Notifications',
+    archive: 'Archive',
+    archiveConfirm: 'Archive this workspace? It becomes read-only: certificates can still be viewed and downloaded, but nothing can be issued or changed until it is restored.',
+    archivedNotice: 'This workspace is archived and read-only.',
+    restore: 'Restore',
+    transferOwnership: 'Transfer Ownership',
+    transferHint: 'The new owner must be an admin of this workspace and has to accept the transfer. You will stay on as an admin.',
+    newOwner: 'New Owner',
+    transferNoAdmins: 'Make a member an admin first.',
+    transferPending: 'Ownership transfer to {name} is waiting for acceptance.',
+    transferCancel: 'Cancel Transfer',
+    transferOffered: 'The owner has offered you ownership of this workspace.',
+    transferAccept: 'Accept Ownership',
+    transferDecline: 'Decline'
+  },
+
+  invitation: {
+    title: 'Invitations',
+    invite: 'Invite',
+    invitedByColumn: 'Invited By',
+    expires: 'Expires',
+    expired: 'Expired',
+    noInvitations: 'No pending invitations',
+    emailSent: 'email sent',
+    revoke: 'Revoke',
+    revokeConfirm: 'Revoke this invitation? The link will stop working.',
+    emailHint: 'Optional. Used to send the link and prefilled when the invitee signs up.',
+    expiresInDays: 'Valid for (days)',
+    sendEmail: 'Send the link by email',
+    createLink: 'Create Link',
+    linkHint: 'Share this link with the person you are inviting. It is shown only once.',
+    emailFailed: 'The invitation was created but the email could not be sent: {message}',
+    copied: 'Copied',
+    done: 'Done',
+    invalid: 'This invitation link is invalid, has expired or has already been used.',
+    joinTitle: 'Join {workspace}',
+    invitedBy: '{name} invited you to join as {role}.',
+    expiresAt: 'Invitation expires {date}',
+    signedInAs: 'Signed in as {name}',
+    join: 'Join Workspace',
+    useAnotherAccount: 'Use another account',
+    createAccountAndJoin: 'Create Account and Join',
+    haveAccount: 'I already have an account',
+    accountCreated: 'Account created',
+    accountCreatedDesc: 'You have joined the workspace. Sign in to finish setting up two-factor authentication.'
   },
 
   system: {
@@ -292,7 +420,84 @@ export default {
     links: 'Project Links',
     license: 'License',
     licenseText: 'This project is open-sourced under the MIT License. You are free to use, modify, and distribute this software. No fees are required for personal or commercial use. Feel free to Star, Fork, and submit Pull Requests to help make this project better!',
-    madeWith: 'ACME Console - Making certificate management simple'
+    madeWith: 'ACME Console - Making certificate management simple',
+    securityPolicy: 'Security Policy',
+    securityPolicyDesc: 'Two-factor requirements, password rules and lockout after failed logins. Users covered by a two-factor requirement set it up at their next login',
+    requireMFAAdmins: 'Require two-factor authentication for administrators',
+    requireMFAOwners: 'Require two-factor authentication for workspace owners',
+    passwordMinLength: 'Minimum password length',
+    lockoutThreshold: 'Failed logins before lockout',
+    lockoutThresholdHint: 'Attempts are slowed down after 3 failures. A single IP address may fail five times as often across all usernames',
+    lockoutMinutes: 'Lockout duration (minutes)',
+    auditRetentionDays: 'Audit log retention (days)',
+    auditRetentionDaysHint: 'Older events are deleted periodically. 0 keeps the audit log forever'
+  },
+
+  discovery: {
+    jobs: 'Scan Jobs',
+    newJob: 'New Scan Job',
+    name: 'Name',
+    cidrs: 'Networks (CIDR)',
+    cidrsHint: 'One range per line or comma separated, e.g. 10.0.0.0/24',
+    ports: 'Ports',
+    interval: 'Interval (hours)',
+    intervalHint: '0 runs the job only when started by hand',
+    everyHours: 'Every {hours}h',
+    manualOnly: 'Manual',
+    status: 'Status',
+    lastRun: 'Last Run',
+    found: '{count} found',
+    run: 'Run Now',
+    deleteJobConfirm: 'Delete scan job "{name}"? Certificates it found are kept.',
+    noJobs: 'No scan jobs yet',
+    noWorkspace: 'Network discovery runs inside a workspace. Join or create a workspace first.',
+    discovered: 'Discovered Certificates',
+    allStatuses: 'All statuses',
+    endpoint: 'Endpoint',
+    issuer: 'Issuer',
+    noDiscovered: 'No certificates discovered',
+    manage: 'Manage',
+    track: 'Track',
+    ignore: 'Ignore',
+    manageTitle: 'Manage with ACME',
+    manageHint: 'Issues a new certificate for these names with ACME and renews it automatically.',
+    job_idle: 'Idle',
+    job_running: 'Running',
+    job_failed: 'Failed',
+    cert_new: 'New',
+    cert_managed: 'Managed',
+    cert_tracked: 'Tracked',
+    cert_ignored: 'Ignored'
+  },
+
+  audit: {
+    title: 'Audit Log',
+    time: 'Time',
+    actor: 'Actor',
+    action: 'Action',
+    resource: 'Resource',
+    outcome: 'Outcome',
+    ip: 'IP Address',
+    requestId: 'Request ID',
+    changes: 'Changes',
+    workspace: 'Workspace',
+    success: 'Success',
+    failure: 'Failure',
+    allOutcomes: 'All outcomes',
+    from: 'From',
+    to: 'To',
+    actionPlaceholder: 'Action, e.g. certificate. or user.create',
+    actorPlaceholder: 'Username',
+    resourceTypePlaceholder: 'Resource type',
+    resourceIdPlaceholder: 'Resource ID',
+    search: 'Search',
+    reset: 'Reset',
+    export: 'Export CSV',
+    exporting: 'Exporting...',
+    noEvents: 'No audit events found',
+    viaToken: 'API token #{id}',
+    scheduler: 'Scheduler',
+    anonymous: 'Anonymous'
   },
 
   notification: {
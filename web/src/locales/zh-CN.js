@@ -29,12 +29,14 @@ export default {
   nav: {
     certificates: '证书管理',
     workspaces: '工作空间',
+    discovery: '网络发现',
     users: '用户管理',
     profile: '个人资料',
     logout: '退出登录',
     system: '系统管理',
     settings: '系统设置',
-    about: '系统说明'
+    about: '系统说明',
+    auditLog: '审计日志'
   },
 
   auth: {
@@ -47,8 +49,63 @@ export default {
     loginTitle: 'ACME Console',
     loginSubtitle: '证书管理系统',
     defaultCredentials: '默认账号: admin / admin123',
+    changePasswordRequired: '设置新密码',
+    changePasswordRequiredDesc: '您的密码由管理员设置，请设置新密码后继续。',
     invalidCredentials: '用户名或密码错误',
-    userDisabled: '用户已被禁用'
+    userDisabled: '用户已被禁用',
+    or: '或',
+    signInWith: '使用 {name} 登录',
+    ssoFailed: '单点登录失败：{message}',
+    adminPasswordOnly: '密码登录仅对管理员开放'
+  },
+
+  mfa: {
+    title: '两步验证',
+    cardTitle: '两步验证',
+    cardDesc: '使用身份验证器应用或通行密钥保护您的账户',
+    unavailable: '服务器未配置两步验证',
+    requiredForAccount: '您的账户必须保留至少一个第二因素',
+    authenticatorApp: '身份验证器应用',
+    authenticatorCode: '身份验证器验证码',
+    recoveryCode: '恢复码',
+    enabled: '已启用',
+    notEnabled: '未设置',
+    setUp: '设置',
+    remove: '移除',
+    verify: '验证',
+    verifying: '验证中...',
+    passkey: '通行密钥',
+    passkeys: '通行密钥',
+    passkeyName: '通行密钥名称（可选）',
+    addPasskey: '添加通行密钥',
+    noPasskeys: '尚未注册通行密钥',
+    usePasskey: '使用通行密钥',
+    useRecoveryCode: '改用恢复码',
+    useAuthenticatorApp: '使用身份验证器应用',
+    backToLogin: '返回登录',
+    enrollRequired: '设置两步验证',
+    enrollRequiredDesc: '您的账户需要第二因素，请先完成设置。',
+    scanSecret: '将此密钥添加到身份验证器应用，或在手机上打开该链接，然后输入显示的 6 位验证码。',
+    recoveryCodes: '恢复码',
+    recoveryCodesLeft: '剩余 {count} 个',
+    regenerate: '重新生成',
+    saveRecoveryCodes: '保存您的恢复码',
+    recoveryCodesDesc: '丢失第二因素时，每个恢复码可使用一次。恢复码不会再次显示。',
+    savedCodes: '我已保存这些恢复码',
+    continue: '继续',
+    stepUpTitle: '确认是您本人',
+    stepUpKeyExport: '导出私钥需要先确认您的身份。'
+  },
+
+  session: {
+    title: '登录会话',
+    description: '当前登录您账户的设备',
+    current: '当前设备',
+    lastSeen: '最近活动 {time}',
+    unknownDevice: '未知设备',
+    revoke: '退出登录',
+    logoutAll: '退出所有会话',
+    logoutAllConfirm: '确定要退出所有设备（包括当前设备）吗？'
   },
 
   user: {
@@ -73,11 +130,19 @@ export default {
     deleteConfirm: '确定要删除此用户吗？',
     cannotDeleteSelf: '不能删除自己',
     cannotDeleteLastAdmin: '不能删除最后一个管理员',
+    reassignTo: '转交所属资源给',
+    reassignToHint: '该用户拥有的工作空间和个人证书将转交给所选用户。若该用户拥有任何此类资源则必须选择。',
     userCreated: '用户创建成功',
     userUpdated: '用户更新成功',
     userDeleted: '用户删除成功',
     passwordReset: '密码重置成功',
-    searchPlaceholder: '搜索用户名、昵称或邮箱...'
+    searchPlaceholder: '搜索用户名、昵称或邮箱...',
+    locked: '已锁定',
+    lockedUntil: '登录失败次数过多，锁定至 {time}',
+    unlock: '解锁',
+    mustChangePasswordHint: '用户下次登录时需要设置新密码',
+    resetMFA: '重置两步验证',
+    resetMFAConfirm: '确定要移除该用户的所有第二因素吗？在重新设置之前，该用户仅需密码即可登录。'
   },
 
   profile: {
@@ -89,6 +154,7 @@ export default {
     profileUpdated: '资料更新成功',
     changePassword: '修改密码',
     changePasswordDesc: '更新您的密码以保护账户安全',
+    passwordManagedByDirectory: '您的密码由组织目录（LDAP / Active Directory）管理',
     currentPassword: '当前密码',
     newPassword: '新密码',
     confirmNewPassword: '确认新密码',
@@ -153,7 +219,23 @@ export default {
     issueModeIndependent: '独立签发',
     issueModeDefault: '默认',
     issueModeCombinedDesc: '所有域名合并为一张 SAN 证书，适合同一服务器部署多个域名',
-    issueModeIndependentDesc: '每个域名独立签发一张证书，适合不同服务器分别部署'
+    issueModeIndependentDesc: '每个域名独立签发一张证书，适合不同服务器分别部署',
+    workspaceSharing: '工作空间与共享',
+    moveTo: '移动到',
+    move: '移动',
+    moveHint: '通知配置、部署目标和续期设置会随证书一起移动。',
+    moveConfirm: '确定将此证书移动到 {workspace} 吗？除非共享给当前工作空间，否则其成员将无法再访问此证书。',
+    sharedWith: '已共享给',
+    notShared: '未共享给其他工作空间',
+    shareWith: '共享给',
+    share: '共享',
+    unshare: '取消共享',
+    shareHint: '被共享工作空间的成员可以查看和下载证书，但无法导出私钥或修改证书。',
+    shared: '共享',
+    sharedHint: '由其他工作空间只读共享',
+    sharedReadOnly: '共享给您，只读',
+    deploymentStatus: '部署状态',
+    neverDeployed: '尚未部署'
   },
 
   renewal: {
@@ -237,6 +319,8 @@ export default {
     roleOwner: '所有者',
     roleAdmin: '管理员',
     roleMember: '成员',
+    roleOperator: '运维',
+    roleViewer: '只读',
     status: '状态',
     active: '活跃',
     archived: '已归档',
@@ -264,7 +348,51 @@ export default {
     searchPlaceholder: '搜索工作空间...',
     certificates: '证书',
     viewCertificates: '查看证书',
-    notifications: '过期通知'
+    notifications: '过期通知',
+    archive: '归档',
+    archiveConfirm: '确定归档此工作空间吗？归档后工作空间只读：证书仍可查看和下载，但在恢复之前无法申请或修改任何内容。',
+    archivedNotice: '此工作空间已归档，处于只读状态。',
+    restore: '恢复',
+    transferOwnership: '转让所有权',
+    transferHint: '新所有者必须是本工作空间的管理员，并需要确认接受。转让后您将成为管理员。',
+    newOwner: '新所有者',
+    transferNoAdmins: '请先将某位成员设为管理员。',
+    transferPending: '所有权转让给 {name}，等待对方接受。',
+    transferCancel: '取消转让',
+    transferOffered: '所有者希望将此工作空间的所有权转让给您。',
+    transferAccept: '接受所有权',
+    transferDecline: '拒绝'
+  },
+
+  invitation: {
+    title: '邀请',
+    invite: '邀请成员',
+    invitedByColumn: '邀请人',
+    expires: '有效期至',
+    expired: '已过期',
+    noInvitations: '暂无待接受的邀请',
+    emailSent: '已发送邮件',
+    revoke: '撤销',
+    revokeConfirm: '确定撤销此邀请吗？链接将立即失效。',
+    emailHint: '可选。用于发送邀请邮件，并在被邀请人注册时自动填入。',
+    expiresInDays: '有效天数',
+    sendEmail: '通过邮件发送链接',
+    createLink: '生成链接',
+    linkHint: '请将此链接发送给被邀请人，链接仅显示一次。',
+    emailFailed: '邀请已创建，但邮件发送失败：{message}',
+    copied: '已复制',
+    done: '完成',
+    invalid: '邀请链接无效、已过期或已被使用。',
+    joinTitle: '加入 {workspace}',
+    invitedBy: '{name} 邀请您以 {role} 身份加入。',
+    expiresAt: '邀请有效期至 {date}',
+    signedInAs: '当前登录账号：{name}',
+    join: '加入工作空间',
+    useAnotherAccount: '使用其他账号',
+    createAccountAndJoin: '注册并加入',
+    haveAccount: '我已有账号',
+    accountCreated: '账号已创建',
+    accountCreatedDesc: '您已加入工作空间，请登录并完成双因素认证设置。'
   },
 
   system: {
@@ -292,7 +420,84 @@ export default {
     links: '项目链接',
     license: '开源协议',
     licenseText: '本项目基于 MIT 协议开源发布，您可以自由使用、修改和分发本软件。无论是个人项目还是商业用途，均无需支付任何费用。欢迎 Star、Fork 和提交 Pull Request，一起让这个项目变得更好！',
-    madeWith: 'ACME Console - 让证书管理更简单'
+    madeWith: 'ACME Console - 让证书管理更简单',
+    securityPolicy: '安全策略',
+    securityPolicyDesc: '两步验证要求、密码规则及登录失败锁定。受两步验证要求约束的用户需在下次登录时完成设置',
+    requireMFAAdmins: '管理员必须启用两步验证',
+    requireMFAOwners: '工作空间所有者必须启用两步验证',
+    passwordMinLength: '密码最小长度',
+    lockoutThreshold: '锁定前允许的登录失败次数',
+    lockoutThresholdHint: '失败 3 次后每次尝试都会延迟。单个 IP 地址在所有用户名上的失败上限为该值的五倍',
+    lockoutMinutes: '锁定时长（分钟）',
+    auditRetentionDays: '审计日志保留天数',
+    auditRetentionDaysHint: '超过保留期的事件会被定期删除。设为 0 则永久保留'
+  },
+
+  discovery: {
+    jobs: '扫描任务',
+    newJob: '新建扫描任务',
+    name: '名称',
+    cidrs: '网段 (CIDR)',
+    cidrsHint: '每行一个或以逗号分隔，例如 10.0.0.0/24',
+    ports: '端口',
+    interval: '执行间隔（小时）',
+    intervalHint: '0 表示仅手动执行',
+    everyHours: '每 {hours} 小时',
+    manualOnly: '手动',
+    status: '状态',
+    lastRun: '上次执行',
+    found: '发现 {count} 个',
+    run: '立即执行',
+    deleteJobConfirm: '确定删除扫描任务 "{name}"？已发现的证书会保留。',
+    noJobs: '暂无扫描任务',
+    noWorkspace: '网络发现需要在工作空间中进行，请先加入或创建工作空间。',
+    discovered: '已发现的证书',
+    allStatuses: '全部状态',
+    endpoint: '端点',
+    issuer: '签发者',
+    noDiscovered: '暂未发现证书',
+    manage: '托管',
+    track: '跟踪',
+    ignore: '忽略',
+    manageTitle: '通过 ACME 托管',
+    manageHint: '将通过 ACME 为这些域名签发新证书并自动续期。',
+    job_idle: '空闲',
+    job_running: '执行中',
+    job_failed: '失败',
+    cert_new: '新发现',
+    cert_managed: '已托管',
+    cert_tracked: '跟踪中',
+    cert_ignored: '已忽略'
+  },
+
+  audit: {
+    title: '审计日志',
+    time: '时间',
+    actor: '操作者',
+    action: '操作',
+    resource: '资源',
+    outcome: '结果',
+    ip: 'IP 地址',
+    requestId: '请求 ID',
+    changes: '变更内容',
+    workspace: '工作空间',
+    success: '成功',
+    failure: '失败',
+    allOutcomes: '全部结果',
+    from: '开始时间',
+    to: '结束时间',
+    actionPlaceholder: '操作，例如 certificate. 或 user.create',
+    actorPlaceholder: '用户名',
+    resourceTypePlaceholder: '资源类型',
+    resourceIdPlaceholder: '资源 ID',
+    search: '查询',
+    reset: '重置',
+    export: '导出 CSV',
+    exporting: '导出中...',
+    noEvents: '暂无审计事件',
+    viaToken: 'API 令牌 #{id}',
+    scheduler: '定时任务',
+    anonymous: '匿名'
   },
 
   notification: {
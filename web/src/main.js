@@ -15,7 +15,10 @@ import WorkspaceDetail from './views/WorkspaceDetail.vue'
 import Profile from './views/Profile.vue'
 import UserList from './views/UserList.vue'
 import SystemSettings from './views/SystemSettings.vue'
+import AuditLog from './views/AuditLog.vue'
+import Discovery from './views/Discovery.vue'
 import SystemAbout from './views/SystemAbout.vue'
+import AcceptInvitation from './views/AcceptInvitation.vue'
 
 const routes = [
   {
@@ -24,6 +27,12 @@ const routes = [
     component: Login,
     meta: { guest: true }
   },
+  {
+    // Public: works both signed in and signed out
+    path: '/invite/:token',
+    name: 'AcceptInvitation',
+    component: AcceptInvitation
+  },
   {
     path: '/',
     redirect: '/certificates'
@@ -58,6 +67,12 @@ const routes = [
     component: WorkspaceDetail,
     meta: { requiresAuth: true }
   },
+  {
+    path: '/discovery',
+    name: 'Discovery',
+    component: Discovery,
+    meta: { requiresAuth: true }
+  },
   {
     path: '/profile',
     name: 'Profile',
@@ -76,6 +91,12 @@ const routes = [
     component: SystemSettings,
     meta: { requiresAuth: true, requiresAdmin: true }
   },
+  {
+    path: '/audit-logs',
+    name: 'AuditLog',
+    component: AuditLog,
+    meta: { requiresAuth: true, requiresAdmin: true }
+  },
   {
     path: '/about',
     name: 'SystemAbout',
@@ -91,11 +112,15 @@ const router = createRouter({
 
 // Navigation guards
 router.beforeEach((to, from, next) => {
-  const { isAuthenticated, isAdmin } = useAuth()
+  const { isAuthenticated, isAdmin, getUser } = useAuth()
   const authenticated = isAuthenticated()
+  // The login page asks for a new password before anything else is usable
+  const mustChangePassword = authenticated && getUser()?.must_change_password
 
   if (to.meta.requiresAuth && !authenticated) {
-    next('/login')
+    next({ path: '/login', query: to.fullPath === '/' ? {} : { redirect: to.fullPath } })
+  } else if (mustChangePassword) {
+    to.path === '/login' ? next() : next('/login')
   } else if (to.meta.guest && authenticated) {
     next('/')
   } else if (to.meta.requiresAdmin && !isAdmin()) {
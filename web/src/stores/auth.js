@@ -11,26 +11,54 @@ export const useAuth = () => {
 
   const isAdmin = () => state.user?.role === 'admin'
 
+  // Returns the user, or the MFA challenge when a second factor is needed
   const login = async (username, password) => {
     const response = await authApi.login(username, password)
-    const { token, user } = response.data
+    return completeLogin(response.data)
+  }
+
+  // Completes a single sign-on login with the code from the callback redirect
+  const loginWithSSOCode = async (code) => {
+    const response = await authApi.exchangeSSOCode(code)
+    return completeLogin(response.data)
+  }
 
+  const completeLogin = (data) => {
+    if (data.mfa_required) {
+      return { mfa: data }
+    }
+    return { user: startSession(data) }
+  }
+
+  const startSession = ({ token, refresh_token, user }) => {
     state.token = token
     state.user = user
 
     localStorage.setItem('token', token)
+    localStorage.setItem('refresh_token', refresh_token)
     localStorage.setItem('user', JSON.stringify(user))
 
     return user
   }
 
-  const logout = () => {
+  // Forgets the session locally once the server has been told to revoke it
+  const clearSession = () => {
     state.token = null
     state.user = null
     localStorage.removeItem('token')
+    localStorage.removeItem('refresh_token')
     localStorage.removeItem('user')
   }
 
+  const logout = async () => {
+    try {
+      await authApi.logout()
+    } catch (e) {
+      // Already expired or revoked
+    }
+    clearSession()
+  }
+
   const getUser = () => state.user
 
   const setUser = (user) => {
@@ -46,7 +74,10 @@ export const useAuth = () => {
     isAuthenticated,
     isAdmin,
     login,
+    loginWithSSOCode,
+    startSession,
     logout,
+    clearSession,
     getUser,
     setUser,
     getToken,